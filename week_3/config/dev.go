@@ -2,11 +2,70 @@
 
 package config
 
+import "time"
+
 var Config = config{
 	DB: DBConfig{
-		DSN: "root:root@tcp(localhost:13316)/webook",
+		Driver:               "mysql",
+		DSN:                  "root:root@tcp(localhost:13316)/webook",
+		Replicas:             nil,
+		MaxOpenConns:         100,
+		MaxIdleConns:         10,
+		ConnMaxLifetime:      time.Hour,
+		ReplicaCheckInterval: 5 * time.Second,
+		ReplicaCheckTimeout:  time.Second,
+		SlowThreshold:        200 * time.Millisecond,
 	},
 	Redis: RedisConfig{
 		Addr: "localhost:6379",
 	},
+	Password: PasswordConfig{
+		Algorithm:       "argon2id",
+		BcryptCost:      12,
+		BreachCorpusDir: "",
+		BreachMinCount:  1,
+		ResetURL:        "http://localhost:3000/reset-password?token=",
+		ResetExpiration: 30 * time.Minute,
+	},
+	Account: AccountConfig{
+		RetentionPeriod: time.Hour,
+		PurgeInterval:   time.Minute,
+	},
+	Storage: StorageConfig{
+		Dir:     "./data/static",
+		BaseURL: "http://localhost:8080/static",
+	},
+	Avatar: AvatarConfig{
+		MaxSize: 2 << 20,
+	},
+	AccessLog: AccessLogConfig{
+		Headers:     true,
+		ReqBody:     true,
+		RespBody:    true,
+		MaxBodySize: 2048,
+	},
+	Tracing: TracingConfig{
+		Exporter:    "stdout",
+		ServiceName: "webook",
+		SampleRatio: 1,
+	},
+	Ranking: RankingConfig{
+		Interval:             time.Minute,
+		LocalCacheExpiration: 10 * time.Second,
+		Window:               7 * 24 * time.Hour,
+	},
+	Outbox: OutboxConfig{
+		RelayInterval: time.Second,
+	},
+	Events: EventsConfig{
+		QueueCapacity:   10000,
+		ShutdownTimeout: 10 * time.Second,
+	},
+	Metrics: MetricsConfig{
+		Addr: ":9091",
+	},
+	Mail: MailConfig{
+		Addr: "",
+		From: "webook <noreply@webook.com>",
+	},
 }
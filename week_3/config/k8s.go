@@ -2,11 +2,72 @@
 
 package config
 
+import "time"
+
 var Config = config{
 	DB: DBConfig{
-		DSN: "root:root@tcp(webook-record-mysql:3308)/webook",
+		Driver:               "mysql",
+		DSN:                  "root:root@tcp(webook-record-mysql:3308)/webook",
+		Replicas:             nil,
+		MaxOpenConns:         100,
+		MaxIdleConns:         10,
+		ConnMaxLifetime:      time.Hour,
+		ReplicaCheckInterval: 5 * time.Second,
+		ReplicaCheckTimeout:  time.Second,
+		SlowThreshold:        200 * time.Millisecond,
 	},
 	Redis: RedisConfig{
 		Addr: "webook-record-redis:6380",
 	},
+	Password: PasswordConfig{
+		Algorithm:  "argon2id",
+		BcryptCost: 12,
+		// 泄露库需要挂载到容器里面之后再配置目录，目前部署文件里面没有挂载
+		BreachCorpusDir: "",
+		BreachMinCount:  1,
+		ResetURL:        "http://localhost/reset-password?token=",
+		ResetExpiration: 30 * time.Minute,
+	},
+	Account: AccountConfig{
+		RetentionPeriod: 30 * 24 * time.Hour,
+		PurgeInterval:   time.Hour,
+	},
+	Storage: StorageConfig{
+		// 挂载的是所有副本共享的卷，见 webook-deployment.yaml
+		Dir:     "/app/data/static",
+		BaseURL: "http://localhost/static",
+	},
+	Avatar: AvatarConfig{
+		MaxSize: 2 << 20,
+	},
+	AccessLog: AccessLogConfig{
+		Headers:     false,
+		ReqBody:     false,
+		RespBody:    false,
+		MaxBodySize: 2048,
+	},
+	Tracing: TracingConfig{
+		Exporter:    "none",
+		ServiceName: "webook",
+		SampleRatio: 1,
+	},
+	Ranking: RankingConfig{
+		Interval:             3 * time.Minute,
+		LocalCacheExpiration: 10 * time.Second,
+		Window:               7 * 24 * time.Hour,
+	},
+	Outbox: OutboxConfig{
+		RelayInterval: time.Second,
+	},
+	Events: EventsConfig{
+		QueueCapacity:   10000,
+		ShutdownTimeout: 10 * time.Second,
+	},
+	Metrics: MetricsConfig{
+		Addr: ":9091",
+	},
+	Mail: MailConfig{
+		Addr: "",
+		From: "webook <noreply@webook.com>",
+	},
 }
@@ -1,14 +1,130 @@
 package config
 
+import "time"
+
 type config struct {
-	DB    DBConfig
-	Redis RedisConfig
+	DB        DBConfig
+	Redis     RedisConfig
+	Password  PasswordConfig
+	Account   AccountConfig
+	Storage   StorageConfig
+	Avatar    AvatarConfig
+	AccessLog AccessLogConfig
+	Tracing   TracingConfig
+	Ranking   RankingConfig
+	Outbox    OutboxConfig
+	Events    EventsConfig
+	Metrics   MetricsConfig
+	Mail      MailConfig
 }
 
 type DBConfig struct {
+	// 数据库驱动，可选 mysql、sqlite、postgres，为空的时候使用 mysql
+	Driver string
+	// 主库的 DSN，所有写请求都走主库
 	DSN string
+	// 从库的 DSN，为空的时候读请求也走主库
+	Replicas []string
+	// 连接池配置，主库和每一个从库各自使用一个连接池
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	// 从库探活的间隔和超时时间
+	ReplicaCheckInterval time.Duration
+	ReplicaCheckTimeout  time.Duration
+	// 执行时间超过这个值的 SQL 记为慢查询，0 表示不记录
+	SlowThreshold time.Duration
 }
 
 type RedisConfig struct {
 	Addr string
 }
+
+type PasswordConfig struct {
+	// 新密码使用的哈希算法，可选 argon2id 或者 bcrypt
+	Algorithm string
+	// bcrypt 的 cost，调高之后老的哈希会在用户下次登录的时候重新哈希
+	BcryptCost int
+	// 本地泄露密码库的目录，为空表示不检查泄露库
+	BreachCorpusDir string
+	// 在泄露库中出现次数达到该值才拒绝
+	BreachMinCount int
+	// 找回密码邮件中的链接，token 拼接在后面，例如 https://webook.com/reset-password?token=
+	ResetURL string
+	// 找回密码链接的有效期
+	ResetExpiration time.Duration
+}
+
+type AccountConfig struct {
+	// 注销之后数据保留的时长，超过之后物理删除
+	RetentionPeriod time.Duration
+	// 清理任务的执行间隔
+	PurgeInterval time.Duration
+}
+
+type StorageConfig struct {
+	// 本地对象存储的根目录
+	Dir string
+	// 对外访问的 URL 前缀，例如 http://localhost:8080/static
+	BaseURL string
+}
+
+type AvatarConfig struct {
+	// 头像文件的最大字节数
+	MaxSize int64
+}
+
+type AccessLogConfig struct {
+	// 是否记录请求和响应的头部，token 之类的头部会被脱敏
+	Headers bool
+	// 是否记录请求体和响应体，密码之类的字段会被脱敏
+	ReqBody  bool
+	RespBody bool
+	// 请求体和响应体最多记录的字节数
+	MaxBodySize int
+}
+
+type TracingConfig struct {
+	// span 的导出方式：stdout 输出到标准输出，为空或者 none 表示不开启链路追踪
+	Exporter string
+	// 上报的服务名
+	ServiceName string
+	// 采样比例，0 到 1 之间。上游已经采样的请求总是会被采样
+	SampleRatio float64
+}
+
+type RankingConfig struct {
+	// 重新计算热榜的间隔
+	Interval time.Duration
+	// 进程内缓存热榜的时长
+	LocalCacheExpiration time.Duration
+	// 只有这段时间内发表的文章参与排名
+	Window time.Duration
+}
+
+type OutboxConfig struct {
+	// 投递发件箱中事件的间隔，也就是事件从写入到投递出去的最大延迟
+	RelayInterval time.Duration
+}
+
+type EventsConfig struct {
+	// 进程内消息队列中每一个消费者组最多缓存的消息数量，满了之后发送消息会失败
+	QueueCapacity int
+	// 退出的时候等待正在处理的请求和消息的最长时间
+	ShutdownTimeout time.Duration
+}
+
+type MetricsConfig struct {
+	// Prometheus 拉取指标的监听地址，和对外提供服务的 8080 端口分开，只在集群内部开放
+	Addr string
+}
+
+type MailConfig struct {
+	// SMTP 服务器地址，例如 smtp.qq.com:587。为空的时候不能发送邮件，也不开放找回密码的接口
+	Addr string
+	// 发件人
+	From string
+	// SMTP 认证的用户名和密码，用户名为空的时候不认证
+	Username string
+	Password string
+}
@@ -0,0 +1,36 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"go_homework/week_3/internal/domain"
+	"go_homework/week_3/internal/service"
+	"go_homework/week_3/pkg/events"
+	"go_homework/week_3/pkg/logger"
+)
+
+// AvatarConsumer 账号的数据从数据库中删除之后，删除对象存储里面的头像文件
+type AvatarConsumer struct {
+	svc *service.AvatarService
+}
+
+func NewAvatarConsumer(svc *service.AvatarService) *AvatarConsumer {
+	return &AvatarConsumer{svc: svc}
+}
+
+// Register 在 p 上注册账号删除事件的处理函数
+func (c *AvatarConsumer) Register(p *events.Processor) {
+	p.Handle(domain.TopicUserPurged, c.handle)
+}
+
+func (c *AvatarConsumer) handle(ctx context.Context, msg events.Message) error {
+	var evt domain.UserPurged
+	if err := json.Unmarshal(msg.Value, &evt); err != nil {
+		// 格式不对的消息重试也不会成功，直接跳过
+		logger.FromContext(ctx).Error("invalid user purged event",
+			logger.String("key", msg.Key), logger.Error(err))
+		return nil
+	}
+	// 删除失败的时候重试，文件已经不存在的时候 Delete 不返回错误，重复删除没有影响
+	return c.svc.DeleteFiles(ctx, evt.Avatar)
+}
@@ -0,0 +1,32 @@
+package consumer
+
+import (
+	"context"
+	"go_homework/week_3/pkg/events"
+	"go_homework/week_3/pkg/logger"
+)
+
+// DeadLetterConsumer 消费死信 topic，把重试之后仍然处理失败的消息记在错误日志里面。
+// 消息体可能包含邮箱之类的个人信息，日志中只有 topic、key 和大小，排查的时候根据 key 找到业务数据。
+// 接入 Kafka 之后死信保留在 Kafka 中，可以换成重放工具
+type DeadLetterConsumer struct {
+	// 原始的 topic，订阅的是它们对应的死信 topic
+	topics []string
+}
+
+func NewDeadLetterConsumer(topics ...string) *DeadLetterConsumer {
+	return &DeadLetterConsumer{topics: topics}
+}
+
+// Register 在 p 上注册所有死信 topic 的处理函数
+func (c *DeadLetterConsumer) Register(p *events.Processor) {
+	for _, topic := range c.topics {
+		p.Handle(events.DeadLetterTopic(topic), c.handle)
+	}
+}
+
+// handle 只记录日志，不会失败，所以死信不会再产生死信
+func (c *DeadLetterConsumer) handle(ctx context.Context, msg events.Message) error {
+	logger.FromContext(ctx).Error("dead letter", messageFields(msg)...)
+	return nil
+}
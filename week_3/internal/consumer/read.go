@@ -0,0 +1,48 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"go_homework/week_3/internal/domain"
+	"go_homework/week_3/internal/service"
+	"go_homework/week_3/pkg/events"
+	"go_homework/week_3/pkg/logger"
+)
+
+// ReadConsumer 消费阅读事件修改阅读数。一批事件先按照业务对象合并，
+// 热门文章的大量阅读只会产生一次数据库写入
+type ReadConsumer struct {
+	svc *service.InteractionService
+}
+
+func NewReadConsumer(svc *service.InteractionService) *ReadConsumer {
+	return &ReadConsumer{svc: svc}
+}
+
+// Register 在 p 上注册阅读事件的处理函数
+func (c *ReadConsumer) Register(p *events.Processor) {
+	p.HandleBatch(domain.TopicBizRead, c.handle)
+}
+
+func (c *ReadConsumer) handle(ctx context.Context, msgs []events.Message) error {
+	// biz 到 bizId 到增量的映射
+	cnts := make(map[string]map[int64]int64)
+	for _, msg := range msgs {
+		var evt domain.BizRead
+		if err := json.Unmarshal(msg.Value, &evt); err != nil {
+			// 格式不对的消息重试也不会成功，跳过，不影响同一批的其他消息
+			logger.FromContext(ctx).Error("invalid read event",
+				logger.String("value", string(msg.Value)), logger.Error(err))
+			continue
+		}
+		if cnts[evt.Biz] == nil {
+			cnts[evt.Biz] = make(map[int64]int64)
+		}
+		cnts[evt.Biz][evt.BizId]++
+	}
+	if len(cnts) == 0 {
+		return nil
+	}
+	// 所有业务对象在同一个事务里面修改，失败的时候整批重试不会重复计数
+	return c.svc.BatchIncrReadCnt(ctx, cnts)
+}
@@ -0,0 +1,37 @@
+package consumer
+
+import (
+	"context"
+	"go_homework/week_3/internal/domain"
+	"go_homework/week_3/pkg/events"
+	"go_homework/week_3/pkg/logger"
+)
+
+// UserEventConsumer 消费发件箱投递的用户事件。目前只记录日志，用来确认事件确实投递出来了，
+// 欢迎邮件、搜索索引之类的下游接入之后在这里注册各自的处理函数
+type UserEventConsumer struct{}
+
+func NewUserEventConsumer() *UserEventConsumer {
+	return &UserEventConsumer{}
+}
+
+// Register 在 p 上注册用户事件的处理函数
+func (c *UserEventConsumer) Register(p *events.Processor) {
+	p.Handle(domain.TopicUserRegistered, c.handle)
+	p.Handle(domain.TopicUserProfileUpdated, c.handle)
+	p.Handle(domain.TopicUserDeleted, c.handle)
+}
+
+func (c *UserEventConsumer) handle(ctx context.Context, msg events.Message) error {
+	logger.FromContext(ctx).Info("user event", messageFields(msg)...)
+	return nil
+}
+
+// messageFields 日志中只记录消息的 topic、key 和大小，消息体可能包含邮箱之类的个人信息
+func messageFields(msg events.Message) []logger.Field {
+	return []logger.Field{
+		logger.String("topic", msg.Topic),
+		logger.String("key", msg.Key),
+		logger.Int64("size", int64(len(msg.Value))),
+	}
+}
@@ -0,0 +1,48 @@
+package consumer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go_homework/week_3/internal/domain"
+	"go_homework/week_3/pkg/events"
+	"go_homework/week_3/pkg/logger"
+	"log/slog"
+	"testing"
+)
+
+func TestConsumers_NoPayloadInLogs(t *testing.T) {
+	const email = "secret@qq.com"
+	value, err := json.Marshal(domain.UserRegistered{Uid: 1, Email: email})
+	require.NoError(t, err)
+	msg := events.Message{Topic: domain.TopicUserRegistered, Key: "1", Value: value}
+
+	testCases := []struct {
+		name   string
+		handle func(ctx context.Context, msg events.Message) error
+	}{
+		{
+			name:   "user event",
+			handle: NewUserEventConsumer().handle,
+		},
+		{
+			name:   "dead letter",
+			handle: NewDeadLetterConsumer(domain.TopicUserRegistered).handle,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			l := logger.NewSlogLogger(slog.New(slog.NewTextHandler(&buf, nil)))
+			ctx := logger.WithContext(context.Background(), l)
+			require.NoError(t, tc.handle(ctx, msg))
+			out := buf.String()
+			assert.NotContains(t, out, email)
+			assert.Contains(t, out, "topic="+domain.TopicUserRegistered)
+			assert.Contains(t, out, "key=1")
+			assert.Contains(t, out, "size=")
+		})
+	}
+}
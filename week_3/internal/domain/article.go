@@ -0,0 +1,34 @@
+package domain
+
+import "time"
+
+// Article 文章。作者看到的是制作库中的版本，读者看到的是线上库中最近一次发表的版本
+type Article struct {
+	Id      int64
+	Title   string
+	Content string
+	Author  Author
+	Status  ArticleStatus
+	Ctime   time.Time
+	Utime   time.Time
+}
+
+// Author 文章的作者
+type Author struct {
+	Id   int64
+	Name string
+}
+
+// ArticleStatus 文章的状态
+type ArticleStatus uint8
+
+const (
+	// ArticleStatusUnknown 零值，避免忘记设置状态的时候被当成某一个合法的状态
+	ArticleStatusUnknown ArticleStatus = iota
+	// ArticleStatusDraft 草稿，还没有发表过，或者发表之后又修改了还没有重新发表
+	ArticleStatusDraft
+	// ArticleStatusPublished 已发表，读者可以看到
+	ArticleStatusPublished
+	// ArticleStatusPrivate 已撤回，只有作者自己可以看到
+	ArticleStatusPrivate
+)
@@ -0,0 +1,19 @@
+package domain
+
+import "time"
+
+// Comment 评论。RootId 为 0 的是直接评论业务对象的顶层评论，
+// 其余的都是回复：RootId 是所在的顶层评论，ParentId 是直接回复的评论
+type Comment struct {
+	Id    int64
+	Biz   string
+	BizId int64
+	// 评论的人，只有 Id、Nickname 和 Avatar
+	Commentator User
+	Content     string
+	RootId      int64
+	ParentId    int64
+	// 顶层评论的回复数，回复的这个字段为 0
+	ReplyCnt int64
+	Ctime    time.Time
+}
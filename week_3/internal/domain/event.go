@@ -0,0 +1,105 @@
+package domain
+
+import (
+	"strconv"
+	"time"
+)
+
+// 领域事件的 topic
+const (
+	TopicUserRegistered     = "user_registered"
+	TopicUserProfileUpdated = "user_profile_updated"
+	TopicUserDeleted        = "user_deleted"
+	TopicUserPurged         = "user_purged"
+	TopicBizRead            = "biz_read"
+)
+
+// Event 领域事件。不能丢失的事件（例如用户注册）和业务数据在同一个事务里面写入发件箱，再由发件箱投递出去，
+// 阅读之类允许少量丢失的事件直接发送。同一个 Key 的事件按照发送的顺序投递
+type Event interface {
+	Topic() string
+	Key() string
+}
+
+// UserRegistered 用户注册成功
+type UserRegistered struct {
+	Uid   int64     `json:"uid"`
+	Email string    `json:"email"`
+	Ctime time.Time `json:"ctime"`
+}
+
+func (e UserRegistered) Topic() string {
+	return TopicUserRegistered
+}
+
+func (e UserRegistered) Key() string {
+	return strconv.FormatInt(e.Uid, 10)
+}
+
+// UserProfileUpdated 用户修改了资料，只带有修改过的字段，需要最新资料的消费方自己回查
+type UserProfileUpdated struct {
+	Uid    int64       `json:"uid"`
+	Fields []UserField `json:"fields"`
+}
+
+func (e UserProfileUpdated) Topic() string {
+	return TopicUserProfileUpdated
+}
+
+func (e UserProfileUpdated) Key() string {
+	return strconv.FormatInt(e.Uid, 10)
+}
+
+// UserDeleted 用户注销了账号，下游需要清理或者匿名化自己保存的这个用户的数据
+type UserDeleted struct {
+	Uid   int64     `json:"uid"`
+	Dtime time.Time `json:"dtime"`
+}
+
+func (e UserDeleted) Topic() string {
+	return TopicUserDeleted
+}
+
+func (e UserDeleted) Key() string {
+	return strconv.FormatInt(e.Uid, 10)
+}
+
+// UserPurged 注销的账号过了保留期，数据库里面这个用户的数据已经全部删除，
+// 下游清理数据库之外的数据，例如头像文件
+type UserPurged struct {
+	Uid    int64  `json:"uid"`
+	Avatar Avatar `json:"avatar"`
+}
+
+func (e UserPurged) Topic() string {
+	return TopicUserPurged
+}
+
+func (e UserPurged) Key() string {
+	return strconv.FormatInt(e.Uid, 10)
+}
+
+// BizRead 用户查看了业务对象的详情，例如阅读了一篇文章
+type BizRead struct {
+	Biz   string `json:"biz"`
+	BizId int64  `json:"bizId"`
+	Uid   int64  `json:"uid"`
+}
+
+func (e BizRead) Topic() string {
+	return TopicBizRead
+}
+
+// Key 同一个业务对象的阅读事件发送到同一个分区
+func (e BizRead) Key() string {
+	return e.Biz + ":" + strconv.FormatInt(e.BizId, 10)
+}
+
+// OutboxMessage 发件箱中等待投递的事件
+type OutboxMessage struct {
+	Id      int64
+	Topic   string
+	Key     string
+	Payload []byte
+	Ctime   time.Time
+}
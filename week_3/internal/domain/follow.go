@@ -0,0 +1,20 @@
+package domain
+
+import "time"
+
+// FollowRelation 关注关系，Follower 关注了 Followee
+type FollowRelation struct {
+	Id       int64
+	Follower int64
+	Followee int64
+	Ctime    time.Time
+}
+
+// FollowStatistic 用户的关注数和粉丝数
+type FollowStatistic struct {
+	Uid       int64
+	Followers int64
+	Followees int64
+	// 查看的人是否关注了这个用户
+	Followed bool
+}
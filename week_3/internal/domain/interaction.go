@@ -0,0 +1,43 @@
+package domain
+
+import "time"
+
+// BizArticle 文章在互动、评论之类通用模块中的业务名
+const BizArticle = "article"
+
+// Interaction 一个业务对象的互动数据，业务对象用 (Biz, BizId) 表示，例如 ("article", 1)
+type Interaction struct {
+	Biz        string
+	BizId      int64
+	ReadCnt    int64
+	LikeCnt    int64
+	CollectCnt int64
+	// 当前用户是否点过赞、是否收藏过
+	Liked     bool
+	Collected bool
+}
+
+// Like 用户的一条点赞记录
+type Like struct {
+	Id    int64
+	Biz   string
+	BizId int64
+	Ctime time.Time
+}
+
+// CollectionItem 收藏夹 Cid 里面的一个业务对象
+type CollectionItem struct {
+	Id    int64
+	Cid   int64
+	Biz   string
+	BizId int64
+	Ctime time.Time
+}
+
+// Collection 用户的收藏夹
+type Collection struct {
+	Id    int64
+	Uid   int64
+	Name  string
+	Ctime time.Time
+}
@@ -7,8 +7,58 @@ type User struct {
 	Email    string
 	Password string
 	Nickname string
+	// 零值表示没有设置生日
 	Birthday time.Time
 	AboutMe  string
+	Avatar   Avatar
+	// 个人主页的可见范围
+	Privacy Privacy
 	// UTC 0的时区
 	Ctime time.Time
+	Utime time.Time
+	// 资料的版本号，修改资料的时候需要带上读到的版本号
+	Version int64
+}
+
+// Avatar 用户头像，没有上传过的时候都是空字符串
+type Avatar struct {
+	// 原图的 URL
+	URL string
+	// 缩略图的 URL
+	ThumbnailURL string
+}
+
+// Privacy 个人主页的可见范围，零值是所有人可见
+type Privacy uint8
+
+const (
+	// PrivacyPublic 所有人都可以查看公开资料，也可以被搜索到
+	PrivacyPublic Privacy = iota
+	// PrivacyPrivate 只有自己可以查看
+	PrivacyPrivate
+)
+
+// UserField 用户资料中可以单独修改的字段，用作部分更新的字段掩码
+type UserField string
+
+const (
+	UserFieldNickname UserField = "nickname"
+	UserFieldBirthday UserField = "birthday"
+	UserFieldAboutMe  UserField = "about_me"
+	UserFieldPrivacy  UserField = "privacy"
+)
+
+// UserArchive 用户保存在系统中的全部数据，用于导出
+type UserArchive struct {
+	// 账号信息，不包含密码哈希
+	Account User
+	// 制作库中的文章，包含草稿和撤回的文章
+	Articles []Article
+	Comments []Comment
+	// 关注的人
+	Followees   []FollowRelation
+	Likes       []Like
+	Collections []Collection
+	// 所有收藏夹里面的业务对象，通过 Cid 对应到收藏夹
+	CollectionItems []CollectionItem
 }
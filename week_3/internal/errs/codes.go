@@ -0,0 +1,45 @@
+package errs
+
+// 业务错误码，前三位 401 表示用户模块的客户端错误，402 表示文章模块的客户端错误，
+// 403 表示互动模块的客户端错误，404 表示评论模块的客户端错误，500 表示系统错误
+const (
+	CodeSuccess = 0
+	// CodeInvalidInput 请求参数不合法，例如邮箱格式错误、两次密码不一致
+	CodeInvalidInput = 401001
+	// CodeCommonPassword 密码过于常见
+	CodeCommonPassword = 401002
+	// CodePasswordPersonalInfo 密码包含了邮箱或者昵称
+	CodePasswordPersonalInfo = 401003
+	// CodeBreachedPassword 密码出现在泄露库中
+	CodeBreachedPassword = 401004
+	// CodeDuplicateEmail 邮箱已经被注册
+	CodeDuplicateEmail = 401005
+	// CodeInvalidUserOrPassword 用户不存在或者密码错误
+	CodeInvalidUserOrPassword = 401006
+	// CodeUserNotFound 用户不存在
+	CodeUserNotFound = 401007
+	// CodeUnauthorized 没有登录或者登录已经失效
+	CodeUnauthorized = 401008
+	// CodeVersionConflict 数据已经被其它请求修改过了，需要基于最新的数据重新修改
+	CodeVersionConflict = 401009
+	// CodeAvatarTooLarge 头像文件太大
+	CodeAvatarTooLarge = 401010
+	// CodeAvatarUnsupportedType 头像不是支持的图片格式
+	CodeAvatarUnsupportedType = 401011
+	// CodeInvalidCursor 分页游标无效
+	CodeInvalidCursor = 401012
+	// CodeCannotFollowSelf 不能关注自己
+	CodeCannotFollowSelf = 401013
+	// CodeInvalidResetToken 找回密码的链接无效或者已经过期
+	CodeInvalidResetToken = 401014
+	// CodeArticleNotFound 文章不存在，或者不是当前用户的文章
+	CodeArticleNotFound = 402001
+	// CodeCollectionNotFound 收藏夹不存在，或者不是当前用户的收藏夹
+	CodeCollectionNotFound = 403001
+	// CodeDuplicateCollection 收藏夹重名
+	CodeDuplicateCollection = 403002
+	// CodeCommentNotFound 评论不存在，或者不是当前用户的评论
+	CodeCommentNotFound = 404001
+	// CodeSystemError 系统错误
+	CodeSystemError = 500001
+)
@@ -0,0 +1,120 @@
+// Package errs 定义了 dao、repository、service 共用的错误类型。
+// 每个错误都带有一个 Kind，web 层只根据 Kind 决定 HTTP 状态码，根据 Code 返回业务错误码，
+// 不再需要在每个 handler 里面逐个比较哨兵错误。
+package errs
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kind 错误的分类
+type Kind uint8
+
+const (
+	// KindInternal 系统内部错误，没有分类的错误都属于这一类
+	KindInternal Kind = iota
+	// KindNotFound 数据不存在
+	KindNotFound
+	// KindConflict 数据冲突，例如唯一索引冲突、版本冲突
+	KindConflict
+	// KindUnauthorized 身份认证失败
+	KindUnauthorized
+	// KindValidation 输入不合法
+	KindValidation
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindNotFound:
+		return "not_found"
+	case KindConflict:
+		return "conflict"
+	case KindUnauthorized:
+		return "unauthorized"
+	case KindValidation:
+		return "validation"
+	default:
+		return "internal"
+	}
+}
+
+// Error 带有分类和业务错误码的错误
+type Error struct {
+	Kind Kind
+	// 业务错误码，见 codes.go
+	Code int
+	// 可以直接展示给用户的提示
+	Msg string
+	// 底层的原因，可以为 nil
+	Err error
+}
+
+func New(kind Kind, code int, msg string) *Error {
+	return &Error{Kind: kind, Code: code, Msg: msg}
+}
+
+func NotFound(code int, msg string) *Error {
+	return New(KindNotFound, code, msg)
+}
+
+func Conflict(code int, msg string) *Error {
+	return New(KindConflict, code, msg)
+}
+
+func Unauthorized(code int, msg string) *Error {
+	return New(KindUnauthorized, code, msg)
+}
+
+func Validation(code int, msg string) *Error {
+	return New(KindValidation, code, msg)
+}
+
+func Internal(err error) *Error {
+	return &Error{Kind: KindInternal, Code: CodeSystemError, Msg: "System error", Err: err}
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("%s(%d): %s", e.Kind, e.Code, e.Msg)
+	}
+	return fmt.Sprintf("%s(%d): %s: %v", e.Kind, e.Code, e.Msg, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is 分类和错误码都相同就认为是同一个错误，这样哨兵错误 Wrap 之后依旧可以用 errors.Is 判断
+func (e *Error) Is(target error) bool {
+	var t *Error
+	if !errors.As(target, &t) {
+		return false
+	}
+	return e.Kind == t.Kind && e.Code == t.Code
+}
+
+// Wrap 返回一个带有底层原因的副本，哨兵错误本身不会被修改
+func (e *Error) Wrap(err error) *Error {
+	cp := *e
+	cp.Err = err
+	return &cp
+}
+
+// KindOf 返回错误链上第一个 *Error 的分类，没有的话就是 KindInternal
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return KindInternal
+}
+
+// As 返回错误链上第一个 *Error，没有的话把 err 包装成内部错误
+func As(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return Internal(err)
+}
@@ -0,0 +1,106 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestError_Is(t *testing.T) {
+	sentinel := NotFound(CodeUserNotFound, "User not found")
+	cause := errors.New("record not found")
+	testCases := []struct {
+		name   string
+		err    error
+		target error
+		want   bool
+	}{
+		{
+			name:   "same sentinel",
+			err:    sentinel,
+			target: sentinel,
+			want:   true,
+		},
+		{
+			name:   "wrapped sentinel",
+			err:    sentinel.Wrap(cause),
+			target: sentinel,
+			want:   true,
+		},
+		{
+			name:   "wrapped cause",
+			err:    sentinel.Wrap(cause),
+			target: cause,
+			want:   true,
+		},
+		{
+			name:   "wrapped by fmt",
+			err:    fmt.Errorf("find user: %w", sentinel.Wrap(cause)),
+			target: sentinel,
+			want:   true,
+		},
+		{
+			name:   "same code different kind",
+			err:    Conflict(CodeUserNotFound, "User not found"),
+			target: sentinel,
+		},
+		{
+			name:   "same kind different code",
+			err:    NotFound(CodeArticleNotFound, "Article not found"),
+			target: sentinel,
+		},
+		{
+			name:   "plain error",
+			err:    cause,
+			target: sentinel,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, errors.Is(tc.err, tc.target))
+		})
+	}
+}
+
+func TestError_Wrap(t *testing.T) {
+	sentinel := Conflict(CodeDuplicateEmail, "Email is already exist")
+	cause := errors.New("UNIQUE constraint failed")
+	err := sentinel.Wrap(cause)
+	assert.Equal(t, KindConflict, err.Kind)
+	assert.Equal(t, CodeDuplicateEmail, err.Code)
+	assert.Equal(t, sentinel.Msg, err.Msg)
+	assert.Equal(t, cause, errors.Unwrap(err))
+	// 哨兵错误本身不会被修改
+	assert.Nil(t, sentinel.Err)
+}
+
+func TestKindOf(t *testing.T) {
+	testCases := []struct {
+		name string
+		err  error
+		want Kind
+	}{
+		{
+			name: "typed",
+			err:  Validation(CodeInvalidInput, "Invalid input"),
+			want: KindValidation,
+		},
+		{
+			name: "wrapped typed",
+			err:  fmt.Errorf("signup: %w", Unauthorized(CodeUnauthorized, "Unauthorized").Wrap(errors.New("expired"))),
+			want: KindUnauthorized,
+		},
+		{
+			name: "plain",
+			err:  errors.New("connection refused"),
+			want: KindInternal,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, KindOf(tc.err))
+			assert.Equal(t, tc.want, As(tc.err).Kind)
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"go_homework/week_3/internal/service"
+	"go_homework/week_3/pkg/logger"
+	"go_homework/week_3/pkg/rlock"
+	"time"
+)
+
+const (
+	outboxRelayLockKey = "job:outbox_relay"
+	// 只有一个实例投递，保证事件按照写入的顺序投递
+	outboxRelayLockExpiration = 10 * time.Second
+	// 一次投递的超时时间，没有投递完的事件留给下一次
+	outboxRelayTimeout = 30 * time.Second
+)
+
+// OutboxRelayJob 定期把发件箱中的事件投递出去。部署了多个实例的时候，同一时间只有拿到分布式锁的实例在投递
+type OutboxRelayJob struct {
+	svc      *service.OutboxRelayService
+	lock     *rlock.Client
+	interval time.Duration
+	l        logger.Logger
+}
+
+func NewOutboxRelayJob(svc *service.OutboxRelayService, lock *rlock.Client, interval time.Duration,
+	l logger.Logger) *OutboxRelayJob {
+	return &OutboxRelayJob{svc: svc, lock: lock, interval: interval,
+		l: l.With(logger.String("job", "outbox_relay"))}
+}
+
+// Start 阻塞执行任务，直到 ctx 被取消
+func (j *OutboxRelayJob) Start(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		j.run(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (j *OutboxRelayJob) run(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, outboxRelayTimeout)
+	defer cancel()
+	ctx = logger.WithContext(ctx, j.l)
+	var n int
+	err := j.lock.Do(ctx, outboxRelayLockKey, outboxRelayLockExpiration, nil, func(ctx context.Context) error {
+		var err error
+		n, err = j.svc.Relay(ctx)
+		return err
+	})
+	switch {
+	case errors.Is(err, rlock.ErrFailedToPreemptLock):
+		j.l.Debug("outbox is being relayed by another instance")
+	case err != nil:
+		j.l.Error("relay outbox failed", logger.Int("relayed", n), logger.Error(err))
+	case n > 0:
+		j.l.Info("outbox relayed", logger.Int("relayed", n))
+	}
+}
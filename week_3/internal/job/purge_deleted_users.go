@@ -0,0 +1,70 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"go_homework/week_3/internal/service"
+	"go_homework/week_3/pkg/logger"
+	"go_homework/week_3/pkg/rlock"
+	"time"
+)
+
+const (
+	purgeDeletedUsersLockKey = "job:purge_deleted_users"
+	// 清理期间会不断续约，过期时间只需要覆盖实例崩溃之后多久可以被别的实例接手
+	purgeDeletedUsersLockExpiration = 30 * time.Second
+)
+
+// PurgeDeletedUsersJob 定期物理删除超过保留期的注销账号。部署了多个实例的时候，同一时间只有拿到分布式锁的实例在清理
+type PurgeDeletedUsersJob struct {
+	svc  *service.UserService
+	lock *rlock.Client
+	// 执行间隔
+	interval time.Duration
+	// 注销之后数据保留的时长
+	retention time.Duration
+	l         logger.Logger
+}
+
+func NewPurgeDeletedUsersJob(svc *service.UserService, lock *rlock.Client, interval, retention time.Duration,
+	l logger.Logger) *PurgeDeletedUsersJob {
+	return &PurgeDeletedUsersJob{svc: svc, lock: lock, interval: interval, retention: retention,
+		l: l.With(logger.String("job", "purge_deleted_users"))}
+}
+
+// Start 阻塞执行任务，直到 ctx 被取消
+func (j *PurgeDeletedUsersJob) Start(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		j.run(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (j *PurgeDeletedUsersJob) run(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, j.interval)
+	defer cancel()
+	// 下面各层打的日志都会带上任务的名字
+	ctx = logger.WithContext(ctx, j.l)
+	var n int64
+	// 别的实例正在清理的时候不需要等待，这一轮直接跳过
+	err := j.lock.Do(ctx, purgeDeletedUsersLockKey, purgeDeletedUsersLockExpiration, nil,
+		func(ctx context.Context) error {
+			var err error
+			n, err = j.svc.PurgeDeletedAccounts(ctx, j.retention)
+			return err
+		})
+	switch {
+	case errors.Is(err, rlock.ErrFailedToPreemptLock):
+		j.l.Debug("deleted users are being purged by another instance")
+	case err != nil:
+		j.l.Error("purge deleted users failed", logger.Int64("purged", n), logger.Error(err))
+	case n > 0:
+		j.l.Info("purged deleted users", logger.Int64("purged", n))
+	}
+}
@@ -0,0 +1,62 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"go_homework/week_3/internal/service"
+	"go_homework/week_3/pkg/logger"
+	"go_homework/week_3/pkg/rlock"
+	"time"
+)
+
+const (
+	rankingLockKey = "job:ranking"
+	// 计算期间会不断续约，过期时间只需要覆盖实例崩溃之后多久可以被别的实例接手
+	rankingLockExpiration = 30 * time.Second
+)
+
+// RankingJob 定期计算热榜。部署了多个实例的时候，同一时间只有拿到分布式锁的实例在计算
+type RankingJob struct {
+	svc  *service.RankingService
+	lock *rlock.Client
+	// 执行间隔，同时也是一次计算的超时时间
+	interval time.Duration
+	l        logger.Logger
+}
+
+func NewRankingJob(svc *service.RankingService, lock *rlock.Client, interval time.Duration,
+	l logger.Logger) *RankingJob {
+	return &RankingJob{svc: svc, lock: lock, interval: interval,
+		l: l.With(logger.String("job", "ranking"))}
+}
+
+// Start 阻塞执行任务，直到 ctx 被取消
+func (j *RankingJob) Start(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		j.run(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (j *RankingJob) run(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, j.interval)
+	defer cancel()
+	ctx = logger.WithContext(ctx, j.l)
+	start := time.Now()
+	// 别的实例正在计算的时候不需要等待，这一轮直接跳过
+	err := j.lock.Do(ctx, rankingLockKey, rankingLockExpiration, nil, j.svc.TopN)
+	switch {
+	case errors.Is(err, rlock.ErrFailedToPreemptLock):
+		j.l.Debug("ranking is being computed by another instance")
+	case err != nil:
+		j.l.Error("compute ranking failed", logger.Error(err))
+	default:
+		j.l.Info("ranking computed", logger.Int64("elapsed_ms", time.Since(start).Milliseconds()))
+	}
+}
@@ -0,0 +1,229 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"go_homework/week_3/internal/domain"
+	"go_homework/week_3/internal/repository/cache"
+	"go_homework/week_3/internal/repository/dao"
+	"go_homework/week_3/pkg/logger"
+	"time"
+)
+
+var ErrArticleNotFound = dao.ErrArticleNotFound
+
+// firstPageCacheSize 作者文章列表第一页缓存的文章数量，
+// 覆盖了每页最多 50 篇再多查一篇判断有没有下一页的情况，更大的 limit 不走缓存
+const firstPageCacheSize = 51
+
+type ArticleRepository struct {
+	dao   *dao.ArticleDAO
+	cache *cache.ArticleCache
+}
+
+func NewArticleRepository(dao *dao.ArticleDAO, cache *cache.ArticleCache) *ArticleRepository {
+	return &ArticleRepository{dao: dao, cache: cache}
+}
+
+// Create 在制作库中新建文章，返回文章的 id
+func (repo *ArticleRepository) Create(ctx context.Context, art domain.Article) (int64, error) {
+	ctx, span := tracer.Start(ctx, "ArticleRepository.Create")
+	defer span.End()
+	id, err := repo.dao.Insert(ctx, repo.toEntity(art))
+	if err == nil {
+		repo.invalidateFirstPage(ctx, art.Author.Id)
+	}
+	return id, err
+}
+
+// Update 更新制作库中的文章，只能更新作者自己的文章
+func (repo *ArticleRepository) Update(ctx context.Context, art domain.Article) error {
+	ctx, span := tracer.Start(ctx, "ArticleRepository.Update")
+	defer span.End()
+	err := repo.dao.UpdateById(ctx, repo.toEntity(art))
+	if err == nil {
+		repo.invalidateFirstPage(ctx, art.Author.Id)
+	}
+	return err
+}
+
+// Sync 保存制作库并同步到线上库，返回线上库中的文章。
+// 刚发表的文章很可能马上被大量读者访问，所以发表之后直接写入缓存
+func (repo *ArticleRepository) Sync(ctx context.Context, art domain.Article) (domain.Article, error) {
+	ctx, span := tracer.Start(ctx, "ArticleRepository.Sync")
+	defer span.End()
+	pub, err := repo.dao.Sync(ctx, repo.toEntity(art))
+	if err != nil {
+		return domain.Article{}, err
+	}
+	repo.invalidateFirstPage(ctx, art.Author.Id)
+	res := repo.toDomain(dao.Article(pub))
+	if err = repo.cache.SetPublished(ctx, res); err != nil {
+		// 缓存没有写进去只是少了预热，读的时候会回查数据库
+		logger.FromContext(ctx).Warn("cache published article failed",
+			logger.Int64("article_id", pub.Id), logger.Error(err))
+	}
+	return res, nil
+}
+
+// SyncStatus 同时修改制作库和线上库中文章的状态
+func (repo *ArticleRepository) SyncStatus(ctx context.Context, authorId, id int64, status domain.ArticleStatus) error {
+	ctx, span := tracer.Start(ctx, "ArticleRepository.SyncStatus")
+	defer span.End()
+	err := repo.dao.SyncStatus(ctx, authorId, id, uint8(status))
+	if err != nil {
+		return err
+	}
+	repo.invalidateFirstPage(ctx, authorId)
+	// 撤回之后读者不能再看到缓存里面的文章
+	if err = repo.cache.DelPublished(ctx, id); err != nil {
+		logger.FromContext(ctx).Error("delete published article cache failed",
+			logger.Int64("article_id", id), logger.Error(err))
+	}
+	return nil
+}
+
+// FindById 查询制作库中的文章
+func (repo *ArticleRepository) FindById(ctx context.Context, id int64) (domain.Article, error) {
+	ctx, span := tracer.Start(ctx, "ArticleRepository.FindById")
+	defer span.End()
+	art, err := repo.dao.FindById(ctx, id)
+	if err != nil {
+		return domain.Article{}, err
+	}
+	return repo.toDomain(art), nil
+}
+
+// ListByAuthor 按照更新时间倒序查询作者在制作库中的文章，文章的内容只有摘要。
+// afterId 为 0 的时候查询第一页，第一页会缓存起来，作者修改文章的时候删除
+func (repo *ArticleRepository) ListByAuthor(ctx context.Context, authorId int64, afterUtime time.Time,
+	afterId int64, limit int) ([]domain.Article, error) {
+	ctx, span := tracer.Start(ctx, "ArticleRepository.ListByAuthor")
+	defer span.End()
+	if afterId > 0 || limit > firstPageCacheSize {
+		return repo.listByAuthor(ctx, authorId, afterUtime.UnixMilli(), afterId, limit)
+	}
+	arts, err := repo.cache.GetFirstPage(ctx, authorId)
+	if err == nil {
+		return arts[:min(limit, len(arts))], nil
+	}
+	if !errors.Is(err, cache.ErrKeyNotExist) {
+		logger.FromContext(ctx).Warn("read article first page cache failed",
+			logger.Int64("author_id", authorId), logger.Error(err))
+	}
+	// 不管这一次要多少篇，都按照缓存的大小查询，这样缓存可以给不同的 limit 使用
+	arts, err = repo.listByAuthor(ctx, authorId, 0, 0, firstPageCacheSize)
+	if err != nil {
+		return nil, err
+	}
+	if err = repo.cache.SetFirstPage(ctx, authorId, arts); err != nil {
+		logger.FromContext(ctx).Warn("cache article first page failed",
+			logger.Int64("author_id", authorId), logger.Error(err))
+	}
+	return arts[:min(limit, len(arts))], nil
+}
+
+func (repo *ArticleRepository) listByAuthor(ctx context.Context, authorId, afterUtime, afterId int64,
+	limit int) ([]domain.Article, error) {
+	arts, err := repo.dao.ListByAuthor(ctx, authorId, afterUtime, afterId, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.Article, 0, len(arts))
+	for _, art := range arts {
+		res = append(res, repo.toDomain(art))
+	}
+	return res, nil
+}
+
+// ListFullByAuthor 按照 id 顺序查询作者在制作库中的文章，包含全文，不走缓存。afterId 是上一页最后一篇文章的 id
+func (repo *ArticleRepository) ListFullByAuthor(ctx context.Context, authorId, afterId int64,
+	limit int) ([]domain.Article, error) {
+	ctx, span := tracer.Start(ctx, "ArticleRepository.ListFullByAuthor")
+	defer span.End()
+	arts, err := repo.dao.ListFullByAuthor(ctx, authorId, afterId, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.Article, 0, len(arts))
+	for _, art := range arts {
+		res = append(res, repo.toDomain(art))
+	}
+	return res, nil
+}
+
+// FindPublishedById 查询线上库中的文章，先查缓存。撤回的文章也会返回，由调用方判断状态
+func (repo *ArticleRepository) FindPublishedById(ctx context.Context, id int64) (domain.Article, error) {
+	ctx, span := tracer.Start(ctx, "ArticleRepository.FindPublishedById")
+	defer span.End()
+	art, err := repo.cache.GetPublished(ctx, id)
+	if err == nil {
+		return art, nil
+	}
+	if !errors.Is(err, cache.ErrKeyNotExist) {
+		logger.FromContext(ctx).Warn("read published article cache failed",
+			logger.Int64("article_id", id), logger.Error(err))
+	}
+	pub, err := repo.dao.FindPublishedById(ctx, id)
+	if err != nil {
+		return domain.Article{}, err
+	}
+	art = repo.toDomain(dao.Article(pub))
+	// 只缓存读者可以看到的文章
+	if art.Status == domain.ArticleStatusPublished {
+		if err = repo.cache.SetPublished(ctx, art); err != nil {
+			logger.FromContext(ctx).Warn("cache published article failed",
+				logger.Int64("article_id", id), logger.Error(err))
+		}
+	}
+	return art, nil
+}
+
+// ListPublished 按照 (发表时间, id) 顺序遍历 since 之后发表的文章，从 after 之后开始返回最多 limit 条，
+// 第一页 after 传零值。文章的内容只有摘要，用于计算排行榜之类的批量任务
+func (repo *ArticleRepository) ListPublished(ctx context.Context, since time.Time, after domain.Article,
+	limit int) ([]domain.Article, error) {
+	ctx, span := tracer.Start(ctx, "ArticleRepository.ListPublished")
+	defer span.End()
+	arts, err := repo.dao.ListPublished(ctx, uint8(domain.ArticleStatusPublished), since.UnixMilli(),
+		after.Ctime.UnixMilli(), after.Id, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.Article, 0, len(arts))
+	for _, art := range arts {
+		res = append(res, repo.toDomain(dao.Article(art)))
+	}
+	return res, nil
+}
+
+// invalidateFirstPage 作者的文章有变化之后删除列表第一页的缓存。
+// 删除失败的时候作者会在缓存过期之前看到旧的列表，只记录日志
+func (repo *ArticleRepository) invalidateFirstPage(ctx context.Context, authorId int64) {
+	if err := repo.cache.DelFirstPage(ctx, authorId); err != nil {
+		logger.FromContext(ctx).Error("delete article first page cache failed",
+			logger.Int64("author_id", authorId), logger.Error(err))
+	}
+}
+
+func (repo *ArticleRepository) toEntity(art domain.Article) dao.Article {
+	return dao.Article{
+		Id:       art.Id,
+		Title:    art.Title,
+		Content:  art.Content,
+		AuthorId: art.Author.Id,
+		Status:   uint8(art.Status),
+	}
+}
+
+func (repo *ArticleRepository) toDomain(art dao.Article) domain.Article {
+	return domain.Article{
+		Id:      art.Id,
+		Title:   art.Title,
+		Content: art.Content,
+		Author:  domain.Author{Id: art.AuthorId},
+		Status:  domain.ArticleStatus(art.Status),
+		Ctime:   time.UnixMilli(art.Ctime),
+		Utime:   time.UnixMilli(art.Utime),
+	}
+}
@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"go_homework/week_3/internal/domain"
+	"time"
+)
+
+const (
+	// 作者文章列表第一页的过期时间，作者修改文章的时候会主动删除
+	firstPageExpiration = 10 * time.Minute
+	// 刚发表的文章的过期时间，刚发表的文章访问量大，过了这段时间之后访问量一般会降下来
+	publishedExpiration = 10 * time.Minute
+)
+
+type ArticleCache struct {
+	cmd redis.Cmdable
+}
+
+func NewArticleCache(cmd redis.Cmdable) *ArticleCache {
+	return &ArticleCache{cmd: cmd}
+}
+
+// GetFirstPage 读取作者文章列表的第一页，没有缓存的时候返回 ErrKeyNotExist
+func (c *ArticleCache) GetFirstPage(ctx context.Context, authorId int64) ([]domain.Article, error) {
+	data, err := c.cmd.Get(ctx, c.firstPageKey(authorId)).Bytes()
+	if err != nil {
+		return nil, err
+	}
+	var res []domain.Article
+	err = json.Unmarshal(data, &res)
+	return res, err
+}
+
+func (c *ArticleCache) SetFirstPage(ctx context.Context, authorId int64, arts []domain.Article) error {
+	data, err := json.Marshal(arts)
+	if err != nil {
+		return err
+	}
+	return c.cmd.Set(ctx, c.firstPageKey(authorId), data, firstPageExpiration).Err()
+}
+
+// DelFirstPage 作者新建、修改、发表或者撤回文章之后调用
+func (c *ArticleCache) DelFirstPage(ctx context.Context, authorId int64) error {
+	return c.cmd.Del(ctx, c.firstPageKey(authorId)).Err()
+}
+
+// GetPublished 读取线上库中的文章，没有缓存的时候返回 ErrKeyNotExist
+func (c *ArticleCache) GetPublished(ctx context.Context, id int64) (domain.Article, error) {
+	data, err := c.cmd.Get(ctx, c.publishedKey(id)).Bytes()
+	if err != nil {
+		return domain.Article{}, err
+	}
+	var art domain.Article
+	err = json.Unmarshal(data, &art)
+	return art, err
+}
+
+// SetPublished 缓存线上库中的文章，作者的名字不会写进缓存，读的时候再批量查询
+func (c *ArticleCache) SetPublished(ctx context.Context, art domain.Article) error {
+	art.Author.Name = ""
+	data, err := json.Marshal(art)
+	if err != nil {
+		return err
+	}
+	return c.cmd.Set(ctx, c.publishedKey(art.Id), data, publishedExpiration).Err()
+}
+
+// DelPublished 文章撤回之后调用
+func (c *ArticleCache) DelPublished(ctx context.Context, id int64) error {
+	return c.cmd.Del(ctx, c.publishedKey(id)).Err()
+}
+
+func (c *ArticleCache) firstPageKey(authorId int64) string {
+	return fmt.Sprintf("article:first_page:%d", authorId)
+}
+
+func (c *ArticleCache) publishedKey(id int64) string {
+	return fmt.Sprintf("article:published:%d", id)
+}
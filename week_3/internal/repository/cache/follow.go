@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"go_homework/week_3/internal/domain"
+	"strconv"
+	"time"
+)
+
+// 关注数和粉丝数缓存的过期时间，计数变化的时候用 Lua 脚本直接修改缓存，过期时间只是兜底
+const followStatisticExpiration = 15 * time.Minute
+
+const (
+	fieldFollowers = "followers"
+	fieldFollowees = "followees"
+)
+
+// FollowCache 用 hash 缓存用户的关注数和粉丝数，key 为 follow:statistic:<uid>
+type FollowCache struct {
+	cmd redis.Cmdable
+}
+
+func NewFollowCache(cmd redis.Cmdable) *FollowCache {
+	return &FollowCache{cmd: cmd}
+}
+
+// IncrIfPresent follower 的关注数和 followee 的粉丝数加 delta，缓存不存在的时候什么都不做
+func (c *FollowCache) IncrIfPresent(ctx context.Context, follower, followee, delta int64) error {
+	// 和互动计数使用同一个脚本
+	pipe := c.cmd.Pipeline()
+	pipe.Eval(ctx, luaIncrCnt, []string{c.key(follower)}, fieldFollowees, delta)
+	pipe.Eval(ctx, luaIncrCnt, []string{c.key(followee)}, fieldFollowers, delta)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Get 读取统计，没有缓存的时候返回 ErrKeyNotExist
+func (c *FollowCache) Get(ctx context.Context, uid int64) (domain.FollowStatistic, error) {
+	data, err := c.cmd.HGetAll(ctx, c.key(uid)).Result()
+	if err != nil {
+		return domain.FollowStatistic{}, err
+	}
+	if len(data) == 0 {
+		return domain.FollowStatistic{}, ErrKeyNotExist
+	}
+	followers, _ := strconv.ParseInt(data[fieldFollowers], 10, 64)
+	followees, _ := strconv.ParseInt(data[fieldFollowees], 10, 64)
+	return domain.FollowStatistic{Uid: uid, Followers: followers, Followees: followees}, nil
+}
+
+func (c *FollowCache) Set(ctx context.Context, s domain.FollowStatistic) error {
+	key := c.key(s.Uid)
+	pipe := c.cmd.Pipeline()
+	pipe.HSet(ctx, key, fieldFollowers, s.Followers, fieldFollowees, s.Followees)
+	pipe.Expire(ctx, key, followStatisticExpiration)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (c *FollowCache) key(uid int64) string {
+	return fmt.Sprintf("follow:statistic:%d", uid)
+}
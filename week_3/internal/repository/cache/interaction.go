@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"go_homework/week_3/internal/domain"
+	"strconv"
+	"time"
+)
+
+// 互动计数缓存的过期时间，计数变化的时候用 Lua 脚本直接修改缓存，过期时间只是兜底
+const interactionExpiration = 15 * time.Minute
+
+// 互动计数缓存中的字段
+const (
+	fieldReadCnt    = "read_cnt"
+	fieldLikeCnt    = "like_cnt"
+	fieldCollectCnt = "collect_cnt"
+)
+
+//go:embed lua/incr_cnt.lua
+var luaIncrCnt string
+
+// InteractionCache 用 hash 缓存业务对象的计数，key 为 interaction:<biz>:<bizId>
+type InteractionCache struct {
+	cmd redis.Cmdable
+}
+
+func NewInteractionCache(cmd redis.Cmdable) *InteractionCache {
+	return &InteractionCache{cmd: cmd}
+}
+
+// IncrReadCntIfPresent 阅读数加 delta，缓存不存在的时候什么都不做
+func (c *InteractionCache) IncrReadCntIfPresent(ctx context.Context, biz string, bizId, delta int64) error {
+	return c.incrCnt(ctx, biz, bizId, fieldReadCnt, delta)
+}
+
+// IncrLikeCntIfPresent 点赞数加 delta，缓存不存在的时候什么都不做
+func (c *InteractionCache) IncrLikeCntIfPresent(ctx context.Context, biz string, bizId, delta int64) error {
+	return c.incrCnt(ctx, biz, bizId, fieldLikeCnt, delta)
+}
+
+// IncrCollectCntIfPresent 收藏数加 delta，缓存不存在的时候什么都不做
+func (c *InteractionCache) IncrCollectCntIfPresent(ctx context.Context, biz string, bizId, delta int64) error {
+	return c.incrCnt(ctx, biz, bizId, fieldCollectCnt, delta)
+}
+
+func (c *InteractionCache) incrCnt(ctx context.Context, biz string, bizId int64, field string, delta int64) error {
+	return c.cmd.Eval(ctx, luaIncrCnt, []string{c.key(biz, bizId)}, field, delta).Err()
+}
+
+// Get 读取计数，没有缓存的时候返回 ErrKeyNotExist。返回的数据中没有当前用户的点赞和收藏状态
+func (c *InteractionCache) Get(ctx context.Context, biz string, bizId int64) (domain.Interaction, error) {
+	data, err := c.cmd.HGetAll(ctx, c.key(biz, bizId)).Result()
+	if err != nil {
+		return domain.Interaction{}, err
+	}
+	// HGETALL 在 key 不存在的时候返回空的结果而不是 redis.Nil
+	if len(data) == 0 {
+		return domain.Interaction{}, ErrKeyNotExist
+	}
+	return c.toDomain(biz, bizId, data), nil
+}
+
+// GetByIds 批量读取计数，只返回命中缓存的业务对象，使用 pipeline 一次往返读取
+func (c *InteractionCache) GetByIds(ctx context.Context, biz string, bizIds []int64) (map[int64]domain.Interaction, error) {
+	pipe := c.cmd.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, 0, len(bizIds))
+	for _, id := range bizIds {
+		cmds = append(cmds, pipe.HGetAll(ctx, c.key(biz, id)))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	res := make(map[int64]domain.Interaction, len(bizIds))
+	for i, cmd := range cmds {
+		if data := cmd.Val(); len(data) > 0 {
+			res[bizIds[i]] = c.toDomain(biz, bizIds[i], data)
+		}
+	}
+	return res, nil
+}
+
+// Set 缓存计数，多个业务对象的时候使用 pipeline 一次写入
+func (c *InteractionCache) Set(ctx context.Context, intrs ...domain.Interaction) error {
+	if len(intrs) == 0 {
+		return nil
+	}
+	pipe := c.cmd.Pipeline()
+	for _, intr := range intrs {
+		key := c.key(intr.Biz, intr.BizId)
+		pipe.HSet(ctx, key,
+			fieldReadCnt, intr.ReadCnt,
+			fieldLikeCnt, intr.LikeCnt,
+			fieldCollectCnt, intr.CollectCnt)
+		pipe.Expire(ctx, key, interactionExpiration)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (c *InteractionCache) toDomain(biz string, bizId int64, data map[string]string) domain.Interaction {
+	// 字段都是 Set 和 Lua 脚本写入的整数，解析失败的时候当作 0
+	readCnt, _ := strconv.ParseInt(data[fieldReadCnt], 10, 64)
+	likeCnt, _ := strconv.ParseInt(data[fieldLikeCnt], 10, 64)
+	collectCnt, _ := strconv.ParseInt(data[fieldCollectCnt], 10, 64)
+	return domain.Interaction{
+		Biz:        biz,
+		BizId:      bizId,
+		ReadCnt:    readCnt,
+		LikeCnt:    likeCnt,
+		CollectCnt: collectCnt,
+	}
+}
+
+func (c *InteractionCache) key(biz string, bizId int64) string {
+	return fmt.Sprintf("interaction:%s:%d", biz, bizId)
+}
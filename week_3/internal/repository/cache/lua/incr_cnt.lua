@@ -0,0 +1,14 @@
+-- 修改互动计数缓存中的一个字段
+-- 只在缓存已经存在的时候修改，不存在的时候不创建，避免只有一个字段的不完整缓存
+local key = KEYS[1]
+-- 计数字段，例如 read_cnt
+local field = ARGV[1]
+-- 变化量，可以是负数
+local delta = tonumber(ARGV[2])
+
+if redis.call('EXISTS', key) == 1 then
+    redis.call('HINCRBY', key, field, delta)
+    return 1
+else
+    return 0
+end
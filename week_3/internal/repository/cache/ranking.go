@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"go_homework/week_3/internal/domain"
+	"sync"
+	"time"
+)
+
+// ErrLocalCacheExpired 本地缓存为空或者已经过期
+var ErrLocalCacheExpired = errors.New("local cache expired")
+
+const rankingKey = "ranking:top_n"
+
+// RankingCache 在 Redis 中保存热榜，所有实例共享同一份
+type RankingCache struct {
+	cmd redis.Cmdable
+	// 需要比计算的间隔长，计算失败几次之后热榜才会消失
+	expiration time.Duration
+}
+
+func NewRankingCache(cmd redis.Cmdable, expiration time.Duration) *RankingCache {
+	return &RankingCache{cmd: cmd, expiration: expiration}
+}
+
+func (c *RankingCache) Set(ctx context.Context, arts []domain.Article) error {
+	data, err := json.Marshal(arts)
+	if err != nil {
+		return err
+	}
+	return c.cmd.Set(ctx, rankingKey, data, c.expiration).Err()
+}
+
+// Get 读取热榜，没有缓存的时候返回 ErrKeyNotExist
+func (c *RankingCache) Get(ctx context.Context) ([]domain.Article, error) {
+	data, err := c.cmd.Get(ctx, rankingKey).Bytes()
+	if err != nil {
+		return nil, err
+	}
+	var res []domain.Article
+	err = json.Unmarshal(data, &res)
+	return res, err
+}
+
+// RankingLocalCache 进程内的热榜缓存，减少读 Redis 的次数，Redis 出问题的时候用来兜底
+type RankingLocalCache struct {
+	mu   sync.RWMutex
+	arts []domain.Article
+	// 过期时间点
+	ddl        time.Time
+	expiration time.Duration
+}
+
+func NewRankingLocalCache(expiration time.Duration) *RankingLocalCache {
+	return &RankingLocalCache{expiration: expiration}
+}
+
+func (c *RankingLocalCache) Set(arts []domain.Article) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.arts = arts
+	c.ddl = time.Now().Add(c.expiration)
+}
+
+// Get 读取没有过期的热榜，为空或者过期的时候返回 ErrLocalCacheExpired
+func (c *RankingLocalCache) Get() ([]domain.Article, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(c.arts) == 0 || time.Now().After(c.ddl) {
+		return nil, ErrLocalCacheExpired
+	}
+	return c.arts, nil
+}
+
+// ForceGet 不管有没有过期都返回热榜，只在 Redis 不可用的时候兜底，为空的时候返回 ErrLocalCacheExpired
+func (c *RankingLocalCache) ForceGet() ([]domain.Article, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(c.arts) == 0 {
+		return nil, ErrLocalCacheExpired
+	}
+	return c.arts, nil
+}
@@ -0,0 +1,101 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"go_homework/week_3/internal/domain"
+	"time"
+)
+
+// ErrKeyNotExist 缓存中没有这个 key
+var ErrKeyNotExist = redis.Nil
+
+// 个人资料缓存的过期时间，资料修改的时候会主动删除缓存，过期时间只是兜底
+const profileExpiration = 15 * time.Minute
+
+type UserCache struct {
+	cmd redis.Cmdable
+	// 吊销记录的保留时间，需要不短于 JWT 可能存活的时间
+	revokeExpiration time.Duration
+}
+
+func NewUserCache(cmd redis.Cmdable, revokeExpiration time.Duration) *UserCache {
+	return &UserCache{cmd: cmd, revokeExpiration: revokeExpiration}
+}
+
+// RevokeSessions 记录吊销时间，这个时间之前签发的 token 都视为失效
+func (c *UserCache) RevokeSessions(ctx context.Context, uid int64, at time.Time) error {
+	return c.cmd.Set(ctx, c.revokeKey(uid), at.UnixMilli(), c.revokeExpiration).Err()
+}
+
+// SessionsRevokedAt 返回用户的吊销时间，没有吊销过的时候返回零值
+func (c *UserCache) SessionsRevokedAt(ctx context.Context, uid int64) (time.Time, error) {
+	ms, err := c.cmd.Get(ctx, c.revokeKey(uid)).Int64()
+	if errors.Is(err, redis.Nil) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(ms), nil
+}
+
+func (c *UserCache) revokeKey(uid int64) string {
+	return fmt.Sprintf("user:sessions:revoked:%d", uid)
+}
+
+// SetResetToken 保存找回密码的 token，在 expiration 之后失效
+func (c *UserCache) SetResetToken(ctx context.Context, token string, uid int64, expiration time.Duration) error {
+	return c.cmd.Set(ctx, c.resetKey(token), uid, expiration).Err()
+}
+
+// GetResetToken 查询 token 对应的用户，token 不存在或者已经过期的时候返回 ErrKeyNotExist
+func (c *UserCache) GetResetToken(ctx context.Context, token string) (int64, error) {
+	return c.cmd.Get(ctx, c.resetKey(token)).Int64()
+}
+
+// DelResetToken 删除 token，使用过之后调用
+func (c *UserCache) DelResetToken(ctx context.Context, token string) error {
+	return c.cmd.Del(ctx, c.resetKey(token)).Err()
+}
+
+// resetKey 使用 token 的 SHA-256 作为 key，Redis 中不保存可以直接使用的 token
+func (c *UserCache) resetKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "user:password:reset:" + hex.EncodeToString(sum[:])
+}
+
+// GetProfile 读取缓存的个人资料，没有缓存的时候返回 ErrKeyNotExist
+func (c *UserCache) GetProfile(ctx context.Context, uid int64) (domain.User, error) {
+	data, err := c.cmd.Get(ctx, c.profileKey(uid)).Bytes()
+	if err != nil {
+		return domain.User{}, err
+	}
+	var u domain.User
+	err = json.Unmarshal(data, &u)
+	return u, err
+}
+
+// SetProfile 缓存个人资料，密码哈希不会写进缓存
+func (c *UserCache) SetProfile(ctx context.Context, u domain.User) error {
+	u.Password = ""
+	data, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	return c.cmd.Set(ctx, c.profileKey(u.Id), data, profileExpiration).Err()
+}
+
+// DelProfile 删除缓存的个人资料，资料修改之后调用
+func (c *UserCache) DelProfile(ctx context.Context, uid int64) error {
+	return c.cmd.Del(ctx, c.profileKey(uid)).Err()
+}
+
+func (c *UserCache) profileKey(uid int64) string {
+	return fmt.Sprintf("user:profile:%d", uid)
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"go_homework/week_3/internal/domain"
+	"go_homework/week_3/internal/repository/dao"
+	"time"
+)
+
+var ErrCommentNotFound = dao.ErrCommentNotFound
+
+type CommentRepository struct {
+	dao *dao.CommentDAO
+}
+
+func NewCommentRepository(dao *dao.CommentDAO) *CommentRepository {
+	return &CommentRepository{dao: dao}
+}
+
+// Create 新建评论，返回评论的 id
+func (repo *CommentRepository) Create(ctx context.Context, c domain.Comment) (int64, error) {
+	ctx, span := tracer.Start(ctx, "CommentRepository.Create")
+	defer span.End()
+	return repo.dao.Insert(ctx, dao.Comment{
+		Uid:      c.Commentator.Id,
+		BizId:    c.BizId,
+		Biz:      c.Biz,
+		RootId:   c.RootId,
+		ParentId: c.ParentId,
+		Content:  c.Content,
+	})
+}
+
+// FindById 查询一条评论
+func (repo *CommentRepository) FindById(ctx context.Context, id int64) (domain.Comment, error) {
+	ctx, span := tracer.Start(ctx, "CommentRepository.FindById")
+	defer span.End()
+	c, err := repo.dao.FindById(ctx, id)
+	if err != nil {
+		return domain.Comment{}, err
+	}
+	return repo.toDomain(c), nil
+}
+
+// Delete 删除 uid 自己的评论以及它下面所有的回复
+func (repo *CommentRepository) Delete(ctx context.Context, uid, id int64) error {
+	ctx, span := tracer.Start(ctx, "CommentRepository.Delete")
+	defer span.End()
+	return repo.dao.Delete(ctx, uid, id)
+}
+
+// ListRoots 按照时间倒序查询业务对象的顶层评论，带上每一条评论的回复数
+func (repo *CommentRepository) ListRoots(ctx context.Context, biz string, bizId, afterId int64,
+	limit int) ([]domain.Comment, error) {
+	ctx, span := tracer.Start(ctx, "CommentRepository.ListRoots")
+	defer span.End()
+	cs, err := repo.dao.ListRoots(ctx, biz, bizId, afterId, limit)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, 0, len(cs))
+	for _, c := range cs {
+		ids = append(ids, c.Id)
+	}
+	cnts, err := repo.dao.CountReplies(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.Comment, 0, len(cs))
+	for _, c := range cs {
+		dc := repo.toDomain(c)
+		dc.ReplyCnt = cnts[c.Id]
+		res = append(res, dc)
+	}
+	return res, nil
+}
+
+// ListReplies 按照时间正序查询顶层评论下面的回复
+func (repo *CommentRepository) ListReplies(ctx context.Context, rootId, afterId int64,
+	limit int) ([]domain.Comment, error) {
+	ctx, span := tracer.Start(ctx, "CommentRepository.ListReplies")
+	defer span.End()
+	cs, err := repo.dao.ListReplies(ctx, rootId, afterId, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.Comment, 0, len(cs))
+	for _, c := range cs {
+		res = append(res, repo.toDomain(c))
+	}
+	return res, nil
+}
+
+// ListByCommentator 按照时间正序查询用户发表的评论和回复，afterId 是上一页最后一条评论的 id
+func (repo *CommentRepository) ListByCommentator(ctx context.Context, uid, afterId int64,
+	limit int) ([]domain.Comment, error) {
+	ctx, span := tracer.Start(ctx, "CommentRepository.ListByCommentator")
+	defer span.End()
+	cs, err := repo.dao.ListByUid(ctx, uid, afterId, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.Comment, 0, len(cs))
+	for _, c := range cs {
+		res = append(res, repo.toDomain(c))
+	}
+	return res, nil
+}
+
+func (repo *CommentRepository) toDomain(c dao.Comment) domain.Comment {
+	return domain.Comment{
+		Id:          c.Id,
+		Biz:         c.Biz,
+		BizId:       c.BizId,
+		Commentator: domain.User{Id: c.Uid},
+		Content:     c.Content,
+		RootId:      c.RootId,
+		ParentId:    c.ParentId,
+		Ctime:       time.UnixMilli(c.Ctime),
+	}
+}
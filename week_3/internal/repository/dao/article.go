@@ -0,0 +1,217 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"go_homework/week_3/internal/errs"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// ErrArticleNotFound 文章不存在，或者不是这个作者的文章。两种情况返回同一个错误，避免泄露别人的文章是否存在
+var ErrArticleNotFound = errs.NotFound(errs.CodeArticleNotFound, "Article not found")
+
+// AbstractLength 文章列表中摘要的长度，单位是字符
+const AbstractLength = 128
+
+// ArticleDAO 文章分成两张表：制作库 articles 保存作者正在编辑的版本，
+// 线上库 published_articles 保存最近一次发表的版本，读者只会读线上库。
+// 两张表中同一篇文章的 id 相同
+type ArticleDAO struct {
+	db *gorm.DB
+}
+
+func NewArticleDAO(db *gorm.DB) *ArticleDAO {
+	return &ArticleDAO{db: db}
+}
+
+// Insert 在制作库中新建文章，返回文章的 id
+func (dao *ArticleDAO) Insert(ctx context.Context, art Article) (int64, error) {
+	return dao.insert(dao.db.WithContext(ctx), art)
+}
+
+func (dao *ArticleDAO) insert(tx *gorm.DB, art Article) (int64, error) {
+	now := time.Now().UnixMilli()
+	art.Ctime = now
+	art.Utime = now
+	err := tx.Create(&art).Error
+	return art.Id, err
+}
+
+// UpdateById 更新制作库中的文章，只能更新 art.AuthorId 自己的文章，否则返回 ErrArticleNotFound
+func (dao *ArticleDAO) UpdateById(ctx context.Context, art Article) error {
+	return dao.updateById(dao.db.WithContext(ctx), art)
+}
+
+func (dao *ArticleDAO) updateById(tx *gorm.DB, art Article) error {
+	res := tx.Model(&Article{}).
+		// author_id 作为更新条件，防止修改别人的文章
+		Where("id = ? AND author_id = ?", art.Id, art.AuthorId).
+		Updates(map[string]any{
+			"title":   art.Title,
+			"content": art.Content,
+			"status":  art.Status,
+			"utime":   time.Now().UnixMilli(),
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrArticleNotFound
+	}
+	return nil
+}
+
+// Sync 在同一个事务里面保存制作库并且把文章同步到线上库，用于发表文章。
+// art.Id 为 0 的时候新建文章，返回线上库中的文章
+func (dao *ArticleDAO) Sync(ctx context.Context, art Article) (PublishedArticle, error) {
+	var res PublishedArticle
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if art.Id > 0 {
+			err = dao.updateById(tx, art)
+		} else {
+			art.Id, err = dao.insert(tx, art)
+		}
+		if err != nil {
+			return err
+		}
+		now := time.Now().UnixMilli()
+		pub := PublishedArticle(art)
+		pub.Ctime = now
+		pub.Utime = now
+		// 第一次发表的时候插入，之后发表的时候覆盖，创建时间保持第一次发表的时间
+		err = tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"title", "content", "status", "utime"}),
+		}).Create(&pub).Error
+		if err != nil {
+			return err
+		}
+		// 在事务里面读，读的是主库，拿到第一次发表的时间
+		return tx.Where("id = ?", art.Id).First(&res).Error
+	})
+	return res, err
+}
+
+// SyncStatus 在同一个事务里面修改制作库和线上库中文章的状态，用于撤回文章。
+// 只能修改 authorId 自己的文章，否则返回 ErrArticleNotFound
+func (dao *ArticleDAO) SyncStatus(ctx context.Context, authorId, id int64, status uint8) error {
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UnixMilli()
+		res := tx.Model(&Article{}).
+			Where("id = ? AND author_id = ?", id, authorId).
+			Updates(map[string]any{"status": status, "utime": now})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrArticleNotFound
+		}
+		// 没有发表过的文章在线上库中没有记录，不需要修改
+		return tx.Model(&PublishedArticle{}).
+			Where("id = ? AND author_id = ?", id, authorId).
+			Updates(map[string]any{"status": status, "utime": now}).Error
+	})
+}
+
+// FindById 查询制作库中的文章
+func (dao *ArticleDAO) FindById(ctx context.Context, id int64) (Article, error) {
+	var art Article
+	err := dao.db.WithContext(ctx).Where("id = ?", id).First(&art).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return art, ErrArticleNotFound.Wrap(err)
+	}
+	return art, err
+}
+
+// ListByAuthor 按照更新时间倒序查询作者在制作库中的文章，
+// afterId 大于 0 的时候只返回排在 (afterUtime, afterId) 之后的文章，用于游标分页。
+// 列表页不需要全文，content 只返回前 AbstractLength 个字符
+func (dao *ArticleDAO) ListByAuthor(ctx context.Context, authorId int64, afterUtime, afterId int64,
+	limit int) ([]Article, error) {
+	var res []Article
+	db := dao.db.WithContext(ctx).
+		Select("id, title, SUBSTR(content, 1, ?) AS content, author_id, status, ctime, utime", AbstractLength).
+		Where("author_id = ?", authorId)
+	if afterId > 0 {
+		db = db.Where("utime < ? OR (utime = ? AND id < ?)", afterUtime, afterUtime, afterId)
+	}
+	err := db.Order("utime DESC, id DESC").Limit(limit).Find(&res).Error
+	return res, err
+}
+
+// ListFullByAuthor 按照 id 顺序查询作者在制作库中的文章，包含全文，导出数据的时候使用。
+// afterId 大于 0 的时候只返回 id 大于 afterId 的文章
+func (dao *ArticleDAO) ListFullByAuthor(ctx context.Context, authorId, afterId int64, limit int) ([]Article, error) {
+	var res []Article
+	err := dao.db.WithContext(ctx).
+		Where("author_id = ? AND id > ?", authorId, afterId).
+		Order("id").Limit(limit).Find(&res).Error
+	return res, err
+}
+
+// FindPublishedById 查询线上库中的文章，撤回的文章也会被查到，由调用方判断状态
+func (dao *ArticleDAO) FindPublishedById(ctx context.Context, id int64) (PublishedArticle, error) {
+	var art PublishedArticle
+	err := dao.db.WithContext(ctx).Where("id = ?", id).First(&art).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return art, ErrArticleNotFound.Wrap(err)
+	}
+	return art, err
+}
+
+// ListPublished 按照 (ctime, id) 顺序遍历线上库中状态为 status、ctime 不早于 since 的文章，
+// 只返回排在 (afterCtime, afterId) 之后的文章，第一页 afterId 传 0。
+// 和列表页一样 content 只返回前 AbstractLength 个字符
+func (dao *ArticleDAO) ListPublished(ctx context.Context, status uint8, since int64,
+	afterCtime, afterId int64, limit int) ([]PublishedArticle, error) {
+	var res []PublishedArticle
+	db := dao.db.WithContext(ctx).
+		Select("id, title, SUBSTR(content, 1, ?) AS content, author_id, status, ctime, utime", AbstractLength).
+		Where("status = ? AND ctime >= ?", status, since)
+	if afterId > 0 {
+		db = db.Where("ctime > ? OR (ctime = ? AND id > ?)", afterCtime, afterCtime, afterId)
+	}
+	err := db.Order("ctime, id").Limit(limit).Find(&res).Error
+	return res, err
+}
+
+// FindPublishedByIds 批量查询线上库中状态为 status 的文章，content 只返回摘要，不存在或者状态不对的文章不在结果中
+func (dao *ArticleDAO) FindPublishedByIds(ctx context.Context, ids []int64, status uint8) ([]PublishedArticle, error) {
+	var res []PublishedArticle
+	if len(ids) == 0 {
+		return res, nil
+	}
+	err := dao.db.WithContext(ctx).
+		Select("id, title, SUBSTR(content, 1, ?) AS content, author_id, status, ctime, utime", AbstractLength).
+		Where("id IN ? AND status = ?", ids, status).Find(&res).Error
+	return res, err
+}
+
+// Article 制作库中的文章
+type Article struct {
+	Id      int64  `gorm:"primaryKey,autoIncrement"`
+	Title   string `gorm:"type:varchar(1024)"`
+	Content string `gorm:"type:text"`
+	// 按照作者查询文章列表
+	AuthorId int64 `gorm:"index:idx_articles_author_utime,priority:1"`
+	// 取值见 domain.ArticleStatus
+	Status uint8
+	Ctime  int64
+	Utime  int64 `gorm:"index:idx_articles_author_utime,priority:2"`
+}
+
+// PublishedArticle 线上库中的文章，字段和 Article 一致，id 使用制作库中的 id
+type PublishedArticle struct {
+	Id      int64  `gorm:"primaryKey,autoIncrement:false"`
+	Title   string `gorm:"type:varchar(1024)"`
+	Content string `gorm:"type:text"`
+	// 关注流的拉模式按照作者查询，再按照第一次发表的时间排序
+	AuthorId int64 `gorm:"index;index:idx_published_articles_author_ctime,priority:1"`
+	Status   uint8
+	// 计算热榜的时候只遍历最近一段时间内发表的文章
+	Ctime int64 `gorm:"index:idx_published_articles_author_ctime,priority:2;index:idx_published_articles_ctime"`
+	Utime int64
+}
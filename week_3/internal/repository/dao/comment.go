@@ -0,0 +1,188 @@
+package dao
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"go_homework/week_3/internal/errs"
+	"gorm.io/gorm"
+	"slices"
+	"time"
+)
+
+// ErrCommentNotFound 评论不存在，或者不是这个用户的评论
+var ErrCommentNotFound = errs.NotFound(errs.CodeCommentNotFound, "Comment not found")
+
+// CommentDAO 评论分成两层：root_id 为 0 的是直接评论业务对象的顶层评论，
+// 其余的都是回复，root_id 是所在的顶层评论，parent_id 是直接回复的那一条评论
+type CommentDAO struct {
+	db *gorm.DB
+}
+
+func NewCommentDAO(db *gorm.DB) *CommentDAO {
+	return &CommentDAO{db: db}
+}
+
+// Insert 新建评论，返回评论的 id
+func (dao *CommentDAO) Insert(ctx context.Context, c Comment) (int64, error) {
+	now := time.Now().UnixMilli()
+	c.Ctime = now
+	c.Utime = now
+	err := dao.db.WithContext(ctx).Create(&c).Error
+	return c.Id, err
+}
+
+// FindById 查询一条评论
+func (dao *CommentDAO) FindById(ctx context.Context, id int64) (Comment, error) {
+	var c Comment
+	err := dao.db.WithContext(ctx).Where("id = ?", id).First(&c).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c, ErrCommentNotFound.Wrap(err)
+	}
+	return c, err
+}
+
+// Delete 删除 uid 自己的评论以及它下面所有的回复，不是自己的评论返回 ErrCommentNotFound
+func (dao *CommentDAO) Delete(ctx context.Context, uid, id int64) error {
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var c Comment
+		err := tx.Where("id = ? AND uid = ?", id, uid).First(&c).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrCommentNotFound.Wrap(err)
+		}
+		if err != nil {
+			return err
+		}
+		if c.RootId == 0 {
+			// 顶层评论下面所有的回复 root_id 都是它
+			return tx.Where("id = ? OR root_id = ?", id, id).Delete(&Comment{}).Error
+		}
+		ids, err := dao.descendants(tx, c)
+		if err != nil {
+			return err
+		}
+		return tx.Where("id IN ?", ids).Delete(&Comment{}).Error
+	})
+}
+
+// descendants 返回回复 c 以及直接或者间接回复它的所有评论的 id。
+// 同一条顶层评论下面的回复一次查出来，在内存里面沿着 parent_id 找
+func (dao *CommentDAO) descendants(tx *gorm.DB, c Comment) ([]int64, error) {
+	var replies []Comment
+	err := tx.Select("id, parent_id").Where("root_id = ?", c.RootId).Find(&replies).Error
+	if err != nil {
+		return nil, err
+	}
+	children := make(map[int64][]int64, len(replies))
+	for _, r := range replies {
+		children[r.ParentId] = append(children[r.ParentId], r.Id)
+	}
+	res := []int64{c.Id}
+	for i := 0; i < len(res); i++ {
+		res = append(res, children[res[i]]...)
+	}
+	return res, nil
+}
+
+// purgeComments 删除 uids 发表的评论以及它们下面所有的回复，还有 articleIds 这些文章下面所有的评论
+func purgeComments(tx *gorm.DB, uids []int64, articleBiz string, articleIds []int64) error {
+	if len(articleIds) > 0 {
+		err := tx.Where("biz = ? AND biz_id IN ?", articleBiz, articleIds).Delete(&Comment{}).Error
+		if err != nil {
+			return err
+		}
+	}
+	var own []Comment
+	if err := tx.Select("id, root_id").Where("uid IN ?", uids).Find(&own).Error; err != nil {
+		return err
+	}
+	var ids, rootIds []int64
+	for _, c := range own {
+		ids = append(ids, c.Id)
+		rootIds = append(rootIds, cmp.Or(c.RootId, c.Id))
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	// 同一条顶层评论下面的回复一次查出来，在内存里面沿着 parent_id 找到所有直接或者间接的回复
+	slices.Sort(rootIds)
+	var replies []Comment
+	err := tx.Select("id, parent_id").Where("root_id IN ?", slices.Compact(rootIds)).Find(&replies).Error
+	if err != nil {
+		return err
+	}
+	children := make(map[int64][]int64, len(replies))
+	for _, r := range replies {
+		children[r.ParentId] = append(children[r.ParentId], r.Id)
+	}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, children[ids[i]]...)
+	}
+	return tx.Where("id IN ?", ids).Delete(&Comment{}).Error
+}
+
+// ListRoots 按照时间倒序查询业务对象的顶层评论，afterId 大于 0 的时候只返回 id 小于 afterId 的评论
+func (dao *CommentDAO) ListRoots(ctx context.Context, biz string, bizId, afterId int64, limit int) ([]Comment, error) {
+	var res []Comment
+	db := dao.db.WithContext(ctx).Where("biz_id = ? AND biz = ? AND root_id = 0", bizId, biz)
+	if afterId > 0 {
+		db = db.Where("id < ?", afterId)
+	}
+	err := db.Order("id DESC").Limit(limit).Find(&res).Error
+	return res, err
+}
+
+// ListReplies 按照时间正序查询顶层评论 rootId 下面的回复，afterId 大于 0 的时候只返回 id 大于 afterId 的回复
+func (dao *CommentDAO) ListReplies(ctx context.Context, rootId, afterId int64, limit int) ([]Comment, error) {
+	var res []Comment
+	err := dao.db.WithContext(ctx).
+		Where("root_id = ? AND id > ?", rootId, afterId).
+		Order("id").Limit(limit).Find(&res).Error
+	return res, err
+}
+
+// ListByUid 按照时间正序查询用户发表的评论和回复，afterId 大于 0 的时候只返回 id 大于 afterId 的评论，导出数据的时候使用
+func (dao *CommentDAO) ListByUid(ctx context.Context, uid, afterId int64, limit int) ([]Comment, error) {
+	var res []Comment
+	err := dao.db.WithContext(ctx).
+		Where("uid = ? AND id > ?", uid, afterId).
+		Order("id").Limit(limit).Find(&res).Error
+	return res, err
+}
+
+// CountReplies 批量查询顶层评论的回复数，没有回复的评论不在结果中
+func (dao *CommentDAO) CountReplies(ctx context.Context, rootIds []int64) (map[int64]int64, error) {
+	res := make(map[int64]int64, len(rootIds))
+	if len(rootIds) == 0 {
+		return res, nil
+	}
+	var rows []struct {
+		RootId int64
+		Cnt    int64
+	}
+	err := dao.db.WithContext(ctx).Model(&Comment{}).
+		Select("root_id, COUNT(*) AS cnt").
+		Where("root_id IN ?", rootIds).
+		Group("root_id").Scan(&rows).Error
+	for _, row := range rows {
+		res[row.RootId] = row.Cnt
+	}
+	return res, err
+}
+
+// Comment 评论
+type Comment struct {
+	Id int64 `gorm:"primaryKey,autoIncrement"`
+	// 评论的人，导出用户的数据时按照评论的人查询
+	Uid int64 `gorm:"index"`
+	// 被评论的业务对象，查询顶层评论
+	BizId int64  `gorm:"index:idx_comments_biz_id_biz_root_id,priority:1"`
+	Biz   string `gorm:"type:varchar(128);index:idx_comments_biz_id_biz_root_id,priority:2"`
+	// 顶层评论为 0，查询回复
+	RootId int64 `gorm:"index:idx_comments_biz_id_biz_root_id,priority:3;index"`
+	// 直接回复的评论，顶层评论为 0
+	ParentId int64
+	Content  string `gorm:"type:varchar(4096)"`
+	Ctime    int64
+	Utime    int64
+}
@@ -0,0 +1,68 @@
+package dao
+
+import (
+	"errors"
+	sqlite "github.com/glebarez/go-sqlite"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+	"strings"
+)
+
+// uniqueConflictDetectors 各个数据库判断唯一索引冲突的方式，key 是 gorm.Dialector 的 Name()
+var uniqueConflictDetectors = map[string]func(err error) bool{
+	"mysql":    isMySQLUniqueConflict,
+	"sqlite":   isSQLiteUniqueConflict,
+	"postgres": isPostgresUniqueConflict,
+}
+
+// isUniqueConflict 判断 err 是否是唯一索引冲突，根据 db 使用的方言选择判断方式
+func isUniqueConflict(db *gorm.DB, err error) bool {
+	if err == nil {
+		return false
+	}
+	// 开启了 TranslateError 的时候，gorm 会把错误转换成 ErrDuplicatedKey
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return true
+	}
+	detector, ok := uniqueConflictDetectors[db.Dialector.Name()]
+	if !ok {
+		return false
+	}
+	return detector(err)
+}
+
+func isMySQLUniqueConflict(err error) bool {
+	var me *mysql.MySQLError
+	// 1062 是 ER_DUP_ENTRY
+	const duplicateErr uint16 = 1062
+	return errors.As(err, &me) && me.Number == duplicateErr
+}
+
+func isSQLiteUniqueConflict(err error) bool {
+	var se *sqlite.Error
+	if !errors.As(err, &se) {
+		return false
+	}
+	const (
+		// SQLITE_CONSTRAINT_UNIQUE 和 SQLITE_CONSTRAINT_PRIMARYKEY 扩展错误码
+		constraintUnique     = 2067
+		constraintPrimaryKey = 1555
+		// 没有开启扩展错误码的时候只有 SQLITE_CONSTRAINT
+		constraint = 19
+	)
+	switch se.Code() {
+	case constraintUnique, constraintPrimaryKey:
+		return true
+	case constraint:
+		return strings.Contains(se.Error(), "UNIQUE constraint failed")
+	default:
+		return false
+	}
+}
+
+func isPostgresUniqueConflict(err error) bool {
+	var pe *pgconn.PgError
+	// 23505 是 unique_violation
+	return errors.As(err, &pe) && pe.Code == "23505"
+}
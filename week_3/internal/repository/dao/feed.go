@@ -0,0 +1,76 @@
+package dao
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FeedDAO 关注流。粉丝少的作者发表文章的时候推送到每一个粉丝的收件箱 feed_push_events 中（推模式），
+// 粉丝多的作者不推送，读的时候直接查询线上库（拉模式）
+type FeedDAO struct {
+	db *gorm.DB
+}
+
+func NewFeedDAO(db *gorm.DB) *FeedDAO {
+	return &FeedDAO{db: db}
+}
+
+// InsertPushEvents 批量写入收件箱，同一篇文章重复推送给同一个用户的时候忽略
+func (dao *FeedDAO) InsertPushEvents(ctx context.Context, events []FeedPushEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	return dao.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&events).Error
+}
+
+// FindPushEvents 按照文章发表时间倒序查询 uid 的收件箱，只返回 uid 现在还关注着的作者的、线上库中状态是 status 的文章。
+// afterArticleId 大于 0 的时候只返回排在 (afterCtime, afterArticleId) 之后的记录
+func (dao *FeedDAO) FindPushEvents(ctx context.Context, uid int64, status uint8, afterCtime, afterArticleId int64,
+	limit int) ([]FeedPushEvent, error) {
+	var res []FeedPushEvent
+	db := dao.db.WithContext(ctx).
+		Where("uid = ?", uid).
+		// 取消关注之后不再展示收件箱里面这个作者的文章
+		Where("author_id IN (?)", dao.followees(uid)).
+		// 撤回的文章在 SQL 中过滤掉，不然 LIMIT 算上了撤回的文章，这一页会少于 limit 条，调用方会误以为没有下一页了
+		Where("article_id IN (?)", dao.db.Model(&PublishedArticle{}).Select("id").Where("status = ?", status))
+	if afterArticleId > 0 {
+		db = db.Where("ctime < ? OR (ctime = ? AND article_id < ?)", afterCtime, afterCtime, afterArticleId)
+	}
+	err := db.Order("ctime DESC, article_id DESC").Limit(limit).Find(&res).Error
+	return res, err
+}
+
+// FindPullArticles 按照第一次发表的时间倒序查询 uid 关注的、粉丝数不少于 threshold 的作者在线上库中的文章，
+// content 只返回摘要。afterId 大于 0 的时候只返回排在 (afterCtime, afterId) 之后的文章
+func (dao *FeedDAO) FindPullArticles(ctx context.Context, uid, threshold int64, status uint8,
+	afterCtime, afterId int64, limit int) ([]PublishedArticle, error) {
+	var res []PublishedArticle
+	bigAuthors := dao.db.Model(&FollowStatistic{}).Select("uid").
+		Where("uid IN (?) AND followers >= ?", dao.followees(uid), threshold)
+	db := dao.db.WithContext(ctx).
+		Select("id, title, SUBSTR(content, 1, ?) AS content, author_id, status, ctime, utime", AbstractLength).
+		Where("author_id IN (?) AND status = ?", bigAuthors, status)
+	if afterId > 0 {
+		db = db.Where("ctime < ? OR (ctime = ? AND id < ?)", afterCtime, afterCtime, afterId)
+	}
+	err := db.Order("ctime DESC, id DESC").Limit(limit).Find(&res).Error
+	return res, err
+}
+
+// followees uid 关注的所有人，作为子查询使用
+func (dao *FeedDAO) followees(uid int64) *gorm.DB {
+	return dao.db.Model(&FollowRelation{}).Select("followee").
+		Where("follower = ? AND status = ?", uid, followStatusActive)
+}
+
+// FeedPushEvent 收件箱中的一条记录，ctime 是文章第一次发表的时间，和拉模式的排序键保持一致
+type FeedPushEvent struct {
+	Id int64 `gorm:"primaryKey,autoIncrement"`
+	// 收件人
+	Uid       int64 `gorm:"uniqueIndex:uk_feed_push_events_uid_article_id,priority:1;index:idx_feed_push_events_uid_ctime,priority:1"`
+	ArticleId int64 `gorm:"uniqueIndex:uk_feed_push_events_uid_article_id,priority:2"`
+	AuthorId  int64
+	Ctime     int64 `gorm:"index:idx_feed_push_events_uid_ctime,priority:2"`
+}
@@ -0,0 +1,198 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+const (
+	// 关注关系的状态，取消关注的时候只修改状态，不删除记录
+	followStatusCancelled uint8 = 0
+	followStatusActive    uint8 = 1
+)
+
+// FollowDAO 关注关系保存在 follow_relations 中，每个用户的关注数和粉丝数保存在 follow_statistics 中，
+// 两者在同一个事务里面修改
+type FollowDAO struct {
+	db *gorm.DB
+}
+
+func NewFollowDAO(db *gorm.DB) *FollowDAO {
+	return &FollowDAO{db: db}
+}
+
+// Follow follower 关注 followee，返回这一次是否真的改变了关注状态。已经关注过的时候什么都不做，返回 false
+func (dao *FollowDAO) Follow(ctx context.Context, follower, followee int64) (bool, error) {
+	var changed bool
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UnixMilli()
+		// 先尝试恢复取消过的关注
+		res := tx.Model(&FollowRelation{}).
+			Where("follower = ? AND followee = ? AND status = ?", follower, followee, followStatusCancelled).
+			Updates(map[string]any{"status": followStatusActive, "utime": now})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			// 没有取消过的记录，插入一条。已经关注过的时候唯一索引冲突，什么都不插入
+			res = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&FollowRelation{
+				Follower: follower, Followee: followee, Status: followStatusActive, Ctime: now, Utime: now,
+			})
+			if res.Error != nil {
+				return res.Error
+			}
+		}
+		changed = res.RowsAffected > 0
+		if !changed {
+			return nil
+		}
+		return dao.incrStatistics(tx, follower, followee, 1)
+	})
+	return changed, err
+}
+
+// Unfollow 取消关注，返回这一次是否真的改变了关注状态。没有关注过的时候什么都不做，返回 false
+func (dao *FollowDAO) Unfollow(ctx context.Context, follower, followee int64) (bool, error) {
+	var changed bool
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&FollowRelation{}).
+			Where("follower = ? AND followee = ? AND status = ?", follower, followee, followStatusActive).
+			Updates(map[string]any{"status": followStatusCancelled, "utime": time.Now().UnixMilli()})
+		if res.Error != nil {
+			return res.Error
+		}
+		changed = res.RowsAffected > 0
+		if !changed {
+			return nil
+		}
+		return dao.incrStatistics(tx, follower, followee, -1)
+	})
+	return changed, err
+}
+
+// incrStatistics follower 的关注数和 followee 的粉丝数加 delta
+func (dao *FollowDAO) incrStatistics(tx *gorm.DB, follower, followee, delta int64) error {
+	now := time.Now().UnixMilli()
+	err := tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "uid"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"followees": gorm.Expr("followees + ?", delta),
+			"utime":     now,
+		}),
+	}).Create(&FollowStatistic{Uid: follower, Followees: delta, Ctime: now, Utime: now}).Error
+	if err != nil {
+		return err
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "uid"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"followers": gorm.Expr("followers + ?", delta),
+			"utime":     now,
+		}),
+	}).Create(&FollowStatistic{Uid: followee, Followers: delta, Ctime: now, Utime: now}).Error
+}
+
+// purgeFollows 删除 uids 的关注关系和统计，他们关注的人的粉丝数、关注他们的人的关注数同时减掉
+func purgeFollows(tx *gorm.DB, uids []int64) error {
+	type uidCnt struct {
+		Uid int64
+		Cnt int64
+	}
+	now := time.Now().UnixMilli()
+	// 关注关系的两边都在 uids 里面的时候统计也会一起删掉，不需要修改
+	sides := []struct {
+		// 需要减掉的统计字段，以及关注关系中 uids 所在的一边和另外一边
+		column, self, other string
+	}{
+		{column: "followers", self: "follower", other: "followee"},
+		{column: "followees", self: "followee", other: "follower"},
+	}
+	for _, side := range sides {
+		var cnts []uidCnt
+		err := tx.Model(&FollowRelation{}).Select(side.other+" AS uid, COUNT(*) AS cnt").
+			Where(side.self+" IN ? AND "+side.other+" NOT IN ? AND status = ?", uids, uids, followStatusActive).
+			Group(side.other).Order(side.other).Scan(&cnts).Error
+		if err != nil {
+			return err
+		}
+		for _, c := range cnts {
+			err = tx.Model(&FollowStatistic{}).Where("uid = ?", c.Uid).
+				Updates(map[string]any{side.column: gorm.Expr(side.column+" - ?", c.Cnt), "utime": now}).Error
+			if err != nil {
+				return err
+			}
+		}
+	}
+	err := tx.Where("follower IN ? OR followee IN ?", uids, uids).Delete(&FollowRelation{}).Error
+	if err != nil {
+		return err
+	}
+	return tx.Where("uid IN ?", uids).Delete(&FollowStatistic{}).Error
+}
+
+// FindStatistic 查询用户的关注数和粉丝数，还没有关注过别人也没有被关注过的时候返回全为 0 的统计
+func (dao *FollowDAO) FindStatistic(ctx context.Context, uid int64) (FollowStatistic, error) {
+	var res FollowStatistic
+	err := dao.db.WithContext(ctx).Where("uid = ?", uid).First(&res).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return FollowStatistic{Uid: uid}, nil
+	}
+	return res, err
+}
+
+// IsFollowing 判断 follower 是否关注了 followee
+func (dao *FollowDAO) IsFollowing(ctx context.Context, follower, followee int64) (bool, error) {
+	var cnt int64
+	err := dao.db.WithContext(ctx).Model(&FollowRelation{}).
+		Where("follower = ? AND followee = ? AND status = ?", follower, followee, followStatusActive).
+		Count(&cnt).Error
+	return cnt > 0, err
+}
+
+// ListFollowers 按照关注时间倒序查询 followee 的粉丝，afterId 大于 0 的时候只返回关注关系 id 小于 afterId 的记录
+func (dao *FollowDAO) ListFollowers(ctx context.Context, followee, afterId int64, limit int) ([]FollowRelation, error) {
+	return dao.list(ctx, "followee", followee, afterId, limit)
+}
+
+// ListFollowees 按照关注时间倒序查询 follower 关注的人，afterId 的含义和 ListFollowers 一样
+func (dao *FollowDAO) ListFollowees(ctx context.Context, follower, afterId int64, limit int) ([]FollowRelation, error) {
+	return dao.list(ctx, "follower", follower, afterId, limit)
+}
+
+// list 关注关系的 id 是自增的，按照 id 倒序就是按照关注时间倒序。
+// 取消之后重新关注的记录保留原来的 id，排在第一次关注的位置
+func (dao *FollowDAO) list(ctx context.Context, column string, uid, afterId int64, limit int) ([]FollowRelation, error) {
+	var res []FollowRelation
+	db := dao.db.WithContext(ctx).Where(column+" = ? AND status = ?", uid, followStatusActive)
+	if afterId > 0 {
+		db = db.Where("id < ?", afterId)
+	}
+	err := db.Order("id DESC").Limit(limit).Find(&res).Error
+	return res, err
+}
+
+// FollowRelation 关注关系，一对用户之间只有一条记录
+type FollowRelation struct {
+	Id int64 `gorm:"primaryKey,autoIncrement"`
+	// 关注者
+	Follower int64 `gorm:"uniqueIndex:uk_follow_relations_follower_followee,priority:1"`
+	// 被关注者，查询粉丝列表的时候使用
+	Followee int64 `gorm:"uniqueIndex:uk_follow_relations_follower_followee,priority:2;index"`
+	// 1 关注中，0 已取消
+	Status uint8 `gorm:"not null;default:0"`
+	Ctime  int64
+	Utime  int64
+}
+
+// FollowStatistic 用户的关注数和粉丝数
+type FollowStatistic struct {
+	Id        int64 `gorm:"primaryKey,autoIncrement"`
+	Uid       int64 `gorm:"uniqueIndex"`
+	Followers int64 `gorm:"not null;default:0"`
+	Followees int64 `gorm:"not null;default:0"`
+	Ctime     int64
+	Utime     int64
+}
@@ -2,6 +2,10 @@ package dao
 
 import "gorm.io/gorm"
 
+// InitTables 使用 AutoMigrate 建表，只用于没有迁移文件的方言（例如本地开发使用的 sqlite）。
+// MySQL 和 Postgres 的表结构由 migrations 目录下的迁移文件管理
 func InitTables(db *gorm.DB) error {
-	return db.AutoMigrate(&User{})
+	return db.AutoMigrate(&User{}, &Article{}, &PublishedArticle{},
+		&Interaction{}, &UserLikeBiz{}, &Collection{}, &UserCollectionBiz{}, &UserCollectedBiz{},
+		&FollowRelation{}, &FollowStatistic{}, &FeedPushEvent{}, &Comment{}, &OutboxMessage{})
 }
@@ -0,0 +1,402 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"go_homework/week_3/internal/errs"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"slices"
+	"time"
+)
+
+var (
+	// ErrCollectionNotFound 收藏夹不存在，或者不是这个用户的收藏夹
+	ErrCollectionNotFound = errs.NotFound(errs.CodeCollectionNotFound, "Collection not found")
+	// ErrDuplicateCollection 同一个用户的收藏夹不能重名
+	ErrDuplicateCollection = errs.Conflict(errs.CodeDuplicateCollection, "Collection name is already exist")
+)
+
+const (
+	// 点赞记录的状态，取消点赞的时候只修改状态，不删除记录
+	likeStatusCancelled uint8 = 0
+	likeStatusLiked     uint8 = 1
+)
+
+// InteractionDAO 业务对象的阅读、点赞和收藏。业务对象用 (biz, biz_id) 表示，例如 ("article", 1)，
+// 计数保存在 interactions 表中，每个用户的点赞和收藏记录保存在各自的表中，记录和计数在同一个事务里面修改
+type InteractionDAO struct {
+	db *gorm.DB
+}
+
+func NewInteractionDAO(db *gorm.DB) *InteractionDAO {
+	return &InteractionDAO{db: db}
+}
+
+// BatchIncrReadCnt 在一个事务里面修改一批业务对象的阅读数，cnts 是 biz 到 bizId 到增量的映射，
+// 业务对象还没有计数的时候插入一行。整批要么全部成功要么全部失败，重试的时候不会重复计数。
+// 按照 (biz, bizId) 的顺序修改，避免并发的批量修改互相死锁
+func (dao *InteractionDAO) BatchIncrReadCnt(ctx context.Context, cnts map[string]map[int64]int64) error {
+	bizs := make([]string, 0, len(cnts))
+	for biz := range cnts {
+		bizs = append(bizs, biz)
+	}
+	slices.Sort(bizs)
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, biz := range bizs {
+			ids := make([]int64, 0, len(cnts[biz]))
+			for id := range cnts[biz] {
+				ids = append(ids, id)
+			}
+			slices.Sort(ids)
+			for _, id := range ids {
+				if err := dao.incrCnt(tx, biz, id, "read_cnt", cnts[biz][id]); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// incrCnt 修改一个计数，使用 upsert 让并发的修改在数据库里面累加，不会互相覆盖
+func (dao *InteractionDAO) incrCnt(tx *gorm.DB, biz string, bizId int64, column string, delta int64) error {
+	now := time.Now().UnixMilli()
+	intr := Interaction{Biz: biz, BizId: bizId, Ctime: now, Utime: now}
+	switch column {
+	case "read_cnt":
+		intr.ReadCnt = delta
+	case "like_cnt":
+		intr.LikeCnt = delta
+	case "collect_cnt":
+		intr.CollectCnt = delta
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "biz_id"}, {Name: "biz"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			column:  gorm.Expr(column+" + ?", delta),
+			"utime": now,
+		}),
+	}).Create(&intr).Error
+}
+
+// InsertLikeInfo 点赞，返回这一次是否真的改变了点赞状态。已经点过赞的时候什么都不做，返回 false
+func (dao *InteractionDAO) InsertLikeInfo(ctx context.Context, biz string, bizId, uid int64) (bool, error) {
+	var changed bool
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UnixMilli()
+		// 先尝试恢复取消过的点赞
+		res := tx.Model(&UserLikeBiz{}).
+			Where("uid = ? AND biz_id = ? AND biz = ? AND status = ?", uid, bizId, biz, likeStatusCancelled).
+			Updates(map[string]any{"status": likeStatusLiked, "utime": now})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			// 没有取消过的记录，插入一条。已经点过赞的时候唯一索引冲突，什么都不插入
+			res = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&UserLikeBiz{
+				Uid: uid, BizId: bizId, Biz: biz, Status: likeStatusLiked, Ctime: now, Utime: now,
+			})
+			if res.Error != nil {
+				return res.Error
+			}
+		}
+		changed = res.RowsAffected > 0
+		if !changed {
+			return nil
+		}
+		return dao.incrCnt(tx, biz, bizId, "like_cnt", 1)
+	})
+	return changed, err
+}
+
+// DeleteLikeInfo 取消点赞，返回这一次是否真的改变了点赞状态。没有点过赞的时候什么都不做，返回 false
+func (dao *InteractionDAO) DeleteLikeInfo(ctx context.Context, biz string, bizId, uid int64) (bool, error) {
+	var changed bool
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&UserLikeBiz{}).
+			Where("uid = ? AND biz_id = ? AND biz = ? AND status = ?", uid, bizId, biz, likeStatusLiked).
+			Updates(map[string]any{"status": likeStatusCancelled, "utime": time.Now().UnixMilli()})
+		if res.Error != nil {
+			return res.Error
+		}
+		changed = res.RowsAffected > 0
+		if !changed {
+			return nil
+		}
+		return dao.incrCnt(tx, biz, bizId, "like_cnt", -1)
+	})
+	return changed, err
+}
+
+// InsertCollectionBiz 把业务对象收藏到用户的收藏夹里面，返回收藏数是否变化。
+// 同一个业务对象可以被同一个用户收藏到多个收藏夹，但是收藏数按照用户计算，只有第一次收藏的时候加一。
+// 已经在这个收藏夹里面的时候什么都不做，返回 false。收藏夹不是这个用户的时候返回 ErrCollectionNotFound
+func (dao *InteractionDAO) InsertCollectionBiz(ctx context.Context, cb UserCollectionBiz) (bool, error) {
+	var changed bool
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var cnt int64
+		err := tx.Model(&Collection{}).Where("id = ? AND uid = ?", cb.Cid, cb.Uid).Count(&cnt).Error
+		if err != nil {
+			return err
+		}
+		if cnt == 0 {
+			return ErrCollectionNotFound
+		}
+		now := time.Now().UnixMilli()
+		cb.Ctime = now
+		cb.Utime = now
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&cb)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		cnt, err = dao.incrCollected(tx, cb.Biz, cb.BizId, cb.Uid, 1)
+		if err != nil || cnt > 1 {
+			return err
+		}
+		changed = true
+		return dao.incrCnt(tx, cb.Biz, cb.BizId, "collect_cnt", 1)
+	})
+	return changed, err
+}
+
+// DeleteCollectionBiz 从收藏夹 cid 里面取消收藏，返回收藏数是否变化。
+// 只有用户的最后一个收藏夹也取消了收藏的时候收藏数才减一，没有收藏过的时候返回 false
+func (dao *InteractionDAO) DeleteCollectionBiz(ctx context.Context, biz string, bizId, cid, uid int64) (bool, error) {
+	var changed bool
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("uid = ? AND biz_id = ? AND biz = ? AND cid = ?", uid, bizId, biz, cid).
+			Delete(&UserCollectionBiz{})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		cnt, err := dao.incrCollected(tx, biz, bizId, uid, -1)
+		if err != nil || cnt > 0 {
+			return err
+		}
+		changed = true
+		return dao.incrCnt(tx, biz, bizId, "collect_cnt", -1)
+	})
+	return changed, err
+}
+
+// incrCollected 修改用户把业务对象收藏到了几个收藏夹，返回修改之后的值。
+// 不能在 user_collection_bizs 上面 COUNT：REPEATABLE READ 下两个并发的事务读到的都是自己的快照，
+// 同时收藏到两个收藏夹的时候都以为自己是第一次收藏。这里先修改 user_collected_bizs 中的一行，
+// 同一个用户对同一个业务对象的收藏和取消收藏在这一行的行锁上排队，修改之后读到的是自己刚写入的值
+func (dao *InteractionDAO) incrCollected(tx *gorm.DB, biz string, bizId, uid, delta int64) (int64, error) {
+	now := time.Now().UnixMilli()
+	err := tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "uid"}, {Name: "biz_id"}, {Name: "biz"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"cnt":   gorm.Expr("cnt + ?", delta),
+			"utime": now,
+		}),
+	}).Create(&UserCollectedBiz{Uid: uid, BizId: bizId, Biz: biz, Cnt: delta, Ctime: now, Utime: now}).Error
+	if err != nil {
+		return 0, err
+	}
+	var cnt int64
+	err = tx.Model(&UserCollectedBiz{}).Select("cnt").
+		Where("uid = ? AND biz_id = ? AND biz = ?", uid, bizId, biz).Scan(&cnt).Error
+	return cnt, err
+}
+
+// bizCnt 按照业务对象分组统计的数量
+type bizCnt struct {
+	Biz   string
+	BizId int64
+	Cnt   int64
+}
+
+// purgeInteractions 删除 uids 的点赞、收藏夹和收藏记录，并且把他们点赞、收藏过的业务对象的计数减掉。
+// articleIds 这些文章的计数以及别的用户对它们的点赞、收藏记录也一起删除
+func purgeInteractions(tx *gorm.DB, uids []int64, articleBiz string, articleIds []int64) error {
+	var liked, collected []bizCnt
+	// 按照 (biz, biz_id) 的顺序修改，和 BatchIncrReadCnt 一样避免死锁
+	err := tx.Model(&UserLikeBiz{}).Select("biz, biz_id, COUNT(*) AS cnt").
+		Where("uid IN ? AND status = ?", uids, likeStatusLiked).
+		Group("biz, biz_id").Order("biz, biz_id").Scan(&liked).Error
+	if err != nil {
+		return err
+	}
+	// 收藏数按照用户计算，同一个用户收藏到多个收藏夹只算一次
+	err = tx.Model(&UserCollectedBiz{}).Select("biz, biz_id, COUNT(*) AS cnt").
+		Where("uid IN ? AND cnt > 0", uids).
+		Group("biz, biz_id").Order("biz, biz_id").Scan(&collected).Error
+	if err != nil {
+		return err
+	}
+	now := time.Now().UnixMilli()
+	for _, c := range liked {
+		if err = decrCnt(tx, c, "like_cnt", now); err != nil {
+			return err
+		}
+	}
+	for _, c := range collected {
+		if err = decrCnt(tx, c, "collect_cnt", now); err != nil {
+			return err
+		}
+	}
+	for _, model := range []any{&UserLikeBiz{}, &UserCollectionBiz{}, &UserCollectedBiz{}, &Collection{}} {
+		if err = tx.Where("uid IN ?", uids).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+	if len(articleIds) == 0 {
+		return nil
+	}
+	for _, model := range []any{&UserLikeBiz{}, &UserCollectionBiz{}, &UserCollectedBiz{}, &Interaction{}} {
+		if err = tx.Where("biz = ? AND biz_id IN ?", articleBiz, articleIds).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decrCnt 把业务对象的一个计数减掉 c.Cnt，用户点赞、收藏过说明计数已经存在，不需要 upsert
+func decrCnt(tx *gorm.DB, c bizCnt, column string, now int64) error {
+	return tx.Model(&Interaction{}).Where("biz_id = ? AND biz = ?", c.BizId, c.Biz).
+		Updates(map[string]any{column: gorm.Expr(column+" - ?", c.Cnt), "utime": now}).Error
+}
+
+// Get 查询一个业务对象的计数，还没有任何互动的时候返回全为 0 的计数
+func (dao *InteractionDAO) Get(ctx context.Context, biz string, bizId int64) (Interaction, error) {
+	var intr Interaction
+	err := dao.db.WithContext(ctx).Where("biz_id = ? AND biz = ?", bizId, biz).First(&intr).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Interaction{Biz: biz, BizId: bizId}, nil
+	}
+	return intr, err
+}
+
+// GetByIds 批量查询计数，还没有任何互动的业务对象不在结果中
+func (dao *InteractionDAO) GetByIds(ctx context.Context, biz string, bizIds []int64) ([]Interaction, error) {
+	var res []Interaction
+	if len(bizIds) == 0 {
+		return res, nil
+	}
+	err := dao.db.WithContext(ctx).Where("biz = ? AND biz_id IN ?", biz, bizIds).Find(&res).Error
+	return res, err
+}
+
+// LikedBizIds 返回 bizIds 中用户点过赞的业务对象
+func (dao *InteractionDAO) LikedBizIds(ctx context.Context, biz string, bizIds []int64, uid int64) ([]int64, error) {
+	var res []int64
+	if len(bizIds) == 0 {
+		return res, nil
+	}
+	err := dao.db.WithContext(ctx).Model(&UserLikeBiz{}).
+		Where("uid = ? AND biz = ? AND biz_id IN ? AND status = ?", uid, biz, bizIds, likeStatusLiked).
+		Pluck("biz_id", &res).Error
+	return res, err
+}
+
+// CollectedBizIds 返回 bizIds 中用户收藏过的业务对象
+func (dao *InteractionDAO) CollectedBizIds(ctx context.Context, biz string, bizIds []int64, uid int64) ([]int64, error) {
+	var res []int64
+	if len(bizIds) == 0 {
+		return res, nil
+	}
+	err := dao.db.WithContext(ctx).Model(&UserCollectionBiz{}).
+		Where("uid = ? AND biz = ? AND biz_id IN ?", uid, biz, bizIds).
+		Distinct().Pluck("biz_id", &res).Error
+	return res, err
+}
+
+// ListLikesByUid 按照 id 顺序查询用户点过赞并且没有取消的记录，导出数据的时候使用。
+// afterId 大于 0 的时候只返回 id 大于 afterId 的记录
+func (dao *InteractionDAO) ListLikesByUid(ctx context.Context, uid, afterId int64, limit int) ([]UserLikeBiz, error) {
+	var res []UserLikeBiz
+	err := dao.db.WithContext(ctx).
+		Where("uid = ? AND status = ? AND id > ?", uid, likeStatusLiked, afterId).
+		Order("id").Limit(limit).Find(&res).Error
+	return res, err
+}
+
+// ListCollectionBizsByUid 按照 id 顺序查询用户所有收藏夹里面的收藏记录，afterId 的含义和 ListLikesByUid 一样
+func (dao *InteractionDAO) ListCollectionBizsByUid(ctx context.Context, uid, afterId int64,
+	limit int) ([]UserCollectionBiz, error) {
+	var res []UserCollectionBiz
+	err := dao.db.WithContext(ctx).
+		Where("uid = ? AND id > ?", uid, afterId).
+		Order("id").Limit(limit).Find(&res).Error
+	return res, err
+}
+
+// InsertCollection 新建收藏夹，返回收藏夹的 id。重名的时候返回 ErrDuplicateCollection
+func (dao *InteractionDAO) InsertCollection(ctx context.Context, c Collection) (int64, error) {
+	now := time.Now().UnixMilli()
+	c.Ctime = now
+	c.Utime = now
+	err := dao.db.WithContext(ctx).Create(&c).Error
+	if isUniqueConflict(dao.db, err) {
+		return 0, ErrDuplicateCollection.Wrap(err)
+	}
+	return c.Id, err
+}
+
+// ListCollections 用户所有的收藏夹，按照创建的顺序排列
+func (dao *InteractionDAO) ListCollections(ctx context.Context, uid int64) ([]Collection, error) {
+	var res []Collection
+	err := dao.db.WithContext(ctx).Where("uid = ?", uid).Order("id").Find(&res).Error
+	return res, err
+}
+
+// Interaction 一个业务对象的计数
+type Interaction struct {
+	Id int64 `gorm:"primaryKey,autoIncrement"`
+	// biz_id 的区分度比 biz 高，放在联合索引的前面
+	BizId      int64  `gorm:"uniqueIndex:uk_interactions_biz_id_biz,priority:1"`
+	Biz        string `gorm:"type:varchar(128);uniqueIndex:uk_interactions_biz_id_biz,priority:2"`
+	ReadCnt    int64  `gorm:"not null;default:0"`
+	LikeCnt    int64  `gorm:"not null;default:0"`
+	CollectCnt int64  `gorm:"not null;default:0"`
+	Ctime      int64
+	Utime      int64
+}
+
+// UserLikeBiz 用户的点赞记录，一个用户对一个业务对象只有一条记录
+type UserLikeBiz struct {
+	Id    int64  `gorm:"primaryKey,autoIncrement"`
+	Uid   int64  `gorm:"uniqueIndex:uk_user_like_bizs_uid_biz_id_biz,priority:1"`
+	BizId int64  `gorm:"uniqueIndex:uk_user_like_bizs_uid_biz_id_biz,priority:2"`
+	Biz   string `gorm:"type:varchar(128);uniqueIndex:uk_user_like_bizs_uid_biz_id_biz,priority:3"`
+	// 1 已点赞，0 已取消
+	Status uint8 `gorm:"not null;default:0"`
+	Ctime  int64
+	Utime  int64
+}
+
+// Collection 用户的收藏夹
+type Collection struct {
+	Id    int64  `gorm:"primaryKey,autoIncrement"`
+	Uid   int64  `gorm:"uniqueIndex:uk_collections_uid_name,priority:1"`
+	Name  string `gorm:"type:varchar(128);uniqueIndex:uk_collections_uid_name,priority:2"`
+	Ctime int64
+	Utime int64
+}
+
+// UserCollectionBiz 用户的收藏记录，一个业务对象在用户的每一个收藏夹里面最多只有一条记录
+type UserCollectionBiz struct {
+	Id int64 `gorm:"primaryKey,autoIncrement"`
+	// 收藏夹的 id，按照收藏夹查询收藏的内容
+	Cid   int64  `gorm:"index;uniqueIndex:uk_user_collection_bizs_uid_biz_id_biz_cid,priority:4"`
+	Uid   int64  `gorm:"uniqueIndex:uk_user_collection_bizs_uid_biz_id_biz_cid,priority:1"`
+	BizId int64  `gorm:"uniqueIndex:uk_user_collection_bizs_uid_biz_id_biz_cid,priority:2"`
+	Biz   string `gorm:"type:varchar(128);uniqueIndex:uk_user_collection_bizs_uid_biz_id_biz_cid,priority:3"`
+	Ctime int64
+	Utime int64
+}
+
+// UserCollectedBiz 用户收藏过的业务对象，一个用户对一个业务对象只有一条记录，cnt 是收藏到了几个收藏夹。
+// 全部取消收藏之后 cnt 为 0，记录保留，下次收藏的时候直接修改
+type UserCollectedBiz struct {
+	Id    int64  `gorm:"primaryKey,autoIncrement"`
+	Uid   int64  `gorm:"uniqueIndex:uk_user_collected_bizs_uid_biz_id_biz,priority:1"`
+	BizId int64  `gorm:"uniqueIndex:uk_user_collected_bizs_uid_biz_id_biz,priority:2"`
+	Biz   string `gorm:"type:varchar(128);uniqueIndex:uk_user_collected_bizs_uid_biz_id_biz,priority:3"`
+	Cnt   int64  `gorm:"not null;default:0"`
+	Ctime int64
+	Utime int64
+}
@@ -0,0 +1,97 @@
+package dao
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go_homework/week_3/internal/repository/dao/migrations"
+	"go_homework/week_3/pkg/migrator"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// newMySQLTestDB 使用迁移文件在 MySQL 中建表。sqlite 的事务是串行执行的，测不出 REPEATABLE READ 下的并发问题，
+// 需要一个可以随意删表的 MySQL 库，通过环境变量 WEBOOK_TEST_MYSQL_DSN 指定，没有设置的时候跳过
+func newMySQLTestDB(t *testing.T) *gorm.DB {
+	dsn := os.Getenv("WEBOOK_TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("WEBOOK_TEST_MYSQL_DSN is not set")
+	}
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+	var tables []string
+	require.NoError(t, db.Raw("SHOW TABLES").Scan(&tables).Error)
+	for _, table := range tables {
+		require.NoError(t, db.Migrator().DropTable(table))
+	}
+	fsys, ok := migrations.ForDialect("mysql")
+	require.True(t, ok)
+	m, err := migrator.New(db, fsys)
+	require.NoError(t, err)
+	require.NoError(t, m.Up(context.Background()))
+	return db
+}
+
+// TestInteractionDAO_CollectConcurrently 同一个用户同时把一篇文章收藏到多个收藏夹、再同时全部取消，
+// 收藏数只能加一次、减一次
+func TestInteractionDAO_CollectConcurrently(t *testing.T) {
+	testCases := []struct {
+		name  string
+		newDB func(t *testing.T) *gorm.DB
+	}{
+		{name: "sqlite", newDB: newTestDB},
+		{name: "mysql", newDB: newMySQLTestDB},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := NewInteractionDAO(tc.newDB(t))
+			ctx := context.Background()
+			const uid, bizId, biz, collections = 1, 100, "article", 8
+			cids := make([]int64, 0, collections)
+			for i := 0; i < collections; i++ {
+				cid, err := d.InsertCollection(ctx, Collection{Uid: uid, Name: string(rune('a' + i))})
+				require.NoError(t, err)
+				cids = append(cids, cid)
+			}
+
+			run := func(fn func(cid int64) (bool, error)) int64 {
+				var changed atomic.Int64
+				var wg sync.WaitGroup
+				for _, cid := range cids {
+					wg.Add(1)
+					go func(cid int64) {
+						defer wg.Done()
+						ok, err := fn(cid)
+						assert.NoError(t, err)
+						if ok {
+							changed.Add(1)
+						}
+					}(cid)
+				}
+				wg.Wait()
+				return changed.Load()
+			}
+
+			changed := run(func(cid int64) (bool, error) {
+				return d.InsertCollectionBiz(ctx, UserCollectionBiz{Cid: cid, Uid: uid, BizId: bizId, Biz: biz})
+			})
+			assert.Equal(t, int64(1), changed)
+			intr, err := d.Get(ctx, biz, bizId)
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), intr.CollectCnt)
+
+			changed = run(func(cid int64) (bool, error) {
+				return d.DeleteCollectionBiz(ctx, biz, bizId, cid, uid)
+			})
+			assert.Equal(t, int64(1), changed)
+			intr, err = d.Get(ctx, biz, bizId)
+			require.NoError(t, err)
+			assert.Equal(t, int64(0), intr.CollectCnt)
+		})
+	}
+}
@@ -0,0 +1,29 @@
+// Package migrations 存放按照版本号排序的 SQL 迁移文件，由 pkg/migrator 执行。
+// 新增或者修改表结构的时候，在 mysql 和 postgres 目录下各新增一对 <版本号>_<名字>.up.sql 和 .down.sql，
+// 两个方言的版本号各自递增。不要修改已经发布的迁移文件
+package migrations
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed mysql/*.sql
+var mysqlFS embed.FS
+
+//go:embed postgres/*.sql
+var postgresFS embed.FS
+
+// ForDialect 返回某个方言的迁移文件，没有对应的迁移文件时返回 false
+func ForDialect(name string) (fs.FS, bool) {
+	switch name {
+	case "mysql":
+		sub, err := fs.Sub(mysqlFS, "mysql")
+		return sub, err == nil
+	case "postgres":
+		sub, err := fs.Sub(postgresFS, "postgres")
+		return sub, err == nil
+	default:
+		return nil, false
+	}
+}
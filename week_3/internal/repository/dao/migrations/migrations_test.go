@@ -0,0 +1,126 @@
+package migrations
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go_homework/week_3/internal/repository/dao"
+	"go_homework/week_3/pkg/migrator"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"os"
+	"testing"
+)
+
+func TestForDialect(t *testing.T) {
+	testCases := []struct {
+		dialect string
+		wantOk  bool
+	}{
+		{dialect: "mysql", wantOk: true},
+		{dialect: "postgres", wantOk: true},
+		{dialect: "sqlite", wantOk: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.dialect, func(t *testing.T) {
+			fsys, ok := ForDialect(tc.dialect)
+			require.Equal(t, tc.wantOk, ok)
+			if !ok {
+				return
+			}
+			// 文件名合法，每一个版本都有 up 和 down，版本号从 1 开始连续递增
+			ms, err := migrator.Load(fsys)
+			require.NoError(t, err)
+			require.NotEmpty(t, ms)
+			for i, m := range ms {
+				assert.Equal(t, int64(i+1), m.Version)
+				assert.NotEmpty(t, m.Down, "version %d has no down migration file", m.Version)
+			}
+		})
+	}
+}
+
+// baselineUser 最初版本通过 AutoMigrate 建的 users 表，type= 是当时的写法，没有生效，两列都是 LONGTEXT
+type baselineUser struct {
+	Id       int64  `gorm:"primaryKey,autoIncrement"`
+	Email    string `gorm:"unique"`
+	Password string
+	Nickname string `gorm:"type=varchar(128)"`
+	Birthday int64
+	AboutMe  string `gorm:"type=varchar(4096)"`
+	Ctime    int64
+	Utime    int64
+}
+
+func (baselineUser) TableName() string {
+	return "users"
+}
+
+// TestMySQL_UpgradeFromAutoMigrate 从最初 AutoMigrate 建的表开始执行全部迁移。
+// 需要一个可以随意删表的 MySQL 库，通过环境变量 WEBOOK_TEST_MYSQL_DSN 指定，
+// 例如 root:root@tcp(localhost:13316)/webook_migration_test，没有设置的时候跳过
+func TestMySQL_UpgradeFromAutoMigrate(t *testing.T) {
+	dsn := os.Getenv("WEBOOK_TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("WEBOOK_TEST_MYSQL_DSN is not set")
+	}
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+	ctx := context.Background()
+	var tables []string
+	require.NoError(t, db.Raw("SHOW TABLES").Scan(&tables).Error)
+	for _, table := range tables {
+		require.NoError(t, db.Migrator().DropTable(table))
+	}
+	require.NoError(t, db.AutoMigrate(&baselineUser{}))
+	require.NoError(t, db.Create(&baselineUser{Email: "a@qq.com", Password: "hash", Nickname: "old"}).Error)
+
+	fsys, ok := ForDialect("mysql")
+	require.True(t, ok)
+	m, err := migrator.New(db, fsys)
+	require.NoError(t, err)
+	require.NoError(t, m.Up(ctx))
+
+	// 升级之前注册的用户可以正常查询，并且没有被当成已注销的用户
+	d := dao.NewUserDAO(db)
+	u, err := d.FindByEmail(ctx, "a@qq.com")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), u.Dtime)
+	assert.Equal(t, int64(1), u.Version)
+	assert.Equal(t, "", u.Avatar)
+	assert.Equal(t, uint8(0), u.Privacy)
+	users, err := d.SearchByNicknamePrefix(ctx, "ol", 0, "", 0, 10)
+	require.NoError(t, err)
+	assert.Len(t, users, 1)
+
+	type column struct {
+		ColumnName    string  `gorm:"column:COLUMN_NAME"`
+		DataType      string  `gorm:"column:DATA_TYPE"`
+		IsNullable    string  `gorm:"column:IS_NULLABLE"`
+		ColumnDefault *string `gorm:"column:COLUMN_DEFAULT"`
+	}
+	var cols []column
+	require.NoError(t, db.Raw("SELECT COLUMN_NAME, DATA_TYPE, IS_NULLABLE, COLUMN_DEFAULT FROM information_schema.COLUMNS "+
+		"WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'users'").Scan(&cols).Error)
+	byName := make(map[string]column, len(cols))
+	for _, c := range cols {
+		byName[c.ColumnName] = c
+	}
+	for _, name := range []string{"dtime", "version", "avatar", "avatar_thumb", "privacy"} {
+		c, ok := byName[name]
+		require.True(t, ok, "column %s is missing", name)
+		assert.Equal(t, "NO", c.IsNullable, "column %s", name)
+		assert.NotNil(t, c.ColumnDefault, "column %s", name)
+	}
+	assert.Equal(t, "varchar", byName["nickname"].DataType)
+	assert.Equal(t, "varchar", byName["about_me"].DataType)
+	var idx int64
+	require.NoError(t, db.Raw("SELECT COUNT(*) FROM information_schema.STATISTICS "+
+		"WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'users' AND INDEX_NAME = 'idx_users_dtime'").
+		Scan(&idx).Error)
+	assert.Positive(t, idx)
+
+	// 再执行一次什么都不会发生
+	require.NoError(t, m.Up(ctx))
+}
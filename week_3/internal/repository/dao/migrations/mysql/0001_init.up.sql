@@ -0,0 +1,25 @@
+-- 原来的 script/mysql/init.sql 只负责建库，表结构由 AutoMigrate 在启动的时候创建。
+-- 使用 IF NOT EXISTS 是为了兼容已经通过 AutoMigrate 建好表的环境
+CREATE TABLE IF NOT EXISTS `users`
+(
+    `id`       BIGINT        NOT NULL AUTO_INCREMENT,
+    `email`    VARCHAR(191)  NULL,
+    `password` LONGTEXT      NULL,
+    `nickname` VARCHAR(128)  NULL,
+    `birthday` BIGINT        NULL,
+    `about_me` VARCHAR(4096) NULL,
+    `ctime`    BIGINT        NULL,
+    `utime`    BIGINT        NULL,
+    `dtime`    BIGINT        NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `email` (`email`),
+    KEY `idx_users_dtime` (`dtime`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
+
+-- AutoMigrate 建好的表里面 nickname 和 about_me 是 LONGTEXT（当时模型上的 tag 写成了 type=，没有生效），
+-- 这里改成上面声明的类型。必须在 0004 给 nickname 建索引之前执行，MySQL 不能直接给 TEXT 类型的列建索引。
+-- 新建的表类型本来就一致，重复 MODIFY 不会改变任何东西
+ALTER TABLE `users`
+    MODIFY COLUMN `nickname` VARCHAR(128)  NULL,
+    MODIFY COLUMN `about_me` VARCHAR(4096) NULL;
@@ -0,0 +1,3 @@
+-- 乐观锁的版本号，每次修改资料加一
+ALTER TABLE `users`
+    ADD COLUMN `version` BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,4 @@
+-- 头像原图和缩略图的 URL
+ALTER TABLE `users`
+    ADD COLUMN `avatar`       VARCHAR(512) NOT NULL DEFAULT '',
+    ADD COLUMN `avatar_thumb` VARCHAR(512) NOT NULL DEFAULT '';
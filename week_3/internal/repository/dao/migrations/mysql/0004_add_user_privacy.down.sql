@@ -0,0 +1,3 @@
+DROP INDEX `idx_users_nickname` ON `users`;
+ALTER TABLE `users`
+    DROP COLUMN `privacy`;
@@ -0,0 +1,5 @@
+-- 个人主页的可见范围：0 所有人可见，1 仅自己可见
+ALTER TABLE `users`
+    ADD COLUMN `privacy` TINYINT UNSIGNED NOT NULL DEFAULT 0;
+-- 按照昵称前缀搜索用户，InnoDB 的二级索引里面带着主键，可以直接按照 (nickname, id) 翻页
+CREATE INDEX `idx_users_nickname` ON `users` (`nickname`);
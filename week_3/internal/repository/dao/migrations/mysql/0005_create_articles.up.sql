@@ -0,0 +1,28 @@
+-- 制作库，保存作者正在编辑的版本
+CREATE TABLE `articles`
+(
+    `id`        BIGINT           NOT NULL AUTO_INCREMENT,
+    `title`     VARCHAR(1024)    NOT NULL DEFAULT '',
+    `content`   MEDIUMTEXT       NULL,
+    `author_id` BIGINT           NOT NULL,
+    `status`    TINYINT UNSIGNED NOT NULL DEFAULT 0,
+    `ctime`     BIGINT           NOT NULL,
+    `utime`     BIGINT           NOT NULL,
+    PRIMARY KEY (`id`),
+    KEY `idx_articles_author_utime` (`author_id`, `utime`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
+-- 线上库，保存最近一次发表的版本，id 和制作库中的 id 相同
+CREATE TABLE `published_articles`
+(
+    `id`        BIGINT           NOT NULL,
+    `title`     VARCHAR(1024)    NOT NULL DEFAULT '',
+    `content`   MEDIUMTEXT       NULL,
+    `author_id` BIGINT           NOT NULL,
+    `status`    TINYINT UNSIGNED NOT NULL DEFAULT 0,
+    `ctime`     BIGINT           NOT NULL,
+    `utime`     BIGINT           NOT NULL,
+    PRIMARY KEY (`id`),
+    KEY `idx_published_articles_author_id` (`author_id`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
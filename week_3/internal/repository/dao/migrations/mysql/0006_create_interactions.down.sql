@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS `user_collection_bizs`;
+DROP TABLE IF EXISTS `collections`;
+DROP TABLE IF EXISTS `user_like_bizs`;
+DROP TABLE IF EXISTS `interactions`;
@@ -0,0 +1,56 @@
+-- 业务对象的阅读、点赞和收藏计数，业务对象用 (biz, biz_id) 表示
+CREATE TABLE `interactions`
+(
+    `id`          BIGINT       NOT NULL AUTO_INCREMENT,
+    `biz_id`      BIGINT       NOT NULL,
+    `biz`         VARCHAR(128) NOT NULL,
+    `read_cnt`    BIGINT       NOT NULL DEFAULT 0,
+    `like_cnt`    BIGINT       NOT NULL DEFAULT 0,
+    `collect_cnt` BIGINT       NOT NULL DEFAULT 0,
+    `ctime`       BIGINT       NOT NULL,
+    `utime`       BIGINT       NOT NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_interactions_biz_id_biz` (`biz_id`, `biz`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
+-- 用户的点赞记录，取消点赞只修改 status：1 已点赞，0 已取消
+CREATE TABLE `user_like_bizs`
+(
+    `id`     BIGINT           NOT NULL AUTO_INCREMENT,
+    `uid`    BIGINT           NOT NULL,
+    `biz_id` BIGINT           NOT NULL,
+    `biz`    VARCHAR(128)     NOT NULL,
+    `status` TINYINT UNSIGNED NOT NULL DEFAULT 0,
+    `ctime`  BIGINT           NOT NULL,
+    `utime`  BIGINT           NOT NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_user_like_bizs_uid_biz_id_biz` (`uid`, `biz_id`, `biz`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
+-- 用户的收藏夹，同一个用户的收藏夹不能重名
+CREATE TABLE `collections`
+(
+    `id`    BIGINT       NOT NULL AUTO_INCREMENT,
+    `uid`   BIGINT       NOT NULL,
+    `name`  VARCHAR(128) NOT NULL,
+    `ctime` BIGINT       NOT NULL,
+    `utime` BIGINT       NOT NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_collections_uid_name` (`uid`, `name`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
+-- 用户的收藏记录，一个业务对象只能被同一个用户收藏一次
+CREATE TABLE `user_collection_bizs`
+(
+    `id`     BIGINT       NOT NULL AUTO_INCREMENT,
+    `cid`    BIGINT       NOT NULL,
+    `uid`    BIGINT       NOT NULL,
+    `biz_id` BIGINT       NOT NULL,
+    `biz`    VARCHAR(128) NOT NULL,
+    `ctime`  BIGINT       NOT NULL,
+    `utime`  BIGINT       NOT NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_user_collection_bizs_uid_biz_id_biz` (`uid`, `biz_id`, `biz`),
+    KEY `idx_user_collection_bizs_cid` (`cid`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,4 @@
+DROP INDEX `idx_published_articles_author_ctime` ON `published_articles`;
+DROP TABLE IF EXISTS `feed_push_events`;
+DROP TABLE IF EXISTS `follow_statistics`;
+DROP TABLE IF EXISTS `follow_relations`;
@@ -0,0 +1,42 @@
+-- 关注关系，取消关注只修改 status：1 关注中，0 已取消
+CREATE TABLE `follow_relations`
+(
+    `id`       BIGINT           NOT NULL AUTO_INCREMENT,
+    `follower` BIGINT           NOT NULL,
+    `followee` BIGINT           NOT NULL,
+    `status`   TINYINT UNSIGNED NOT NULL DEFAULT 0,
+    `ctime`    BIGINT           NOT NULL,
+    `utime`    BIGINT           NOT NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_follow_relations_follower_followee` (`follower`, `followee`),
+    KEY `idx_follow_relations_followee` (`followee`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
+-- 每个用户的关注数和粉丝数，和关注关系在同一个事务里面修改
+CREATE TABLE `follow_statistics`
+(
+    `id`        BIGINT NOT NULL AUTO_INCREMENT,
+    `uid`       BIGINT NOT NULL,
+    `followers` BIGINT NOT NULL DEFAULT 0,
+    `followees` BIGINT NOT NULL DEFAULT 0,
+    `ctime`     BIGINT NOT NULL,
+    `utime`     BIGINT NOT NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `idx_follow_statistics_uid` (`uid`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
+-- 关注流的收件箱，粉丝少的作者发表文章的时候推送给每一个粉丝
+CREATE TABLE `feed_push_events`
+(
+    `id`         BIGINT NOT NULL AUTO_INCREMENT,
+    `uid`        BIGINT NOT NULL,
+    `article_id` BIGINT NOT NULL,
+    `author_id`  BIGINT NOT NULL,
+    `ctime`      BIGINT NOT NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_feed_push_events_uid_article_id` (`uid`, `article_id`),
+    KEY `idx_feed_push_events_uid_ctime` (`uid`, `ctime`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
+-- 拉模式按照作者查询线上库，再按照第一次发表的时间排序
+CREATE INDEX `idx_published_articles_author_ctime` ON `published_articles` (`author_id`, `ctime`);
@@ -0,0 +1,17 @@
+-- 评论，root_id 为 0 的是顶层评论，其余的是回复：root_id 是所在的顶层评论，parent_id 是直接回复的评论
+CREATE TABLE `comments`
+(
+    `id`        BIGINT        NOT NULL AUTO_INCREMENT,
+    `uid`       BIGINT        NOT NULL,
+    `biz_id`    BIGINT        NOT NULL,
+    `biz`       VARCHAR(128)  NOT NULL,
+    `root_id`   BIGINT        NOT NULL DEFAULT 0,
+    `parent_id` BIGINT        NOT NULL DEFAULT 0,
+    `content`   VARCHAR(4096) NOT NULL DEFAULT '',
+    `ctime`     BIGINT        NOT NULL,
+    `utime`     BIGINT        NOT NULL,
+    PRIMARY KEY (`id`),
+    KEY `idx_comments_biz_id_biz_root_id` (`biz_id`, `biz`, `root_id`),
+    KEY `idx_comments_root_id` (`root_id`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
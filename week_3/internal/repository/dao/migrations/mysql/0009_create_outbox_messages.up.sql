@@ -0,0 +1,11 @@
+-- 事务性发件箱，和业务数据在同一个事务里面写入，投递成功之后删除
+CREATE TABLE `outbox_messages`
+(
+    `id`      BIGINT       NOT NULL AUTO_INCREMENT,
+    `topic`   VARCHAR(128) NOT NULL DEFAULT '',
+    `msg_key` VARCHAR(128) NOT NULL DEFAULT '',
+    `payload` TEXT         NOT NULL,
+    `ctime`   BIGINT       NOT NULL,
+    PRIMARY KEY (`id`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
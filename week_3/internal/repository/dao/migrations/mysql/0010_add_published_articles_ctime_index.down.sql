@@ -0,0 +1 @@
+DROP INDEX `idx_published_articles_ctime` ON `published_articles`;
@@ -0,0 +1,2 @@
+-- 计算热榜的时候按照 (ctime, id) 遍历最近一段时间内发表的文章
+CREATE INDEX `idx_published_articles_ctime` ON `published_articles` (`ctime`);
@@ -0,0 +1,4 @@
+-- 用户把同一个业务对象收藏到了多个收藏夹的时候，需要先删掉多余的记录才能回滚
+ALTER TABLE `user_collection_bizs`
+    DROP INDEX `uk_user_collection_bizs_uid_biz_id_biz_cid`,
+    ADD UNIQUE KEY `uk_user_collection_bizs_uid_biz_id_biz` (`uid`, `biz_id`, `biz`);
@@ -0,0 +1,4 @@
+-- 同一个业务对象可以收藏到同一个用户的多个收藏夹里面，唯一索引加上收藏夹 id
+ALTER TABLE `user_collection_bizs`
+    DROP INDEX `uk_user_collection_bizs_uid_biz_id_biz`,
+    ADD UNIQUE KEY `uk_user_collection_bizs_uid_biz_id_biz_cid` (`uid`, `biz_id`, `biz`, `cid`);
@@ -0,0 +1 @@
+DROP INDEX `idx_comments_uid` ON `comments`;
@@ -0,0 +1,2 @@
+-- 导出用户的数据时按照评论的人查询评论
+CREATE INDEX `idx_comments_uid` ON `comments` (`uid`);
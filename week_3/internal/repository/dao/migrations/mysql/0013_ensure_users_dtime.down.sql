@@ -0,0 +1 @@
+-- 无法区分 dtime 是这个版本加上的还是原来就有的，回滚的时候保留这一列和索引
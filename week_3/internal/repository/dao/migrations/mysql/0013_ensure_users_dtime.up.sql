@@ -0,0 +1,30 @@
+-- dtime 是在改用迁移文件之前通过 AutoMigrate 加上的，0001 的 CREATE TABLE IF NOT EXISTS 不会修改已有的表，
+-- 只用过最初版本 AutoMigrate 的库里面没有这一列。MySQL 不支持 ADD COLUMN IF NOT EXISTS，
+-- 所以先查 information_schema 再决定执行哪条语句。
+-- version、avatar、avatar_thumb 和 privacy 由 0002 到 0004 添加，都是 NOT NULL 带默认值，已有的行不需要处理
+SET @webook_sql = (SELECT IF(COUNT(*) = 0,
+                             'ALTER TABLE `users` ADD COLUMN `dtime` BIGINT NOT NULL DEFAULT 0',
+                             'DO 0')
+                   FROM information_schema.COLUMNS
+                   WHERE TABLE_SCHEMA = DATABASE()
+                     AND TABLE_NAME = 'users'
+                     AND COLUMN_NAME = 'dtime');
+PREPARE webook_stmt FROM @webook_sql;
+EXECUTE webook_stmt;
+DEALLOCATE PREPARE webook_stmt;
+-- AutoMigrate 加的列允许 NULL，加列之前的行是 NULL，匹配不上 dtime = 0
+UPDATE `users`
+SET `dtime` = 0
+WHERE `dtime` IS NULL;
+ALTER TABLE `users`
+    MODIFY COLUMN `dtime` BIGINT NOT NULL DEFAULT 0;
+SET @webook_sql = (SELECT IF(COUNT(*) = 0,
+                             'CREATE INDEX `idx_users_dtime` ON `users` (`dtime`)',
+                             'DO 0')
+                   FROM information_schema.STATISTICS
+                   WHERE TABLE_SCHEMA = DATABASE()
+                     AND TABLE_NAME = 'users'
+                     AND INDEX_NAME = 'idx_users_dtime');
+PREPARE webook_stmt FROM @webook_sql;
+EXECUTE webook_stmt;
+DEALLOCATE PREPARE webook_stmt;
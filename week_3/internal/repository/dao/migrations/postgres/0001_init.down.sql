@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS outbox_messages;
+DROP TABLE IF EXISTS comments;
+DROP TABLE IF EXISTS feed_push_events;
+DROP TABLE IF EXISTS follow_statistics;
+DROP TABLE IF EXISTS follow_relations;
+DROP TABLE IF EXISTS user_collection_bizs;
+DROP TABLE IF EXISTS collections;
+DROP TABLE IF EXISTS user_like_bizs;
+DROP TABLE IF EXISTS interactions;
+DROP TABLE IF EXISTS published_articles;
+DROP TABLE IF EXISTS articles;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,167 @@
+-- Postgres 没有 AutoMigrate 建表的历史包袱，直接按照 MySQL 执行完 0013 之后的表结构建表，
+-- 之后的表结构变更和 MySQL 一样在这个目录下新增迁移文件。
+-- 索引的名字在整个 schema 里面唯一，所以都带上表名
+CREATE TABLE users
+(
+    id           BIGSERIAL     NOT NULL,
+    email        VARCHAR(191)  NULL,
+    password     TEXT          NULL,
+    nickname     VARCHAR(128)  NULL,
+    birthday     BIGINT        NULL,
+    about_me     VARCHAR(4096) NULL,
+    ctime        BIGINT        NULL,
+    utime        BIGINT        NULL,
+    dtime        BIGINT        NOT NULL DEFAULT 0,
+    version      BIGINT        NOT NULL DEFAULT 1,
+    avatar       VARCHAR(512)  NOT NULL DEFAULT '',
+    avatar_thumb VARCHAR(512)  NOT NULL DEFAULT '',
+    privacy      SMALLINT      NOT NULL DEFAULT 0,
+    PRIMARY KEY (id),
+    CONSTRAINT uk_users_email UNIQUE (email)
+);
+CREATE INDEX idx_users_dtime ON users (dtime);
+CREATE INDEX idx_users_nickname ON users (nickname);
+
+CREATE TABLE articles
+(
+    id        BIGSERIAL     NOT NULL,
+    title     VARCHAR(1024) NOT NULL DEFAULT '',
+    content   TEXT          NULL,
+    author_id BIGINT        NOT NULL,
+    status    SMALLINT      NOT NULL DEFAULT 0,
+    ctime     BIGINT        NOT NULL,
+    utime     BIGINT        NOT NULL,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_articles_author_utime ON articles (author_id, utime);
+
+CREATE TABLE published_articles
+(
+    id        BIGINT        NOT NULL,
+    title     VARCHAR(1024) NOT NULL DEFAULT '',
+    content   TEXT          NULL,
+    author_id BIGINT        NOT NULL,
+    status    SMALLINT      NOT NULL DEFAULT 0,
+    ctime     BIGINT        NOT NULL,
+    utime     BIGINT        NOT NULL,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_published_articles_author_id ON published_articles (author_id);
+CREATE INDEX idx_published_articles_author_ctime ON published_articles (author_id, ctime);
+CREATE INDEX idx_published_articles_ctime ON published_articles (ctime);
+
+CREATE TABLE interactions
+(
+    id          BIGSERIAL    NOT NULL,
+    biz_id      BIGINT       NOT NULL,
+    biz         VARCHAR(128) NOT NULL,
+    read_cnt    BIGINT       NOT NULL DEFAULT 0,
+    like_cnt    BIGINT       NOT NULL DEFAULT 0,
+    collect_cnt BIGINT       NOT NULL DEFAULT 0,
+    ctime       BIGINT       NOT NULL,
+    utime       BIGINT       NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT uk_interactions_biz_id_biz UNIQUE (biz_id, biz)
+);
+
+CREATE TABLE user_like_bizs
+(
+    id     BIGSERIAL    NOT NULL,
+    uid    BIGINT       NOT NULL,
+    biz_id BIGINT       NOT NULL,
+    biz    VARCHAR(128) NOT NULL,
+    status SMALLINT     NOT NULL DEFAULT 0,
+    ctime  BIGINT       NOT NULL,
+    utime  BIGINT       NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT uk_user_like_bizs_uid_biz_id_biz UNIQUE (uid, biz_id, biz)
+);
+
+CREATE TABLE collections
+(
+    id    BIGSERIAL    NOT NULL,
+    uid   BIGINT       NOT NULL,
+    name  VARCHAR(128) NOT NULL,
+    ctime BIGINT       NOT NULL,
+    utime BIGINT       NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT uk_collections_uid_name UNIQUE (uid, name)
+);
+
+CREATE TABLE user_collection_bizs
+(
+    id     BIGSERIAL    NOT NULL,
+    cid    BIGINT       NOT NULL,
+    uid    BIGINT       NOT NULL,
+    biz_id BIGINT       NOT NULL,
+    biz    VARCHAR(128) NOT NULL,
+    ctime  BIGINT       NOT NULL,
+    utime  BIGINT       NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT uk_user_collection_bizs_uid_biz_id_biz_cid UNIQUE (uid, biz_id, biz, cid)
+);
+CREATE INDEX idx_user_collection_bizs_cid ON user_collection_bizs (cid);
+
+CREATE TABLE follow_relations
+(
+    id       BIGSERIAL NOT NULL,
+    follower BIGINT    NOT NULL,
+    followee BIGINT    NOT NULL,
+    status   SMALLINT  NOT NULL DEFAULT 0,
+    ctime    BIGINT    NOT NULL,
+    utime    BIGINT    NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT uk_follow_relations_follower_followee UNIQUE (follower, followee)
+);
+CREATE INDEX idx_follow_relations_followee ON follow_relations (followee);
+
+CREATE TABLE follow_statistics
+(
+    id        BIGSERIAL NOT NULL,
+    uid       BIGINT    NOT NULL,
+    followers BIGINT    NOT NULL DEFAULT 0,
+    followees BIGINT    NOT NULL DEFAULT 0,
+    ctime     BIGINT    NOT NULL,
+    utime     BIGINT    NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT idx_follow_statistics_uid UNIQUE (uid)
+);
+
+CREATE TABLE feed_push_events
+(
+    id         BIGSERIAL NOT NULL,
+    uid        BIGINT    NOT NULL,
+    article_id BIGINT    NOT NULL,
+    author_id  BIGINT    NOT NULL,
+    ctime      BIGINT    NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT uk_feed_push_events_uid_article_id UNIQUE (uid, article_id)
+);
+CREATE INDEX idx_feed_push_events_uid_ctime ON feed_push_events (uid, ctime);
+
+CREATE TABLE comments
+(
+    id        BIGSERIAL     NOT NULL,
+    uid       BIGINT        NOT NULL,
+    biz_id    BIGINT        NOT NULL,
+    biz       VARCHAR(128)  NOT NULL,
+    root_id   BIGINT        NOT NULL DEFAULT 0,
+    parent_id BIGINT        NOT NULL DEFAULT 0,
+    content   VARCHAR(4096) NOT NULL DEFAULT '',
+    ctime     BIGINT        NOT NULL,
+    utime     BIGINT        NOT NULL,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_comments_biz_id_biz_root_id ON comments (biz_id, biz, root_id);
+CREATE INDEX idx_comments_root_id ON comments (root_id);
+CREATE INDEX idx_comments_uid ON comments (uid);
+
+CREATE TABLE outbox_messages
+(
+    id      BIGSERIAL    NOT NULL,
+    topic   VARCHAR(128) NOT NULL DEFAULT '',
+    msg_key VARCHAR(128) NOT NULL DEFAULT '',
+    payload TEXT         NOT NULL,
+    ctime   BIGINT       NOT NULL,
+    PRIMARY KEY (id)
+);
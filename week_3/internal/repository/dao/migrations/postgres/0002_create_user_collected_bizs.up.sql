@@ -0,0 +1,19 @@
+-- 用户收藏过的业务对象，cnt 是收藏到了几个收藏夹。收藏和取消收藏都先修改这一行，
+-- 同一个用户对同一个业务对象的并发操作在行锁上排队，收藏数不会多加或者少减
+CREATE TABLE user_collected_bizs
+(
+    id     BIGSERIAL    NOT NULL,
+    uid    BIGINT       NOT NULL,
+    biz_id BIGINT       NOT NULL,
+    biz    VARCHAR(128) NOT NULL,
+    cnt    BIGINT       NOT NULL DEFAULT 0,
+    ctime  BIGINT       NOT NULL,
+    utime  BIGINT       NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT uk_user_collected_bizs_uid_biz_id_biz UNIQUE (uid, biz_id, biz)
+);
+-- 根据已有的收藏记录生成
+INSERT INTO user_collected_bizs (uid, biz_id, biz, cnt, ctime, utime)
+SELECT uid, biz_id, biz, COUNT(*), MIN(ctime), MAX(utime)
+FROM user_collection_bizs
+GROUP BY uid, biz_id, biz;
@@ -0,0 +1,57 @@
+package dao
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+	"time"
+)
+
+// OutboxDAO 事务性发件箱。业务数据和事件在同一个事务里面写入，
+// 再由 relay 按照 id 顺序读出来投递，投递成功之后删除
+type OutboxDAO struct {
+	db *gorm.DB
+}
+
+func NewOutboxDAO(db *gorm.DB) *OutboxDAO {
+	return &OutboxDAO{db: db}
+}
+
+// insertOutbox 在业务的事务 tx 里面写入事件
+func insertOutbox(tx *gorm.DB, msgs ...OutboxMessage) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	now := time.Now().UnixMilli()
+	for i := range msgs {
+		msgs[i].Ctime = now
+	}
+	return tx.Create(&msgs).Error
+}
+
+// FindPending 按照 id 顺序查询还没有投递的事件。
+// 读主库，避免从库延迟导致刚刚提交的事件晚投递，或者已经删除的事件重复投递
+func (dao *OutboxDAO) FindPending(ctx context.Context, limit int) ([]OutboxMessage, error) {
+	var res []OutboxMessage
+	err := dao.db.WithContext(ctx).Clauses(dbresolver.Write).
+		Order("id").Limit(limit).Find(&res).Error
+	return res, err
+}
+
+// DeleteByIds 删除已经投递的事件
+func (dao *OutboxDAO) DeleteByIds(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return dao.db.WithContext(ctx).Where("id IN ?", ids).Delete(&OutboxMessage{}).Error
+}
+
+// OutboxMessage 发件箱中的事件
+type OutboxMessage struct {
+	Id    int64  `gorm:"primaryKey,autoIncrement"`
+	Topic string `gorm:"type:varchar(128)"`
+	// key 是 MySQL 的保留字
+	Key     string `gorm:"column:msg_key;type:varchar(128)"`
+	Payload string `gorm:"type:text"`
+	Ctime   int64
+}
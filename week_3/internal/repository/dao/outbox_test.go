@@ -0,0 +1,150 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"testing"
+)
+
+// outboxTopics 发件箱中所有事件的 topic，按照写入的顺序
+func outboxTopics(t *testing.T, db *gorm.DB) []string {
+	var topics []string
+	require.NoError(t, db.Model(&OutboxMessage{}).Order("id").Pluck("topic", &topics).Error)
+	return topics
+}
+
+func TestUserDAO_Outbox(t *testing.T) {
+	testCases := []struct {
+		name string
+		// 在已经注册了 a@qq.com（版本号为 1）的数据库上执行
+		op      func(ctx context.Context, d *UserDAO, uid int64) error
+		wantErr error
+		// 期望发件箱中新增的事件，为空表示事务回滚了，没有写入
+		wantTopics []string
+	}{
+		{
+			name: "insert",
+			op: func(ctx context.Context, d *UserDAO, uid int64) error {
+				return d.Insert(ctx, User{Email: "b@qq.com"}, func(u User) (OutboxMessage, error) {
+					return OutboxMessage{Topic: "registered", Key: "b", Payload: "{}"}, nil
+				})
+			},
+			wantTopics: []string{"registered"},
+		},
+		{
+			name: "insert duplicate email",
+			op: func(ctx context.Context, d *UserDAO, uid int64) error {
+				return d.Insert(ctx, User{Email: "a@qq.com"}, func(u User) (OutboxMessage, error) {
+					return OutboxMessage{Topic: "registered", Payload: "{}"}, nil
+				})
+			},
+			wantErr: ErrDuplicateEmail,
+		},
+		{
+			name: "update by id",
+			op: func(ctx context.Context, d *UserDAO, uid int64) error {
+				return d.UpdateById(ctx, User{Id: uid, Nickname: "n", Version: 1},
+					OutboxMessage{Topic: "updated", Payload: "{}"})
+			},
+			wantTopics: []string{"updated"},
+		},
+		{
+			name: "update by id version conflict",
+			op: func(ctx context.Context, d *UserDAO, uid int64) error {
+				return d.UpdateById(ctx, User{Id: uid, Nickname: "n", Version: 2},
+					OutboxMessage{Topic: "updated", Payload: "{}"})
+			},
+			wantErr: ErrVersionConflict,
+		},
+		{
+			name: "update fields",
+			op: func(ctx context.Context, d *UserDAO, uid int64) error {
+				return d.UpdateFieldsById(ctx, User{Id: uid, Nickname: "n", Version: 1}, []string{"nickname"},
+					OutboxMessage{Topic: "updated", Payload: "{}"})
+			},
+			wantTopics: []string{"updated"},
+		},
+		{
+			name: "update fields version conflict",
+			op: func(ctx context.Context, d *UserDAO, uid int64) error {
+				return d.UpdateFieldsById(ctx, User{Id: uid, Nickname: "n", Version: 2}, []string{"nickname"},
+					OutboxMessage{Topic: "updated", Payload: "{}"})
+			},
+			wantErr: ErrVersionConflict,
+		},
+		{
+			name: "soft delete",
+			op: func(ctx context.Context, d *UserDAO, uid int64) error {
+				return d.SoftDeleteById(ctx, uid, "deleted@invalid", OutboxMessage{Topic: "deleted", Payload: "{}"})
+			},
+			wantTopics: []string{"deleted"},
+		},
+		{
+			name: "soft delete missing user",
+			op: func(ctx context.Context, d *UserDAO, uid int64) error {
+				return d.SoftDeleteById(ctx, uid+1, "deleted@invalid", OutboxMessage{Topic: "deleted", Payload: "{}"})
+			},
+			wantErr: ErrRecordNotFound,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := newTestDB(t)
+			d := NewUserDAO(db)
+			ctx := context.Background()
+			require.NoError(t, d.Insert(ctx, User{Email: "a@qq.com"}, noOutbox))
+			u, err := d.FindByEmail(ctx, "a@qq.com")
+			require.NoError(t, err)
+			require.NoError(t, db.Where("1 = 1").Delete(&OutboxMessage{}).Error)
+
+			err = tc.op(ctx, d, u.Id)
+			assert.ErrorIs(t, err, tc.wantErr)
+			topics := outboxTopics(t, db)
+			if len(tc.wantTopics) == 0 {
+				assert.Empty(t, topics)
+				return
+			}
+			assert.Equal(t, tc.wantTopics, topics)
+		})
+	}
+}
+
+// TestUserDAO_Insert_OutboxRollback 写发件箱失败的时候用户也不会被创建
+func TestUserDAO_Insert_OutboxRollback(t *testing.T) {
+	db := newTestDB(t)
+	d := NewUserDAO(db)
+	ctx := context.Background()
+	wantErr := errors.New("marshal failed")
+	err := d.Insert(ctx, User{Email: "a@qq.com"}, func(u User) (OutboxMessage, error) {
+		// 这时候用户已经插入，事务还没有提交
+		assert.True(t, u.Id > 0)
+		return OutboxMessage{}, wantErr
+	})
+	assert.ErrorIs(t, err, wantErr)
+	_, err = d.FindByEmail(ctx, "a@qq.com")
+	assert.ErrorIs(t, err, ErrRecordNotFound)
+	assert.Empty(t, outboxTopics(t, db))
+}
+
+func TestOutboxDAO(t *testing.T) {
+	db := newTestDB(t)
+	d := NewOutboxDAO(db)
+	ctx := context.Background()
+	require.NoError(t, insertOutbox(db,
+		OutboxMessage{Topic: "t1", Key: "k", Payload: "1"},
+		OutboxMessage{Topic: "t2", Key: "k", Payload: "2"},
+		OutboxMessage{Topic: "t3", Key: "k", Payload: "3"}))
+
+	msgs, err := d.FindPending(ctx, 2)
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+	assert.Equal(t, "t1", msgs[0].Topic)
+	assert.Equal(t, "t2", msgs[1].Topic)
+	assert.True(t, msgs[0].Ctime > 0)
+
+	require.NoError(t, d.DeleteByIds(ctx, []int64{msgs[0].Id, msgs[1].Id}))
+	assert.Equal(t, []string{"t3"}, outboxTopics(t, db))
+}
@@ -0,0 +1,53 @@
+package dao
+
+import (
+	"sync"
+	"time"
+)
+
+// stickyWrites 记录最近写过的 key。配置了从库的时候，主从同步有延迟，
+// 刚写完马上读从库可能读到旧数据，所以在 window 时间内这些 key 的读请求走主库。
+// 记录只保存在当前进程的内存里面，只对同一个实例上的读写生效：部署了多个实例的时候，
+// 写请求和紧接着的读请求被负载均衡到不同的实例上，读请求仍然可能走从库读到旧数据
+type stickyWrites struct {
+	window time.Duration
+	mu     sync.Mutex
+	keys   map[int64]time.Time
+}
+
+// 记录的 key 超过这个数量的时候清理一次过期的记录
+const stickySweepThreshold = 10000
+
+func newStickyWrites(window time.Duration) *stickyWrites {
+	return &stickyWrites{window: window, keys: make(map[int64]time.Time)}
+}
+
+// mark 记录 key 刚刚被写过
+func (s *stickyWrites) mark(key int64) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.keys) >= stickySweepThreshold {
+		for k, expire := range s.keys {
+			if now.After(expire) {
+				delete(s.keys, k)
+			}
+		}
+	}
+	s.keys[key] = now.Add(s.window)
+}
+
+// recent 判断 key 是否在 window 时间内被写过
+func (s *stickyWrites) recent(key int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expire, ok := s.keys[key]
+	if !ok {
+		return false
+	}
+	if time.Now().After(expire) {
+		delete(s.keys, key)
+		return false
+	}
+	return true
+}
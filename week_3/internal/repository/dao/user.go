@@ -3,38 +3,58 @@ package dao
 import (
 	"context"
 	"errors"
-	"github.com/go-sql-driver/mysql"
+	"fmt"
+	"go_homework/week_3/internal/errs"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/plugin/dbresolver"
+	"strings"
 	"time"
 )
 
 var (
-	ErrDuplicateEmail = errors.New("email already exists")
-	ErrRecordNotFound = gorm.ErrRecordNotFound
+	ErrDuplicateEmail = errs.Conflict(errs.CodeDuplicateEmail, "Email is already exist")
+	ErrRecordNotFound = errs.NotFound(errs.CodeUserNotFound, "User not found")
+	// ErrVersionConflict 更新时携带的版本号已经过期
+	ErrVersionConflict = errs.Conflict(errs.CodeVersionConflict,
+		"The profile has been modified elsewhere, please reload and try again")
 )
 
+// 主从同步延迟的上限，用户更新资料之后这段时间内在同一个实例上读自己的资料走主库
+const readYourWritesWindow = 5 * time.Second
+
 type UserDAO struct {
-	db *gorm.DB
+	db     *gorm.DB
+	sticky *stickyWrites
 }
 
 // NewUserDAO NewUserDao 函数创建并返回一个 UserDAO 类型的指针。该指针包含了一个指向 gorm.DB 类型的指针字段 db。
+// 如果 db 注册了 dbresolver，读请求默认走从库，写请求走主库
 func NewUserDAO(db *gorm.DB) *UserDAO {
-	return &UserDAO{db: db}
+	return &UserDAO{db: db, sticky: newStickyWrites(readYourWritesWindow)}
 }
 
 // Insert 方法插入一条用户记录到数据库中，确保数据完整性和一致性。如果邮箱已存在，返回 ErrDuplicateEmail。
-func (dao *UserDAO) Insert(ctx context.Context, u User) error {
+// outbox 根据插入之后的用户（带有数据库生成的 id）生成事件，和用户在同一个事务里面写入发件箱
+func (dao *UserDAO) Insert(ctx context.Context, u User, outbox func(u User) (OutboxMessage, error)) error {
 	now := time.Now().UnixMilli()
 	u.Ctime = now
 	u.Utime = now
-	err := dao.db.WithContext(ctx).Create(&u).Error
-	var me *mysql.MySQLError
-	if errors.As(err, &me) {
-		const duplicateErr uint16 = 1062
-		if me.Number == duplicateErr {
-			// 用户冲突，邮箱冲突
-			return ErrDuplicateEmail
+	u.Version = 1
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&u).Error; err != nil {
+			return err
+		}
+		msg, err := outbox(u)
+		if err != nil {
+			return err
 		}
+		return insertOutbox(tx, msg)
+	})
+	// 不同数据库的唯一索引冲突错误不一样，交给 isUniqueConflict 按照方言判断
+	if isUniqueConflict(dao.db, err) {
+		// 用户冲突，邮箱冲突
+		return ErrDuplicateEmail.Wrap(err)
 	}
 	return err
 }
@@ -44,37 +64,266 @@ func (dao *UserDAO) FindByEmail(ctx context.Context, email string) (User, error)
 	// 定义变量 u，类型为 User
 	var u User
 	// 通过数据库操作，db.WithContext(ctx) 设置上下文，Where 条件查找邮箱，First 找到第一条匹配记录，并将结果赋给 u
-	err := dao.db.WithContext(ctx).Where("email =?", email).First(&u).Error
-	// 返回查询到的用户信息 u 和空错误信息
-	return u, err
+	// 已经注销的用户不会被查询到
+	err := dao.db.WithContext(ctx).Where("email =? AND dtime = 0", email).First(&u).Error
+	// 返回查询到的用户信息 u，没有找到的时候返回 ErrRecordNotFound
+	return u, translateNotFound(err)
+}
+
+// UpdateById 根据给定的用户标识更新数据库中的用户信息。
+// 只有数据库中的版本号和 entity.Version 一致的时候才会更新，否则返回 ErrVersionConflict。
+// 更新成功的时候 msg 在同一个事务里面写入发件箱
+func (dao *UserDAO) UpdateById(ctx context.Context, entity User, msg OutboxMessage) error {
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return dao.updateById(tx, entity, msg)
+	})
 }
 
-// UpdateById 根据给定的用户标识更新数据库中的用户信息
-func (dao *UserDAO) UpdateById(ctx context.Context, entity User) error {
-	// 使用 GORM 的 Model 函数指定要更新的表和条件
-	return dao.db.WithContext(ctx).Model(&entity).Where("id =?", entity.Id).
+func (dao *UserDAO) updateById(tx *gorm.DB, entity User, msg OutboxMessage) error {
+	// 使用 GORM 的 Model 函数指定要更新的表和条件，版本号作为乐观锁的条件
+	res := tx.Model(&User{}).
+		Where("id =? AND version =? AND dtime = 0", entity.Id, entity.Version).
 		// 使用 Updates 函数构建更新字段的映射
 		Updates(map[string]any{
-			"utime":    time.Now().UnixMilli(), // 更新用户的最后更新时间戳
-			"nickname": entity.Nickname,        // 更新用户的昵称
-			"birthday": entity.Birthday,        // 更新用户的生日
-			"about_me": entity.AboutMe,         // 更新用户的个性签名
+			"utime":    time.Now().UnixMilli(),   // 更新用户的最后更新时间戳
+			"nickname": entity.Nickname,          // 更新用户的昵称
+			"birthday": entity.Birthday,          // 更新用户的生日
+			"about_me": entity.AboutMe,           // 更新用户的个性签名
+			"version":  gorm.Expr("version + 1"), // 版本号加一
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	// 无论更新成功还是版本冲突，接下来一小段时间内读这个用户都走主库，
+	// 保证用户能读到自己刚刚修改的资料，或者读到导致冲突的最新资料
+	dao.sticky.mark(entity.Id)
+	if res.RowsAffected == 0 {
+		return ErrVersionConflict
+	}
+	return insertOutbox(tx, msg)
+}
+
+// UpdateFieldsById 只更新 columns 中列出的列，其余列保持不变。
+// entity.Version 大于 0 的时候以版本号作为乐观锁的条件，版本号过期返回 ErrVersionConflict。
+// 更新成功的时候 msg 在同一个事务里面写入发件箱
+func (dao *UserDAO) UpdateFieldsById(ctx context.Context, entity User, columns []string, msg OutboxMessage) error {
+	values := map[string]any{
+		"nickname": entity.Nickname,
+		"birthday": entity.Birthday,
+		"about_me": entity.AboutMe,
+		"privacy":  entity.Privacy,
+	}
+	updates := map[string]any{
+		"utime":   time.Now().UnixMilli(),
+		"version": gorm.Expr("version + 1"),
+	}
+	for _, col := range columns {
+		val, ok := values[col]
+		if !ok {
+			return fmt.Errorf("dao: column %s can not be updated", col)
+		}
+		updates[col] = val
+	}
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		db := tx.Model(&User{}).Where("id =? AND dtime = 0", entity.Id)
+		if entity.Version > 0 {
+			db = db.Where("version =?", entity.Version)
+		}
+		res := db.Updates(updates)
+		if res.Error != nil {
+			return res.Error
+		}
+		dao.sticky.mark(entity.Id)
+		if res.RowsAffected == 0 {
+			if entity.Version > 0 {
+				return ErrVersionConflict
+			}
+			return ErrRecordNotFound
+		}
+		return insertOutbox(tx, msg)
+	})
+}
+
+// UpdateAvatarById 更新用户头像原图和缩略图的 URL，返回更新之前的 URL，由调用方删除旧的文件。
+// 旧的 URL 在事务里面加锁读出来，并发上传的时候每个旧文件只会被一个请求拿到
+func (dao *UserDAO) UpdateAvatarById(ctx context.Context, id int64, avatar, thumb string) (User, error) {
+	var old User
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id, avatar, avatar_thumb").
+			Where("id =? AND dtime = 0", id).First(&old).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrRecordNotFound.Wrap(err)
+		}
+		if err != nil {
+			return err
+		}
+		return tx.Model(&User{}).Where("id =?", id).
+			Updates(map[string]any{
+				"utime":        time.Now().UnixMilli(),
+				"avatar":       avatar,
+				"avatar_thumb": thumb,
+			}).Error
+	})
+	if err == nil {
+		dao.sticky.mark(id)
+	}
+	return old, err
+}
+
+// UpdatePasswordById 只更新用户的密码哈希，用于登录时的透明重新哈希
+func (dao *UserDAO) UpdatePasswordById(ctx context.Context, id int64, password string) error {
+	return dao.db.WithContext(ctx).Model(&User{}).Where("id =?", id).
+		Updates(map[string]any{
+			"utime":    time.Now().UnixMilli(),
+			"password": password,
 		}).Error
 }
 
 func (dao *UserDAO) FindById(ctx context.Context, id int64) (User, error) {
 	var u User
-	err := dao.db.WithContext(ctx).Where("id =?", id).First(&u).Error
-	return u, err
+	db := dao.db.WithContext(ctx)
+	if dao.sticky.recent(id) {
+		// 刚刚更新过，强制读主库
+		db = db.Clauses(dbresolver.Write)
+	}
+	err := db.Where("id =? AND dtime = 0", id).First(&u).Error
+	return u, translateNotFound(err)
+}
+
+// FindByIds 批量查询用户，已经注销或者不存在的用户不会出现在结果中
+func (dao *UserDAO) FindByIds(ctx context.Context, ids []int64) ([]User, error) {
+	var res []User
+	if len(ids) == 0 {
+		return res, nil
+	}
+	err := dao.db.WithContext(ctx).Where("id IN ? AND dtime = 0", ids).Find(&res).Error
+	return res, err
+}
+
+// SearchByNicknamePrefix 查询昵称以 prefix 开头、可见范围为 privacy 的用户，结果按照 (nickname, id) 升序排列。
+// afterId 大于 0 的时候只返回排在 (afterNickname, afterId) 之后的用户，用于游标分页
+func (dao *UserDAO) SearchByNicknamePrefix(ctx context.Context, prefix string, privacy uint8,
+	afterNickname string, afterId int64, limit int) ([]User, error) {
+	var res []User
+	db := dao.db.WithContext(ctx).
+		Where("nickname LIKE ? ESCAPE '!' AND dtime = 0 AND privacy = ?", escapeLike(prefix)+"%", privacy)
+	if afterId > 0 {
+		db = db.Where("nickname > ? OR (nickname = ? AND id > ?)", afterNickname, afterNickname, afterId)
+	}
+	err := db.Order("nickname, id").Limit(limit).Find(&res).Error
+	return res, err
+}
+
+// escapeLike 转义 LIKE 中的通配符，配合 ESCAPE '!' 使用。
+// 不用反斜杠是因为 MySQL 和 sqlite、postgres 对字符串里面的反斜杠处理不一样
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
+
+// SoftDeleteById 软删除用户，同时把邮箱替换成匿名邮箱，这样原来的邮箱可以再次注册。
+// 删除成功的时候 msg 在同一个事务里面写入发件箱
+func (dao *UserDAO) SoftDeleteById(ctx context.Context, id int64, anonymizedEmail string, msg OutboxMessage) error {
+	now := time.Now().UnixMilli()
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&User{}).Where("id =? AND dtime = 0", id).
+			Updates(map[string]any{
+				"email": anonymizedEmail,
+				"dtime": now,
+				"utime": now,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			// 用户不存在或者已经注销过了
+			return ErrRecordNotFound
+		}
+		return insertOutbox(tx, msg)
+	})
+}
+
+// PurgeDeleted 物理删除注销时间早于 before 的用户，以及他们的文章、评论、点赞、收藏、关注关系和关注流，
+// 每次最多删除 limit 个用户，返回删除的用户数。articleBiz 是文章在互动、评论中的业务名。
+// 同一批用户的数据在一个事务里面删除，purged 根据删除的用户生成事件，在同一个事务里面写入发件箱，
+// 由下游清理头像文件之类数据库之外的数据。
+// 别的用户的点赞数、收藏数、关注数和粉丝数会同步减掉，Redis 中缓存的计数要等过期之后才会更新
+func (dao *UserDAO) PurgeDeleted(ctx context.Context, before int64, limit int, articleBiz string,
+	purged func(u User) (OutboxMessage, error)) (int64, error) {
+	var n int64
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var users []User
+		err := tx.Select("id, avatar, avatar_thumb").
+			Where("dtime > 0 AND dtime < ?", before).
+			Order("id").Limit(limit).Find(&users).Error
+		if err != nil || len(users) == 0 {
+			return err
+		}
+		ids := make([]int64, 0, len(users))
+		msgs := make([]OutboxMessage, 0, len(users))
+		for _, u := range users {
+			ids = append(ids, u.Id)
+			msg, err := purged(u)
+			if err != nil {
+				return err
+			}
+			msgs = append(msgs, msg)
+		}
+		var artIds []int64
+		if err = tx.Model(&Article{}).Where("author_id IN ?", ids).Pluck("id", &artIds).Error; err != nil {
+			return err
+		}
+		if err = purgeComments(tx, ids, articleBiz, artIds); err != nil {
+			return err
+		}
+		if err = purgeInteractions(tx, ids, articleBiz, artIds); err != nil {
+			return err
+		}
+		if err = purgeFollows(tx, ids); err != nil {
+			return err
+		}
+		if err = tx.Where("uid IN ? OR author_id IN ?", ids, ids).Delete(&FeedPushEvent{}).Error; err != nil {
+			return err
+		}
+		if err = tx.Where("author_id IN ?", ids).Delete(&PublishedArticle{}).Error; err != nil {
+			return err
+		}
+		if err = tx.Where("author_id IN ?", ids).Delete(&Article{}).Error; err != nil {
+			return err
+		}
+		res := tx.Where("id IN ? AND dtime > 0", ids).Delete(&User{})
+		if res.Error != nil {
+			return res.Error
+		}
+		n = res.RowsAffected
+		return insertOutbox(tx, msgs...)
+	})
+	return n, err
+}
+
+// translateNotFound 把 gorm 的 ErrRecordNotFound 转换成带分类的 ErrRecordNotFound
+func translateNotFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrRecordNotFound.Wrap(err)
+	}
+	return err
 }
 
 type User struct {
 	Id       int64  `gorm:"primaryKey,autoIncrement"`
 	Email    string `gorm:"unique"`
 	Password string
-	Nickname string `gorm:"type=varchar(128)"`
+	Nickname string `gorm:"type:varchar(128);index"`
 	Birthday int64
-	AboutMe  string `gorm:"type=varchar(4096)"`
+	AboutMe  string `gorm:"type:varchar(4096)"`
 	Ctime    int64
 	Utime    int64
+	// 注销时间，0 表示没有注销
+	Dtime int64 `gorm:"not null;default:0;index"`
+	// 乐观锁的版本号
+	Version int64 `gorm:"not null;default:1"`
+	// 头像原图和缩略图的 URL
+	Avatar      string `gorm:"type:varchar(512);not null;default:''"`
+	AvatarThumb string `gorm:"type:varchar(512);not null;default:''"`
+	// 个人主页的可见范围，取值见 domain.Privacy
+	Privacy uint8 `gorm:"not null;default:0"`
 }
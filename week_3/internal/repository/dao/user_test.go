@@ -0,0 +1,145 @@
+package dao
+
+import (
+	"context"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go_homework/week_3/internal/errs"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"testing"
+)
+
+// newTestDB 创建一个内存中的 sqlite 数据库，表结构和本地开发一样由 InitTables 创建。
+// 内存数据库每个连接各自独立，所以只允许一个连接
+func newTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	require.NoError(t, InitTables(db))
+	return db
+}
+
+func noOutbox(u User) (OutboxMessage, error) {
+	return OutboxMessage{Topic: "test", Payload: "{}"}, nil
+}
+
+func TestUserDAO_Insert(t *testing.T) {
+	db := newTestDB(t)
+	d := NewUserDAO(db)
+	ctx := context.Background()
+
+	require.NoError(t, d.Insert(ctx, User{Email: "a@qq.com", Password: "hash"}, noOutbox))
+	u, err := d.FindByEmail(ctx, "a@qq.com")
+	require.NoError(t, err)
+	assert.True(t, u.Id > 0)
+	assert.Equal(t, int64(1), u.Version)
+	assert.True(t, u.Ctime > 0)
+
+	err = d.Insert(ctx, User{Email: "a@qq.com", Password: "hash"}, noOutbox)
+	assert.ErrorIs(t, err, ErrDuplicateEmail)
+	assert.Equal(t, errs.KindConflict, errs.KindOf(err))
+}
+
+func TestUserDAO_FindByEmail_NotFound(t *testing.T) {
+	d := NewUserDAO(newTestDB(t))
+	_, err := d.FindByEmail(context.Background(), "missing@qq.com")
+	assert.ErrorIs(t, err, ErrRecordNotFound)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.Equal(t, errs.KindNotFound, errs.KindOf(err))
+}
+
+func TestUserDAO_FindByEmail_Deleted(t *testing.T) {
+	d := NewUserDAO(newTestDB(t))
+	ctx := context.Background()
+	require.NoError(t, d.Insert(ctx, User{Email: "a@qq.com"}, noOutbox))
+	u, err := d.FindByEmail(ctx, "a@qq.com")
+	require.NoError(t, err)
+	require.NoError(t, d.SoftDeleteById(ctx, u.Id, "deleted-1@invalid", OutboxMessage{Topic: "test", Payload: "{}"}))
+	_, err = d.FindByEmail(ctx, "a@qq.com")
+	assert.ErrorIs(t, err, ErrRecordNotFound)
+}
+
+func TestUserDAO_UpdateById(t *testing.T) {
+	testCases := []struct {
+		name    string
+		version int64
+		wantErr error
+		// 更新之后数据库中的昵称和版本号
+		wantNickname string
+		wantVersion  int64
+	}{
+		{
+			name:         "current version",
+			version:      1,
+			wantNickname: "new",
+			wantVersion:  2,
+		},
+		{
+			name:         "stale version",
+			version:      0,
+			wantErr:      ErrVersionConflict,
+			wantNickname: "old",
+			wantVersion:  1,
+		},
+		{
+			name:         "future version",
+			version:      2,
+			wantErr:      ErrVersionConflict,
+			wantNickname: "old",
+			wantVersion:  1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := NewUserDAO(newTestDB(t))
+			ctx := context.Background()
+			require.NoError(t, d.Insert(ctx, User{Email: "a@qq.com", Nickname: "old"}, noOutbox))
+			u, err := d.FindByEmail(ctx, "a@qq.com")
+			require.NoError(t, err)
+
+			err = d.UpdateById(ctx, User{Id: u.Id, Nickname: "new", Version: tc.version},
+				OutboxMessage{Topic: "test", Payload: "{}"})
+			assert.ErrorIs(t, err, tc.wantErr)
+			u, err = d.FindById(ctx, u.Id)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantNickname, u.Nickname)
+			assert.Equal(t, tc.wantVersion, u.Version)
+		})
+	}
+}
+
+func TestUserDAO_UpdateFieldsById(t *testing.T) {
+	d := NewUserDAO(newTestDB(t))
+	ctx := context.Background()
+	require.NoError(t, d.Insert(ctx, User{Email: "a@qq.com"}, noOutbox))
+	u, err := d.FindByEmail(ctx, "a@qq.com")
+	require.NoError(t, err)
+	msg := OutboxMessage{Topic: "test", Payload: "{}"}
+
+	// 只更新 nickname，about_me 保持不变
+	require.NoError(t, d.UpdateFieldsById(ctx, User{Id: u.Id, Nickname: "n1", AboutMe: "ignored", Version: 1},
+		[]string{"nickname"}, msg))
+	// 两个请求基于同一个版本修改，后一个冲突
+	err = d.UpdateFieldsById(ctx, User{Id: u.Id, Nickname: "n2", Version: 1}, []string{"nickname"}, msg)
+	assert.ErrorIs(t, err, ErrVersionConflict)
+	// 不带版本号的时候不检查
+	require.NoError(t, d.UpdateFieldsById(ctx, User{Id: u.Id, AboutMe: "about"}, []string{"about_me"}, msg))
+
+	u, err = d.FindById(ctx, u.Id)
+	require.NoError(t, err)
+	assert.Equal(t, "n1", u.Nickname)
+	assert.Equal(t, "about", u.AboutMe)
+	assert.Equal(t, int64(3), u.Version)
+
+	err = d.UpdateFieldsById(ctx, User{Id: u.Id + 1}, []string{"nickname"}, msg)
+	assert.ErrorIs(t, err, ErrRecordNotFound)
+	err = d.UpdateFieldsById(ctx, User{Id: u.Id}, []string{"password"}, msg)
+	assert.Error(t, err)
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"go_homework/week_3/internal/domain"
+	"go_homework/week_3/internal/repository/dao"
+	"time"
+)
+
+// FeedRepository 关注流，推模式的收件箱和拉模式的线上库查询。返回的文章内容只有摘要
+type FeedRepository struct {
+	dao    *dao.FeedDAO
+	artDAO *dao.ArticleDAO
+}
+
+func NewFeedRepository(dao *dao.FeedDAO, artDAO *dao.ArticleDAO) *FeedRepository {
+	return &FeedRepository{dao: dao, artDAO: artDAO}
+}
+
+// Push 把文章推送到 uids 的收件箱中，重复推送会被忽略
+func (repo *FeedRepository) Push(ctx context.Context, art domain.Article, uids []int64) error {
+	ctx, span := tracer.Start(ctx, "FeedRepository.Push")
+	defer span.End()
+	events := make([]dao.FeedPushEvent, 0, len(uids))
+	for _, uid := range uids {
+		events = append(events, dao.FeedPushEvent{
+			Uid:       uid,
+			ArticleId: art.Id,
+			AuthorId:  art.Author.Id,
+			Ctime:     art.Ctime.UnixMilli(),
+		})
+	}
+	return repo.dao.InsertPushEvents(ctx, events)
+}
+
+// FindPushArticles 按照第一次发表的时间倒序查询 uid 收件箱中的文章，排在 (afterCtime, afterId) 之后，
+// 已经撤回的文章会被过滤掉
+func (repo *FeedRepository) FindPushArticles(ctx context.Context, uid int64, afterCtime time.Time, afterId int64,
+	limit int) ([]domain.Article, error) {
+	ctx, span := tracer.Start(ctx, "FeedRepository.FindPushArticles")
+	defer span.End()
+	events, err := repo.dao.FindPushEvents(ctx, uid, uint8(domain.ArticleStatusPublished),
+		afterCtime.UnixMilli(), afterId, limit)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, 0, len(events))
+	for _, evt := range events {
+		ids = append(ids, evt.ArticleId)
+	}
+	arts, err := repo.artDAO.FindPublishedByIds(ctx, ids, uint8(domain.ArticleStatusPublished))
+	if err != nil {
+		return nil, err
+	}
+	artMap := make(map[int64]dao.PublishedArticle, len(arts))
+	for _, art := range arts {
+		artMap[art.Id] = art
+	}
+	// 按照收件箱的顺序返回。两次查询之间撤回的文章这里还会被过滤掉
+	res := make([]domain.Article, 0, len(arts))
+	for _, evt := range events {
+		if art, ok := artMap[evt.ArticleId]; ok {
+			res = append(res, repo.toDomain(art))
+		}
+	}
+	return res, nil
+}
+
+// FindPullArticles 按照第一次发表的时间倒序查询 uid 关注的、粉丝数不少于 threshold 的作者的文章，
+// 排在 (afterCtime, afterId) 之后
+func (repo *FeedRepository) FindPullArticles(ctx context.Context, uid, threshold int64, afterCtime time.Time,
+	afterId int64, limit int) ([]domain.Article, error) {
+	ctx, span := tracer.Start(ctx, "FeedRepository.FindPullArticles")
+	defer span.End()
+	arts, err := repo.dao.FindPullArticles(ctx, uid, threshold, uint8(domain.ArticleStatusPublished),
+		afterCtime.UnixMilli(), afterId, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.Article, 0, len(arts))
+	for _, art := range arts {
+		res = append(res, repo.toDomain(art))
+	}
+	return res, nil
+}
+
+func (repo *FeedRepository) toDomain(art dao.PublishedArticle) domain.Article {
+	return domain.Article{
+		Id:      art.Id,
+		Title:   art.Title,
+		Content: art.Content,
+		Author:  domain.Author{Id: art.AuthorId},
+		Status:  domain.ArticleStatus(art.Status),
+		Ctime:   time.UnixMilli(art.Ctime),
+		Utime:   time.UnixMilli(art.Utime),
+	}
+}
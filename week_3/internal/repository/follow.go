@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"go_homework/week_3/internal/domain"
+	"go_homework/week_3/internal/repository/cache"
+	"go_homework/week_3/internal/repository/dao"
+	"go_homework/week_3/pkg/logger"
+	"time"
+)
+
+// FollowRepository 关注数和粉丝数以数据库为准，缓存在数据库修改成功之后用 Lua 脚本修改
+type FollowRepository struct {
+	dao   *dao.FollowDAO
+	cache *cache.FollowCache
+}
+
+func NewFollowRepository(dao *dao.FollowDAO, cache *cache.FollowCache) *FollowRepository {
+	return &FollowRepository{dao: dao, cache: cache}
+}
+
+// Follow follower 关注 followee，重复关注不会重复计数
+func (repo *FollowRepository) Follow(ctx context.Context, follower, followee int64) error {
+	ctx, span := tracer.Start(ctx, "FollowRepository.Follow")
+	defer span.End()
+	changed, err := repo.dao.Follow(ctx, follower, followee)
+	if err != nil || !changed {
+		return err
+	}
+	repo.incrCache(ctx, follower, followee, 1)
+	return nil
+}
+
+// Unfollow 取消关注，没有关注过的时候什么都不做
+func (repo *FollowRepository) Unfollow(ctx context.Context, follower, followee int64) error {
+	ctx, span := tracer.Start(ctx, "FollowRepository.Unfollow")
+	defer span.End()
+	changed, err := repo.dao.Unfollow(ctx, follower, followee)
+	if err != nil || !changed {
+		return err
+	}
+	repo.incrCache(ctx, follower, followee, -1)
+	return nil
+}
+
+// incrCache 修改缓存失败的时候只记录日志，缓存最多在过期之前和数据库不一致
+func (repo *FollowRepository) incrCache(ctx context.Context, follower, followee, delta int64) {
+	if err := repo.cache.IncrIfPresent(ctx, follower, followee, delta); err != nil {
+		logger.FromContext(ctx).Warn("update follow statistic cache failed",
+			logger.Int64("follower", follower), logger.Int64("followee", followee), logger.Error(err))
+	}
+}
+
+// GetStatistic 查询用户的关注数和粉丝数，先查缓存
+func (repo *FollowRepository) GetStatistic(ctx context.Context, uid int64) (domain.FollowStatistic, error) {
+	ctx, span := tracer.Start(ctx, "FollowRepository.GetStatistic")
+	defer span.End()
+	s, err := repo.cache.Get(ctx, uid)
+	if err == nil {
+		return s, nil
+	}
+	if !errors.Is(err, cache.ErrKeyNotExist) {
+		logger.FromContext(ctx).Warn("read follow statistic cache failed", logger.Int64("uid", uid), logger.Error(err))
+	}
+	entity, err := repo.dao.FindStatistic(ctx, uid)
+	if err != nil {
+		return domain.FollowStatistic{}, err
+	}
+	s = domain.FollowStatistic{Uid: uid, Followers: entity.Followers, Followees: entity.Followees}
+	if err = repo.cache.Set(ctx, s); err != nil {
+		logger.FromContext(ctx).Warn("cache follow statistic failed", logger.Int64("uid", uid), logger.Error(err))
+	}
+	return s, nil
+}
+
+// IsFollowing 判断 follower 是否关注了 followee
+func (repo *FollowRepository) IsFollowing(ctx context.Context, follower, followee int64) (bool, error) {
+	ctx, span := tracer.Start(ctx, "FollowRepository.IsFollowing")
+	defer span.End()
+	return repo.dao.IsFollowing(ctx, follower, followee)
+}
+
+// ListFollowers 按照关注时间倒序查询 followee 的粉丝，afterId 是上一页最后一条关注关系的 id
+func (repo *FollowRepository) ListFollowers(ctx context.Context, followee, afterId int64,
+	limit int) ([]domain.FollowRelation, error) {
+	ctx, span := tracer.Start(ctx, "FollowRepository.ListFollowers")
+	defer span.End()
+	rels, err := repo.dao.ListFollowers(ctx, followee, afterId, limit)
+	return repo.toDomains(rels), err
+}
+
+// ListFollowees 按照关注时间倒序查询 follower 关注的人，afterId 是上一页最后一条关注关系的 id
+func (repo *FollowRepository) ListFollowees(ctx context.Context, follower, afterId int64,
+	limit int) ([]domain.FollowRelation, error) {
+	ctx, span := tracer.Start(ctx, "FollowRepository.ListFollowees")
+	defer span.End()
+	rels, err := repo.dao.ListFollowees(ctx, follower, afterId, limit)
+	return repo.toDomains(rels), err
+}
+
+func (repo *FollowRepository) toDomains(rels []dao.FollowRelation) []domain.FollowRelation {
+	res := make([]domain.FollowRelation, 0, len(rels))
+	for _, rel := range rels {
+		res = append(res, domain.FollowRelation{
+			Id:       rel.Id,
+			Follower: rel.Follower,
+			Followee: rel.Followee,
+			Ctime:    time.UnixMilli(rel.Ctime),
+		})
+	}
+	return res
+}
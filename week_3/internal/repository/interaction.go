@@ -0,0 +1,263 @@
+package repository
+
+import (
+	"context"
+	"go_homework/week_3/internal/domain"
+	"go_homework/week_3/internal/repository/cache"
+	"go_homework/week_3/internal/repository/dao"
+	"go_homework/week_3/pkg/logger"
+	"time"
+)
+
+var (
+	ErrCollectionNotFound  = dao.ErrCollectionNotFound
+	ErrDuplicateCollection = dao.ErrDuplicateCollection
+)
+
+// InteractionRepository 计数以数据库为准，缓存中的计数在数据库修改成功之后用 Lua 脚本原子地修改。
+// 修改缓存失败的时候只记录日志，缓存最多在过期之前和数据库不一致
+type InteractionRepository struct {
+	dao   *dao.InteractionDAO
+	cache *cache.InteractionCache
+}
+
+func NewInteractionRepository(dao *dao.InteractionDAO, cache *cache.InteractionCache) *InteractionRepository {
+	return &InteractionRepository{dao: dao, cache: cache}
+}
+
+// BatchIncrReadCnt 在一个事务里面修改一批业务对象的阅读数，cnts 是 biz 到 bizId 到增量的映射
+func (repo *InteractionRepository) BatchIncrReadCnt(ctx context.Context, cnts map[string]map[int64]int64) error {
+	ctx, span := tracer.Start(ctx, "InteractionRepository.BatchIncrReadCnt")
+	defer span.End()
+	if err := repo.dao.BatchIncrReadCnt(ctx, cnts); err != nil {
+		return err
+	}
+	for biz, bizCnts := range cnts {
+		for bizId, delta := range bizCnts {
+			if err := repo.cache.IncrReadCntIfPresent(ctx, biz, bizId, delta); err != nil {
+				repo.logCacheError(ctx, "incr read count cache failed", biz, bizId, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Like 点赞，重复点赞不会重复计数
+func (repo *InteractionRepository) Like(ctx context.Context, biz string, bizId, uid int64) error {
+	ctx, span := tracer.Start(ctx, "InteractionRepository.Like")
+	defer span.End()
+	changed, err := repo.dao.InsertLikeInfo(ctx, biz, bizId, uid)
+	if err != nil || !changed {
+		return err
+	}
+	if err = repo.cache.IncrLikeCntIfPresent(ctx, biz, bizId, 1); err != nil {
+		repo.logCacheError(ctx, "incr like count cache failed", biz, bizId, err)
+	}
+	return nil
+}
+
+// CancelLike 取消点赞，没有点过赞的时候什么都不做
+func (repo *InteractionRepository) CancelLike(ctx context.Context, biz string, bizId, uid int64) error {
+	ctx, span := tracer.Start(ctx, "InteractionRepository.CancelLike")
+	defer span.End()
+	changed, err := repo.dao.DeleteLikeInfo(ctx, biz, bizId, uid)
+	if err != nil || !changed {
+		return err
+	}
+	if err = repo.cache.IncrLikeCntIfPresent(ctx, biz, bizId, -1); err != nil {
+		repo.logCacheError(ctx, "decr like count cache failed", biz, bizId, err)
+	}
+	return nil
+}
+
+// Collect 收藏到用户的收藏夹，已经在这个收藏夹里面的时候什么都不做。收藏夹不是这个用户的时候返回 ErrCollectionNotFound
+func (repo *InteractionRepository) Collect(ctx context.Context, biz string, bizId, cid, uid int64) error {
+	ctx, span := tracer.Start(ctx, "InteractionRepository.Collect")
+	defer span.End()
+	changed, err := repo.dao.InsertCollectionBiz(ctx, dao.UserCollectionBiz{
+		Cid: cid, Uid: uid, BizId: bizId, Biz: biz,
+	})
+	if err != nil || !changed {
+		return err
+	}
+	if err = repo.cache.IncrCollectCntIfPresent(ctx, biz, bizId, 1); err != nil {
+		repo.logCacheError(ctx, "incr collect count cache failed", biz, bizId, err)
+	}
+	return nil
+}
+
+// CancelCollect 从收藏夹 cid 里面取消收藏，没有收藏过的时候什么都不做
+func (repo *InteractionRepository) CancelCollect(ctx context.Context, biz string, bizId, cid, uid int64) error {
+	ctx, span := tracer.Start(ctx, "InteractionRepository.CancelCollect")
+	defer span.End()
+	changed, err := repo.dao.DeleteCollectionBiz(ctx, biz, bizId, cid, uid)
+	if err != nil || !changed {
+		return err
+	}
+	if err = repo.cache.IncrCollectCntIfPresent(ctx, biz, bizId, -1); err != nil {
+		repo.logCacheError(ctx, "decr collect count cache failed", biz, bizId, err)
+	}
+	return nil
+}
+
+// GetLikeCnts 直接从数据库批量查询点赞数，返回 bizId 到点赞数的映射，没有点赞的业务对象不在结果中。
+// 用于计算热榜之类遍历大量冷数据的批量任务，不读也不写缓存，避免把缓存挤满
+func (repo *InteractionRepository) GetLikeCnts(ctx context.Context, biz string, bizIds []int64) (map[int64]int64, error) {
+	ctx, span := tracer.Start(ctx, "InteractionRepository.GetLikeCnts")
+	defer span.End()
+	intrs, err := repo.dao.GetByIds(ctx, biz, bizIds)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[int64]int64, len(intrs))
+	for _, intr := range intrs {
+		if intr.LikeCnt > 0 {
+			res[intr.BizId] = intr.LikeCnt
+		}
+	}
+	return res, nil
+}
+
+// GetByIds 批量查询计数以及用户 uid 的点赞和收藏状态，结果中包含 bizIds 中的每一个业务对象。
+// 计数先查缓存，没有命中的一次性回查数据库并写回缓存
+func (repo *InteractionRepository) GetByIds(ctx context.Context, biz string, bizIds []int64,
+	uid int64) (map[int64]domain.Interaction, error) {
+	ctx, span := tracer.Start(ctx, "InteractionRepository.GetByIds")
+	defer span.End()
+	res, err := repo.cache.GetByIds(ctx, biz, bizIds)
+	if err != nil {
+		repo.logCacheError(ctx, "read interaction cache failed", biz, 0, err)
+		res = make(map[int64]domain.Interaction, len(bizIds))
+	}
+	missed := make([]int64, 0, len(bizIds)-len(res))
+	for _, id := range bizIds {
+		if _, ok := res[id]; !ok {
+			missed = append(missed, id)
+		}
+	}
+	if len(missed) > 0 {
+		intrs, err := repo.dao.GetByIds(ctx, biz, missed)
+		if err != nil {
+			return nil, err
+		}
+		// 还没有任何互动的业务对象在数据库里面没有记录，计数都是 0，同样缓存起来
+		for _, id := range missed {
+			res[id] = domain.Interaction{Biz: biz, BizId: id}
+		}
+		for _, intr := range intrs {
+			res[intr.BizId] = repo.toDomain(intr)
+		}
+		toCache := make([]domain.Interaction, 0, len(missed))
+		for _, id := range missed {
+			toCache = append(toCache, res[id])
+		}
+		if err = repo.cache.Set(ctx, toCache...); err != nil {
+			repo.logCacheError(ctx, "cache interaction failed", biz, 0, err)
+		}
+	}
+	if uid <= 0 {
+		return res, nil
+	}
+	liked, err := repo.dao.LikedBizIds(ctx, biz, bizIds, uid)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range liked {
+		intr := res[id]
+		intr.Liked = true
+		res[id] = intr
+	}
+	collected, err := repo.dao.CollectedBizIds(ctx, biz, bizIds, uid)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range collected {
+		intr := res[id]
+		intr.Collected = true
+		res[id] = intr
+	}
+	return res, nil
+}
+
+// CreateCollection 新建收藏夹，返回收藏夹的 id。重名的时候返回 ErrDuplicateCollection
+func (repo *InteractionRepository) CreateCollection(ctx context.Context, c domain.Collection) (int64, error) {
+	ctx, span := tracer.Start(ctx, "InteractionRepository.CreateCollection")
+	defer span.End()
+	return repo.dao.InsertCollection(ctx, dao.Collection{Uid: c.Uid, Name: c.Name})
+}
+
+// ListCollections 用户所有的收藏夹
+func (repo *InteractionRepository) ListCollections(ctx context.Context, uid int64) ([]domain.Collection, error) {
+	ctx, span := tracer.Start(ctx, "InteractionRepository.ListCollections")
+	defer span.End()
+	cs, err := repo.dao.ListCollections(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.Collection, 0, len(cs))
+	for _, c := range cs {
+		res = append(res, domain.Collection{
+			Id:    c.Id,
+			Uid:   c.Uid,
+			Name:  c.Name,
+			Ctime: time.UnixMilli(c.Ctime),
+		})
+	}
+	return res, nil
+}
+
+// ListLikes 按照点赞的顺序查询用户点过赞的业务对象，afterId 是上一页最后一条记录的 id
+func (repo *InteractionRepository) ListLikes(ctx context.Context, uid, afterId int64, limit int) ([]domain.Like, error) {
+	ctx, span := tracer.Start(ctx, "InteractionRepository.ListLikes")
+	defer span.End()
+	likes, err := repo.dao.ListLikesByUid(ctx, uid, afterId, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.Like, 0, len(likes))
+	for _, l := range likes {
+		res = append(res, domain.Like{
+			Id:    l.Id,
+			Biz:   l.Biz,
+			BizId: l.BizId,
+			Ctime: time.UnixMilli(l.Ctime),
+		})
+	}
+	return res, nil
+}
+
+// ListCollectionItems 按照收藏的顺序查询用户所有收藏夹里面的业务对象，afterId 是上一页最后一条记录的 id
+func (repo *InteractionRepository) ListCollectionItems(ctx context.Context, uid, afterId int64,
+	limit int) ([]domain.CollectionItem, error) {
+	ctx, span := tracer.Start(ctx, "InteractionRepository.ListCollectionItems")
+	defer span.End()
+	cbs, err := repo.dao.ListCollectionBizsByUid(ctx, uid, afterId, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.CollectionItem, 0, len(cbs))
+	for _, cb := range cbs {
+		res = append(res, domain.CollectionItem{
+			Id:    cb.Id,
+			Cid:   cb.Cid,
+			Biz:   cb.Biz,
+			BizId: cb.BizId,
+			Ctime: time.UnixMilli(cb.Ctime),
+		})
+	}
+	return res, nil
+}
+
+func (repo *InteractionRepository) logCacheError(ctx context.Context, msg string, biz string, bizId int64, err error) {
+	logger.FromContext(ctx).Warn(msg, logger.String("biz", biz), logger.Int64("biz_id", bizId), logger.Error(err))
+}
+
+func (repo *InteractionRepository) toDomain(intr dao.Interaction) domain.Interaction {
+	return domain.Interaction{
+		Biz:        intr.Biz,
+		BizId:      intr.BizId,
+		ReadCnt:    intr.ReadCnt,
+		LikeCnt:    intr.LikeCnt,
+		CollectCnt: intr.CollectCnt,
+	}
+}
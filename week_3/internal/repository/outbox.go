@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"go_homework/week_3/internal/domain"
+	"go_homework/week_3/internal/repository/dao"
+	"time"
+)
+
+type OutboxRepository struct {
+	dao *dao.OutboxDAO
+}
+
+func NewOutboxRepository(dao *dao.OutboxDAO) *OutboxRepository {
+	return &OutboxRepository{dao: dao}
+}
+
+// FindPending 按照写入的顺序查询最多 limit 条还没有投递的事件
+func (repo *OutboxRepository) FindPending(ctx context.Context, limit int) ([]domain.OutboxMessage, error) {
+	ctx, span := tracer.Start(ctx, "OutboxRepository.FindPending")
+	defer span.End()
+	msgs, err := repo.dao.FindPending(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.OutboxMessage, 0, len(msgs))
+	for _, msg := range msgs {
+		res = append(res, domain.OutboxMessage{
+			Id:      msg.Id,
+			Topic:   msg.Topic,
+			Key:     msg.Key,
+			Payload: []byte(msg.Payload),
+			Ctime:   time.UnixMilli(msg.Ctime),
+		})
+	}
+	return res, nil
+}
+
+// Delete 删除已经投递的事件
+func (repo *OutboxRepository) Delete(ctx context.Context, ids []int64) error {
+	ctx, span := tracer.Start(ctx, "OutboxRepository.Delete")
+	defer span.End()
+	return repo.dao.DeleteByIds(ctx, ids)
+}
+
+// newOutboxMessage 把领域事件序列化成 JSON，写入发件箱
+func newOutboxMessage(evt domain.Event) (dao.OutboxMessage, error) {
+	payload, err := json.Marshal(evt)
+	if err != nil {
+		return dao.OutboxMessage{}, err
+	}
+	return dao.OutboxMessage{
+		Topic:   evt.Topic(),
+		Key:     evt.Key(),
+		Payload: string(payload),
+	}, nil
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"go_homework/week_3/internal/domain"
+	"go_homework/week_3/internal/repository/cache"
+	"go_homework/week_3/pkg/logger"
+)
+
+// RankingRepository 热榜只保存在缓存中：Redis 保存所有实例共享的一份，本地缓存减少读 Redis 的次数，
+// Redis 出问题的时候返回本地缓存中过期的热榜兜底
+type RankingRepository struct {
+	redis *cache.RankingCache
+	local *cache.RankingLocalCache
+}
+
+func NewRankingRepository(redis *cache.RankingCache, local *cache.RankingLocalCache) *RankingRepository {
+	return &RankingRepository{redis: redis, local: local}
+}
+
+// ReplaceTopN 保存新计算出来的热榜
+func (repo *RankingRepository) ReplaceTopN(ctx context.Context, arts []domain.Article) error {
+	ctx, span := tracer.Start(ctx, "RankingRepository.ReplaceTopN")
+	defer span.End()
+	// 先写本地缓存，Redis 写失败的时候至少这个实例可以用新的热榜
+	repo.local.Set(arts)
+	return repo.redis.Set(ctx, arts)
+}
+
+// GetTopN 读取热榜，还没有计算过的时候返回空的热榜
+func (repo *RankingRepository) GetTopN(ctx context.Context) ([]domain.Article, error) {
+	ctx, span := tracer.Start(ctx, "RankingRepository.GetTopN")
+	defer span.End()
+	if arts, err := repo.local.Get(); err == nil {
+		return arts, nil
+	}
+	arts, err := repo.redis.Get(ctx)
+	if err == nil {
+		repo.local.Set(arts)
+		return arts, nil
+	}
+	if !errors.Is(err, cache.ErrKeyNotExist) {
+		logger.FromContext(ctx).Warn("read ranking cache failed, fallback to local cache", logger.Error(err))
+	}
+	if res, localErr := repo.local.ForceGet(); localErr == nil {
+		return res, nil
+	}
+	if errors.Is(err, cache.ErrKeyNotExist) {
+		return nil, nil
+	}
+	return nil, err
+}
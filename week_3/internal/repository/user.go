@@ -2,36 +2,57 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"go.opentelemetry.io/otel"
 	"go_homework/week_3/internal/domain"
+	"go_homework/week_3/internal/errs"
+	"go_homework/week_3/internal/repository/cache"
 	"go_homework/week_3/internal/repository/dao"
+	"go_homework/week_3/pkg/logger"
 	"time"
 )
 
 var (
-	ErrDuplicateEmail = dao.ErrDuplicateEmail
-	ErrUserNotFound   = dao.ErrRecordNotFound
+	ErrDuplicateEmail  = dao.ErrDuplicateEmail
+	ErrUserNotFound    = dao.ErrRecordNotFound
+	ErrVersionConflict = dao.ErrVersionConflict
+	// ErrResetTokenNotFound 找回密码的 token 不存在或者已经过期
+	ErrResetTokenNotFound = errs.Validation(errs.CodeInvalidResetToken, "The reset link is invalid or has expired")
 )
 
+// tracer 仓储层的每一个方法都会创建一个 span，名字为 <类型>.<方法>，例如 UserRepository.FindByID
+var tracer = otel.Tracer("go_homework/week_3/internal/repository")
+
 type UserRepository struct {
-	dao *dao.UserDAO
+	dao   *dao.UserDAO
+	cache *cache.UserCache
 }
 
-func NewUserRepository(dao *dao.UserDAO) *UserRepository {
+func NewUserRepository(dao *dao.UserDAO, cache *cache.UserCache) *UserRepository {
 	// NewUserRepository 函数用于创建并返回一个 UserRepository 类型的指针
 	// 参数 dao 是一个指向 UserDAO 类型的指针，用于初始化 UserRepository 的 dao 字段
-	return &UserRepository{dao: dao}
+	// 参数 cache 用于保存会话吊销之类的缓存数据
+	return &UserRepository{dao: dao, cache: cache}
 }
 
 // Create 函数用于创建新用户。
-func (repo *UserRepository) Create(ctx context.Context, u domain.User) error {
+// registered 根据创建好的用户生成事件，和用户在同一个事务里面写入发件箱
+func (repo *UserRepository) Create(ctx context.Context, u domain.User,
+	registered func(u domain.User) domain.Event) error {
+	ctx, span := tracer.Start(ctx, "UserRepository.Create")
+	defer span.End()
 	return repo.dao.Insert(ctx, dao.User{
 		Email:    u.Email,
 		Password: u.Password,
+	}, func(u dao.User) (dao.OutboxMessage, error) {
+		return newOutboxMessage(registered(repo.toDomain(u)))
 	})
 }
 
 // FindByEmail 方法根据邮箱地址查询用户信息
 func (repo *UserRepository) FindByEmail(ctx context.Context, email string) (domain.User, error) {
+	ctx, span := tracer.Start(ctx, "UserRepository.FindByEmail")
+	defer span.End()
 	// 调用 dao 层的 FindByEmail 方法，传入上下文和邮箱地址作为参数
 	u, err := repo.dao.FindByEmail(ctx, email)
 	// 如果发生错误，则返回一个空的 domain.User 和 error
@@ -50,24 +71,236 @@ func (repo *UserRepository) toDomain(u dao.User) domain.User {
 		Email:    u.Email,
 		Password: u.Password,
 		Nickname: u.Nickname,
-		Birthday: time.UnixMilli(u.Birthday),
+		Birthday: fromMillis(u.Birthday),
 		AboutMe:  u.AboutMe,
+		Avatar: domain.Avatar{
+			URL:          u.Avatar,
+			ThumbnailURL: u.AvatarThumb,
+		},
+		Privacy: domain.Privacy(u.Privacy),
+		Ctime:   time.UnixMilli(u.Ctime),
+		Utime:   time.UnixMilli(u.Utime),
+		Version: u.Version,
 	}
 }
 
-func (repo *UserRepository) UpdateNonZeroFields(ctx context.Context, u domain.User) error {
-	return repo.dao.UpdateById(ctx, dao.User{
+// UpdateNonZeroFields 更新用户的昵称、生日和个性签名，更新成功的时候 evt 在同一个事务里面写入发件箱
+func (repo *UserRepository) UpdateNonZeroFields(ctx context.Context, u domain.User, evt domain.Event) error {
+	ctx, span := tracer.Start(ctx, "UserRepository.UpdateNonZeroFields")
+	defer span.End()
+	msg, err := newOutboxMessage(evt)
+	if err != nil {
+		return err
+	}
+	err = repo.dao.UpdateById(ctx, dao.User{
 		Id:       u.Id,
 		Nickname: u.Nickname,
-		Birthday: u.Birthday.UnixMilli(),
+		Birthday: toMillis(u.Birthday),
 		AboutMe:  u.AboutMe,
-	})
+		Version:  u.Version,
+	}, msg)
+	repo.invalidateProfile(ctx, u.Id)
+	return err
+}
+
+// UpdateFields 只更新 mask 中列出的字段，字段的值取自 u，零值表示清空这个字段。
+// u.Version 为 0 的时候不检查版本号。更新成功的时候 evt 在同一个事务里面写入发件箱
+func (repo *UserRepository) UpdateFields(ctx context.Context, u domain.User, mask []domain.UserField,
+	evt domain.Event) error {
+	ctx, span := tracer.Start(ctx, "UserRepository.UpdateFields")
+	defer span.End()
+	columns := make([]string, 0, len(mask))
+	for _, f := range mask {
+		columns = append(columns, string(f))
+	}
+	msg, err := newOutboxMessage(evt)
+	if err != nil {
+		return err
+	}
+	err = repo.dao.UpdateFieldsById(ctx, dao.User{
+		Id:       u.Id,
+		Nickname: u.Nickname,
+		Birthday: toMillis(u.Birthday),
+		AboutMe:  u.AboutMe,
+		Privacy:  uint8(u.Privacy),
+		Version:  u.Version,
+	}, columns, msg)
+	repo.invalidateProfile(ctx, u.Id)
+	return err
+}
+
+// UpdateAvatar 更新用户的头像，返回更新之前的头像
+func (repo *UserRepository) UpdateAvatar(ctx context.Context, uid int64, avatar domain.Avatar) (domain.Avatar, error) {
+	ctx, span := tracer.Start(ctx, "UserRepository.UpdateAvatar")
+	defer span.End()
+	old, err := repo.dao.UpdateAvatarById(ctx, uid, avatar.URL, avatar.ThumbnailURL)
+	repo.invalidateProfile(ctx, uid)
+	return domain.Avatar{URL: old.Avatar, ThumbnailURL: old.AvatarThumb}, err
+}
+
+// UpdatePassword 更新用户的密码哈希
+func (repo *UserRepository) UpdatePassword(ctx context.Context, uid int64, password string) error {
+	ctx, span := tracer.Start(ctx, "UserRepository.UpdatePassword")
+	defer span.End()
+	return repo.dao.UpdatePasswordById(ctx, uid, password)
 }
 
 func (repo *UserRepository) FindByID(ctx context.Context, uid int64) (domain.User, error) {
+	ctx, span := tracer.Start(ctx, "UserRepository.FindByID")
+	defer span.End()
 	u, err := repo.dao.FindById(ctx, uid)
 	if err != nil {
 		return domain.User{}, err
 	}
 	return repo.toDomain(u), nil
 }
+
+// FindProfile 查询用户的资料，优先读缓存，查询频繁的资料（例如热门用户的主页）不会每次都打到数据库。
+// 返回的用户不包含密码哈希，需要校验密码的场景使用 FindByID
+func (repo *UserRepository) FindProfile(ctx context.Context, uid int64) (domain.User, error) {
+	ctx, span := tracer.Start(ctx, "UserRepository.FindProfile")
+	defer span.End()
+	u, err := repo.cache.GetProfile(ctx, uid)
+	if err == nil {
+		return u, nil
+	}
+	if !errors.Is(err, cache.ErrKeyNotExist) {
+		logger.FromContext(ctx).Warn("read profile cache failed", logger.Int64("uid", uid), logger.Error(err))
+	}
+	// 缓存没有命中或者 Redis 出了问题都回查数据库
+	u, err = repo.FindByID(ctx, uid)
+	if err != nil {
+		return domain.User{}, err
+	}
+	u.Password = ""
+	// 写缓存失败不影响这次查询
+	if err = repo.cache.SetProfile(ctx, u); err != nil {
+		logger.FromContext(ctx).Warn("cache profile failed", logger.Int64("uid", uid), logger.Error(err))
+	}
+	return u, nil
+}
+
+// FindByIds 批量查询用户，返回 id 到用户的映射，已经注销或者不存在的用户不在结果中。
+// 需要展示一批数据的作者之类的场景使用，避免一个一个查询
+func (repo *UserRepository) FindByIds(ctx context.Context, ids []int64) (map[int64]domain.User, error) {
+	ctx, span := tracer.Start(ctx, "UserRepository.FindByIds")
+	defer span.End()
+	users, err := repo.dao.FindByIds(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[int64]domain.User, len(users))
+	for _, u := range users {
+		u.Password = ""
+		res[u.Id] = repo.toDomain(u)
+	}
+	return res, nil
+}
+
+// SearchByNickname 查询昵称以 prefix 开头、可见范围为 privacy 的用户，结果按照 (nickname, id) 升序排列，
+// 从 (afterNickname, afterId) 之后开始返回最多 limit 条
+func (repo *UserRepository) SearchByNickname(ctx context.Context, prefix string, privacy domain.Privacy,
+	afterNickname string, afterId int64, limit int) ([]domain.User, error) {
+	ctx, span := tracer.Start(ctx, "UserRepository.SearchByNickname")
+	defer span.End()
+	users, err := repo.dao.SearchByNicknamePrefix(ctx, prefix, uint8(privacy), afterNickname, afterId, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.User, 0, len(users))
+	for _, u := range users {
+		u.Password = ""
+		res = append(res, repo.toDomain(u))
+	}
+	return res, nil
+}
+
+// Delete 注销用户，邮箱会被替换成 anonymizedEmail，注销成功的时候 evt 在同一个事务里面写入发件箱
+func (repo *UserRepository) Delete(ctx context.Context, uid int64, anonymizedEmail string, evt domain.Event) error {
+	ctx, span := tracer.Start(ctx, "UserRepository.Delete")
+	defer span.End()
+	msg, err := newOutboxMessage(evt)
+	if err != nil {
+		return err
+	}
+	err = repo.dao.SoftDeleteById(ctx, uid, anonymizedEmail, msg)
+	repo.invalidateProfile(ctx, uid)
+	return err
+}
+
+// invalidateProfile 删除缓存的个人资料。更新失败的时候也删除，
+// 因为版本冲突说明数据库里面的资料已经被别人改过了，缓存可能是旧的
+func (repo *UserRepository) invalidateProfile(ctx context.Context, uid int64) {
+	// 删除失败的时候缓存会在过期之后自然失效
+	if err := repo.cache.DelProfile(ctx, uid); err != nil {
+		logger.FromContext(ctx).Warn("invalidate profile cache failed", logger.Int64("uid", uid), logger.Error(err))
+	}
+}
+
+// PurgeDeleted 物理删除注销时间早于 before 的用户以及他们的所有数据，返回本次删除的用户数。
+// 每个删除的用户在同一个事务里面写入一条 UserPurged 事件，由下游删除头像文件
+func (repo *UserRepository) PurgeDeleted(ctx context.Context, before time.Time, limit int) (int64, error) {
+	ctx, span := tracer.Start(ctx, "UserRepository.PurgeDeleted")
+	defer span.End()
+	return repo.dao.PurgeDeleted(ctx, before.UnixMilli(), limit, domain.BizArticle,
+		func(u dao.User) (dao.OutboxMessage, error) {
+			return newOutboxMessage(domain.UserPurged{Uid: u.Id,
+				Avatar: domain.Avatar{URL: u.Avatar, ThumbnailURL: u.AvatarThumb}})
+		})
+}
+
+// RevokeSessions 让用户在 at 之前登录得到的会话全部失效
+func (repo *UserRepository) RevokeSessions(ctx context.Context, uid int64, at time.Time) error {
+	ctx, span := tracer.Start(ctx, "UserRepository.RevokeSessions")
+	defer span.End()
+	return repo.cache.RevokeSessions(ctx, uid, at)
+}
+
+// SessionsRevokedAt 查询用户会话的吊销时间，没有吊销过的时候返回零值
+func (repo *UserRepository) SessionsRevokedAt(ctx context.Context, uid int64) (time.Time, error) {
+	ctx, span := tracer.Start(ctx, "UserRepository.SessionsRevokedAt")
+	defer span.End()
+	return repo.cache.SessionsRevokedAt(ctx, uid)
+}
+
+// SaveResetToken 保存找回密码的 token，在 expiration 之后失效
+func (repo *UserRepository) SaveResetToken(ctx context.Context, token string, uid int64,
+	expiration time.Duration) error {
+	ctx, span := tracer.Start(ctx, "UserRepository.SaveResetToken")
+	defer span.End()
+	return repo.cache.SetResetToken(ctx, token, uid, expiration)
+}
+
+// FindResetToken 查询 token 对应的用户，token 不存在或者已经过期的时候返回 ErrResetTokenNotFound
+func (repo *UserRepository) FindResetToken(ctx context.Context, token string) (int64, error) {
+	ctx, span := tracer.Start(ctx, "UserRepository.FindResetToken")
+	defer span.End()
+	uid, err := repo.cache.GetResetToken(ctx, token)
+	if errors.Is(err, cache.ErrKeyNotExist) {
+		return 0, ErrResetTokenNotFound
+	}
+	return uid, err
+}
+
+// DeleteResetToken 删除 token，token 只能使用一次
+func (repo *UserRepository) DeleteResetToken(ctx context.Context, token string) error {
+	ctx, span := tracer.Start(ctx, "UserRepository.DeleteResetToken")
+	defer span.End()
+	return repo.cache.DelResetToken(ctx, token)
+}
+
+// toMillis 零值时间在数据库里面存 0，而不是零值时间对应的毫秒数
+func toMillis(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+// fromMillis 数据库里面的 0 转换成零值时间，避免没有设置的生日变成 1970-01-01
+func fromMillis(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
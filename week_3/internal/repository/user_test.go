@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/glebarez/sqlite"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go_homework/week_3/internal/domain"
+	"go_homework/week_3/internal/repository/cache"
+	"go_homework/week_3/internal/repository/dao"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"testing"
+	"time"
+)
+
+// newTestDB 创建一个内存中的 sqlite 数据库，内存数据库每个连接各自独立，所以只允许一个连接
+func newTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	require.NoError(t, dao.InitTables(db))
+	return db
+}
+
+func newTestUserRepository(t *testing.T) (*UserRepository, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	rc := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { _ = rc.Close() })
+	repo := NewUserRepository(dao.NewUserDAO(newTestDB(t)), cache.NewUserCache(rc, time.Hour))
+	return repo, mr
+}
+
+func registered(u domain.User) domain.Event {
+	return domain.UserRegistered{Uid: u.Id, Email: u.Email, Ctime: u.Ctime}
+}
+
+func createUser(t *testing.T, repo *UserRepository, email string) domain.User {
+	ctx := context.Background()
+	require.NoError(t, repo.Create(ctx, domain.User{Email: email, Password: "hash"}, registered))
+	u, err := repo.FindByEmail(ctx, email)
+	require.NoError(t, err)
+	return u
+}
+
+func TestUserRepository_Create(t *testing.T) {
+	repo, _ := newTestUserRepository(t)
+	ctx := context.Background()
+	createUser(t, repo, "a@qq.com")
+	err := repo.Create(ctx, domain.User{Email: "a@qq.com", Password: "hash"}, registered)
+	assert.ErrorIs(t, err, ErrDuplicateEmail)
+
+	_, err = repo.FindByEmail(ctx, "missing@qq.com")
+	assert.ErrorIs(t, err, ErrUserNotFound)
+}
+
+func TestUserRepository_FindProfile(t *testing.T) {
+	testCases := []struct {
+		name string
+		// 准备数据，返回要查询的用户 id 和期望的昵称
+		before       func(t *testing.T, repo *UserRepository, mr *miniredis.Miniredis) (int64, string)
+		wantErr      error
+		wantCacheSet bool
+	}{
+		{
+			name: "cache hit",
+			before: func(t *testing.T, repo *UserRepository, mr *miniredis.Miniredis) (int64, string) {
+				// 只在缓存里面，说明没有查数据库
+				require.NoError(t, repo.cache.SetProfile(context.Background(), domain.User{Id: 100, Nickname: "cached"}))
+				return 100, "cached"
+			},
+			wantCacheSet: true,
+		},
+		{
+			name: "cache miss",
+			before: func(t *testing.T, repo *UserRepository, mr *miniredis.Miniredis) (int64, string) {
+				u := createUser(t, repo, "a@qq.com")
+				return u.Id, ""
+			},
+			wantCacheSet: true,
+		},
+		{
+			name: "redis unavailable",
+			before: func(t *testing.T, repo *UserRepository, mr *miniredis.Miniredis) (int64, string) {
+				u := createUser(t, repo, "a@qq.com")
+				mr.Close()
+				return u.Id, ""
+			},
+		},
+		{
+			name: "not found",
+			before: func(t *testing.T, repo *UserRepository, mr *miniredis.Miniredis) (int64, string) {
+				return 100, ""
+			},
+			wantErr: ErrUserNotFound,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, mr := newTestUserRepository(t)
+			ctx := context.Background()
+			uid, nickname := tc.before(t, repo, mr)
+			u, err := repo.FindProfile(ctx, uid)
+			assert.ErrorIs(t, err, tc.wantErr)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, uid, u.Id)
+			assert.Equal(t, nickname, u.Nickname)
+			assert.Empty(t, u.Password)
+			if tc.wantCacheSet {
+				cached, err := repo.cache.GetProfile(ctx, uid)
+				require.NoError(t, err)
+				assert.Equal(t, uid, cached.Id)
+				assert.Empty(t, cached.Password)
+			}
+		})
+	}
+}
+
+func TestUserRepository_UpdateFields_InvalidateCache(t *testing.T) {
+	repo, _ := newTestUserRepository(t)
+	ctx := context.Background()
+	u := createUser(t, repo, "a@qq.com")
+	_, err := repo.FindProfile(ctx, u.Id)
+	require.NoError(t, err)
+
+	u.Nickname = "new"
+	evt := domain.UserProfileUpdated{Uid: u.Id, Fields: []domain.UserField{domain.UserFieldNickname}}
+	require.NoError(t, repo.UpdateFields(ctx, u, []domain.UserField{domain.UserFieldNickname}, evt))
+	_, err = repo.cache.GetProfile(ctx, u.Id)
+	assert.ErrorIs(t, err, cache.ErrKeyNotExist)
+
+	// 版本冲突的时候也删除缓存
+	_, err = repo.FindProfile(ctx, u.Id)
+	require.NoError(t, err)
+	err = repo.UpdateFields(ctx, u, []domain.UserField{domain.UserFieldNickname}, evt)
+	assert.ErrorIs(t, err, ErrVersionConflict)
+	_, err = repo.cache.GetProfile(ctx, u.Id)
+	assert.ErrorIs(t, err, cache.ErrKeyNotExist)
+
+	got, err := repo.FindProfile(ctx, u.Id)
+	require.NoError(t, err)
+	assert.Equal(t, "new", got.Nickname)
+	assert.Equal(t, int64(2), got.Version)
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"go_homework/week_3/internal/domain"
+	"go_homework/week_3/internal/repository"
+	"go_homework/week_3/pkg/logger"
+	"go_homework/week_3/pkg/pagination"
+	"time"
+)
+
+var ErrArticleNotFound = repository.ErrArticleNotFound
+
+const (
+	// 作者文章列表每页默认和最多返回的条数
+	articleListDefaultLimit = 20
+	articleListMaxLimit     = 50
+)
+
+// articleCursor 作者文章列表的分页游标，也就是上一页最后一篇文章的排序键
+type articleCursor struct {
+	// 更新时间，毫秒
+	Utime int64 `json:"u"`
+	Id    int64 `json:"i"`
+}
+
+type ArticleService struct {
+	repo     *repository.ArticleRepository
+	userRepo *repository.UserRepository
+	feedSvc  *FeedService
+}
+
+// NewArticleService userRepo 用于查询作者的昵称，feedSvc 用于把发表的文章推送给粉丝
+func NewArticleService(repo *repository.ArticleRepository, userRepo *repository.UserRepository,
+	feedSvc *FeedService) *ArticleService {
+	return &ArticleService{repo: repo, userRepo: userRepo, feedSvc: feedSvc}
+}
+
+// Save 保存草稿，art.Id 为 0 的时候新建文章，返回文章的 id。
+// 已经发表的文章修改之后回到草稿状态，读者看到的还是上一次发表的版本，直到重新发表
+func (svc *ArticleService) Save(ctx context.Context, art domain.Article) (int64, error) {
+	ctx, span := tracer.Start(ctx, "ArticleService.Save")
+	defer span.End()
+	art.Status = domain.ArticleStatusDraft
+	if art.Id > 0 {
+		return art.Id, svc.repo.Update(ctx, art)
+	}
+	return svc.repo.Create(ctx, art)
+}
+
+// Publish 保存并发表文章，art.Id 为 0 的时候新建文章，返回文章的 id
+func (svc *ArticleService) Publish(ctx context.Context, art domain.Article) (int64, error) {
+	ctx, span := tracer.Start(ctx, "ArticleService.Publish")
+	defer span.End()
+	art.Status = domain.ArticleStatusPublished
+	pub, err := svc.repo.Sync(ctx, art)
+	if err != nil {
+		return 0, err
+	}
+	// 推送失败的粉丝在关注流里面看不到这篇文章，不影响发表
+	if err = svc.feedSvc.Push(ctx, pub); err != nil {
+		logger.FromContext(ctx).Error("push article to feed failed",
+			logger.Int64("article_id", pub.Id), logger.Error(err))
+	}
+	return pub.Id, nil
+}
+
+// Detail 作者查看自己的文章，看到的是制作库中的版本。不是自己的文章返回 ErrArticleNotFound
+func (svc *ArticleService) Detail(ctx context.Context, authorId, id int64) (domain.Article, error) {
+	ctx, span := tracer.Start(ctx, "ArticleService.Detail")
+	defer span.End()
+	art, err := svc.repo.FindById(ctx, id)
+	if err != nil {
+		return domain.Article{}, err
+	}
+	if art.Author.Id != authorId {
+		return domain.Article{}, ErrArticleNotFound
+	}
+	return art, nil
+}
+
+// Withdraw 撤回文章，撤回之后只有作者自己可以看到
+func (svc *ArticleService) Withdraw(ctx context.Context, authorId, id int64) error {
+	ctx, span := tracer.Start(ctx, "ArticleService.Withdraw")
+	defer span.End()
+	return svc.repo.SyncStatus(ctx, authorId, id, domain.ArticleStatusPrivate)
+}
+
+// List 作者自己的文章列表，按照更新时间倒序排列，包括草稿和撤回的文章。
+// 列表中文章的 Content 只有摘要
+func (svc *ArticleService) List(ctx context.Context, authorId int64, cursor string,
+	limit int) (pagination.Page[domain.Article], error) {
+	ctx, span := tracer.Start(ctx, "ArticleService.List")
+	defer span.End()
+	var after articleCursor
+	if err := pagination.DecodeCursor(cursor, &after); err != nil {
+		return pagination.Page[domain.Article]{}, ErrInvalidCursor.Wrap(err)
+	}
+	limit = pagination.Limit(limit, articleListDefaultLimit, articleListMaxLimit)
+	// 多查一条用来判断还有没有下一页
+	arts, err := svc.repo.ListByAuthor(ctx, authorId, time.UnixMilli(after.Utime), after.Id, limit+1)
+	if err != nil {
+		return pagination.Page[domain.Article]{}, err
+	}
+	return pagination.NewPage(arts, limit, func(last domain.Article) any {
+		return articleCursor{Utime: last.Utime.UnixMilli(), Id: last.Id}
+	})
+}
+
+// PubDetail 读者查看已经发表的文章，看到的是线上库中的版本。
+// 撤回的文章和不存在的文章一样返回 ErrArticleNotFound
+func (svc *ArticleService) PubDetail(ctx context.Context, id int64) (domain.Article, error) {
+	ctx, span := tracer.Start(ctx, "ArticleService.PubDetail")
+	defer span.End()
+	art, err := svc.repo.FindPublishedById(ctx, id)
+	if err != nil {
+		return domain.Article{}, err
+	}
+	if art.Status != domain.ArticleStatusPublished {
+		return domain.Article{}, ErrArticleNotFound
+	}
+	arts := []domain.Article{art}
+	if err = fillAuthors(ctx, svc.userRepo, arts); err != nil {
+		return domain.Article{}, err
+	}
+	return arts[0], nil
+}
+
+// fillAuthors 批量查询文章作者的昵称，一次查询所有作者，避免每篇文章查一次。
+// 作者已经注销的时候昵称留空
+func fillAuthors(ctx context.Context, userRepo *repository.UserRepository, arts []domain.Article) error {
+	ids := make([]int64, 0, len(arts))
+	seen := make(map[int64]struct{}, len(arts))
+	for _, art := range arts {
+		if _, ok := seen[art.Author.Id]; ok {
+			continue
+		}
+		seen[art.Author.Id] = struct{}{}
+		ids = append(ids, art.Author.Id)
+	}
+	users, err := userRepo.FindByIds(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for i := range arts {
+		arts[i].Author.Name = users[arts[i].Author.Id].Nickname
+	}
+	return nil
+}
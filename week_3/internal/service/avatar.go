@@ -0,0 +1,165 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"go_homework/week_3/internal/domain"
+	"go_homework/week_3/internal/errs"
+	"go_homework/week_3/internal/repository"
+	"go_homework/week_3/pkg/logger"
+	"go_homework/week_3/pkg/objstore"
+	"golang.org/x/image/draw"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+var (
+	ErrAvatarTooLarge        = errs.Validation(errs.CodeAvatarTooLarge, "The avatar is too large")
+	ErrAvatarUnsupportedType = errs.Validation(errs.CodeAvatarUnsupportedType,
+		"The avatar must be a PNG, JPEG or GIF image")
+)
+
+const (
+	// 缩略图的边长
+	thumbnailSize = 128
+	// 图片的最大边长，避免很小的文件解码出超大的图片把内存打满
+	maxAvatarDimension = 4096
+)
+
+// 支持的图片格式，key 是 http.DetectContentType 识别出来的类型，value 是文件扩展名
+var avatarTypes = map[string]string{
+	"image/png":  "png",
+	"image/jpeg": "jpg",
+	"image/gif":  "gif",
+}
+
+type AvatarService struct {
+	repo    *repository.UserRepository
+	storage objstore.Storage
+	// 头像文件的最大字节数
+	maxSize int64
+}
+
+func NewAvatarService(repo *repository.UserRepository, storage objstore.Storage, maxSize int64) *AvatarService {
+	return &AvatarService{repo: repo, storage: storage, maxSize: maxSize}
+}
+
+// MaxSize 头像文件的最大字节数，web 层据此限制请求体的大小
+func (svc *AvatarService) MaxSize() int64 {
+	return svc.maxSize
+}
+
+// Upload 校验图片的大小和格式，生成缩略图，保存原图和缩略图并更新用户的头像
+func (svc *AvatarService) Upload(ctx context.Context, uid int64, data []byte) (domain.Avatar, error) {
+	ctx, span := tracer.Start(ctx, "AvatarService.Upload")
+	defer span.End()
+	if int64(len(data)) > svc.maxSize {
+		return domain.Avatar{}, ErrAvatarTooLarge
+	}
+	// 根据文件内容判断类型，不信任客户端传上来的 Content-Type 和文件名
+	contentType := http.DetectContentType(data)
+	ext, ok := avatarTypes[contentType]
+	if !ok {
+		return domain.Avatar{}, ErrAvatarUnsupportedType
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || cfg.Width > maxAvatarDimension || cfg.Height > maxAvatarDimension {
+		return domain.Avatar{}, ErrAvatarUnsupportedType.Wrap(err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return domain.Avatar{}, ErrAvatarUnsupportedType.Wrap(err)
+	}
+	thumb, err := encodeThumbnail(img, contentType)
+	if err != nil {
+		return domain.Avatar{}, err
+	}
+
+	name, err := randomName()
+	if err != nil {
+		return domain.Avatar{}, err
+	}
+	var avatar domain.Avatar
+	avatar.URL, err = svc.storage.Put(ctx, fmt.Sprintf("avatars/%d/%s.%s", uid, name, ext),
+		bytes.NewReader(data), int64(len(data)), contentType)
+	if err != nil {
+		return domain.Avatar{}, err
+	}
+	avatar.ThumbnailURL, err = svc.storage.Put(ctx, fmt.Sprintf("avatars/%d/%s_thumb.%s", uid, name, ext),
+		bytes.NewReader(thumb), int64(len(thumb)), contentType)
+	if err != nil {
+		svc.deleteFilesQuietly(ctx, uid, domain.Avatar{URL: avatar.URL})
+		return domain.Avatar{}, err
+	}
+	old, err := svc.repo.UpdateAvatar(ctx, uid, avatar)
+	if err != nil {
+		// 没有用上的新文件删掉
+		svc.deleteFilesQuietly(ctx, uid, avatar)
+		return domain.Avatar{}, err
+	}
+	// 头像已经换成新的了，旧文件删除失败只会留下没有引用的文件，不影响这次上传
+	svc.deleteFilesQuietly(ctx, uid, old)
+	return avatar, nil
+}
+
+func (svc *AvatarService) deleteFilesQuietly(ctx context.Context, uid int64, avatar domain.Avatar) {
+	if err := svc.DeleteFiles(ctx, avatar); err != nil {
+		logger.FromContext(ctx).Warn("delete avatar files failed",
+			logger.Int64("uid", uid), logger.Error(err))
+	}
+}
+
+// DeleteFiles 删除头像的原图和缩略图，不是当前存储保存的文件（例如外部的 URL）会被忽略
+func (svc *AvatarService) DeleteFiles(ctx context.Context, avatar domain.Avatar) error {
+	ctx, span := tracer.Start(ctx, "AvatarService.DeleteFiles")
+	defer span.End()
+	for _, url := range []string{avatar.URL, avatar.ThumbnailURL} {
+		key, ok := svc.storage.Key(url)
+		if !ok {
+			continue
+		}
+		if err := svc.storage.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeThumbnail 居中裁剪成正方形之后缩放到 thumbnailSize，保持原来的格式
+func encodeThumbnail(img image.Image, contentType string) ([]byte, error) {
+	b := img.Bounds()
+	side := min(b.Dx(), b.Dy())
+	crop := image.Rect(0, 0, side, side).Add(image.Pt(
+		b.Min.X+(b.Dx()-side)/2,
+		b.Min.Y+(b.Dy()-side)/2,
+	))
+	dst := image.NewRGBA(image.Rect(0, 0, thumbnailSize, thumbnailSize))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, crop, draw.Over, nil)
+
+	var buf bytes.Buffer
+	var err error
+	switch contentType {
+	case "image/jpeg":
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85})
+	case "image/gif":
+		err = gif.Encode(&buf, dst, nil)
+	default:
+		err = png.Encode(&buf, dst)
+	}
+	return buf.Bytes(), err
+}
+
+// randomName 每次上传使用新的文件名，避免 CDN 或者浏览器缓存旧的头像
+func randomName() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
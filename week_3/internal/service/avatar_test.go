@@ -0,0 +1,59 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go_homework/week_3/internal/domain"
+	"go_homework/week_3/internal/repository"
+	"go_homework/week_3/internal/repository/cache"
+	"go_homework/week_3/internal/repository/dao"
+	"go_homework/week_3/pkg/objstore"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAvatarService_Upload_DeletesPreviousFiles(t *testing.T) {
+	db := newTestDB(t)
+	mr := miniredis.RunT(t)
+	rc := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rc.Close() })
+	repo := repository.NewUserRepository(dao.NewUserDAO(db), cache.NewUserCache(rc, time.Hour))
+	dir := t.TempDir()
+	const baseURL = "http://localhost/static"
+	svc := NewAvatarService(repo, objstore.NewLocalStorage(dir, baseURL), 1<<20)
+	ctx := context.Background()
+
+	require.NoError(t, repo.Create(ctx, domain.User{Email: "a@qq.com", Password: "hash"},
+		func(u domain.User) domain.Event { return domain.UserRegistered{Uid: u.Id, Email: u.Email} }))
+	u, err := repo.FindByEmail(ctx, "a@qq.com")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 16, 16))))
+	exists := func(url string) bool {
+		_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(url, baseURL+"/"))))
+		return err == nil
+	}
+
+	first, err := svc.Upload(ctx, u.Id, buf.Bytes())
+	require.NoError(t, err)
+	assert.True(t, exists(first.URL))
+	assert.True(t, exists(first.ThumbnailURL))
+
+	second, err := svc.Upload(ctx, u.Id, buf.Bytes())
+	require.NoError(t, err)
+	assert.True(t, exists(second.URL))
+	assert.True(t, exists(second.ThumbnailURL))
+	// 换了头像之后旧的原图和缩略图都被删除
+	assert.False(t, exists(first.URL))
+	assert.False(t, exists(first.ThumbnailURL))
+}
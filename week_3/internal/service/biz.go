@@ -0,0 +1,29 @@
+package service
+
+import (
+	"context"
+	"go_homework/week_3/internal/domain"
+	"go_homework/week_3/internal/errs"
+	"go_homework/week_3/internal/repository"
+)
+
+// ErrUnsupportedBiz 不支持互动的业务，web 层已经校验过 biz，正常情况下不会出现
+var ErrUnsupportedBiz = errs.Validation(errs.CodeInvalidInput, "Unsupported biz")
+
+// checkBizVisible 点赞、收藏、评论之前检查业务对象存在并且读者能够看到，
+// 撤回的文章和不存在的文章一样返回 ErrArticleNotFound。支持新的业务的时候在这里加上对应的检查
+func checkBizVisible(ctx context.Context, artRepo *repository.ArticleRepository, biz string, bizId int64) error {
+	switch biz {
+	case domain.BizArticle:
+		art, err := artRepo.FindPublishedById(ctx, bizId)
+		if err != nil {
+			return err
+		}
+		if art.Status != domain.ArticleStatusPublished {
+			return ErrArticleNotFound
+		}
+		return nil
+	default:
+		return ErrUnsupportedBiz
+	}
+}
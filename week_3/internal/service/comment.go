@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"go_homework/week_3/internal/domain"
+	"go_homework/week_3/internal/repository"
+	"go_homework/week_3/pkg/pagination"
+)
+
+var ErrCommentNotFound = repository.ErrCommentNotFound
+
+const (
+	// 评论和回复每页默认和最多返回的条数
+	commentDefaultLimit = 20
+	commentMaxLimit     = 50
+)
+
+// commentCursor 评论的分页游标，也就是上一页最后一条评论的 id
+type commentCursor struct {
+	Id int64 `json:"i"`
+}
+
+type CommentService struct {
+	repo     *repository.CommentRepository
+	userRepo *repository.UserRepository
+	artRepo  *repository.ArticleRepository
+}
+
+// NewCommentService userRepo 用于查询评论人的昵称和头像，artRepo 用于发表评论之前检查文章已经发表
+func NewCommentService(repo *repository.CommentRepository, userRepo *repository.UserRepository,
+	artRepo *repository.ArticleRepository) *CommentService {
+	return &CommentService{repo: repo, userRepo: userRepo, artRepo: artRepo}
+}
+
+// Post 发表评论，返回评论的 id。业务对象不存在或者读者看不到的时候返回对应的 NotFound 错误。
+// c.ParentId 大于 0 的时候是回复，被回复的评论不存在或者不属于同一个业务对象的时候返回 ErrCommentNotFound
+func (svc *CommentService) Post(ctx context.Context, c domain.Comment) (int64, error) {
+	ctx, span := tracer.Start(ctx, "CommentService.Post")
+	defer span.End()
+	if err := checkBizVisible(ctx, svc.artRepo, c.Biz, c.BizId); err != nil {
+		return 0, err
+	}
+	c.RootId = 0
+	if c.ParentId > 0 {
+		parent, err := svc.repo.FindById(ctx, c.ParentId)
+		if err != nil {
+			return 0, err
+		}
+		if parent.Biz != c.Biz || parent.BizId != c.BizId {
+			return 0, ErrCommentNotFound
+		}
+		// 回复顶层评论的时候 root 就是它，回复别的回复的时候和它在同一个 root 下面
+		c.RootId = parent.RootId
+		if c.RootId == 0 {
+			c.RootId = parent.Id
+		}
+	}
+	return svc.repo.Create(ctx, c)
+}
+
+// Delete 删除 uid 自己的评论，它下面所有的回复一起删除
+func (svc *CommentService) Delete(ctx context.Context, uid, id int64) error {
+	ctx, span := tracer.Start(ctx, "CommentService.Delete")
+	defer span.End()
+	return svc.repo.Delete(ctx, uid, id)
+}
+
+// Roots 业务对象的顶层评论，按照时间倒序排列，回复需要通过 Replies 单独加载
+func (svc *CommentService) Roots(ctx context.Context, biz string, bizId int64, cursor string,
+	limit int) (pagination.Page[domain.Comment], error) {
+	ctx, span := tracer.Start(ctx, "CommentService.Roots")
+	defer span.End()
+	return svc.list(ctx, cursor, limit, func(afterId int64, limit int) ([]domain.Comment, error) {
+		return svc.repo.ListRoots(ctx, biz, bizId, afterId, limit)
+	})
+}
+
+// Replies 顶层评论 rootId 下面的回复，按照时间正序排列
+func (svc *CommentService) Replies(ctx context.Context, rootId int64, cursor string,
+	limit int) (pagination.Page[domain.Comment], error) {
+	ctx, span := tracer.Start(ctx, "CommentService.Replies")
+	defer span.End()
+	return svc.list(ctx, cursor, limit, func(afterId int64, limit int) ([]domain.Comment, error) {
+		return svc.repo.ListReplies(ctx, rootId, afterId, limit)
+	})
+}
+
+func (svc *CommentService) list(ctx context.Context, cursor string, limit int,
+	listFn func(afterId int64, limit int) ([]domain.Comment, error)) (pagination.Page[domain.Comment], error) {
+	var after commentCursor
+	if err := pagination.DecodeCursor(cursor, &after); err != nil {
+		return pagination.Page[domain.Comment]{}, ErrInvalidCursor.Wrap(err)
+	}
+	limit = pagination.Limit(limit, commentDefaultLimit, commentMaxLimit)
+	// 多查一条用来判断还有没有下一页
+	cs, err := listFn(after.Id, limit+1)
+	if err != nil {
+		return pagination.Page[domain.Comment]{}, err
+	}
+	page, err := pagination.NewPage(cs, limit, func(last domain.Comment) any {
+		return commentCursor{Id: last.Id}
+	})
+	if err != nil {
+		return pagination.Page[domain.Comment]{}, err
+	}
+	return page, svc.fillCommentators(ctx, page.Items)
+}
+
+// fillCommentators 批量查询评论人的昵称和头像，已经注销的用户只保留 id
+func (svc *CommentService) fillCommentators(ctx context.Context, cs []domain.Comment) error {
+	ids := make([]int64, 0, len(cs))
+	for _, c := range cs {
+		ids = append(ids, c.Commentator.Id)
+	}
+	users, err := svc.userRepo.FindByIds(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for i := range cs {
+		if u, ok := users[cs[i].Commentator.Id]; ok {
+			cs[i].Commentator = u
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go_homework/week_3/internal/domain"
+	"go_homework/week_3/internal/repository"
+	"go_homework/week_3/internal/repository/cache"
+	"go_homework/week_3/internal/repository/dao"
+	"testing"
+	"time"
+)
+
+func TestCommentService_Post(t *testing.T) {
+	const author = 1
+	db := newTestDB(t)
+	mr := miniredis.RunT(t)
+	rc := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rc.Close() })
+	artRepo := repository.NewArticleRepository(dao.NewArticleDAO(db), cache.NewArticleCache(rc))
+	svc := NewCommentService(repository.NewCommentRepository(dao.NewCommentDAO(db)),
+		repository.NewUserRepository(dao.NewUserDAO(db), cache.NewUserCache(rc, time.Hour)), artRepo)
+	ctx := context.Background()
+	published, err := artRepo.Sync(ctx, domain.Article{Title: "t", Content: "c",
+		Author: domain.Author{Id: author}, Status: domain.ArticleStatusPublished})
+	require.NoError(t, err)
+	withdrawn, err := artRepo.Sync(ctx, domain.Article{Title: "t", Content: "c",
+		Author: domain.Author{Id: author}, Status: domain.ArticleStatusPublished})
+	require.NoError(t, err)
+	require.NoError(t, artRepo.SyncStatus(ctx, author, withdrawn.Id, domain.ArticleStatusPrivate))
+
+	comment := func(bizId, parentId int64) domain.Comment {
+		return domain.Comment{Biz: domain.BizArticle, BizId: bizId, ParentId: parentId,
+			Commentator: domain.User{Id: 2}, Content: "content"}
+	}
+	rootId, err := svc.Post(ctx, comment(published.Id, 0))
+	require.NoError(t, err)
+	_, err = svc.Post(ctx, comment(published.Id, rootId))
+	require.NoError(t, err)
+
+	_, err = svc.Post(ctx, comment(withdrawn.Id, 0))
+	assert.ErrorIs(t, err, ErrArticleNotFound)
+	_, err = svc.Post(ctx, comment(100, 0))
+	assert.ErrorIs(t, err, ErrArticleNotFound)
+	_, err = svc.Post(ctx, domain.Comment{Biz: "unknown", BizId: 1, Content: "content"})
+	assert.ErrorIs(t, err, ErrUnsupportedBiz)
+	// 回复的评论在另一个业务对象下面
+	other, err := artRepo.Sync(ctx, domain.Article{Title: "t", Content: "c",
+		Author: domain.Author{Id: author}, Status: domain.ArticleStatusPublished})
+	require.NoError(t, err)
+	_, err = svc.Post(ctx, comment(other.Id, rootId))
+	assert.ErrorIs(t, err, ErrCommentNotFound)
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"go_homework/week_3/internal/domain"
+	"go_homework/week_3/internal/repository"
+)
+
+// exportBatchSize 导出的时候每一类数据分批查询，避免一次把大量数据读进内存
+const exportBatchSize = 200
+
+// ExportService 导出用户保存在系统中的全部数据：账号、文章、评论、关注、点赞和收藏
+type ExportService struct {
+	userRepo    *repository.UserRepository
+	artRepo     *repository.ArticleRepository
+	commentRepo *repository.CommentRepository
+	followRepo  *repository.FollowRepository
+	intrRepo    *repository.InteractionRepository
+}
+
+func NewExportService(userRepo *repository.UserRepository, artRepo *repository.ArticleRepository,
+	commentRepo *repository.CommentRepository, followRepo *repository.FollowRepository,
+	intrRepo *repository.InteractionRepository) *ExportService {
+	return &ExportService{userRepo: userRepo, artRepo: artRepo, commentRepo: commentRepo,
+		followRepo: followRepo, intrRepo: intrRepo}
+}
+
+// Export 导出用户的全部数据，密码哈希不会导出。用户不存在或者已经注销的时候返回 ErrUserNotFound
+func (svc *ExportService) Export(ctx context.Context, uid int64) (domain.UserArchive, error) {
+	ctx, span := tracer.Start(ctx, "ExportService.Export")
+	defer span.End()
+	u, err := svc.userRepo.FindByID(ctx, uid)
+	if err != nil {
+		return domain.UserArchive{}, err
+	}
+	u.Password = ""
+	res := domain.UserArchive{Account: u}
+	if res.Articles, err = listAll(ctx, func(afterId int64) ([]domain.Article, error) {
+		return svc.artRepo.ListFullByAuthor(ctx, uid, afterId, exportBatchSize)
+	}, func(art domain.Article) int64 { return art.Id }); err != nil {
+		return domain.UserArchive{}, err
+	}
+	if res.Comments, err = listAll(ctx, func(afterId int64) ([]domain.Comment, error) {
+		return svc.commentRepo.ListByCommentator(ctx, uid, afterId, exportBatchSize)
+	}, func(c domain.Comment) int64 { return c.Id }); err != nil {
+		return domain.UserArchive{}, err
+	}
+	if res.Followees, err = listAll(ctx, func(afterId int64) ([]domain.FollowRelation, error) {
+		return svc.followRepo.ListFollowees(ctx, uid, afterId, exportBatchSize)
+	}, func(rel domain.FollowRelation) int64 { return rel.Id }); err != nil {
+		return domain.UserArchive{}, err
+	}
+	if res.Likes, err = listAll(ctx, func(afterId int64) ([]domain.Like, error) {
+		return svc.intrRepo.ListLikes(ctx, uid, afterId, exportBatchSize)
+	}, func(l domain.Like) int64 { return l.Id }); err != nil {
+		return domain.UserArchive{}, err
+	}
+	if res.Collections, err = svc.intrRepo.ListCollections(ctx, uid); err != nil {
+		return domain.UserArchive{}, err
+	}
+	if res.CollectionItems, err = listAll(ctx, func(afterId int64) ([]domain.CollectionItem, error) {
+		return svc.intrRepo.ListCollectionItems(ctx, uid, afterId, exportBatchSize)
+	}, func(item domain.CollectionItem) int64 { return item.Id }); err != nil {
+		return domain.UserArchive{}, err
+	}
+	return res, nil
+}
+
+// listAll 按照 id 游标一批一批地查询，直到某一批不满 exportBatchSize。
+// list 传入上一批最后一条记录的 id，第一批传 0
+func listAll[T any](ctx context.Context, list func(afterId int64) ([]T, error), id func(T) int64) ([]T, error) {
+	var res []T
+	var afterId int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		batch, err := list(afterId)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, batch...)
+		if len(batch) < exportBatchSize {
+			return res, nil
+		}
+		afterId = id(batch[len(batch)-1])
+	}
+}
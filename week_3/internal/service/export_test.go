@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go_homework/week_3/internal/domain"
+	"go_homework/week_3/internal/repository"
+	"go_homework/week_3/internal/repository/cache"
+	"go_homework/week_3/internal/repository/dao"
+	"go_homework/week_3/pkg/events"
+	"testing"
+	"time"
+)
+
+func TestExportService_Export(t *testing.T) {
+	db := newTestDB(t)
+	mr := miniredis.RunT(t)
+	rc := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rc.Close() })
+	userRepo := repository.NewUserRepository(dao.NewUserDAO(db), cache.NewUserCache(rc, time.Hour))
+	artRepo := repository.NewArticleRepository(dao.NewArticleDAO(db), cache.NewArticleCache(rc))
+	commentRepo := repository.NewCommentRepository(dao.NewCommentDAO(db))
+	followRepo := repository.NewFollowRepository(dao.NewFollowDAO(db), cache.NewFollowCache(rc))
+	intrRepo := repository.NewInteractionRepository(dao.NewInteractionDAO(db), cache.NewInteractionCache(rc))
+	intrSvc := NewInteractionService(intrRepo, artRepo, events.NewMemoryBroker(10))
+	svc := NewExportService(userRepo, artRepo, commentRepo, followRepo, intrRepo)
+	ctx := context.Background()
+
+	createUser := func(email string) domain.User {
+		require.NoError(t, userRepo.Create(ctx, domain.User{Email: email, Password: "hash"},
+			func(u domain.User) domain.Event { return domain.UserRegistered{Uid: u.Id, Email: u.Email} }))
+		u, err := userRepo.FindByEmail(ctx, email)
+		require.NoError(t, err)
+		return u
+	}
+	u := createUser("a@qq.com")
+	other := createUser("b@qq.com")
+
+	pub, err := artRepo.Sync(ctx, domain.Article{Title: "published", Content: "full content",
+		Author: domain.Author{Id: u.Id}, Status: domain.ArticleStatusPublished})
+	require.NoError(t, err)
+	_, err = artRepo.Create(ctx, domain.Article{Title: "draft", Content: "draft content",
+		Author: domain.Author{Id: u.Id}, Status: domain.ArticleStatusDraft})
+	require.NoError(t, err)
+	_, err = artRepo.Create(ctx, domain.Article{Title: "other", Content: "c",
+		Author: domain.Author{Id: other.Id}, Status: domain.ArticleStatusDraft})
+	require.NoError(t, err)
+	// 评论超过一批，需要分批查询
+	const comments = exportBatchSize + 1
+	for i := 0; i < comments; i++ {
+		_, err = commentRepo.Create(ctx, domain.Comment{Biz: domain.BizArticle, BizId: pub.Id,
+			Commentator: domain.User{Id: u.Id}, Content: "comment"})
+		require.NoError(t, err)
+	}
+	_, err = commentRepo.Create(ctx, domain.Comment{Biz: domain.BizArticle, BizId: pub.Id,
+		Commentator: domain.User{Id: other.Id}, Content: "other"})
+	require.NoError(t, err)
+	require.NoError(t, followRepo.Follow(ctx, u.Id, other.Id))
+	require.NoError(t, followRepo.Follow(ctx, other.Id, u.Id))
+	require.NoError(t, intrSvc.Like(ctx, domain.BizArticle, pub.Id, u.Id))
+	cid, err := intrSvc.CreateCollection(ctx, domain.Collection{Uid: u.Id, Name: "c"})
+	require.NoError(t, err)
+	_, err = intrSvc.CreateCollection(ctx, domain.Collection{Uid: u.Id, Name: "empty"})
+	require.NoError(t, err)
+	require.NoError(t, intrSvc.Collect(ctx, domain.BizArticle, pub.Id, cid, u.Id))
+
+	a, err := svc.Export(ctx, u.Id)
+	require.NoError(t, err)
+	assert.Equal(t, u.Email, a.Account.Email)
+	assert.Empty(t, a.Account.Password)
+	require.Len(t, a.Articles, 2)
+	assert.Equal(t, "full content", a.Articles[0].Content)
+	assert.Equal(t, "draft", a.Articles[1].Title)
+	assert.Len(t, a.Comments, comments)
+	require.Len(t, a.Followees, 1)
+	assert.Equal(t, other.Id, a.Followees[0].Followee)
+	require.Len(t, a.Likes, 1)
+	assert.Equal(t, pub.Id, a.Likes[0].BizId)
+	assert.Len(t, a.Collections, 2)
+	require.Len(t, a.CollectionItems, 1)
+	assert.Equal(t, cid, a.CollectionItems[0].Cid)
+
+	_, err = svc.Export(ctx, other.Id+1)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"go_homework/week_3/internal/domain"
+	"go_homework/week_3/internal/repository"
+	"go_homework/week_3/pkg/pagination"
+	"slices"
+	"time"
+)
+
+const (
+	// 粉丝数达到这个值的作者发表文章的时候不推送，由粉丝读的时候拉取
+	feedPushThreshold = 1000
+	// 推送的时候每一批查询的粉丝数量
+	feedPushBatchSize = 500
+	// 关注流每页默认和最多返回的条数
+	feedDefaultLimit = 20
+	feedMaxLimit     = 50
+)
+
+// feedCursor 关注流的分页游标，也就是上一页最后一篇文章的排序键
+type feedCursor struct {
+	// 第一次发表的时间，毫秒
+	Ctime int64 `json:"c"`
+	Id    int64 `json:"i"`
+}
+
+// FeedService 关注流，推拉结合：粉丝少的作者发表文章的时候推送到每一个粉丝的收件箱，
+// 粉丝多的作者不推送，粉丝读的时候直接查询这些作者的文章，两部分合并之后按照第一次发表的时间倒序排列。
+// 作者的粉丝数跨过阈值前后发表的文章可能只在其中一边，粉丝数变少之后以前没有推送的文章不会出现在关注流中
+type FeedService struct {
+	repo       *repository.FeedRepository
+	followRepo *repository.FollowRepository
+	userRepo   *repository.UserRepository
+}
+
+func NewFeedService(repo *repository.FeedRepository, followRepo *repository.FollowRepository,
+	userRepo *repository.UserRepository) *FeedService {
+	return &FeedService{repo: repo, followRepo: followRepo, userRepo: userRepo}
+}
+
+// Push 文章发表之后调用，作者的粉丝数少于 feedPushThreshold 的时候推送到所有粉丝的收件箱。
+// 重新发表同一篇文章不会重复推送
+func (svc *FeedService) Push(ctx context.Context, art domain.Article) error {
+	ctx, span := tracer.Start(ctx, "FeedService.Push")
+	defer span.End()
+	s, err := svc.followRepo.GetStatistic(ctx, art.Author.Id)
+	if err != nil {
+		return err
+	}
+	if s.Followers >= feedPushThreshold {
+		return nil
+	}
+	var afterId int64
+	for {
+		rels, err := svc.followRepo.ListFollowers(ctx, art.Author.Id, afterId, feedPushBatchSize)
+		if err != nil {
+			return err
+		}
+		uids := make([]int64, 0, len(rels))
+		for _, rel := range rels {
+			uids = append(uids, rel.Follower)
+		}
+		if err = svc.repo.Push(ctx, art, uids); err != nil {
+			return err
+		}
+		if len(rels) < feedPushBatchSize {
+			return nil
+		}
+		afterId = rels[len(rels)-1].Id
+	}
+}
+
+// Feed uid 关注的作者发表的文章，按照第一次发表的时间倒序排列，文章的内容只有摘要
+func (svc *FeedService) Feed(ctx context.Context, uid int64, cursor string,
+	limit int) (pagination.Page[domain.Article], error) {
+	ctx, span := tracer.Start(ctx, "FeedService.Feed")
+	defer span.End()
+	var after feedCursor
+	if err := pagination.DecodeCursor(cursor, &after); err != nil {
+		return pagination.Page[domain.Article]{}, ErrInvalidCursor.Wrap(err)
+	}
+	limit = pagination.Limit(limit, feedDefaultLimit, feedMaxLimit)
+	afterCtime := time.UnixMilli(after.Ctime)
+	// 两边都多查一条，合并之后才能判断还有没有下一页
+	pushed, err := svc.repo.FindPushArticles(ctx, uid, afterCtime, after.Id, limit+1)
+	if err != nil {
+		return pagination.Page[domain.Article]{}, err
+	}
+	pulled, err := svc.repo.FindPullArticles(ctx, uid, feedPushThreshold, afterCtime, after.Id, limit+1)
+	if err != nil {
+		return pagination.Page[domain.Article]{}, err
+	}
+	arts := mergeFeed(pushed, pulled, limit+1)
+	if err = fillAuthors(ctx, svc.userRepo, arts); err != nil {
+		return pagination.Page[domain.Article]{}, err
+	}
+	return pagination.NewPage(arts, limit, func(last domain.Article) any {
+		return feedCursor{Ctime: last.Ctime.UnixMilli(), Id: last.Id}
+	})
+}
+
+// mergeFeed 合并推和拉两部分，去掉重复的文章，按照 (Ctime, Id) 倒序排列之后最多保留 n 篇
+func mergeFeed(pushed, pulled []domain.Article, n int) []domain.Article {
+	res := make([]domain.Article, 0, len(pushed)+len(pulled))
+	seen := make(map[int64]struct{}, len(pushed)+len(pulled))
+	for _, art := range slices.Concat(pushed, pulled) {
+		if _, ok := seen[art.Id]; ok {
+			continue
+		}
+		seen[art.Id] = struct{}{}
+		res = append(res, art)
+	}
+	slices.SortFunc(res, func(a, b domain.Article) int {
+		if c := b.Ctime.Compare(a.Ctime); c != 0 {
+			return c
+		}
+		return cmp.Compare(b.Id, a.Id)
+	})
+	return res[:min(n, len(res))]
+}
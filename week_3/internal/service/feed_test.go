@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go_homework/week_3/internal/domain"
+	"go_homework/week_3/internal/repository"
+	"go_homework/week_3/internal/repository/cache"
+	"go_homework/week_3/internal/repository/dao"
+	"testing"
+	"time"
+)
+
+func TestFeedService_Feed_SkipsWithdrawn(t *testing.T) {
+	db := newTestDB(t)
+	mr := miniredis.RunT(t)
+	rc := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rc.Close() })
+	userRepo := repository.NewUserRepository(dao.NewUserDAO(db), cache.NewUserCache(rc, time.Hour))
+	artRepo := repository.NewArticleRepository(dao.NewArticleDAO(db), cache.NewArticleCache(rc))
+	followRepo := repository.NewFollowRepository(dao.NewFollowDAO(db), cache.NewFollowCache(rc))
+	svc := NewFeedService(repository.NewFeedRepository(dao.NewFeedDAO(db), dao.NewArticleDAO(db)),
+		followRepo, userRepo)
+	ctx := context.Background()
+
+	createUser := func(email string) domain.User {
+		require.NoError(t, userRepo.Create(ctx, domain.User{Email: email, Password: "hash"},
+			func(u domain.User) domain.Event { return domain.UserRegistered{Uid: u.Id, Email: u.Email} }))
+		u, err := userRepo.FindByEmail(ctx, email)
+		require.NoError(t, err)
+		return u
+	}
+	author := createUser("author@qq.com")
+	reader := createUser("reader@qq.com")
+	require.NoError(t, followRepo.Follow(ctx, reader.Id, author.Id))
+
+	var arts []domain.Article
+	for _, title := range []string{"first", "second", "third"} {
+		art, err := artRepo.Sync(ctx, domain.Article{Title: title, Content: "content",
+			Author: domain.Author{Id: author.Id}, Status: domain.ArticleStatusPublished})
+		require.NoError(t, err)
+		require.NoError(t, svc.Push(ctx, art))
+		arts = append(arts, art)
+	}
+	// 最新的一篇排在第一页，撤回之后这一页仍然要有一条可见的文章和下一页的游标
+	require.NoError(t, artRepo.SyncStatus(ctx, author.Id, arts[2].Id, domain.ArticleStatusPrivate))
+
+	page, err := svc.Feed(ctx, reader.Id, "", 1)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, arts[1].Id, page.Items[0].Id)
+	require.NotEmpty(t, page.NextCursor)
+
+	page, err = svc.Feed(ctx, reader.Id, page.NextCursor, 1)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, arts[0].Id, page.Items[0].Id)
+	assert.Empty(t, page.NextCursor)
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"go_homework/week_3/internal/domain"
+	"go_homework/week_3/internal/repository"
+	"go_homework/week_3/pkg/pagination"
+)
+
+const (
+	// 粉丝和关注列表每页默认和最多返回的条数
+	followListDefaultLimit = 20
+	followListMaxLimit     = 50
+)
+
+// followCursor 粉丝和关注列表的分页游标，也就是上一页最后一条关注关系的 id
+type followCursor struct {
+	Id int64 `json:"i"`
+}
+
+type FollowService struct {
+	repo     *repository.FollowRepository
+	userRepo *repository.UserRepository
+}
+
+func NewFollowService(repo *repository.FollowRepository, userRepo *repository.UserRepository) *FollowService {
+	return &FollowService{repo: repo, userRepo: userRepo}
+}
+
+// Follow follower 关注 followee。不能关注自己，followee 不存在或者已经注销的时候返回 ErrUserNotFound
+func (svc *FollowService) Follow(ctx context.Context, follower, followee int64) error {
+	ctx, span := tracer.Start(ctx, "FollowService.Follow")
+	defer span.End()
+	if follower == followee {
+		return ErrCannotFollowSelf
+	}
+	// 个人资料有缓存，用来确认用户存在
+	if _, err := svc.userRepo.FindProfile(ctx, followee); err != nil {
+		return err
+	}
+	return svc.repo.Follow(ctx, follower, followee)
+}
+
+// Unfollow 取消关注，没有关注过也返回成功
+func (svc *FollowService) Unfollow(ctx context.Context, follower, followee int64) error {
+	ctx, span := tracer.Start(ctx, "FollowService.Unfollow")
+	defer span.End()
+	return svc.repo.Unfollow(ctx, follower, followee)
+}
+
+// Statistic 查询 uid 的关注数和粉丝数，以及 viewer 是否关注了 uid
+func (svc *FollowService) Statistic(ctx context.Context, viewer, uid int64) (domain.FollowStatistic, error) {
+	ctx, span := tracer.Start(ctx, "FollowService.Statistic")
+	defer span.End()
+	s, err := svc.repo.GetStatistic(ctx, uid)
+	if err != nil {
+		return domain.FollowStatistic{}, err
+	}
+	if viewer != uid {
+		s.Followed, err = svc.repo.IsFollowing(ctx, viewer, uid)
+	}
+	return s, err
+}
+
+// Followers viewer 查看 uid 的粉丝列表，按照关注时间倒序排列。
+// 和个人资料一样，仅自己可见的用户的列表对其他人返回 ErrUserNotFound
+func (svc *FollowService) Followers(ctx context.Context, viewer, uid int64, cursor string,
+	limit int) (pagination.Page[domain.User], error) {
+	ctx, span := tracer.Start(ctx, "FollowService.Followers")
+	defer span.End()
+	return svc.list(ctx, viewer, uid, cursor, limit, svc.repo.ListFollowers,
+		func(rel domain.FollowRelation) int64 { return rel.Follower })
+}
+
+// Followees viewer 查看 uid 关注的人，按照关注时间倒序排列，可见范围和 Followers 一样
+func (svc *FollowService) Followees(ctx context.Context, viewer, uid int64, cursor string,
+	limit int) (pagination.Page[domain.User], error) {
+	ctx, span := tracer.Start(ctx, "FollowService.Followees")
+	defer span.End()
+	return svc.list(ctx, viewer, uid, cursor, limit, svc.repo.ListFollowees,
+		func(rel domain.FollowRelation) int64 { return rel.Followee })
+}
+
+// list 查询一页关注关系，再批量查询关系另一端的用户。已经注销的用户不在结果中，所以一页的数量可能比 limit 少
+func (svc *FollowService) list(ctx context.Context, viewer, uid int64, cursor string, limit int,
+	listFn func(ctx context.Context, uid, afterId int64, limit int) ([]domain.FollowRelation, error),
+	userOf func(rel domain.FollowRelation) int64) (pagination.Page[domain.User], error) {
+	u, err := svc.userRepo.FindProfile(ctx, uid)
+	if err != nil {
+		return pagination.Page[domain.User]{}, err
+	}
+	if u.Privacy == domain.PrivacyPrivate && viewer != uid {
+		return pagination.Page[domain.User]{}, ErrUserNotFound
+	}
+	var after followCursor
+	if err = pagination.DecodeCursor(cursor, &after); err != nil {
+		return pagination.Page[domain.User]{}, ErrInvalidCursor.Wrap(err)
+	}
+	limit = pagination.Limit(limit, followListDefaultLimit, followListMaxLimit)
+	// 多查一条用来判断还有没有下一页
+	rels, err := listFn(ctx, uid, after.Id, limit+1)
+	if err != nil {
+		return pagination.Page[domain.User]{}, err
+	}
+	page, err := pagination.NewPage(rels, limit, func(last domain.FollowRelation) any {
+		return followCursor{Id: last.Id}
+	})
+	if err != nil {
+		return pagination.Page[domain.User]{}, err
+	}
+	ids := make([]int64, 0, len(page.Items))
+	for _, rel := range page.Items {
+		ids = append(ids, userOf(rel))
+	}
+	users, err := svc.userRepo.FindByIds(ctx, ids)
+	if err != nil {
+		return pagination.Page[domain.User]{}, err
+	}
+	items := make([]domain.User, 0, len(ids))
+	for _, id := range ids {
+		if u, ok := users[id]; ok {
+			items = append(items, u)
+		}
+	}
+	return pagination.Page[domain.User]{Items: items, NextCursor: page.NextCursor}, nil
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"go_homework/week_3/internal/domain"
+	"go_homework/week_3/internal/repository"
+	"go_homework/week_3/pkg/events"
+)
+
+var (
+	ErrCollectionNotFound  = repository.ErrCollectionNotFound
+	ErrDuplicateCollection = repository.ErrDuplicateCollection
+)
+
+// InteractionService 任意业务对象的阅读、点赞和收藏，业务对象用 (biz, bizId) 表示
+type InteractionService struct {
+	repo *repository.InteractionRepository
+	// 点赞和收藏之前检查文章存在并且已经发表
+	artRepo *repository.ArticleRepository
+	// 阅读事件的生产者，阅读数由消费阅读事件的消费者异步修改
+	publisher events.Publisher
+}
+
+func NewInteractionService(repo *repository.InteractionRepository, artRepo *repository.ArticleRepository,
+	publisher events.Publisher) *InteractionService {
+	return &InteractionService{repo: repo, artRepo: artRepo, publisher: publisher}
+}
+
+// RecordRead 查看详情的时候调用，只发送阅读事件，不等待阅读数修改完成
+func (svc *InteractionService) RecordRead(ctx context.Context, biz string, bizId, uid int64) error {
+	ctx, span := tracer.Start(ctx, "InteractionService.RecordRead")
+	defer span.End()
+	msg, err := newMessage(domain.BizRead{Biz: biz, BizId: bizId, Uid: uid})
+	if err != nil {
+		return err
+	}
+	return svc.publisher.Publish(ctx, msg)
+}
+
+// BatchIncrReadCnt 在一个事务里面修改一批业务对象的阅读数，cnts 是 biz 到 bizId 到增量的映射，消费阅读事件的时候调用
+func (svc *InteractionService) BatchIncrReadCnt(ctx context.Context, cnts map[string]map[int64]int64) error {
+	ctx, span := tracer.Start(ctx, "InteractionService.BatchIncrReadCnt")
+	defer span.End()
+	return svc.repo.BatchIncrReadCnt(ctx, cnts)
+}
+
+// Like 点赞，重复点赞不会重复计数。业务对象不存在或者读者看不到的时候返回对应的 NotFound 错误
+func (svc *InteractionService) Like(ctx context.Context, biz string, bizId, uid int64) error {
+	ctx, span := tracer.Start(ctx, "InteractionService.Like")
+	defer span.End()
+	if err := checkBizVisible(ctx, svc.artRepo, biz, bizId); err != nil {
+		return err
+	}
+	return svc.repo.Like(ctx, biz, bizId, uid)
+}
+
+// CancelLike 取消点赞
+func (svc *InteractionService) CancelLike(ctx context.Context, biz string, bizId, uid int64) error {
+	ctx, span := tracer.Start(ctx, "InteractionService.CancelLike")
+	defer span.End()
+	return svc.repo.CancelLike(ctx, biz, bizId, uid)
+}
+
+// Collect 收藏到用户自己的收藏夹 cid 里面，同一个业务对象可以收藏到多个收藏夹，收藏数按照用户计算。
+// 业务对象不存在或者读者看不到的时候返回对应的 NotFound 错误
+func (svc *InteractionService) Collect(ctx context.Context, biz string, bizId, cid, uid int64) error {
+	ctx, span := tracer.Start(ctx, "InteractionService.Collect")
+	defer span.End()
+	if err := checkBizVisible(ctx, svc.artRepo, biz, bizId); err != nil {
+		return err
+	}
+	return svc.repo.Collect(ctx, biz, bizId, cid, uid)
+}
+
+// CancelCollect 从收藏夹 cid 里面取消收藏，文章撤回之后也可以取消
+func (svc *InteractionService) CancelCollect(ctx context.Context, biz string, bizId, cid, uid int64) error {
+	ctx, span := tracer.Start(ctx, "InteractionService.CancelCollect")
+	defer span.End()
+	return svc.repo.CancelCollect(ctx, biz, bizId, cid, uid)
+}
+
+// Get 查询一个业务对象的计数以及用户 uid 的点赞和收藏状态
+func (svc *InteractionService) Get(ctx context.Context, biz string, bizId, uid int64) (domain.Interaction, error) {
+	ctx, span := tracer.Start(ctx, "InteractionService.Get")
+	defer span.End()
+	res, err := svc.repo.GetByIds(ctx, biz, []int64{bizId}, uid)
+	if err != nil {
+		return domain.Interaction{}, err
+	}
+	return res[bizId], nil
+}
+
+// GetByIds 列表页批量查询计数以及用户 uid 的点赞和收藏状态，结果中包含 bizIds 中的每一个业务对象
+func (svc *InteractionService) GetByIds(ctx context.Context, biz string, bizIds []int64,
+	uid int64) (map[int64]domain.Interaction, error) {
+	ctx, span := tracer.Start(ctx, "InteractionService.GetByIds")
+	defer span.End()
+	return svc.repo.GetByIds(ctx, biz, bizIds, uid)
+}
+
+// CreateCollection 新建收藏夹，返回收藏夹的 id
+func (svc *InteractionService) CreateCollection(ctx context.Context, c domain.Collection) (int64, error) {
+	ctx, span := tracer.Start(ctx, "InteractionService.CreateCollection")
+	defer span.End()
+	return svc.repo.CreateCollection(ctx, c)
+}
+
+// ListCollections 用户所有的收藏夹
+func (svc *InteractionService) ListCollections(ctx context.Context, uid int64) ([]domain.Collection, error) {
+	ctx, span := tracer.Start(ctx, "InteractionService.ListCollections")
+	defer span.End()
+	return svc.repo.ListCollections(ctx, uid)
+}
+
+// newMessage 把领域事件序列化成 JSON 消息
+func newMessage(evt domain.Event) (events.Message, error) {
+	val, err := json.Marshal(evt)
+	if err != nil {
+		return events.Message{}, err
+	}
+	return events.Message{Topic: evt.Topic(), Key: evt.Key(), Value: val}, nil
+}
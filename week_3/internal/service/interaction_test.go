@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go_homework/week_3/internal/domain"
+	"go_homework/week_3/internal/repository"
+	"go_homework/week_3/internal/repository/cache"
+	"go_homework/week_3/internal/repository/dao"
+	"go_homework/week_3/pkg/events"
+	"testing"
+)
+
+func newTestInteractionService(t *testing.T) (*InteractionService, *repository.ArticleRepository) {
+	db := newTestDB(t)
+	mr := miniredis.RunT(t)
+	rc := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rc.Close() })
+	artRepo := repository.NewArticleRepository(dao.NewArticleDAO(db), cache.NewArticleCache(rc))
+	repo := repository.NewInteractionRepository(dao.NewInteractionDAO(db), cache.NewInteractionCache(rc))
+	return NewInteractionService(repo, artRepo, events.NewMemoryBroker(10)), artRepo
+}
+
+func TestInteractionService_BizVisible(t *testing.T) {
+	const author, uid = 1, 2
+	testCases := []struct {
+		name string
+		// 准备文章，返回文章的 id
+		before  func(t *testing.T, ctx context.Context, artRepo *repository.ArticleRepository) int64
+		wantErr error
+	}{
+		{
+			name: "published",
+			before: func(t *testing.T, ctx context.Context, artRepo *repository.ArticleRepository) int64 {
+				art, err := artRepo.Sync(ctx, domain.Article{Title: "t", Content: "c",
+					Author: domain.Author{Id: author}, Status: domain.ArticleStatusPublished})
+				require.NoError(t, err)
+				return art.Id
+			},
+		},
+		{
+			name: "withdrawn",
+			before: func(t *testing.T, ctx context.Context, artRepo *repository.ArticleRepository) int64 {
+				art, err := artRepo.Sync(ctx, domain.Article{Title: "t", Content: "c",
+					Author: domain.Author{Id: author}, Status: domain.ArticleStatusPublished})
+				require.NoError(t, err)
+				require.NoError(t, artRepo.SyncStatus(ctx, author, art.Id, domain.ArticleStatusPrivate))
+				return art.Id
+			},
+			wantErr: ErrArticleNotFound,
+		},
+		{
+			name: "draft",
+			before: func(t *testing.T, ctx context.Context, artRepo *repository.ArticleRepository) int64 {
+				id, err := artRepo.Create(ctx, domain.Article{Title: "t", Content: "c",
+					Author: domain.Author{Id: author}, Status: domain.ArticleStatusDraft})
+				require.NoError(t, err)
+				return id
+			},
+			wantErr: ErrArticleNotFound,
+		},
+		{
+			name: "not exist",
+			before: func(t *testing.T, ctx context.Context, artRepo *repository.ArticleRepository) int64 {
+				return 100
+			},
+			wantErr: ErrArticleNotFound,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			svc, artRepo := newTestInteractionService(t)
+			ctx := context.Background()
+			id := tc.before(t, ctx, artRepo)
+			cid, err := svc.CreateCollection(ctx, domain.Collection{Uid: uid, Name: "c"})
+			require.NoError(t, err)
+
+			assert.ErrorIs(t, svc.Like(ctx, domain.BizArticle, id, uid), tc.wantErr)
+			assert.ErrorIs(t, svc.Collect(ctx, domain.BizArticle, id, cid, uid), tc.wantErr)
+			intr, err := svc.Get(ctx, domain.BizArticle, id, uid)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantErr == nil, intr.Liked)
+			assert.Equal(t, tc.wantErr == nil, intr.Collected)
+		})
+	}
+}
+
+func TestInteractionService_UnsupportedBiz(t *testing.T) {
+	svc, _ := newTestInteractionService(t)
+	assert.ErrorIs(t, svc.Like(context.Background(), "unknown", 1, 2), ErrUnsupportedBiz)
+}
+
+func TestInteractionService_CollectMultipleCollections(t *testing.T) {
+	const uid = 2
+	svc, artRepo := newTestInteractionService(t)
+	ctx := context.Background()
+	art, err := artRepo.Sync(ctx, domain.Article{Title: "t", Content: "c",
+		Author: domain.Author{Id: 1}, Status: domain.ArticleStatusPublished})
+	require.NoError(t, err)
+	c1, err := svc.CreateCollection(ctx, domain.Collection{Uid: uid, Name: "c1"})
+	require.NoError(t, err)
+	c2, err := svc.CreateCollection(ctx, domain.Collection{Uid: uid, Name: "c2"})
+	require.NoError(t, err)
+
+	assertCollected := func(wantCnt int64, wantCollected bool) {
+		t.Helper()
+		intr, err := svc.Get(ctx, domain.BizArticle, art.Id, uid)
+		require.NoError(t, err)
+		assert.Equal(t, wantCnt, intr.CollectCnt)
+		assert.Equal(t, wantCollected, intr.Collected)
+	}
+	// 收藏到两个收藏夹，收藏数按照用户计算
+	require.NoError(t, svc.Collect(ctx, domain.BizArticle, art.Id, c1, uid))
+	require.NoError(t, svc.Collect(ctx, domain.BizArticle, art.Id, c2, uid))
+	require.NoError(t, svc.Collect(ctx, domain.BizArticle, art.Id, c2, uid))
+	assertCollected(1, true)
+	// 另一个用户收藏
+	c3, err := svc.CreateCollection(ctx, domain.Collection{Uid: uid + 1, Name: "c3"})
+	require.NoError(t, err)
+	require.NoError(t, svc.Collect(ctx, domain.BizArticle, art.Id, c3, uid+1))
+	assertCollected(2, true)
+	// 从一个收藏夹里面取消，另一个收藏夹里面还有
+	require.NoError(t, svc.CancelCollect(ctx, domain.BizArticle, art.Id, c1, uid))
+	require.NoError(t, svc.CancelCollect(ctx, domain.BizArticle, art.Id, c1, uid))
+	assertCollected(2, true)
+	require.NoError(t, svc.CancelCollect(ctx, domain.BizArticle, art.Id, c2, uid))
+	assertCollected(1, false)
+	// 别人的收藏夹
+	assert.ErrorIs(t, svc.Collect(ctx, domain.BizArticle, art.Id, c3, uid), ErrCollectionNotFound)
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"go_homework/week_3/internal/repository"
+	"go_homework/week_3/pkg/events"
+)
+
+// 每一批投递的事件数量
+const outboxRelayBatchSize = 100
+
+// OutboxRelayService 把发件箱中的事件按照写入的顺序投递给 publisher，投递成功之后删除。
+// 投递之后删除之前崩溃的话事件会被再次投递，消费方需要保证幂等
+type OutboxRelayService struct {
+	repo      *repository.OutboxRepository
+	publisher events.Publisher
+}
+
+func NewOutboxRelayService(repo *repository.OutboxRepository, publisher events.Publisher) *OutboxRelayService {
+	return &OutboxRelayService{repo: repo, publisher: publisher}
+}
+
+// Relay 投递发件箱中所有的事件，返回投递的数量。
+// 某一批投递失败的时候停下来，下次从这一批重新开始，保证同一个 key 的事件不会乱序
+func (svc *OutboxRelayService) Relay(ctx context.Context) (int, error) {
+	ctx, span := tracer.Start(ctx, "OutboxRelayService.Relay")
+	defer span.End()
+	total := 0
+	for ctx.Err() == nil {
+		msgs, err := svc.repo.FindPending(ctx, outboxRelayBatchSize)
+		if err != nil {
+			return total, err
+		}
+		if len(msgs) == 0 {
+			return total, nil
+		}
+		batch := make([]events.Message, 0, len(msgs))
+		ids := make([]int64, 0, len(msgs))
+		for _, msg := range msgs {
+			batch = append(batch, events.Message{Topic: msg.Topic, Key: msg.Key, Value: msg.Payload})
+			ids = append(ids, msg.Id)
+		}
+		if err = svc.publisher.Publish(ctx, batch...); err != nil {
+			return total, err
+		}
+		if err = svc.repo.Delete(ctx, ids); err != nil {
+			return total, err
+		}
+		total += len(msgs)
+		if len(msgs) < outboxRelayBatchSize {
+			return total, nil
+		}
+	}
+	return total, ctx.Err()
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go_homework/week_3/internal/domain"
+	"go_homework/week_3/internal/repository"
+	"go_homework/week_3/internal/repository/dao"
+	"go_homework/week_3/pkg/events"
+	"gorm.io/gorm"
+	"testing"
+)
+
+// relayPublisher 记录投递的消息，failures 大于 0 的时候投递失败并减一。
+// 投递的时候检查发件箱中的事件还在，保证先投递成功再删除
+type relayPublisher struct {
+	t        *testing.T
+	db       *gorm.DB
+	failures int
+	got      []events.Message
+}
+
+func (p *relayPublisher) Publish(ctx context.Context, msgs ...events.Message) error {
+	var cnt int64
+	require.NoError(p.t, p.db.Model(&dao.OutboxMessage{}).Count(&cnt).Error)
+	assert.GreaterOrEqual(p.t, cnt, int64(len(msgs)), "outbox rows deleted before publish")
+	if p.failures > 0 {
+		p.failures--
+		return errors.New("broker unavailable")
+	}
+	p.got = append(p.got, msgs...)
+	return nil
+}
+
+// insertOutbox 通过注册用户写入 n 个事件
+func insertOutbox(t *testing.T, db *gorm.DB, n int) {
+	ud := dao.NewUserDAO(db)
+	for i := 0; i < n; i++ {
+		err := ud.Insert(context.Background(), dao.User{Email: fmt.Sprintf("%d@qq.com", i)},
+			func(u dao.User) (dao.OutboxMessage, error) {
+				return dao.OutboxMessage{Topic: domain.TopicUserRegistered, Key: fmt.Sprint(u.Id),
+					Payload: fmt.Sprintf(`{"uid":%d}`, u.Id)}, nil
+			})
+		require.NoError(t, err)
+	}
+}
+
+func outboxCount(t *testing.T, db *gorm.DB) int64 {
+	var cnt int64
+	require.NoError(t, db.Model(&dao.OutboxMessage{}).Count(&cnt).Error)
+	return cnt
+}
+
+func TestOutboxRelayService_Relay(t *testing.T) {
+	testCases := []struct {
+		name     string
+		pending  int
+		failures int
+
+		wantErr   bool
+		wantTotal int
+		// 投递之后发件箱中剩下的事件数量
+		wantLeft int64
+	}{
+		{
+			name:      "empty",
+			wantTotal: 0,
+		},
+		{
+			name:      "one batch",
+			pending:   3,
+			wantTotal: 3,
+		},
+		{
+			name:      "several batches",
+			pending:   outboxRelayBatchSize*2 + 10,
+			wantTotal: outboxRelayBatchSize*2 + 10,
+		},
+		{
+			name:     "publish failed",
+			pending:  3,
+			failures: 1,
+			wantErr:  true,
+			wantLeft: 3,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := newTestDB(t)
+			insertOutbox(t, db, tc.pending)
+			pub := &relayPublisher{t: t, db: db, failures: tc.failures}
+			svc := NewOutboxRelayService(repository.NewOutboxRepository(dao.NewOutboxDAO(db)), pub)
+
+			total, err := svc.Relay(context.Background())
+			assert.Equal(t, tc.wantErr, err != nil)
+			assert.Equal(t, tc.wantTotal, total)
+			assert.Equal(t, tc.wantLeft, outboxCount(t, db))
+			require.Len(t, pub.got, tc.wantTotal)
+			// 按照写入的顺序投递
+			for i, msg := range pub.got {
+				assert.Equal(t, domain.TopicUserRegistered, msg.Topic)
+				assert.Equal(t, fmt.Sprint(i+1), msg.Key)
+				assert.Equal(t, fmt.Sprintf(`{"uid":%d}`, i+1), string(msg.Value))
+			}
+		})
+	}
+}
+
+// TestOutboxRelayService_RelayRetry 投递失败之后下一次从同一批重新开始
+func TestOutboxRelayService_RelayRetry(t *testing.T) {
+	db := newTestDB(t)
+	insertOutbox(t, db, 3)
+	pub := &relayPublisher{t: t, db: db, failures: 1}
+	svc := NewOutboxRelayService(repository.NewOutboxRepository(dao.NewOutboxDAO(db)), pub)
+	_, err := svc.Relay(context.Background())
+	require.Error(t, err)
+	total, err := svc.Relay(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Len(t, pub.got, 3)
+	assert.Zero(t, outboxCount(t, db))
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"go_homework/week_3/internal/repository"
+	"go_homework/week_3/pkg/logger"
+	"go_homework/week_3/pkg/mailer"
+	"time"
+)
+
+// ErrInvalidResetToken 找回密码的链接无效或者已经过期
+var ErrInvalidResetToken = repository.ErrResetTokenNotFound
+
+// token 的随机字节数
+const resetTokenBytes = 32
+
+// PasswordResetService 通过邮件找回密码：先给用户的邮箱发送带 token 的链接，
+// 用户打开链接之后凭 token 设置新密码，新密码同样需要满足密码策略
+type PasswordResetService struct {
+	repo    *repository.UserRepository
+	userSvc *UserService
+	mailer  mailer.Mailer
+	// 邮件中的链接，token 拼接在后面
+	resetURL   string
+	expiration time.Duration
+}
+
+func NewPasswordResetService(repo *repository.UserRepository, userSvc *UserService, mailer mailer.Mailer,
+	resetURL string, expiration time.Duration) *PasswordResetService {
+	return &PasswordResetService{repo: repo, userSvc: userSvc, mailer: mailer, resetURL: resetURL,
+		expiration: expiration}
+}
+
+// SendResetLink 给 email 对应的用户发送找回密码的邮件。
+// 邮箱没有注册过的时候也返回 nil，不能通过这个接口判断邮箱有没有注册
+func (svc *PasswordResetService) SendResetLink(ctx context.Context, email string) error {
+	ctx, span := tracer.Start(ctx, "PasswordResetService.SendResetLink")
+	defer span.End()
+	u, err := svc.repo.FindByEmail(ctx, email)
+	if errors.Is(err, ErrUserNotFound) {
+		logger.FromContext(ctx).Info("password reset requested for unknown email")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, resetTokenBytes)
+	if _, err = rand.Read(buf); err != nil {
+		return err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	if err = svc.repo.SaveResetToken(ctx, token, u.Id, svc.expiration); err != nil {
+		return err
+	}
+	body := fmt.Sprintf("Open the link below to reset your password, it expires in %s:\n\n%s%s\n\n"+
+		"If you did not request a password reset, please ignore this email.", svc.expiration, svc.resetURL, token)
+	return svc.mailer.Send(ctx, u.Email, "Reset your webook password", body)
+}
+
+// Reset 凭邮件中的 token 设置新密码。新密码不满足密码策略的时候 token 仍然有效，用户可以换一个密码重试，
+// 设置成功之后 token 失效
+func (svc *PasswordResetService) Reset(ctx context.Context, token, newPassword string) error {
+	ctx, span := tracer.Start(ctx, "PasswordResetService.Reset")
+	defer span.End()
+	uid, err := svc.repo.FindResetToken(ctx, token)
+	if err != nil {
+		return err
+	}
+	if err = svc.userSvc.ResetPassword(ctx, uid, newPassword); err != nil {
+		return err
+	}
+	return svc.repo.DeleteResetToken(ctx, token)
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go_homework/week_3/internal/domain"
+	"go_homework/week_3/internal/repository"
+	"go_homework/week_3/internal/repository/cache"
+	"go_homework/week_3/internal/repository/dao"
+	"go_homework/week_3/pkg/hasher"
+	"go_homework/week_3/pkg/pwdpolicy"
+	"golang.org/x/crypto/bcrypt"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// mailRecorder 记录发送的邮件，不真正发送
+type mailRecorder struct {
+	to   []string
+	body []string
+}
+
+func (m *mailRecorder) Send(ctx context.Context, to, subject, body string) error {
+	m.to = append(m.to, to)
+	m.body = append(m.body, body)
+	return nil
+}
+
+var resetTokenRegexp = regexp.MustCompile(`token=([A-Za-z0-9_-]+)`)
+
+func TestPasswordResetService(t *testing.T) {
+	db := newTestDB(t)
+	mr := miniredis.RunT(t)
+	rc := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rc.Close() })
+	repo := repository.NewUserRepository(dao.NewUserDAO(db), cache.NewUserCache(rc, time.Hour))
+	userSvc := NewUserService(repo, hasher.NewBcryptHasher(bcrypt.MinCost), pwdpolicy.NewPolicy(nil))
+	mails := &mailRecorder{}
+	svc := NewPasswordResetService(repo, userSvc, mails, "https://webook.com/reset-password?token=", time.Minute)
+	ctx := context.Background()
+
+	const email, password, newPassword = "tom@qq.com", "Kq7#vLp2!xZr", "Zr8$wMq3@yTn"
+	require.NoError(t, userSvc.Signup(ctx, domain.User{Email: email, Password: password}))
+	u, err := repo.FindByEmail(ctx, email)
+	require.NoError(t, err)
+	loginAt := time.Now().Add(-time.Second)
+
+	// 没有注册过的邮箱不发送邮件，也不返回错误
+	require.NoError(t, svc.SendResetLink(ctx, "nobody@qq.com"))
+	assert.Empty(t, mails.to)
+
+	require.NoError(t, svc.SendResetLink(ctx, email))
+	require.Equal(t, []string{email}, mails.to)
+	m := resetTokenRegexp.FindStringSubmatch(mails.body[0])
+	require.Len(t, m, 2)
+	token := m[1]
+
+	assert.ErrorIs(t, svc.Reset(ctx, "bad-token", newPassword), ErrInvalidResetToken)
+	// 新密码同样要满足密码策略，被拒绝之后 token 仍然可以使用
+	assert.ErrorIs(t, svc.Reset(ctx, token, "Password1!"), ErrCommonPassword)
+	assert.ErrorIs(t, svc.Reset(ctx, token, "xTOM@2024xk"), ErrPasswordPersonalInfo)
+	require.NoError(t, svc.Reset(ctx, token, newPassword))
+	// token 只能使用一次
+	assert.ErrorIs(t, svc.Reset(ctx, token, "Wy4%nBq8&uHs"), ErrInvalidResetToken)
+
+	_, err = userSvc.Login(ctx, email, password)
+	assert.ErrorIs(t, err, ErrInvalidUserOrPassword)
+	_, err = userSvc.Login(ctx, email, newPassword)
+	assert.NoError(t, err)
+	// 重置之前登录的会话全部失效
+	revoked, err := userSvc.IsSessionRevoked(ctx, u.Id, loginAt)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+}
+
+func TestPasswordResetService_Expired(t *testing.T) {
+	db := newTestDB(t)
+	mr := miniredis.RunT(t)
+	rc := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rc.Close() })
+	repo := repository.NewUserRepository(dao.NewUserDAO(db), cache.NewUserCache(rc, time.Hour))
+	userSvc := NewUserService(repo, hasher.NewBcryptHasher(bcrypt.MinCost), pwdpolicy.NewPolicy(nil))
+	mails := &mailRecorder{}
+	svc := NewPasswordResetService(repo, userSvc, mails, "https://webook.com/reset-password?token=", time.Minute)
+	ctx := context.Background()
+
+	require.NoError(t, userSvc.Signup(ctx, domain.User{Email: "tom@qq.com", Password: "Kq7#vLp2!xZr"}))
+	require.NoError(t, svc.SendResetLink(ctx, "tom@qq.com"))
+	m := resetTokenRegexp.FindStringSubmatch(mails.body[0])
+	require.Len(t, m, 2)
+	mr.FastForward(time.Minute + time.Second)
+	assert.ErrorIs(t, svc.Reset(ctx, m[1], "Zr8$wMq3@yTn"), ErrInvalidResetToken)
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"cmp"
+	"container/heap"
+	"context"
+	"go_homework/week_3/internal/domain"
+	"go_homework/week_3/internal/repository"
+	"math"
+	"slices"
+	"time"
+)
+
+const (
+	// 热榜的长度
+	rankingN = 100
+	// 计算热榜的时候每一批处理的文章数量
+	rankingBatchSize = 100
+	// 热度随时间衰减的速度，越大新文章越容易排在前面
+	rankingGravity = 1.5
+)
+
+// RankingService 根据点赞数和发表时间计算热门文章的排行榜
+type RankingService struct {
+	artRepo  *repository.ArticleRepository
+	intrRepo *repository.InteractionRepository
+	userRepo *repository.UserRepository
+	repo     *repository.RankingRepository
+	// 只有这段时间内发表的文章参与排名，更早的文章分数已经衰减得很低了
+	window time.Duration
+}
+
+func NewRankingService(artRepo *repository.ArticleRepository, intrRepo *repository.InteractionRepository,
+	userRepo *repository.UserRepository, repo *repository.RankingRepository, window time.Duration) *RankingService {
+	return &RankingService{artRepo: artRepo, intrRepo: intrRepo, userRepo: userRepo, repo: repo, window: window}
+}
+
+// TopN 重新计算热榜并保存。分批遍历 window 之内发表的文章，直接从数据库批量查询点赞数，
+// 用大小为 rankingN 的小顶堆保留分数最高的文章
+func (svc *RankingService) TopN(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "RankingService.TopN")
+	defer span.End()
+	now := time.Now()
+	since := now.Add(-svc.window)
+	h := make(scoredArticles, 0, rankingN)
+	var after domain.Article
+	for {
+		arts, err := svc.artRepo.ListPublished(ctx, since, after, rankingBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(arts) == 0 {
+			break
+		}
+		ids := make([]int64, 0, len(arts))
+		for _, art := range arts {
+			ids = append(ids, art.Id)
+		}
+		likeCnts, err := svc.intrRepo.GetLikeCnts(ctx, domain.BizArticle, ids)
+		if err != nil {
+			return err
+		}
+		for _, art := range arts {
+			sa := scoredArticle{art: art, score: hotScore(likeCnts[art.Id], art.Ctime, now)}
+			if h.Len() < rankingN {
+				heap.Push(&h, sa)
+			} else if sa.score > h[0].score {
+				h[0] = sa
+				heap.Fix(&h, 0)
+			}
+		}
+		if len(arts) < rankingBatchSize {
+			break
+		}
+		after = arts[len(arts)-1]
+	}
+	slices.SortFunc(h, func(a, b scoredArticle) int {
+		return cmp.Compare(b.score, a.score)
+	})
+	res := make([]domain.Article, 0, len(h))
+	for _, sa := range h {
+		res = append(res, sa.art)
+	}
+	return svc.repo.ReplaceTopN(ctx, res)
+}
+
+// GetTopN 读取热榜，还没有计算过的时候返回空的热榜
+func (svc *RankingService) GetTopN(ctx context.Context) ([]domain.Article, error) {
+	ctx, span := tracer.Start(ctx, "RankingService.GetTopN")
+	defer span.End()
+	arts, err := svc.repo.GetTopN(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// 缓存中的热榜多个请求共用，复制一份再填作者
+	arts = slices.Clone(arts)
+	if err = fillAuthors(ctx, svc.userRepo, arts); err != nil {
+		return nil, err
+	}
+	return arts, nil
+}
+
+// hotScore 参考 Hacker News 的排序算法：点赞越多分数越高，发表的时间越久分数越低。
+// 点赞数加一，让还没有点赞的新文章按照发表时间排序
+func hotScore(likeCnt int64, publishedAt, now time.Time) float64 {
+	hours := max(now.Sub(publishedAt).Hours(), 0)
+	return float64(likeCnt+1) / math.Pow(hours+2, rankingGravity)
+}
+
+type scoredArticle struct {
+	art   domain.Article
+	score float64
+}
+
+// scoredArticles 按照分数排列的小顶堆，实现 heap.Interface
+type scoredArticles []scoredArticle
+
+func (s scoredArticles) Len() int           { return len(s) }
+func (s scoredArticles) Less(i, j int) bool { return s[i].score < s[j].score }
+func (s scoredArticles) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+func (s *scoredArticles) Push(x any) {
+	*s = append(*s, x.(scoredArticle))
+}
+
+func (s *scoredArticles) Pop() any {
+	old := *s
+	n := len(old)
+	x := old[n-1]
+	*s = old[:n-1]
+	return x
+}
@@ -3,68 +3,289 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"go.opentelemetry.io/otel"
 	"go_homework/week_3/internal/domain"
+	"go_homework/week_3/internal/errs"
 	"go_homework/week_3/internal/repository"
-	"golang.org/x/crypto/bcrypt"
+	"go_homework/week_3/pkg/hasher"
+	"go_homework/week_3/pkg/logger"
+	"go_homework/week_3/pkg/pagination"
+	"go_homework/week_3/pkg/pwdpolicy"
+	"time"
 )
 
 var (
 	ErrDuplicateEmail        = repository.ErrDuplicateEmail
-	ErrInvalidUserOrPassword = errors.New("the user does not exist or the password is incorrect")
+	ErrVersionConflict       = repository.ErrVersionConflict
+	ErrInvalidUserOrPassword = errs.Unauthorized(errs.CodeInvalidUserOrPassword, "Invalid user or password")
+	ErrCommonPassword        = errs.Validation(errs.CodeCommonPassword, "The password is too common")
+	ErrPasswordPersonalInfo  = errs.Validation(errs.CodePasswordPersonalInfo,
+		"The password must not contain your email or nickname")
+	ErrBreachedPassword = errs.Validation(errs.CodeBreachedPassword,
+		"The password has appeared in a data breach, please choose another one")
+	ErrUserNotFound  = repository.ErrUserNotFound
+	ErrInvalidCursor = errs.Validation(errs.CodeInvalidCursor, "Invalid cursor")
+	// ErrCannotFollowSelf 不能关注自己
+	ErrCannotFollowSelf = errs.Validation(errs.CodeCannotFollowSelf, "You cannot follow yourself")
 )
 
+// tracer 服务层的每一个方法都会创建一个 span，名字为 <类型>.<方法>，例如 UserService.Login
+var tracer = otel.Tracer("go_homework/week_3/internal/service")
+
+const (
+	// 每一批物理删除的用户数量，避免一次删除太多数据锁表
+	purgeBatchSize = 100
+	// 搜索用户时每页默认和最多返回的条数
+	searchDefaultLimit = 20
+	searchMaxLimit     = 50
+)
+
+// nicknameCursor 按照昵称搜索用户时的分页游标，也就是上一页最后一个用户的排序键
+type nicknameCursor struct {
+	Nickname string `json:"n"`
+	Id       int64  `json:"i"`
+}
+
 type UserService struct {
-	repo *repository.UserRepository
+	repo   *repository.UserRepository
+	hasher hasher.PasswordHasher
+	policy *pwdpolicy.Policy
 }
 
 // NewUserService 函数创建并返回一个 UserService 实例
-func NewUserService(repo *repository.UserRepository) *UserService {
-	return &UserService{repo: repo}
+func NewUserService(repo *repository.UserRepository, hasher hasher.PasswordHasher,
+	policy *pwdpolicy.Policy) *UserService {
+	return &UserService{repo: repo, hasher: hasher, policy: policy}
 }
 
 // Signup 函数处理用户的注册流程
 func (svc *UserService) Signup(ctx context.Context, u domain.User) error {
-	// 使用 bcrypt 算法对用户输入的密码进行哈希
-	hash, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
+	ctx, span := tracer.Start(ctx, "UserService.Signup")
+	defer span.End()
+	// 拒绝常见密码、包含邮箱的密码以及已经泄露过的密码
+	err := svc.checkPassword(ctx, u, u.Password)
+	if err != nil {
+		return err
+	}
+	// 使用当前配置的算法对用户输入的密码进行哈希
+	hash, err := svc.hasher.Hash(u.Password)
 	if err != nil {
 		// 如果哈希过程中出现错误，则返回该错误
 		return err
 	}
-	// 将哈希后的密码转换为字符串并赋值给 u.Password
-	u.Password = string(hash)
-	// 调用仓储层的 Create 方法，将用户数据保存到数据库中
-	return svc.repo.Create(ctx, u)
+	// 将哈希后的密码赋值给 u.Password
+	u.Password = hash
+	// 调用仓储层的 Create 方法，将用户数据保存到数据库中，同时发出 UserRegistered 事件
+	return svc.repo.Create(ctx, u, func(created domain.User) domain.Event {
+		return domain.UserRegistered{Uid: created.Id, Email: created.Email, Ctime: created.Ctime}
+	})
 }
 
 // Login 函数用于验证用户的登录信息。
 // 它接受上下文、邮箱和密码作为参数。
 func (svc *UserService) Login(ctx context.Context, email string, password string) (domain.User, error) {
+	ctx, span := tracer.Start(ctx, "UserService.Login")
+	defer span.End()
 	// 调用仓库层的 FindByEmail 方法，根据邮箱查找用户。
 	u, err := svc.repo.FindByEmail(ctx, email)
-	// 如果发生错误，则返回一个空的 domain.User 和错误信息。
+	// 如果没有找到用户，和密码错误返回同一个错误，避免暴露邮箱是否注册过。
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return domain.User{}, ErrInvalidUserOrPassword
+	}
+	// 其余错误都是系统错误，返回一个空的 domain.User 和错误信息。
 	if err != nil {
 		return domain.User{}, err
 	}
-	// 如果没有找到用户，则返回 ErrUserNotFound 错误。
-	if errors.Is(err, repository.ErrUserNotFound) {
-		return domain.User{}, ErrDuplicateEmail
-	}
-	// 使用 bcrypt.CompareHashAndPassword 方法，对用户输入的密码进行验证。
-	err = bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
-	// 如果发生错误，则返回一个空的 domain.User 和错误信息。
+	// 根据哈希串里面记录的算法，对用户输入的密码进行验证。
+	ok, err := svc.hasher.Verify(u.Password, password)
+	// 哈希串本身损坏了，属于系统错误
 	if err != nil {
+		return domain.User{}, err
+	}
+	// 密码不匹配
+	if !ok {
 		return domain.User{}, ErrInvalidUserOrPassword
 	}
+	// 密码是用旧的算法或者旧的参数哈希的，趁着有明文密码，按照当前配置重新哈希
+	if svc.hasher.NeedsRehash(u.Password) {
+		svc.rehash(ctx, u.Id, password)
+	}
 	// 如果登录成功，返回用户信息和 nil，表示没有发生错误。
 	return u, nil
 }
 
-// UpdateNonSensitiveInfo 在给定的上下文中更新用户的非敏感信息
+// ChangePassword 已登录用户修改密码，需要校验旧密码
+func (svc *UserService) ChangePassword(ctx context.Context, uid int64, oldPassword, newPassword string) error {
+	ctx, span := tracer.Start(ctx, "UserService.ChangePassword")
+	defer span.End()
+	u, err := svc.repo.FindByID(ctx, uid)
+	if err != nil {
+		return err
+	}
+	ok, err := svc.hasher.Verify(u.Password, oldPassword)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidUserOrPassword
+	}
+	return svc.setPassword(ctx, u, newPassword)
+}
+
+// ResetPassword 找回密码时设置新密码，调用方需要先完成身份校验，见 PasswordResetService。
+// 设置成功之后所有已经登录的会话都会失效
+func (svc *UserService) ResetPassword(ctx context.Context, uid int64, newPassword string) error {
+	ctx, span := tracer.Start(ctx, "UserService.ResetPassword")
+	defer span.End()
+	u, err := svc.repo.FindByID(ctx, uid)
+	if err != nil {
+		return err
+	}
+	if err = svc.setPassword(ctx, u, newPassword); err != nil {
+		return err
+	}
+	return svc.repo.RevokeSessions(ctx, uid, time.Now())
+}
+
+// setPassword 按照密码策略校验新密码，通过之后哈希并保存
+func (svc *UserService) setPassword(ctx context.Context, u domain.User, password string) error {
+	err := svc.checkPassword(ctx, u, password)
+	if err != nil {
+		return err
+	}
+	hash, err := svc.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+	return svc.repo.UpdatePassword(ctx, u.Id, hash)
+}
+
+// checkPassword 使用密码策略检查密码，并把策略的错误转换成带分类的错误
+func (svc *UserService) checkPassword(ctx context.Context, u domain.User, password string) error {
+	err := svc.policy.Check(ctx, password, pwdpolicy.UserInfo{Email: u.Email, Nickname: u.Nickname})
+	switch {
+	case errors.Is(err, pwdpolicy.ErrCommonPassword):
+		return ErrCommonPassword.Wrap(err)
+	case errors.Is(err, pwdpolicy.ErrPersonalInfo):
+		return ErrPasswordPersonalInfo.Wrap(err)
+	case errors.Is(err, pwdpolicy.ErrBreachedPassword):
+		return ErrBreachedPassword.Wrap(err)
+	default:
+		return err
+	}
+}
+
+// rehash 重新哈希并保存用户的密码，失败了也不影响这一次登录，下次登录会再次尝试
+func (svc *UserService) rehash(ctx context.Context, uid int64, password string) {
+	hash, err := svc.hasher.Hash(password)
+	if err != nil {
+		logger.FromContext(ctx).Error("rehash password failed", logger.Int64("uid", uid), logger.Error(err))
+		return
+	}
+	if err = svc.repo.UpdatePassword(ctx, uid, hash); err != nil {
+		logger.FromContext(ctx).Error("save rehashed password failed", logger.Int64("uid", uid), logger.Error(err))
+	}
+}
+
+// UpdateNonSensitiveInfo 在给定的上下文中更新用户的非敏感信息，
+// user.Version 需要是用户读到的版本号，版本号过期的时候返回 ErrVersionConflict
 func (svc *UserService) UpdateNonSensitiveInfo(ctx context.Context,
 	user domain.User) error {
-	return svc.repo.UpdateNonZeroFields(ctx, user)
+	ctx, span := tracer.Start(ctx, "UserService.UpdateNonSensitiveInfo")
+	defer span.End()
+	return svc.repo.UpdateNonZeroFields(ctx, user, domain.UserProfileUpdated{
+		Uid:    user.Id,
+		Fields: []domain.UserField{domain.UserFieldNickname, domain.UserFieldBirthday, domain.UserFieldAboutMe},
+	})
+}
+
+// UpdateProfileFields 部分更新用户资料，只有 mask 中列出的字段会被修改
+func (svc *UserService) UpdateProfileFields(ctx context.Context, user domain.User, mask []domain.UserField) error {
+	ctx, span := tracer.Start(ctx, "UserService.UpdateProfileFields")
+	defer span.End()
+	return svc.repo.UpdateFields(ctx, user, mask, domain.UserProfileUpdated{Uid: user.Id, Fields: mask})
 }
 
 func (svc *UserService) FindByID(ctx context.Context, uid int64) (domain.User, error) {
+	ctx, span := tracer.Start(ctx, "UserService.FindByID")
+	defer span.End()
 	return svc.repo.FindByID(ctx, uid)
 }
+
+// PublicProfile 查询 uid 的公开资料，viewer 是查看资料的用户。
+// 仅自己可见的资料在其他人看来和不存在一样，返回 ErrUserNotFound，避免泄露用户是否存在
+func (svc *UserService) PublicProfile(ctx context.Context, viewer, uid int64) (domain.User, error) {
+	ctx, span := tracer.Start(ctx, "UserService.PublicProfile")
+	defer span.End()
+	u, err := svc.repo.FindProfile(ctx, uid)
+	if err != nil {
+		return domain.User{}, err
+	}
+	if u.Privacy == domain.PrivacyPrivate && viewer != uid {
+		return domain.User{}, ErrUserNotFound
+	}
+	return u, nil
+}
+
+// SearchByNickname 按照昵称前缀搜索资料公开的用户，cursor 为空的时候返回第一页
+func (svc *UserService) SearchByNickname(ctx context.Context, prefix, cursor string,
+	limit int) (pagination.Page[domain.User], error) {
+	ctx, span := tracer.Start(ctx, "UserService.SearchByNickname")
+	defer span.End()
+	var after nicknameCursor
+	if err := pagination.DecodeCursor(cursor, &after); err != nil {
+		return pagination.Page[domain.User]{}, ErrInvalidCursor.Wrap(err)
+	}
+	limit = pagination.Limit(limit, searchDefaultLimit, searchMaxLimit)
+	// 多查一条用来判断还有没有下一页
+	users, err := svc.repo.SearchByNickname(ctx, prefix, domain.PrivacyPublic,
+		after.Nickname, after.Id, limit+1)
+	if err != nil {
+		return pagination.Page[domain.User]{}, err
+	}
+	return pagination.NewPage(users, limit, func(last domain.User) any {
+		return nicknameCursor{Nickname: last.Nickname, Id: last.Id}
+	})
+}
+
+// DeleteAccount 注销账号：软删除、匿名化邮箱，并让所有已经登录的会话失效。
+// 数据会在保留期过后由 PurgeDeletedAccounts 物理删除
+func (svc *UserService) DeleteAccount(ctx context.Context, uid int64) error {
+	ctx, span := tracer.Start(ctx, "UserService.DeleteAccount")
+	defer span.End()
+	now := time.Now()
+	// 使用 uid 生成匿名邮箱，既不会冲突，也不会保留用户原来的邮箱
+	err := svc.repo.Delete(ctx, uid, fmt.Sprintf("deleted+%d@webook.invalid", uid),
+		domain.UserDeleted{Uid: uid, Dtime: now})
+	if err != nil {
+		return err
+	}
+	return svc.repo.RevokeSessions(ctx, uid, now)
+}
+
+// IsSessionRevoked 判断在 issuedAt 签发的会话是否已经被吊销
+func (svc *UserService) IsSessionRevoked(ctx context.Context, uid int64, issuedAt time.Time) (bool, error) {
+	ctx, span := tracer.Start(ctx, "UserService.IsSessionRevoked")
+	defer span.End()
+	revokedAt, err := svc.repo.SessionsRevokedAt(ctx, uid)
+	if err != nil {
+		return false, err
+	}
+	return !revokedAt.IsZero() && !issuedAt.After(revokedAt), nil
+}
+
+// PurgeDeletedAccounts 物理删除注销时间超过 retention 的账号，返回删除的总数
+func (svc *UserService) PurgeDeletedAccounts(ctx context.Context, retention time.Duration) (int64, error) {
+	ctx, span := tracer.Start(ctx, "UserService.PurgeDeletedAccounts")
+	defer span.End()
+	before := time.Now().Add(-retention)
+	var total int64
+	for {
+		n, err := svc.repo.PurgeDeleted(ctx, before, purgeBatchSize)
+		total += n
+		if err != nil || n < purgeBatchSize {
+			return total, err
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/glebarez/sqlite"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go_homework/week_3/internal/domain"
+	"go_homework/week_3/internal/errs"
+	"go_homework/week_3/internal/repository"
+	"go_homework/week_3/internal/repository/cache"
+	"go_homework/week_3/internal/repository/dao"
+	"go_homework/week_3/pkg/events"
+	"go_homework/week_3/pkg/hasher"
+	"go_homework/week_3/pkg/pwdpolicy"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"testing"
+	"time"
+)
+
+// newTestDB 创建一个内存中的 sqlite 数据库，内存数据库每个连接各自独立，所以只允许一个连接
+func newTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	require.NoError(t, dao.InitTables(db))
+	return db
+}
+
+func newTestUserService(t *testing.T) *UserService {
+	db := newTestDB(t)
+	mr := miniredis.RunT(t)
+	rc := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rc.Close() })
+	repo := repository.NewUserRepository(dao.NewUserDAO(db), cache.NewUserCache(rc, time.Hour))
+	return NewUserService(repo, hasher.NewBcryptHasher(bcrypt.MinCost), pwdpolicy.NewPolicy(nil))
+}
+
+func TestUserService_Login(t *testing.T) {
+	const password = "Kq7#vLp2!xZr"
+	testCases := []struct {
+		name     string
+		email    string
+		password string
+		wantErr  error
+	}{
+		{
+			name:     "success",
+			email:    "a@qq.com",
+			password: password,
+		},
+		{
+			name:     "unknown email",
+			email:    "missing@qq.com",
+			password: password,
+			wantErr:  ErrInvalidUserOrPassword,
+		},
+		{
+			name:     "wrong password",
+			email:    "a@qq.com",
+			password: "wrong" + password,
+			wantErr:  ErrInvalidUserOrPassword,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := newTestUserService(t)
+			ctx := context.Background()
+			require.NoError(t, svc.Signup(ctx, domain.User{Email: "a@qq.com", Password: password}))
+			u, err := svc.Login(ctx, tc.email, tc.password)
+			assert.ErrorIs(t, err, tc.wantErr)
+			if err != nil {
+				// 邮箱不存在和密码错误返回同一个错误，不暴露邮箱是否注册过
+				assert.NotErrorIs(t, err, ErrUserNotFound)
+				assert.Equal(t, errs.KindUnauthorized, errs.KindOf(err))
+				return
+			}
+			assert.Equal(t, tc.email, u.Email)
+		})
+	}
+}
+
+func TestUserService_PurgeDeletedAccounts(t *testing.T) {
+	db := newTestDB(t)
+	mr := miniredis.RunT(t)
+	rc := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rc.Close() })
+	userRepo := repository.NewUserRepository(dao.NewUserDAO(db), cache.NewUserCache(rc, time.Hour))
+	artRepo := repository.NewArticleRepository(dao.NewArticleDAO(db), cache.NewArticleCache(rc))
+	commentRepo := repository.NewCommentRepository(dao.NewCommentDAO(db))
+	followRepo := repository.NewFollowRepository(dao.NewFollowDAO(db), cache.NewFollowCache(rc))
+	intrRepo := repository.NewInteractionRepository(dao.NewInteractionDAO(db), cache.NewInteractionCache(rc))
+	intrSvc := NewInteractionService(intrRepo, artRepo, events.NewMemoryBroker(10))
+	feedSvc := NewFeedService(repository.NewFeedRepository(dao.NewFeedDAO(db), dao.NewArticleDAO(db)),
+		followRepo, userRepo)
+	svc := NewUserService(userRepo, hasher.NewBcryptHasher(bcrypt.MinCost), pwdpolicy.NewPolicy(nil))
+	ctx := context.Background()
+
+	createUser := func(email string) domain.User {
+		require.NoError(t, userRepo.Create(ctx, domain.User{Email: email, Password: "hash"},
+			func(u domain.User) domain.Event { return domain.UserRegistered{Uid: u.Id, Email: u.Email} }))
+		u, err := userRepo.FindByEmail(ctx, email)
+		require.NoError(t, err)
+		return u
+	}
+	publish := func(author int64) int64 {
+		art, err := artRepo.Sync(ctx, domain.Article{Title: "t", Content: "c",
+			Author: domain.Author{Id: author}, Status: domain.ArticleStatusPublished})
+		require.NoError(t, err)
+		require.NoError(t, feedSvc.Push(ctx, art))
+		return art.Id
+	}
+	comment := func(uid, bizId, rootId, parentId int64) int64 {
+		id, err := commentRepo.Create(ctx, domain.Comment{Biz: domain.BizArticle, BizId: bizId,
+			Commentator: domain.User{Id: uid}, RootId: rootId, ParentId: parentId, Content: "c"})
+		require.NoError(t, err)
+		return id
+	}
+	u := createUser("a@qq.com")
+	other := createUser("b@qq.com")
+	avatar := domain.Avatar{URL: "http://localhost/static/avatars/1/a.png",
+		ThumbnailURL: "http://localhost/static/avatars/1/a_thumb.png"}
+	_, err := userRepo.UpdateAvatar(ctx, u.Id, avatar)
+	require.NoError(t, err)
+	require.NoError(t, followRepo.Follow(ctx, u.Id, other.Id))
+	require.NoError(t, followRepo.Follow(ctx, other.Id, u.Id))
+
+	// u 的文章以及别人在上面的点赞和评论
+	uArt := publish(u.Id)
+	_, err = artRepo.Create(ctx, domain.Article{Title: "draft", Author: domain.Author{Id: u.Id},
+		Status: domain.ArticleStatusDraft})
+	require.NoError(t, err)
+	require.NoError(t, intrSvc.Like(ctx, domain.BizArticle, uArt, other.Id))
+	comment(other.Id, uArt, 0, 0)
+
+	// u 在别人的文章上的点赞、收藏和评论
+	otherArt := publish(other.Id)
+	require.NoError(t, intrSvc.Like(ctx, domain.BizArticle, otherArt, u.Id))
+	require.NoError(t, intrSvc.Like(ctx, domain.BizArticle, otherArt, other.Id))
+	for _, name := range []string{"c1", "c2"} {
+		cid, err := intrSvc.CreateCollection(ctx, domain.Collection{Uid: u.Id, Name: name})
+		require.NoError(t, err)
+		require.NoError(t, intrSvc.Collect(ctx, domain.BizArticle, otherArt, cid, u.Id))
+	}
+	// u 的顶层评论连同别人的回复一起删除
+	uRoot := comment(u.Id, otherArt, 0, 0)
+	comment(other.Id, otherArt, uRoot, uRoot)
+	// 别人的顶层评论保留，u 的回复以及回复 u 的评论删除
+	otherRoot := comment(other.Id, otherArt, 0, 0)
+	uReply := comment(u.Id, otherArt, otherRoot, otherRoot)
+	comment(other.Id, otherArt, otherRoot, uReply)
+	keptReply := comment(other.Id, otherArt, otherRoot, otherRoot)
+
+	require.NoError(t, svc.DeleteAccount(ctx, u.Id))
+	// 保留期为负数，刚注销的账号也会被删除
+	n, err := svc.PurgeDeletedAccounts(ctx, -time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	count := func(model any, query string, args ...any) int64 {
+		var cnt int64
+		require.NoError(t, db.Model(model).Where(query, args...).Count(&cnt).Error)
+		return cnt
+	}
+	assert.Zero(t, count(&dao.User{}, "id = ?", u.Id))
+	assert.Zero(t, count(&dao.Article{}, "author_id = ?", u.Id))
+	assert.Zero(t, count(&dao.PublishedArticle{}, "author_id = ?", u.Id))
+	assert.Zero(t, count(&dao.Interaction{}, "biz_id = ?", uArt))
+	assert.Zero(t, count(&dao.UserLikeBiz{}, "uid = ? OR biz_id = ?", u.Id, uArt))
+	assert.Zero(t, count(&dao.UserCollectionBiz{}, "uid = ?", u.Id))
+	assert.Zero(t, count(&dao.Collection{}, "uid = ?", u.Id))
+	assert.Zero(t, count(&dao.FollowRelation{}, "follower = ? OR followee = ?", u.Id, u.Id))
+	assert.Zero(t, count(&dao.FollowStatistic{}, "uid = ?", u.Id))
+	assert.Zero(t, count(&dao.FeedPushEvent{}, "uid = ? OR author_id = ?", u.Id, u.Id))
+	assert.Zero(t, count(&dao.Comment{}, "uid = ? OR biz_id = ?", u.Id, uArt))
+	var comments []int64
+	require.NoError(t, db.Model(&dao.Comment{}).Order("id").Pluck("id", &comments).Error)
+	assert.Equal(t, []int64{otherRoot, keptReply}, comments)
+
+	// 数据库中别人的计数减掉了 u 的部分，Redis 中缓存的计数等过期之后更新
+	intr, err := dao.NewInteractionDAO(db).Get(ctx, domain.BizArticle, otherArt)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), intr.LikeCnt)
+	assert.Zero(t, intr.CollectCnt)
+	s, err := dao.NewFollowDAO(db).FindStatistic(ctx, other.Id)
+	require.NoError(t, err)
+	assert.Zero(t, s.Followers)
+	assert.Zero(t, s.Followees)
+
+	// 头像文件由下游根据发件箱中的事件删除
+	var msg dao.OutboxMessage
+	require.NoError(t, db.Where("topic = ?", domain.TopicUserPurged).First(&msg).Error)
+	var evt domain.UserPurged
+	require.NoError(t, json.Unmarshal([]byte(msg.Payload), &evt))
+	assert.Equal(t, domain.UserPurged{Uid: u.Id, Avatar: avatar}, evt)
+}
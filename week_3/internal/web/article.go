@@ -0,0 +1,292 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+	"go_homework/week_3/internal/domain"
+	"go_homework/week_3/internal/errs"
+	"go_homework/week_3/internal/service"
+	"go_homework/week_3/pkg/logger"
+	"go_homework/week_3/pkg/pagination"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type ArticleHandler struct {
+	svc     *service.ArticleService
+	intrSvc *service.InteractionService
+}
+
+func NewArticleHandler(svc *service.ArticleService, intrSvc *service.InteractionService) *ArticleHandler {
+	return &ArticleHandler{svc: svc, intrSvc: intrSvc}
+}
+
+// RegisterRoutes 所有接口都只能操作当前登录用户自己的文章
+func (h *ArticleHandler) RegisterRoutes(server *gin.Engine) {
+	g := server.Group("/articles")
+	g.POST("/edit", h.Edit)
+	g.POST("/publish", h.Publish)
+	g.POST("/withdraw", h.Withdraw)
+	g.GET("/detail/:id", h.Detail)
+	g.GET("/list", h.List)
+	// 读者查看已经发表的文章，可以看任何人的文章
+	g.GET("/pub/:id", h.PubDetail)
+}
+
+// ArticleRequest 编辑和发表文章的请求，id 为 0 表示新建文章
+type ArticleRequest struct {
+	Id      int64  `json:"id" binding:"min=0"`
+	Title   string `json:"title" binding:"max=256"`
+	Content string `json:"content" binding:"max=100000"`
+}
+
+func (req ArticleRequest) toDomain(uid int64) domain.Article {
+	return domain.Article{
+		Id:      req.Id,
+		Title:   req.Title,
+		Content: req.Content,
+		Author:  domain.Author{Id: uid},
+	}
+}
+
+// Edit 保存草稿，返回文章的 id
+func (h *ArticleHandler) Edit(ctx *gin.Context) {
+	var req ArticleRequest
+	if !bind(ctx, &req) {
+		return
+	}
+	uc, err := getUserClaims(ctx)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	id, err := h.svc.Save(ctx, req.toDomain(uc.Uid))
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{Msg: "Saved", Data: id})
+}
+
+// Publish 保存并发表文章，返回文章的 id
+func (h *ArticleHandler) Publish(ctx *gin.Context) {
+	type PublishRequest struct {
+		Id int64 `json:"id" binding:"min=0"`
+		// 发表的文章必须有标题
+		Title   string `json:"title" binding:"required,max=256"`
+		Content string `json:"content" binding:"max=100000"`
+	}
+	var req PublishRequest
+	if !bind(ctx, &req) {
+		return
+	}
+	uc, err := getUserClaims(ctx)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	id, err := h.svc.Publish(ctx, ArticleRequest(req).toDomain(uc.Uid))
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{Msg: "Published", Data: id})
+}
+
+// ArticleVO 作者看到的文章
+type ArticleVO struct {
+	Id      int64  `json:"id"`
+	Title   string `json:"title"`
+	Content string `json:"content"`
+	// draft、published 或者 private
+	Status string    `json:"status"`
+	Ctime  time.Time `json:"ctime"`
+	Utime  time.Time `json:"utime"`
+}
+
+// articleStatusNames 文章状态在接口中的名字
+var articleStatusNames = map[domain.ArticleStatus]string{
+	domain.ArticleStatusDraft:     "draft",
+	domain.ArticleStatusPublished: "published",
+	domain.ArticleStatusPrivate:   "private",
+}
+
+func newArticleVO(art domain.Article) ArticleVO {
+	return ArticleVO{
+		Id:      art.Id,
+		Title:   art.Title,
+		Content: art.Content,
+		Status:  articleStatusNames[art.Status],
+		Ctime:   art.Ctime,
+		Utime:   art.Utime,
+	}
+}
+
+// Detail 作者查看自己的文章，用于继续编辑
+func (h *ArticleHandler) Detail(ctx *gin.Context) {
+	id, ok := articleIdParam(ctx)
+	if !ok {
+		return
+	}
+	uc, err := getUserClaims(ctx)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	art, err := h.svc.Detail(ctx, uc.Uid, id)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{Data: newArticleVO(art)})
+}
+
+// Withdraw 撤回已经发表的文章
+func (h *ArticleHandler) Withdraw(ctx *gin.Context) {
+	type WithdrawRequest struct {
+		Id int64 `json:"id" binding:"required,min=1"`
+	}
+	var req WithdrawRequest
+	if !bind(ctx, &req) {
+		return
+	}
+	uc, err := getUserClaims(ctx)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	if err = h.svc.Withdraw(ctx, uc.Uid, req.Id); err != nil {
+		writeError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{Msg: "Withdrawn"})
+}
+
+// ArticleListItemVO 作者文章列表中的一项，只有摘要没有全文
+type ArticleListItemVO struct {
+	Id       int64  `json:"id"`
+	Title    string `json:"title"`
+	Abstract string `json:"abstract"`
+	// draft、published 或者 private
+	Status string    `json:"status"`
+	Ctime  time.Time `json:"ctime"`
+	Utime  time.Time `json:"utime"`
+}
+
+// List 作者自己的文章列表，按照更新时间倒序排列，使用游标分页
+func (h *ArticleHandler) List(ctx *gin.Context) {
+	type ListRequest struct {
+		// 上一页返回的 nextCursor，第一页不传
+		Cursor string `form:"cursor"`
+		Limit  int    `form:"limit" binding:"omitempty,min=1,max=50"`
+	}
+	var req ListRequest
+	if !bind(ctx, &req) {
+		return
+	}
+	uc, err := getUserClaims(ctx)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	page, err := h.svc.List(ctx, uc.Uid, req.Cursor, req.Limit)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{Data: pagination.Map(page, func(art domain.Article) ArticleListItemVO {
+		return ArticleListItemVO{
+			Id:       art.Id,
+			Title:    art.Title,
+			Abstract: art.Content,
+			Status:   articleStatusNames[art.Status],
+			Ctime:    art.Ctime,
+			Utime:    art.Utime,
+		}
+	})})
+}
+
+// PubArticleVO 读者看到的文章
+type PubArticleVO struct {
+	Id      int64    `json:"id"`
+	Title   string   `json:"title"`
+	Content string   `json:"content"`
+	Author  AuthorVO `json:"author"`
+	// 阅读、点赞和收藏的计数，以及当前用户是否点过赞、收藏过
+	Interaction InteractionVO `json:"interaction"`
+	Ctime       time.Time     `json:"ctime"`
+	Utime       time.Time     `json:"utime"`
+}
+
+// ArticleAbstractVO 读者在热榜、关注流之类的列表中看到的文章，只有摘要没有全文
+type ArticleAbstractVO struct {
+	Id       int64    `json:"id"`
+	Title    string   `json:"title"`
+	Abstract string   `json:"abstract"`
+	Author   AuthorVO `json:"author"`
+	// 第一次发表的时间
+	Ctime time.Time `json:"ctime"`
+}
+
+func newArticleAbstractVO(art domain.Article) ArticleAbstractVO {
+	return ArticleAbstractVO{
+		Id:       art.Id,
+		Title:    art.Title,
+		Abstract: art.Content,
+		Author:   AuthorVO{Id: art.Author.Id, Name: art.Author.Name},
+		Ctime:    art.Ctime,
+	}
+}
+
+type AuthorVO struct {
+	Id int64 `json:"id"`
+	// 作者注销之后为空
+	Name string `json:"name"`
+}
+
+// PubDetail 读者查看已经发表的文章
+func (h *ArticleHandler) PubDetail(ctx *gin.Context) {
+	id, ok := articleIdParam(ctx)
+	if !ok {
+		return
+	}
+	uc, err := getUserClaims(ctx)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	art, err := h.svc.PubDetail(ctx, id)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	// 阅读数由消费者异步修改，只是参考数据，事件发送失败不影响读者看文章
+	if err = h.intrSvc.RecordRead(ctx, domain.BizArticle, id, uc.Uid); err != nil {
+		logger.FromContext(ctx).Error("record article read failed",
+			logger.Int64("article_id", id), logger.Error(err))
+	}
+	intr, err := h.intrSvc.Get(ctx, domain.BizArticle, id, uc.Uid)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{Data: PubArticleVO{
+		Id:          art.Id,
+		Title:       art.Title,
+		Content:     art.Content,
+		Author:      AuthorVO{Id: art.Author.Id, Name: art.Author.Name},
+		Interaction: newInteractionVO(intr),
+		Ctime:       art.Ctime,
+		Utime:       art.Utime,
+	}})
+}
+
+// articleIdParam 解析路径中的文章 id，不合法的时候直接返回 400
+func articleIdParam(ctx *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		ctx.JSON(http.StatusBadRequest, Result{Code: errs.CodeInvalidInput, Msg: "Invalid article id"})
+		return 0, false
+	}
+	return id, true
+}
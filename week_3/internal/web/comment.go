@@ -0,0 +1,166 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+	"go_homework/week_3/internal/domain"
+	"go_homework/week_3/internal/errs"
+	"go_homework/week_3/internal/service"
+	"go_homework/week_3/pkg/pagination"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type CommentHandler struct {
+	svc *service.CommentService
+	// 发表评论的限流，按照用户限流
+	postLimiter gin.HandlerFunc
+}
+
+func NewCommentHandler(svc *service.CommentService, postLimiter gin.HandlerFunc) *CommentHandler {
+	return &CommentHandler{svc: svc, postLimiter: postLimiter}
+}
+
+func (h *CommentHandler) RegisterRoutes(server *gin.Engine) {
+	g := server.Group("/comments")
+	g.GET("", h.Roots)
+	g.POST("", h.postLimiter, h.Post)
+	g.DELETE("/:id", h.Delete)
+	g.GET("/:id/replies", h.Replies)
+}
+
+// CommentVO 评论。parentId 为 0 的是顶层评论，replyCnt 只对顶层评论有意义
+type CommentVO struct {
+	Id       int64  `json:"id"`
+	Content  string `json:"content"`
+	RootId   int64  `json:"rootId"`
+	ParentId int64  `json:"parentId"`
+	ReplyCnt int64  `json:"replyCnt"`
+	// 评论人，已经注销的用户只有 id
+	Commentator CommentatorVO `json:"commentator"`
+	Ctime       time.Time     `json:"ctime"`
+}
+
+type CommentatorVO struct {
+	Id              int64  `json:"id"`
+	Nickname        string `json:"nickname"`
+	AvatarThumbnail string `json:"avatarThumbnail"`
+}
+
+func newCommentVO(c domain.Comment) CommentVO {
+	return CommentVO{
+		Id:       c.Id,
+		Content:  c.Content,
+		RootId:   c.RootId,
+		ParentId: c.ParentId,
+		ReplyCnt: c.ReplyCnt,
+		Commentator: CommentatorVO{
+			Id:              c.Commentator.Id,
+			Nickname:        c.Commentator.Nickname,
+			AvatarThumbnail: c.Commentator.Avatar.ThumbnailURL,
+		},
+		Ctime: c.Ctime,
+	}
+}
+
+// Post 发表评论或者回复，返回评论的 id
+func (h *CommentHandler) Post(ctx *gin.Context) {
+	type PostRequest struct {
+		Biz     string `json:"biz" binding:"required,oneof=article"`
+		BizId   int64  `json:"bizId" binding:"required,min=1"`
+		Content string `json:"content" binding:"required,max=4096"`
+		// 回复的评论，为 0 的时候是顶层评论
+		ParentId int64 `json:"parentId" binding:"min=0"`
+	}
+	var req PostRequest
+	if !bind(ctx, &req) {
+		return
+	}
+	uc, err := getUserClaims(ctx)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	id, err := h.svc.Post(ctx, domain.Comment{
+		Biz:         req.Biz,
+		BizId:       req.BizId,
+		Commentator: domain.User{Id: uc.Uid},
+		Content:     req.Content,
+		ParentId:    req.ParentId,
+	})
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{Msg: "Posted", Data: id})
+}
+
+// Delete 删除自己的评论，下面所有的回复一起删除
+func (h *CommentHandler) Delete(ctx *gin.Context) {
+	id, ok := commentIdParam(ctx)
+	if !ok {
+		return
+	}
+	uc, err := getUserClaims(ctx)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	if err = h.svc.Delete(ctx, uc.Uid, id); err != nil {
+		writeError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{Msg: "Deleted"})
+}
+
+// Roots 业务对象的顶层评论，按照时间倒序排列，例如 /comments?biz=article&bizId=1
+func (h *CommentHandler) Roots(ctx *gin.Context) {
+	type RootsRequest struct {
+		Biz    string `form:"biz" binding:"required,oneof=article"`
+		BizId  int64  `form:"bizId" binding:"required,min=1"`
+		Cursor string `form:"cursor"`
+		Limit  int    `form:"limit" binding:"omitempty,min=1,max=50"`
+	}
+	var req RootsRequest
+	if !bind(ctx, &req) {
+		return
+	}
+	page, err := h.svc.Roots(ctx, req.Biz, req.BizId, req.Cursor, req.Limit)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{Data: pagination.Map(page, newCommentVO)})
+}
+
+// Replies 顶层评论下面的回复，按照时间正序排列，展开评论的时候再加载
+func (h *CommentHandler) Replies(ctx *gin.Context) {
+	id, ok := commentIdParam(ctx)
+	if !ok {
+		return
+	}
+	type RepliesRequest struct {
+		Cursor string `form:"cursor"`
+		Limit  int    `form:"limit" binding:"omitempty,min=1,max=50"`
+	}
+	var req RepliesRequest
+	if !bind(ctx, &req) {
+		return
+	}
+	page, err := h.svc.Replies(ctx, id, req.Cursor, req.Limit)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{Data: pagination.Map(page, newCommentVO)})
+}
+
+// commentIdParam 解析路径中的评论 id，不合法的时候直接返回 400
+func commentIdParam(ctx *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		ctx.JSON(http.StatusBadRequest, Result{Code: errs.CodeInvalidInput, Msg: "Invalid comment id"})
+		return 0, false
+	}
+	return id, true
+}
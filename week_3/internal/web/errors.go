@@ -0,0 +1,37 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+	"go_homework/week_3/internal/errs"
+	"go_homework/week_3/pkg/logger"
+	"net/http"
+)
+
+// statusOf 错误分类和 HTTP 状态码的对应关系
+func statusOf(kind errs.Kind) int {
+	switch kind {
+	case errs.KindNotFound:
+		return http.StatusNotFound
+	case errs.KindConflict:
+		return http.StatusConflict
+	case errs.KindUnauthorized:
+		return http.StatusUnauthorized
+	case errs.KindValidation:
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeError 统一把 service 返回的错误转换成 HTTP 响应。
+// 内部错误不会把细节暴露给用户，只返回 System error，细节记在日志里面，通过请求 ID 关联
+func writeError(ctx *gin.Context, err error) {
+	e := errs.As(err)
+	if e.Kind == errs.KindInternal {
+		logger.FromContext(ctx).Error("system error", logger.String("method", ctx.Request.Method),
+			logger.String("path", ctx.FullPath()), logger.Error(err))
+		ctx.JSON(statusOf(e.Kind), Result{Code: errs.CodeSystemError, Msg: "System error"})
+		return
+	}
+	ctx.JSON(statusOf(e.Kind), Result{Code: e.Code, Msg: e.Msg})
+}
@@ -0,0 +1,78 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go_homework/week_3/internal/errs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	testCases := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantResult Result
+	}{
+		{
+			name:       "not found",
+			err:        errs.NotFound(errs.CodeUserNotFound, "User not found").Wrap(errors.New("record not found")),
+			wantStatus: http.StatusNotFound,
+			wantResult: Result{Code: errs.CodeUserNotFound, Msg: "User not found"},
+		},
+		{
+			name:       "conflict",
+			err:        fmt.Errorf("signup: %w", errs.Conflict(errs.CodeDuplicateEmail, "Email is already exist")),
+			wantStatus: http.StatusConflict,
+			wantResult: Result{Code: errs.CodeDuplicateEmail, Msg: "Email is already exist"},
+		},
+		{
+			name:       "unauthorized",
+			err:        errs.Unauthorized(errs.CodeInvalidUserOrPassword, "Invalid user or password"),
+			wantStatus: http.StatusUnauthorized,
+			wantResult: Result{Code: errs.CodeInvalidUserOrPassword, Msg: "Invalid user or password"},
+		},
+		{
+			name:       "validation",
+			err:        errs.Validation(errs.CodeInvalidInput, "Invalid email"),
+			wantStatus: http.StatusBadRequest,
+			wantResult: Result{Code: errs.CodeInvalidInput, Msg: "Invalid email"},
+		},
+		{
+			// 内部错误的细节不会返回给用户
+			name:       "internal",
+			err:        errors.New("dial tcp 127.0.0.1:3306: connection refused"),
+			wantStatus: http.StatusInternalServerError,
+			wantResult: Result{Code: errs.CodeSystemError, Msg: "System error"},
+		},
+		{
+			name:       "typed internal",
+			err:        errs.Internal(errors.New("disk full")),
+			wantStatus: http.StatusInternalServerError,
+			wantResult: Result{Code: errs.CodeSystemError, Msg: "System error"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := gin.New()
+			server.GET("/test", func(ctx *gin.Context) {
+				writeError(ctx, tc.err)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tc.wantStatus, recorder.Code)
+			var res Result
+			require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+			assert.Equal(t, tc.wantResult, res)
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package web
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"go_homework/week_3/internal/domain"
+	"go_homework/week_3/internal/errs"
+	"go_homework/week_3/internal/service"
+	"go_homework/week_3/pkg/pagination"
+	"net/http"
+	"strconv"
+)
+
+type FollowHandler struct {
+	svc     *service.FollowService
+	feedSvc *service.FeedService
+}
+
+func NewFollowHandler(svc *service.FollowService, feedSvc *service.FeedService) *FollowHandler {
+	return &FollowHandler{svc: svc, feedSvc: feedSvc}
+}
+
+func (h *FollowHandler) RegisterRoutes(server *gin.Engine) {
+	g := server.Group("/follow")
+	g.POST("/:uid", h.Follow)
+	g.DELETE("/:uid", h.Unfollow)
+	g.GET("/:uid/followers", h.Followers)
+	g.GET("/:uid/followees", h.Followees)
+	// 当前用户关注的作者发表的文章
+	server.GET("/feed", h.Feed)
+}
+
+// FollowStatisticVO 个人主页上展示的关注数和粉丝数
+type FollowStatisticVO struct {
+	Followers int64 `json:"followers"`
+	Followees int64 `json:"followees"`
+	// 当前用户是否关注了这个用户
+	Followed bool `json:"followed"`
+}
+
+func newFollowStatisticVO(s domain.FollowStatistic) *FollowStatisticVO {
+	return &FollowStatisticVO{Followers: s.Followers, Followees: s.Followees, Followed: s.Followed}
+}
+
+// FollowListRequest 粉丝和关注列表的分页参数，返回的 nextCursor 不为空的时候带上 cursor=nextCursor 查询下一页
+type FollowListRequest struct {
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=50"`
+}
+
+// Follow 关注路径中的用户，重复关注也返回成功
+func (h *FollowHandler) Follow(ctx *gin.Context) {
+	uid, ok := userIdParam(ctx)
+	if !ok {
+		return
+	}
+	uc, err := getUserClaims(ctx)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	if err = h.svc.Follow(ctx, uc.Uid, uid); err != nil {
+		writeError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{Msg: "Followed"})
+}
+
+// Unfollow 取消关注路径中的用户，没有关注过也返回成功
+func (h *FollowHandler) Unfollow(ctx *gin.Context) {
+	uid, ok := userIdParam(ctx)
+	if !ok {
+		return
+	}
+	uc, err := getUserClaims(ctx)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	if err = h.svc.Unfollow(ctx, uc.Uid, uid); err != nil {
+		writeError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{Msg: "Unfollowed"})
+}
+
+// Followers 路径中用户的粉丝列表，按照关注时间倒序排列
+func (h *FollowHandler) Followers(ctx *gin.Context) {
+	h.list(ctx, h.svc.Followers)
+}
+
+// Followees 路径中用户关注的人，按照关注时间倒序排列
+func (h *FollowHandler) Followees(ctx *gin.Context) {
+	h.list(ctx, h.svc.Followees)
+}
+
+func (h *FollowHandler) list(ctx *gin.Context, fn func(ctx context.Context, viewer, uid int64, cursor string,
+	limit int) (pagination.Page[domain.User], error)) {
+	uid, ok := userIdParam(ctx)
+	if !ok {
+		return
+	}
+	var req FollowListRequest
+	if !bind(ctx, &req) {
+		return
+	}
+	uc, err := getUserClaims(ctx)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	page, err := fn(ctx, uc.Uid, uid, req.Cursor, req.Limit)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{Data: pagination.Map(page, newPublicProfileVO)})
+}
+
+// Feed 当前用户关注的作者发表的文章，按照第一次发表的时间倒序排列，使用游标分页
+func (h *FollowHandler) Feed(ctx *gin.Context) {
+	var req FollowListRequest
+	if !bind(ctx, &req) {
+		return
+	}
+	uc, err := getUserClaims(ctx)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	page, err := h.feedSvc.Feed(ctx, uc.Uid, req.Cursor, req.Limit)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{Data: pagination.Map(page, newArticleAbstractVO)})
+}
+
+// userIdParam 解析路径中的用户 id，不合法的时候直接返回 400
+func userIdParam(ctx *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(ctx.Param("uid"), 10, 64)
+	if err != nil || id <= 0 {
+		ctx.JSON(http.StatusBadRequest, Result{Code: errs.CodeInvalidInput, Msg: "Invalid user id"})
+		return 0, false
+	}
+	return id, true
+}
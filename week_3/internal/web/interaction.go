@@ -0,0 +1,199 @@
+package web
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"go_homework/week_3/internal/domain"
+	"go_homework/week_3/internal/service"
+	"net/http"
+	"time"
+)
+
+type InteractionHandler struct {
+	svc *service.InteractionService
+}
+
+func NewInteractionHandler(svc *service.InteractionService) *InteractionHandler {
+	return &InteractionHandler{svc: svc}
+}
+
+func (h *InteractionHandler) RegisterRoutes(server *gin.Engine) {
+	g := server.Group("/interactions")
+	g.GET("", h.BatchGet)
+	g.POST("/like", h.Like)
+	g.POST("/cancel_like", h.CancelLike)
+	g.POST("/collect", h.Collect)
+	g.POST("/cancel_collect", h.CancelCollect)
+	cg := server.Group("/collections")
+	cg.POST("", h.CreateCollection)
+	cg.GET("", h.ListCollections)
+}
+
+// BizRequest 互动的业务对象，目前只有文章
+type BizRequest struct {
+	Biz   string `json:"biz" binding:"required,oneof=article"`
+	BizId int64  `json:"bizId" binding:"required,min=1"`
+}
+
+// InteractionVO 业务对象的计数以及当前用户的点赞和收藏状态
+type InteractionVO struct {
+	BizId      int64 `json:"bizId"`
+	ReadCnt    int64 `json:"readCnt"`
+	LikeCnt    int64 `json:"likeCnt"`
+	CollectCnt int64 `json:"collectCnt"`
+	Liked      bool  `json:"liked"`
+	Collected  bool  `json:"collected"`
+}
+
+func newInteractionVO(intr domain.Interaction) InteractionVO {
+	return InteractionVO{
+		BizId:      intr.BizId,
+		ReadCnt:    intr.ReadCnt,
+		LikeCnt:    intr.LikeCnt,
+		CollectCnt: intr.CollectCnt,
+		Liked:      intr.Liked,
+		Collected:  intr.Collected,
+	}
+}
+
+// BatchGet 列表页批量查询互动数据，例如 /interactions?biz=article&ids=1&ids=2，
+// 按照 ids 的顺序返回，没有任何互动的业务对象计数都是 0
+func (h *InteractionHandler) BatchGet(ctx *gin.Context) {
+	type BatchGetRequest struct {
+		Biz string  `form:"biz" binding:"required,oneof=article"`
+		Ids []int64 `form:"ids" binding:"required,min=1,max=100,dive,min=1"`
+	}
+	var req BatchGetRequest
+	if !bind(ctx, &req) {
+		return
+	}
+	uc, err := getUserClaims(ctx)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	intrs, err := h.svc.GetByIds(ctx, req.Biz, req.Ids, uc.Uid)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	res := make([]InteractionVO, 0, len(req.Ids))
+	for _, id := range req.Ids {
+		res = append(res, newInteractionVO(intrs[id]))
+	}
+	ctx.JSON(http.StatusOK, Result{Data: res})
+}
+
+// Like 点赞，重复点赞也返回成功
+func (h *InteractionHandler) Like(ctx *gin.Context) {
+	h.handleBiz(ctx, "Liked", h.svc.Like)
+}
+
+// CancelLike 取消点赞，没有点过赞也返回成功
+func (h *InteractionHandler) CancelLike(ctx *gin.Context) {
+	h.handleBiz(ctx, "Like cancelled", h.svc.CancelLike)
+}
+
+// handleBiz 处理只需要业务对象和当前用户的请求
+func (h *InteractionHandler) handleBiz(ctx *gin.Context, msg string,
+	fn func(ctx context.Context, biz string, bizId, uid int64) error) {
+	var req BizRequest
+	if !bind(ctx, &req) {
+		return
+	}
+	uc, err := getUserClaims(ctx)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	if err = fn(ctx, req.Biz, req.BizId, uc.Uid); err != nil {
+		writeError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{Msg: msg})
+}
+
+// CollectRequest 收藏和取消收藏的业务对象以及收藏夹
+type CollectRequest struct {
+	Biz   string `json:"biz" binding:"required,oneof=article"`
+	BizId int64  `json:"bizId" binding:"required,min=1"`
+	// 收藏夹的 id
+	Cid int64 `json:"cid" binding:"required,min=1"`
+}
+
+// Collect 收藏到当前用户自己的收藏夹里面，已经在这个收藏夹里面也返回成功
+func (h *InteractionHandler) Collect(ctx *gin.Context) {
+	h.handleCollect(ctx, "Collected", h.svc.Collect)
+}
+
+// CancelCollect 从收藏夹里面取消收藏，没有收藏过也返回成功
+func (h *InteractionHandler) CancelCollect(ctx *gin.Context) {
+	h.handleCollect(ctx, "Collect cancelled", h.svc.CancelCollect)
+}
+
+// handleCollect 处理需要业务对象、收藏夹和当前用户的请求
+func (h *InteractionHandler) handleCollect(ctx *gin.Context, msg string,
+	fn func(ctx context.Context, biz string, bizId, cid, uid int64) error) {
+	var req CollectRequest
+	if !bind(ctx, &req) {
+		return
+	}
+	uc, err := getUserClaims(ctx)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	if err = fn(ctx, req.Biz, req.BizId, req.Cid, uc.Uid); err != nil {
+		writeError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{Msg: msg})
+}
+
+// CollectionVO 收藏夹
+type CollectionVO struct {
+	Id    int64     `json:"id"`
+	Name  string    `json:"name"`
+	Ctime time.Time `json:"ctime"`
+}
+
+// CreateCollection 新建收藏夹，返回收藏夹的 id
+func (h *InteractionHandler) CreateCollection(ctx *gin.Context) {
+	type CreateCollectionRequest struct {
+		Name string `json:"name" binding:"required,max=128"`
+	}
+	var req CreateCollectionRequest
+	if !bind(ctx, &req) {
+		return
+	}
+	uc, err := getUserClaims(ctx)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	id, err := h.svc.CreateCollection(ctx, domain.Collection{Uid: uc.Uid, Name: req.Name})
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{Msg: "Created", Data: id})
+}
+
+// ListCollections 当前用户所有的收藏夹
+func (h *InteractionHandler) ListCollections(ctx *gin.Context) {
+	uc, err := getUserClaims(ctx)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	cs, err := h.svc.ListCollections(ctx, uc.Uid)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	res := make([]CollectionVO, 0, len(cs))
+	for _, c := range cs {
+		res = append(res, CollectionVO{Id: c.Id, Name: c.Name, Ctime: c.Ctime})
+	}
+	ctx.JSON(http.StatusOK, Result{Data: res})
+}
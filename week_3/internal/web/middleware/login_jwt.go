@@ -1,17 +1,32 @@
 package middleware
 
 import (
+	"context"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"go.opentelemetry.io/otel"
 	"go_homework/week_3/internal/web"
-	"log"
+	"go_homework/week_3/pkg/logger"
 	"net/http"
 	"strings"
 	"time"
 )
 
+var tracer = otel.Tracer("go_homework/week_3/internal/web/middleware")
+
+// SessionChecker 用于判断 token 是否已经被吊销，例如用户注销了账号
+type SessionChecker interface {
+	IsSessionRevoked(ctx context.Context, uid int64, issuedAt time.Time) (bool, error)
+}
+
 // LoginJWTMiddlewareBuilder 定义了一个名为 LoginJWTMiddlewareBuilder 的结构体，用于构建处理 JWT 登录校验的中间件
 type LoginJWTMiddlewareBuilder struct {
+	sessions SessionChecker
+}
+
+// NewLoginJWTMiddlewareBuilder 创建 LoginJWTMiddlewareBuilder，sessions 用于检查 token 是否已经被吊销
+func NewLoginJWTMiddlewareBuilder(sessions SessionChecker) *LoginJWTMiddlewareBuilder {
+	return &LoginJWTMiddlewareBuilder{sessions: sessions}
 }
 
 // CheckLogin 定义了一个名为 CheckLogin 的方法，返回一个 gin.HandlerFunc 类型的函数
@@ -20,11 +35,15 @@ func (m *LoginJWTMiddlewareBuilder) CheckLogin() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		// 获取当前请求的路径
 		path := ctx.Request.URL.Path
-		// 判断当前请求路径是否为注册或登录的接口
-		if path == "/users/signup" || path == "/users/login" || path == "/hello" {
+		// 判断当前请求路径是否为注册或登录的接口，找回密码和静态文件（例如头像）也不需要登录
+		if path == "/users/signup" || path == "/users/login" || path == "/hello" ||
+			strings.HasPrefix(path, "/users/password/reset") || strings.HasPrefix(path, "/static/") {
 			// 如果是注册或登录的接口，不需要进行登录校验，直接返回
 			return
 		}
+		// 登录校验单独作为一个 span，可以看出 JWT 解析和吊销检查花了多少时间
+		spanCtx, span := tracer.Start(ctx, "LoginJWTMiddlewareBuilder.CheckLogin")
+		defer span.End()
 		// 从请求头中获取 Authorization 字段的值 like Bearer XXXX
 		authCode := ctx.GetHeader("Authorization")
 		// 如果请求头中没有 Authorization 字段，或者字段值为空
@@ -66,6 +85,25 @@ func (m *LoginJWTMiddlewareBuilder) CheckLogin() gin.HandlerFunc {
 			return
 		}
 
+		// 检查 token 是否已经被吊销
+		var issuedAt time.Time
+		if uc.IssuedAt != nil {
+			issuedAt = uc.IssuedAt.Time
+		}
+		revoked, err := m.sessions.IsSessionRevoked(spanCtx, uc.Uid, issuedAt)
+		if err != nil {
+			// 查不到吊销记录的时候拒绝请求，不然 Redis 出问题期间注销的账号、重置过密码的账号的旧 token 仍然可以使用。
+			// 返回 503 而不是 401，客户端不会因此清掉登录状态，恢复之后可以继续使用
+			logger.FromContext(ctx).Error("check session revocation failed",
+				logger.Int64("uid", uc.Uid), logger.Error(err))
+			ctx.AbortWithStatus(http.StatusServiceUnavailable)
+			return
+		}
+		if revoked {
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
 		// 获取 JWT 中的过期时间
 		expireTime := uc.ExpiresAt
 
@@ -80,11 +118,14 @@ func (m *LoginJWTMiddlewareBuilder) CheckLogin() gin.HandlerFunc {
 			// 如果生成新令牌的过程中发生错误
 			if err != nil {
 				// 不要终止请求处理流程，因为即使令牌的过期时间没有被刷新，用户仍然是登录状态
-				log.Println(err)
+				logger.FromContext(ctx).Error("refresh jwt failed", logger.Int64("uid", uc.Uid), logger.Error(err))
 			}
 		}
 		// 将解析后的声明信息存储到上下文中，以便后续处理使用
 		ctx.Set("user", uc)
+		// 之后的日志都带上当前登录的用户
+		l := logger.FromContext(ctx).With(logger.Int64("uid", uc.Uid))
+		ctx.Request = ctx.Request.WithContext(logger.WithContext(ctx.Request.Context(), l))
 	}
 
 }
@@ -0,0 +1,56 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+	"go_homework/week_3/internal/service"
+	"net/http"
+)
+
+// PasswordResetHandler 找回密码，两个接口都不需要登录
+type PasswordResetHandler struct {
+	svc *service.PasswordResetService
+}
+
+func NewPasswordResetHandler(svc *service.PasswordResetService) *PasswordResetHandler {
+	return &PasswordResetHandler{svc: svc}
+}
+
+func (h *PasswordResetHandler) RegisterRoutes(server *gin.Engine) {
+	g := server.Group("/users/password")
+	g.POST("/reset-link", h.SendResetLink)
+	g.POST("/reset", h.Reset)
+}
+
+// SendResetLink 发送找回密码的邮件，邮箱有没有注册过都返回成功
+func (h *PasswordResetHandler) SendResetLink(ctx *gin.Context) {
+	type SendResetLinkRequest struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	var req SendResetLinkRequest
+	if !bind(ctx, &req) {
+		return
+	}
+	if err := h.svc.SendResetLink(ctx, req.Email); err != nil {
+		writeError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{Msg: "If the email is registered, a reset link has been sent"})
+}
+
+// Reset 凭邮件中的 token 设置新密码
+func (h *PasswordResetHandler) Reset(ctx *gin.Context) {
+	type ResetRequest struct {
+		Token           string `json:"token" binding:"required"`
+		NewPassword     string `json:"newPassword" binding:"required,password"`
+		ConfirmPassword string `json:"confirmPassword" binding:"required,eqfield=NewPassword"`
+	}
+	var req ResetRequest
+	if !bind(ctx, &req) {
+		return
+	}
+	if err := h.svc.Reset(ctx, req.Token, req.NewPassword); err != nil {
+		writeError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{Msg: "Password reset"})
+}
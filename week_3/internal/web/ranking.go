@@ -0,0 +1,33 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+	"go_homework/week_3/internal/service"
+	"net/http"
+)
+
+type RankingHandler struct {
+	svc *service.RankingService
+}
+
+func NewRankingHandler(svc *service.RankingService) *RankingHandler {
+	return &RankingHandler{svc: svc}
+}
+
+func (h *RankingHandler) RegisterRoutes(server *gin.Engine) {
+	server.GET("/ranking/articles", h.TopArticles)
+}
+
+// TopArticles 热门文章排行榜，按照热度从高到低排列，热榜还没有计算出来的时候返回空列表
+func (h *RankingHandler) TopArticles(ctx *gin.Context) {
+	arts, err := h.svc.GetTopN(ctx)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	res := make([]ArticleAbstractVO, 0, len(arts))
+	for _, art := range arts {
+		res = append(res, newArticleAbstractVO(art))
+	}
+	ctx.JSON(http.StatusOK, Result{Data: res})
+}
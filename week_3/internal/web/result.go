@@ -0,0 +1,11 @@
+package web
+
+// Result 统一的 JSON 响应结构
+type Result struct {
+	// 业务错误码，0 表示成功，其余取值见 errs 包
+	Code int `json:"code"`
+	// 给用户看的提示信息
+	Msg string `json:"msg"`
+	// 业务数据
+	Data any `json:"data,omitempty"`
+}
@@ -2,37 +2,43 @@ package web
 
 import (
 	"errors"
-	regexp "github.com/dlclark/regexp2"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"go_homework/week_3/internal/domain"
+	"go_homework/week_3/internal/errs"
 	"go_homework/week_3/internal/service"
+	"go_homework/week_3/pkg/optional"
+	"go_homework/week_3/pkg/pagination"
+	"io"
 	"net/http"
+	"strconv"
 	"time"
 )
 
-const (
-	// 电子邮箱正则表达式模式，用于校验电子邮箱格式
-	emailRegexPattern = "^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\\.[a-zA-Z]{2,}$"
-	// 密码正则表达式模式，用于校验密码强度
-	passwordRegexPattern = "^(?=.*[a-z])(?=.*[A-Z])(?=.*\\d)(?=.*[!@#$%^&*()-_=+\\[{\\]};:'\",<.>/?])[a-zA-Z0-9!@#$%^&*()-_=+\\[{\\]};:'\",<.>/?]{8,16}$"
-)
+// errUnauthorized 上下文中没有登录信息
+var errUnauthorized = errs.Unauthorized(errs.CodeUnauthorized, "Unauthorized")
 
 type UserHandler struct {
-	emailRexExp    *regexp.Regexp
-	passwordRexExp *regexp.Regexp
-	svc            *service.UserService
+	svc       *service.UserService
+	avatarSvc *service.AvatarService
+	followSvc *service.FollowService
+	exportSvc *service.ExportService
 }
 
-// NewUserHandler 函数创建并返回一个 UserHandler 类型的指针
-func NewUserHandler(svc *service.UserService) *UserHandler {
+// NewUserHandler 函数创建并返回一个 UserHandler 类型的指针。
+// 请求参数的校验规则写在各个请求结构体的 binding tag 上，自定义规则见 pkg/ginx/validator
+func NewUserHandler(svc *service.UserService, avatarSvc *service.AvatarService,
+	followSvc *service.FollowService, exportSvc *service.ExportService) *UserHandler {
 	return &UserHandler{
-		// 编译正则表达式来验证邮箱
-		emailRexExp: regexp.MustCompile(emailRegexPattern, regexp.None),
-		// 编译正则表达式来验证密码
-		passwordRexExp: regexp.MustCompile(passwordRegexPattern, regexp.None),
 		// 存储 UserService 类型的指针，用于后续用户操作
 		svc: svc,
+		// 处理头像上传
+		avatarSvc: avatarSvc,
+		// 查询公开资料中的关注数和粉丝数
+		followSvc: followSvc,
+		// 导出用户的全部数据
+		exportSvc: exportSvc,
 	}
 }
 
@@ -42,92 +48,70 @@ func (h *UserHandler) RegisterRoutes(server *gin.Engine) {
 	ug.POST("/signup", h.Signup)
 	ug.POST("/login", h.LoginJWT)
 	ug.POST("/edit", h.Edit)
+	ug.POST("/password", h.ChangePassword)
 	ug.GET("/profile", h.Profile)
+	ug.PATCH("/profile", h.Patch)
+	ug.POST("/avatar", h.UploadAvatar)
+	ug.DELETE("/me", h.DeleteMe)
+	ug.GET("/me/export", h.Export)
+	ug.GET("/search", h.Search)
+	// 放在最后，静态路由优先于参数路由匹配
+	ug.GET("/:id", h.PublicProfile)
 }
 
 func (h *UserHandler) Signup(ctx *gin.Context) {
 	type SignupRequest struct {
-		Email           string `json:"email"`
-		Password        string `json:"password"`
-		ConfirmPassword string `json:"confirmPassword"`
+		Email           string `json:"email" binding:"required,email"`
+		Password        string `json:"password" binding:"required,password"`
+		ConfirmPassword string `json:"confirmPassword" binding:"required,eqfield=Password"`
 	}
 
 	// 定义 SignupRequest 结构体变量 req，用于接收请求参数
 	var req SignupRequest
-	// 调用 ctx.Bind() 来绑定请求数据到 req 变量
-	if err := ctx.Bind(&req); err != nil {
-		// 如果绑定过程发生错误，直接返回，不进行后续处理
+	// 绑定请求数据到 req 变量，并校验邮箱格式、密码强度以及两次密码是否一致
+	if !bind(ctx, &req) {
+		// 校验失败的时候 bind 已经返回了所有字段的错误，不进行后续处理
 		return
 	}
 
-	// 使用编译好的正则表达式检查邮箱格式是否正确，isEmail 为布尔值
-	isEmail, err := h.emailRexExp.MatchString(req.Email)
-	// 如果正则表达式匹配过程中发生错误
+	// 调用 h.svc 指针，为指定的上下文和用户对象进行注册
+	err := h.svc.Signup(ctx, domain.User{
+		// 设置用户的电子邮箱
+		Email: req.Email,
+		// 设置用户的密码
+		Password: req.Password,
+	})
+
+	// 邮箱冲突、密码策略之类的错误统一由 writeError 转换成对应的状态码和错误码
 	if err != nil {
-		// 返回 200 OK 状态码，并给出错误信息
-		ctx.String(http.StatusOK, "System error")
-		// 因为出错，所以结束当前请求的处理流程，return 执行完该方法会终止后续流程
-		return
-	}
-	// 如果邮箱格式不正确
-	if !isEmail {
-		// 返回 200 OK 状态码，并给出错误信息
-		ctx.String(http.StatusOK, "Email is invalid")
-		// 因为邮箱格式出错，所以结束当前请求的处理流程
+		writeError(ctx, err)
 		return
 	}
+	ctx.JSON(http.StatusOK, Result{Msg: "Signup success"})
+}
 
-	// 检查密码字段是否匹配确认密码字段
-	if req.Password != req.ConfirmPassword {
-		// 返回 200 OK 状态码，并给出错误信息
-		ctx.String(http.StatusOK, "Password is not same")
-		// 因为密码不一致，所以结束当前请求的处理流程
+// ChangePassword 已登录用户修改密码
+func (h *UserHandler) ChangePassword(ctx *gin.Context) {
+	type ChangePasswordRequest struct {
+		OldPassword     string `json:"oldPassword" binding:"required"`
+		NewPassword     string `json:"newPassword" binding:"required,password"`
+		ConfirmPassword string `json:"confirmPassword" binding:"required,eqfield=NewPassword"`
+	}
+	var req ChangePasswordRequest
+	if !bind(ctx, &req) {
 		return
 	}
-
-	// 检查密码是否符合密码强度要求
-	isPassword, err := h.passwordRexExp.MatchString(req.Password)
-	// 如果正则表达式匹配过程中发生错误
+	uc, err := h.getUCFromCtx(ctx)
 	if err != nil {
-		// 返回 200 OK 状态码，并给出错误信息
-		ctx.String(http.StatusOK, "System error")
-		// 因为出错，所以结束当前请求的处理流程
+		writeError(ctx, err)
 		return
 	}
-	// 如果密码不符合要求
-	if !isPassword {
-		// 返回 200 OK 状态码，并给出错误信息，提示密码强度要求
-		ctx.String(http.StatusOK, "The password must contain at least one uppercase letter, one lowercase letter, "+
-			"one number, and one special character, and must be between 8 and 16 characters long")
-		// 因为密码格式出错，所以结束当前请求的处理流程
+	err = h.svc.ChangePassword(ctx, uc.Uid, req.OldPassword, req.NewPassword)
+	if err != nil {
+		writeError(ctx, err)
 		return
 	}
-
-	// 调用 h.svc 指针，为指定的上下文和用户对象进行注册
-	err = h.svc.Signup(ctx, domain.User{
-		// 设置用户的电子邮箱
-		Email: req.Email,
-		// 设置用户的密码
-		Password: req.Password,
-	})
-
-	// 根据注册返回的错误情况（err），执行不同的操作
-	switch {
-	// 成功注册
-	case err == nil:
-		// 在上下文中设置状态代码为 200 OK
-		ctx.String(http.StatusOK, "Signup success")
-		// 服务层定义的错误，表示该邮箱已存在
-	case errors.Is(err, service.ErrDuplicateEmail):
-		// 在上下文中设置状态代码为 200 OK，并给出错误消息提示
-		ctx.String(http.StatusOK, "Email is already exist")
-		// 其他未知的错误
-	default:
-		// 在上下文中设置状态代码为 200 OK，提示系统错误
-		ctx.String(http.StatusOK, "System error")
-		// 根据错误类型（err），展示不同的提示
-	}
-
+	ctx.JSON(http.StatusOK, Result{Msg: "Password changed"})
 }
 
 // Login 函数用于验证用户的登录信息
@@ -175,82 +159,74 @@ func (h *UserHandler) Signup(ctx *gin.Context) {
 func (h *UserHandler) LoginJWT(ctx *gin.Context) {
 	// 定义登录请求结构体
 	type LoginRequest struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email    string `json:"email" binding:"required"`
+		Password string `json:"password" binding:"required"`
 	}
 	var req LoginRequest
 	// 解析请求中的 JSON 数据到登录请求结构体
-	if err := ctx.Bind(&req); err != nil {
+	if !bind(ctx, &req) {
 		return
 	}
 	// 调用服务层的 Login 方法进行登录验证
 	u, err := h.svc.Login(ctx, req.Email, req.Password)
-	// 根据 Login 方法的返回结果进行不同的响应处理
-	// 检查错误是否为 nil，即登录成功
-	switch {
-	case err == nil:
-		// 创建一个 UserClaims 结构体，包含登录用户的信息和会话超时时间
-		uc := UserClaims{
-			Uid:       u.Id,
-			UserAgent: ctx.GetHeader("User-Agent"),
-			RegisteredClaims: jwt.RegisteredClaims{
-				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
-			},
-		}
-		// 使用 UserClaims 创建一个新的 JWT 令牌
-		token := jwt.NewWithClaims(jwt.SigningMethodHS256, uc)
-		// 使用一个密钥对 JWT 令牌进行签名，生成令牌字符串
-		tokenString, err := token.SignedString(JWTKey)
-		// 如果在生成令牌字符串的过程中发生错误
-		if err != nil {
-			ctx.String(http.StatusOK, "System error")
-			return
-		}
-		// 将生成的 JWT 令牌作为响应头 x-jwt-token 返回给客户端
-		ctx.Header("x-jwt-token", tokenString)
-
-		// 返回 200 状态码和登录成功的消息给客户端
-		ctx.String(http.StatusOK, "Login success")
-	case errors.Is(err, service.ErrInvalidUserOrPassword):
-		// 登录失败，密码错误，返回相应的错误消息
-		ctx.String(http.StatusOK, "Invalid user or password")
-	default:
-		// 其他错误，返回系统错误消息
-		ctx.String(http.StatusOK, "System error")
+	// 用户不存在、密码错误之类的错误统一由 writeError 处理
+	if err != nil {
+		writeError(ctx, err)
+		return
 	}
+	// 创建一个 UserClaims 结构体，包含登录用户的信息和会话超时时间
+	uc := UserClaims{
+		Uid:       u.Id,
+		UserAgent: ctx.GetHeader("User-Agent"),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			// 签发时间，注销账号之后据此判断 token 是否已经被吊销
+			IssuedAt: jwt.NewNumericDate(time.Now()),
+		},
+	}
+	// 使用 UserClaims 创建一个新的 JWT 令牌
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, uc)
+	// 使用一个密钥对 JWT 令牌进行签名，生成令牌字符串
+	tokenString, err := token.SignedString(JWTKey)
+	// 如果在生成令牌字符串的过程中发生错误
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	// 将生成的 JWT 令牌作为响应头 x-jwt-token 返回给客户端
+	ctx.Header("x-jwt-token", tokenString)
+	// 返回 200 状态码和登录成功的消息给客户端
+	ctx.JSON(http.StatusOK, Result{Msg: "Login success"})
 }
 
 func (h *UserHandler) Edit(ctx *gin.Context) {
 	type EditRequest struct {
-		Nickname string `json:"nickname"`
-		// YYYY-MM-DD 格式的生日日期字符串
-		Birthday string `json:"birthday"`
-		About    string `json:"about"`
+		// 和 dao.User 的 nickname 列长度保持一致
+		Nickname string `json:"nickname" binding:"max=128"`
+		// YYYY-MM-DD 格式的生日日期字符串，不能晚于今天
+		Birthday string `json:"birthday" binding:"required,date,notfuture"`
+		// 和 dao.User 的 about_me 列长度保持一致
+		About string `json:"about" binding:"max=4096"`
+		// 读取资料时拿到的版本号，用于发现并发修改
+		Version int64 `json:"version" binding:"required,min=1"`
 	}
 	// 声明一个变量用来接收解析 Web 请求后的表单数据
 	var req EditRequest
-	// 调用 ctx.Bind 函数对请求参数进行自动解析
-	if err := ctx.Bind(&req); err != nil {
-		// 如果解析过程中发生错误，则打印错误并返回空
+	// 绑定并校验请求参数，所有字段的错误会一次性返回
+	if !bind(ctx, &req) {
 		return
 	}
 
 	// 从上下文中获取用户 Claims
 	uc, err := h.getUCFromCtx(ctx)
 	if err != nil {
-		ctx.String(http.StatusOK, "System error")
+		writeError(ctx, err)
 		return
 	}
 	// 获取 uid
 	uid := uc.Uid
-	// 尝试将请求中的生日字符串转换为 time.Time 类型
-	// 使用 time.DateOnly 作为格式模板，确保只解析日期部分
-	birthday, err := time.Parse(time.DateOnly, req.Birthday)
-	// 发生错误时（如格式不正确），打印错误信息并返回
-	if err != nil {
-		ctx.String(http.StatusOK, "wrong birthday format")
-		return
-	}
+	// 格式已经由 date 规则校验过了，这里不会出错
+	birthday, _ := time.ParseInLocation(time.DateOnly, req.Birthday, time.Local)
 	// 调用服务层的 UpdateNonSensitiveInfo 方法来更新用户的非敏感信息
 	// 通过 domain.User{...} 构造一个用户对象，包含从请求中解析的 ID、昵称、生日和个人简介
 	err = h.svc.UpdateNonSensitiveInfo(ctx, domain.User{
@@ -258,27 +234,96 @@ func (h *UserHandler) Edit(ctx *gin.Context) {
 		Nickname: req.Nickname,
 		Birthday: birthday,
 		AboutMe:  req.About,
+		Version:  req.Version,
 	})
-	// 如果更新过程中发生错误（如数据库更新失败），打印错误信息并返回
+	// 版本号过期，说明资料在别的地方被修改过，把最新的资料返回给前端让用户确认
+	if errors.Is(err, service.ErrVersionConflict) {
+		h.writeVersionConflict(ctx, uid, err)
+		return
+	}
+	// 如果更新过程中发生错误（如数据库更新失败），返回对应的错误
 	if err != nil {
-		ctx.String(http.StatusOK, "System error")
+		writeError(ctx, err)
 		return
 	}
-	// 打印编辑成功的信息，并返回 200 状态码表示请求成功
-	ctx.String(http.StatusOK, "Edit success")
+	// 返回 200 状态码表示请求成功
+	ctx.JSON(http.StatusOK, Result{Msg: "Edit success"})
+
+}
 
+// Patch 部分更新个人资料：没有传的字段保持不变，传 null 的字段会被清空
+func (h *UserHandler) Patch(ctx *gin.Context) {
+	type PatchRequest struct {
+		Nickname optional.Field[string] `json:"nickname" binding:"omitempty,max=128"`
+		// YYYY-MM-DD 格式的生日日期字符串，不能晚于今天
+		Birthday optional.Field[string] `json:"birthday" binding:"omitempty,date,notfuture"`
+		About    optional.Field[string] `json:"about" binding:"omitempty,max=4096"`
+		// public 所有人可见，private 仅自己可见，null 恢复成默认的 public
+		Privacy optional.Field[string] `json:"privacy" binding:"omitempty,oneof=public private"`
+		// 读取资料时拿到的版本号，用于发现并发修改
+		Version int64 `json:"version" binding:"required,min=1"`
+	}
+	var req PatchRequest
+	if !bind(ctx, &req) {
+		return
+	}
+	uc, err := h.getUCFromCtx(ctx)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	u := domain.User{Id: uc.Uid, Version: req.Version}
+	var mask []domain.UserField
+	if req.Nickname.Set {
+		u.Nickname = req.Nickname.Value
+		mask = append(mask, domain.UserFieldNickname)
+	}
+	if req.Birthday.Set {
+		// null 的时候保持零值，表示清空生日
+		if req.Birthday.HasValue() {
+			u.Birthday, _ = time.ParseInLocation(time.DateOnly, req.Birthday.Value, time.Local)
+		}
+		mask = append(mask, domain.UserFieldBirthday)
+	}
+	if req.About.Set {
+		u.AboutMe = req.About.Value
+		mask = append(mask, domain.UserFieldAboutMe)
+	}
+	if req.Privacy.Set {
+		u.Privacy = privacyValues[req.Privacy.Value]
+		mask = append(mask, domain.UserFieldPrivacy)
+	}
+	if len(mask) == 0 {
+		ctx.JSON(http.StatusBadRequest, Result{Code: errs.CodeInvalidInput, Msg: "Nothing to update"})
+		return
+	}
+	err = h.svc.UpdateProfileFields(ctx, u, mask)
+	if errors.Is(err, service.ErrVersionConflict) {
+		h.writeVersionConflict(ctx, uc.Uid, err)
+		return
+	}
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{Msg: "Edit success"})
 }
 
 func (h *UserHandler) getUCFromCtx(ctx *gin.Context) (UserClaims, error) {
+	return getUserClaims(ctx)
+}
+
+// getUserClaims 取出登录校验中间件放进上下文的 UserClaims，没有登录的时候返回 errUnauthorized
+func getUserClaims(ctx *gin.Context) (UserClaims, error) {
 	// 从上下文中获取用户 Claims
 	claims, exists := ctx.Get("user")
 	if !exists {
-		return UserClaims{}, errors.New("user not found")
+		return UserClaims{}, errUnauthorized
 	}
 	// 类型断言
 	uc, ok := claims.(UserClaims)
 	if !ok {
-		return UserClaims{}, errors.New("wrong user claims type")
+		return UserClaims{}, errs.Internal(errors.New("wrong user claims type"))
 	}
 	// 获取 uid
 	return uc, nil
@@ -289,7 +334,7 @@ func (h *UserHandler) Profile(ctx *gin.Context) {
 	// 从上下文中获取用户 Claims
 	uc, err := h.getUCFromCtx(ctx)
 	if err != nil {
-		ctx.String(http.StatusOK, "System error")
+		writeError(ctx, err)
 		return
 	}
 	// 获取 uid
@@ -297,23 +342,322 @@ func (h *UserHandler) Profile(ctx *gin.Context) {
 	// 调用服务层的 FindByID 方法来查询用户信息
 	u, err := h.svc.FindByID(ctx, uid)
 	if err != nil {
-		ctx.String(http.StatusOK, "System error")
+		writeError(ctx, err)
 		return
 	}
-	type User struct {
-		Email    string
-		Nickname string
-		Birthday string
-		AboutMe  string
+	ctx.JSON(http.StatusOK, newProfileVO(u))
+}
+
+// ProfileSchemaVersion 个人资料响应结构的版本号，字段发生不兼容的变化（删除字段、修改类型或者含义）时加一，
+// 新增字段不需要修改
+const ProfileSchemaVersion = 1
+
+// ProfileVO 个人资料的响应结构，字段名统一使用 camelCase：
+//   - birthday 为 YYYY-MM-DD 格式，没有设置的时候为 null
+//   - avatar、avatarThumbnail 为头像原图和 128x128 缩略图的 URL，没有上传的时候为空字符串
+//   - privacy 为个人主页的可见范围，public 所有人可见，private 仅自己可见
+//   - ctime 为注册时间，RFC 3339 格式
+//   - version 需要在修改资料的时候带回来
+type ProfileVO struct {
+	SchemaVersion   int       `json:"schemaVersion"`
+	Id              int64     `json:"id"`
+	Email           string    `json:"email"`
+	Nickname        string    `json:"nickname"`
+	Birthday        *string   `json:"birthday"`
+	AboutMe         string    `json:"aboutMe"`
+	Avatar          string    `json:"avatar"`
+	AvatarThumbnail string    `json:"avatarThumbnail"`
+	Privacy         string    `json:"privacy"`
+	Ctime           time.Time `json:"ctime"`
+	Version         int64     `json:"version"`
+}
+
+func newProfileVO(u domain.User) ProfileVO {
+	return ProfileVO{
+		SchemaVersion:   ProfileSchemaVersion,
+		Id:              u.Id,
+		Email:           u.Email,
+		Nickname:        u.Nickname,
+		Birthday:        formatBirthday(u.Birthday),
+		AboutMe:         u.AboutMe,
+		Avatar:          u.Avatar.URL,
+		AvatarThumbnail: u.Avatar.ThumbnailURL,
+		Privacy:         privacyNames[u.Privacy],
+		Ctime:           u.Ctime,
+		Version:         u.Version,
+	}
+}
+
+// privacyNames 个人主页可见范围在接口中的名字
+var privacyNames = map[domain.Privacy]string{
+	domain.PrivacyPublic:  "public",
+	domain.PrivacyPrivate: "private",
+}
+
+// privacyValues 是 privacyNames 的反向映射，空字符串（传了 null）对应默认的 public
+var privacyValues = map[string]domain.Privacy{
+	"":        domain.PrivacyPublic,
+	"public":  domain.PrivacyPublic,
+	"private": domain.PrivacyPrivate,
+}
+
+// PublicProfileVO 其他用户可以看到的公开资料，不包含邮箱、生日这些隐私信息，
+// 字段的含义和 ProfileVO 一致
+type PublicProfileVO struct {
+	SchemaVersion   int       `json:"schemaVersion"`
+	Id              int64     `json:"id"`
+	Nickname        string    `json:"nickname"`
+	AboutMe         string    `json:"aboutMe"`
+	Avatar          string    `json:"avatar"`
+	AvatarThumbnail string    `json:"avatarThumbnail"`
+	Ctime           time.Time `json:"ctime"`
+	// 关注数和粉丝数，只在查看单个用户的公开资料时返回，搜索之类的列表中没有
+	Follow *FollowStatisticVO `json:"follow,omitempty"`
+}
+
+func newPublicProfileVO(u domain.User) PublicProfileVO {
+	return PublicProfileVO{
+		SchemaVersion:   ProfileSchemaVersion,
+		Id:              u.Id,
+		Nickname:        u.Nickname,
+		AboutMe:         u.AboutMe,
+		Avatar:          u.Avatar.URL,
+		AvatarThumbnail: u.Avatar.ThumbnailURL,
+		Ctime:           u.Ctime,
 	}
+}
 
-	repData := User{
-		Email:    u.Email,
-		Nickname: u.Nickname,
-		Birthday: u.Birthday.Format(time.DateOnly),
-		AboutMe:  u.AboutMe,
+// PublicProfile 查看其他用户的公开资料，仅自己可见的资料对其他人返回 404
+func (h *UserHandler) PublicProfile(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		ctx.JSON(http.StatusBadRequest, Result{Code: errs.CodeInvalidInput, Msg: "Invalid user id"})
+		return
+	}
+	uc, err := h.getUCFromCtx(ctx)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	u, err := h.svc.PublicProfile(ctx, uc.Uid, id)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	s, err := h.followSvc.Statistic(ctx, uc.Uid, id)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	vo := newPublicProfileVO(u)
+	vo.Follow = newFollowStatisticVO(s)
+	ctx.JSON(http.StatusOK, vo)
+}
+
+// Search 按照昵称前缀搜索用户，只会搜到资料公开的用户。
+// 返回的 nextCursor 不为空的时候，带上 cursor=nextCursor 可以查询下一页
+func (h *UserHandler) Search(ctx *gin.Context) {
+	type SearchRequest struct {
+		Q      string `form:"q" binding:"required,max=128"`
+		Cursor string `form:"cursor"`
+		Limit  int    `form:"limit" binding:"omitempty,min=1,max=50"`
+	}
+	var req SearchRequest
+	if !bind(ctx, &req) {
+		return
+	}
+	page, err := h.svc.SearchByNickname(ctx, req.Q, req.Cursor, req.Limit)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{Data: pagination.Map(page, newPublicProfileVO)})
+}
+
+// formatBirthday 没有设置生日的时候返回 nil，序列化成 JSON 的 null
+func formatBirthday(birthday time.Time) *string {
+	if birthday.IsZero() {
+		return nil
+	}
+	s := birthday.Format(time.DateOnly)
+	return &s
+}
+
+// writeVersionConflict 返回 409 和当前最新的资料，前端可以据此提示用户或者合并修改
+func (h *UserHandler) writeVersionConflict(ctx *gin.Context, uid int64, conflict error) {
+	u, err := h.svc.FindByID(ctx, uid)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	e := errs.As(conflict)
+	ctx.JSON(http.StatusConflict, Result{Code: e.Code, Msg: e.Msg, Data: newProfileVO(u)})
+}
+
+// UploadAvatar 上传头像，multipart 表单的 file 字段为图片文件，成功之后返回头像和缩略图的 URL
+func (h *UserHandler) UploadAvatar(ctx *gin.Context) {
+	uc, err := h.getUCFromCtx(ctx)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	// 限制请求体的大小，多留一些空间给 multipart 的边界和其他头部
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, h.avatarSvc.MaxSize()+4096)
+	fh, err := ctx.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(ctx, service.ErrAvatarTooLarge)
+			return
+		}
+		ctx.JSON(http.StatusBadRequest, Result{Code: errs.CodeInvalidInput, Msg: "Missing avatar file"})
+		return
+	}
+	if fh.Size > h.avatarSvc.MaxSize() {
+		writeError(ctx, service.ErrAvatarTooLarge)
+		return
+	}
+	f, err := fh.Open()
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	avatar, err := h.avatarSvc.Upload(ctx, uc.Uid, data)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{Msg: "Avatar uploaded", Data: gin.H{
+		"avatar":          avatar.URL,
+		"avatarThumbnail": avatar.ThumbnailURL,
+	}})
+}
+
+// DeleteMe 注销当前登录的账号
+func (h *UserHandler) DeleteMe(ctx *gin.Context) {
+	uc, err := h.getUCFromCtx(ctx)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	err = h.svc.DeleteAccount(ctx, uc.Uid)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{Msg: "Account deleted"})
+}
+
+// Export 以 JSON 文件的形式导出当前用户保存在系统中的全部数据，
+// 包括账号、文章、评论、关注的人、点赞和收藏
+func (h *UserHandler) Export(ctx *gin.Context) {
+	uc, err := h.getUCFromCtx(ctx)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	a, err := h.exportSvc.Export(ctx, uc.Uid)
+	if err != nil {
+		writeError(ctx, err)
+		return
+	}
+	type Account struct {
+		Id       int64     `json:"id"`
+		Email    string    `json:"email"`
+		Nickname string    `json:"nickname"`
+		Birthday *string   `json:"birthday"`
+		AboutMe  string    `json:"aboutMe"`
+		Avatar   string    `json:"avatar"`
+		Ctime    time.Time `json:"ctime"`
+		Utime    time.Time `json:"utime"`
+	}
+	type Comment struct {
+		Id       int64     `json:"id"`
+		Biz      string    `json:"biz"`
+		BizId    int64     `json:"bizId"`
+		ParentId int64     `json:"parentId"`
+		Content  string    `json:"content"`
+		Ctime    time.Time `json:"ctime"`
+	}
+	type Followee struct {
+		Uid   int64     `json:"uid"`
+		Ctime time.Time `json:"ctime"`
+	}
+	type Like struct {
+		Biz   string    `json:"biz"`
+		BizId int64     `json:"bizId"`
+		Ctime time.Time `json:"ctime"`
+	}
+	type CollectionItem struct {
+		Biz   string    `json:"biz"`
+		BizId int64     `json:"bizId"`
+		Ctime time.Time `json:"ctime"`
+	}
+	type Collection struct {
+		CollectionVO
+		Items []CollectionItem `json:"items"`
+	}
+	type Archive struct {
+		ExportedAt  time.Time    `json:"exportedAt"`
+		Account     Account      `json:"account"`
+		Articles    []ArticleVO  `json:"articles"`
+		Comments    []Comment    `json:"comments"`
+		Followees   []Followee   `json:"followees"`
+		Likes       []Like       `json:"likes"`
+		Collections []Collection `json:"collections"`
+	}
+	u := a.Account
+	res := Archive{
+		ExportedAt: time.Now(),
+		Account: Account{
+			Id:       u.Id,
+			Email:    u.Email,
+			Nickname: u.Nickname,
+			Birthday: formatBirthday(u.Birthday),
+			AboutMe:  u.AboutMe,
+			Avatar:   u.Avatar.URL,
+			Ctime:    u.Ctime,
+			Utime:    u.Utime,
+		},
+		Articles:    make([]ArticleVO, 0, len(a.Articles)),
+		Comments:    make([]Comment, 0, len(a.Comments)),
+		Followees:   make([]Followee, 0, len(a.Followees)),
+		Likes:       make([]Like, 0, len(a.Likes)),
+		Collections: make([]Collection, 0, len(a.Collections)),
+	}
+	for _, art := range a.Articles {
+		res.Articles = append(res.Articles, newArticleVO(art))
+	}
+	for _, c := range a.Comments {
+		res.Comments = append(res.Comments, Comment{Id: c.Id, Biz: c.Biz, BizId: c.BizId,
+			ParentId: c.ParentId, Content: c.Content, Ctime: c.Ctime})
+	}
+	for _, rel := range a.Followees {
+		res.Followees = append(res.Followees, Followee{Uid: rel.Followee, Ctime: rel.Ctime})
+	}
+	for _, l := range a.Likes {
+		res.Likes = append(res.Likes, Like{Biz: l.Biz, BizId: l.BizId, Ctime: l.Ctime})
+	}
+	// 收藏的内容放到各自的收藏夹下面
+	items := make(map[int64][]CollectionItem, len(a.Collections))
+	for _, item := range a.CollectionItems {
+		items[item.Cid] = append(items[item.Cid], CollectionItem{Biz: item.Biz, BizId: item.BizId, Ctime: item.Ctime})
+	}
+	for _, c := range a.Collections {
+		res.Collections = append(res.Collections, Collection{
+			CollectionVO: CollectionVO{Id: c.Id, Name: c.Name, Ctime: c.Ctime},
+			// 空的收藏夹输出 [] 而不是 null
+			Items: append([]CollectionItem{}, items[c.Id]...),
+		})
 	}
-	ctx.JSON(http.StatusOK, repData)
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="webook-export-%d.json"`, u.Id))
+	ctx.JSON(http.StatusOK, res)
 }
 
 // JWTKey 定义一个名为 JWTKey 的字节切片变量，赋值为用于验证 JWT 的密钥
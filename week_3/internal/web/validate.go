@@ -0,0 +1,24 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+	"go_homework/week_3/internal/errs"
+	"go_homework/week_3/pkg/ginx/validator"
+	"net/http"
+)
+
+// bind 绑定请求参数并执行 binding tag 上面的校验规则。
+// 校验失败的时候一次性返回所有字段的错误，调用方拿到 false 直接 return 即可。
+func bind(ctx *gin.Context, req any) bool {
+	err := ctx.ShouldBind(req)
+	if err == nil {
+		return true
+	}
+	if fields, ok := validator.Translate(err); ok {
+		ctx.JSON(http.StatusBadRequest, Result{Code: errs.CodeInvalidInput, Msg: "Invalid request", Data: fields})
+		return false
+	}
+	// 请求体本身就解析不了，例如 JSON 格式错误
+	ctx.JSON(http.StatusBadRequest, Result{Code: errs.CodeInvalidInput, Msg: "Malformed request"})
+	return false
+}
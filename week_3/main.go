@@ -1,76 +1,535 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.uber.org/zap"
 	"go_homework/week_3/config"
+	"go_homework/week_3/internal/consumer"
+	"go_homework/week_3/internal/domain"
+	"go_homework/week_3/internal/errs"
+	"go_homework/week_3/internal/job"
 	"go_homework/week_3/internal/repository"
+	"go_homework/week_3/internal/repository/cache"
 	"go_homework/week_3/internal/repository/dao"
+	"go_homework/week_3/internal/repository/dao/migrations"
 	"go_homework/week_3/internal/service"
 	"go_homework/week_3/internal/web"
 	"go_homework/week_3/internal/web/middleware"
+	"go_homework/week_3/pkg/events"
+	"go_homework/week_3/pkg/ginx/middleware/accesslog"
+	"go_homework/week_3/pkg/ginx/middleware/metrics"
 	"go_homework/week_3/pkg/ginx/middleware/ratelimit"
+	"go_homework/week_3/pkg/ginx/middleware/requestid"
+	"go_homework/week_3/pkg/ginx/middleware/tracing"
+	"go_homework/week_3/pkg/ginx/validator"
+	"go_homework/week_3/pkg/gormx"
+	"go_homework/week_3/pkg/gormx/replica"
+	"go_homework/week_3/pkg/hasher"
+	"go_homework/week_3/pkg/logger"
+	"go_homework/week_3/pkg/mailer"
+	"go_homework/week_3/pkg/migrator"
+	"go_homework/week_3/pkg/objstore"
+	"go_homework/week_3/pkg/pwdpolicy"
+	"go_homework/week_3/pkg/redisx"
+	"go_homework/week_3/pkg/rlock"
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 )
 
+// Prometheus 指标的命名空间，所有自定义指标都以 webook_ 开头
+const metricsNamespace = "webook"
+
 // main 函数是应用的启动点，负责初始化数据库、配置服务器和启动服务
 func main() {
-	// 初始化数据库连接
-	db := initDB()
+	// webook migrate up|down|status 只执行数据库迁移，不启动服务
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
+	// 收到 SIGINT 或者 SIGTERM 的时候 ctx 被取消，开始优雅退出
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// 初始化日志，其余各层通过 context 拿到带有请求 ID 的 Logger
+	l := initLogger()
+	// 初始化链路追踪，退出之前把还没有导出的 span 导出
+	shutdownTracer := initTracer()
+	defer shutdownTracer(context.Background())
+	// 初始化数据库连接，从库的健康检查在 ctx 取消之后停止
+	db := initDB(ctx)
+	// 初始化 Redis 客户端
+	redisClient := initRedis()
+	// 进程内的消息队列，阅读事件和发件箱中的事件都通过它异步处理
+	broker := events.NewMemoryBroker(config.Config.Events.QueueCapacity)
+	// 初始化用户仓储，用户服务和头像服务共用
+	ur := initUserRepo(db, redisClient)
+	// 初始化用户服务
+	us := initUserSvc(ur)
 	// 初始化 Web 服务器
-	server := initWebServer()
+	server := initWebServer(redisClient, us, l)
+	// 初始化关注和关注流服务，关注数展示在用户的公开资料中
+	followRepo := repository.NewFollowRepository(dao.NewFollowDAO(db), cache.NewFollowCache(redisClient))
+	followSvc := service.NewFollowService(followRepo, ur)
+	feedSvc := service.NewFeedService(repository.NewFeedRepository(dao.NewFeedDAO(db), dao.NewArticleDAO(db)),
+		followRepo, ur)
+	// 初始化互动和文章的仓储，文章、互动、热榜、导出数据之类的模块共用
+	intrRepo := repository.NewInteractionRepository(dao.NewInteractionDAO(db), cache.NewInteractionCache(redisClient))
+	ar := repository.NewArticleRepository(dao.NewArticleDAO(db), cache.NewArticleCache(redisClient))
+	commentRepo := repository.NewCommentRepository(dao.NewCommentDAO(db))
+	// 头像服务，上传头像和删除已经清理的账号的头像文件共用
+	avatarSvc := initAvatarSvc(ur)
 	// 初始化用户处理器，主要负责实现用户相关的路由和逻辑
-	initUserHdl(db, server)
+	initUserHdl(us, avatarSvc, followSvc,
+		service.NewExportService(ur, ar, commentRepo, followRepo, intrRepo), server)
+	initPasswordResetHdl(ur, us, server)
+	web.NewFollowHandler(followSvc, feedSvc).RegisterRoutes(server)
+	intrSvc := service.NewInteractionService(intrRepo, ar, broker)
+	// 初始化文章、互动和热榜处理器，发表的文章推送到粉丝的关注流
+	web.NewArticleHandler(service.NewArticleService(ar, ur, feedSvc), intrSvc).RegisterRoutes(server)
+	web.NewInteractionHandler(intrSvc).RegisterRoutes(server)
+	rankingSvc := initRankingSvc(redisClient, ar, intrRepo, ur)
+	web.NewRankingHandler(rankingSvc).RegisterRoutes(server)
+	// 初始化评论处理器，发表评论按照用户限流
+	initCommentHdl(commentRepo, redisClient, ur, ar, server)
+	// 在后台消费阅读事件之类的消息
+	processor := initProcessor(broker, intrSvc, avatarSvc)
+	if err := processor.Start(ctx); err != nil {
+		panic(err)
+	}
+	// 在后台定期清理超过保留期的注销账号、计算热榜和投递发件箱中的事件，
+	// 多个实例之间用分布式锁保证同一时间只有一个在执行
+	lockClient := rlock.NewClient(redisClient)
+	go job.NewPurgeDeletedUsersJob(us, lockClient, config.Config.Account.PurgeInterval,
+		config.Config.Account.RetentionPeriod, l).Start(ctx)
+	go job.NewRankingJob(rankingSvc, lockClient, config.Config.Ranking.Interval, l).Start(ctx)
+	go job.NewOutboxRelayJob(initOutboxRelaySvc(db, broker), lockClient, config.Config.Outbox.RelayInterval, l).
+		Start(ctx)
 	// 测试一下服务是否正常启动
 	server.GET("/hello", func(ctx *gin.Context) {
 		ctx.String(http.StatusOK, "hello，just for test！")
 	})
-	// 启动 Web 服务器，并监听 8080 端口，返回错误信息，err!= nil 时退出
-	err := server.Run(":8080")
+	// 启动 Web 服务器，并监听 8080 端口，启动失败的时候直接退出
+	srv := &http.Server{Addr: ":8080", Handler: server}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			l.Error("server exited", logger.Error(err))
+			stop()
+		}
+	}()
+	// Prometheus 拉取指标的接口单独监听一个内部端口，不对外暴露，指标的说明见 docs/metrics.md
+	metricsSrv := initMetricsServer()
+	go func() {
+		if err := metricsSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			l.Error("metrics server exited", logger.Error(err))
+			stop()
+		}
+	}()
+	<-ctx.Done()
+	shutdown(srv, metricsSrv, processor, l)
+}
+
+// initMetricsServer 只提供 /metrics 的内部 HTTP 服务
+func initMetricsServer() *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	return &http.Server{Addr: config.Config.Metrics.Addr, Handler: mux}
+}
+
+// shutdown 先停止接收新的请求并等待正在处理的请求结束，这之后不会再产生新的消息，
+// 再等待消费者处理完队列中剩下的消息，最后停止指标服务，这样退出过程中的指标还能被拉取到
+func shutdown(srv, metricsSrv *http.Server, processor *events.Processor, l logger.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), config.Config.Events.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		l.Error("shutdown server failed", logger.Error(err))
+	}
+	if err := processor.Shutdown(ctx); err != nil {
+		l.Error("shutdown processor failed", logger.Error(err))
+	}
+	if err := metricsSrv.Shutdown(ctx); err != nil {
+		l.Error("shutdown metrics server failed", logger.Error(err))
+	}
+}
+
+// initTracer 根据配置设置 otel 全局的 TracerProvider，返回的函数用于在退出之前导出剩余的 span。
+// 没有开启链路追踪的时候使用 otel 默认的 noop 实现，各层创建 span 基本没有开销
+func initTracer() func(ctx context.Context) error {
+	cfg := config.Config.Tracing
+	var exporter sdktrace.SpanExporter
+	switch cfg.Exporter {
+	case "stdout":
+		var err error
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+		if err != nil {
+			panic(err)
+		}
+	case "", "none":
+		return func(ctx context.Context) error { return nil }
+	default:
+		panic(fmt.Sprintf("unsupported tracing exporter: %s", cfg.Exporter))
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", cfg.ServiceName))),
+	)
+	otel.SetTracerProvider(tp)
+	// 使用 W3C 的 traceparent 头部在服务之间传递 trace
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+	return tp.Shutdown
+}
+
+// initLogger 初始化 zap 并设置成默认的 Logger，没有请求 ID 的地方（例如启动流程、定时任务）使用默认的 Logger
+func initLogger() logger.Logger {
+	zl, err := zap.NewProduction()
 	if err != nil {
-		return
+		panic(err)
 	}
+	l := logger.NewZapLogger(zl)
+	logger.SetDefault(l)
+	return l
 }
 
-func initUserHdl(db *gorm.DB, server *gin.Engine) {
+func initUserRepo(db *gorm.DB, redisClient redis.Cmdable) *repository.UserRepository {
 	// 将 GORM 数据库实例传入 UserDAO
 	ud := dao.NewUserDAO(db)
+	// 会话吊销记录需要保留到注销账号被物理删除为止
+	uc := cache.NewUserCache(redisClient, config.Config.Account.RetentionPeriod)
 	// 在 UserRepository 中，通过之前创建的 UserDAO 初始化
-	ur := repository.NewUserRepository(ud)
+	return repository.NewUserRepository(ud, uc)
+}
+
+func initUserSvc(ur *repository.UserRepository) *service.UserService {
 	// 实例化 UserService，并注入 UserRepository
-	us := service.NewUserService(ur)
+	return service.NewUserService(ur, initHasher(), initPasswordPolicy())
+}
+
+// initAvatarSvc 头像保存在本地目录，通过 /static 对外提供访问
+func initAvatarSvc(ur *repository.UserRepository) *service.AvatarService {
+	storage := objstore.NewLocalStorage(config.Config.Storage.Dir, config.Config.Storage.BaseURL)
+	return service.NewAvatarService(ur, storage, config.Config.Avatar.MaxSize)
+}
+
+func initUserHdl(us *service.UserService, as *service.AvatarService, fs *service.FollowService,
+	es *service.ExportService, server *gin.Engine) {
 	// 创建 UserHandler 实例以便处理用户相关的请求，其中包含用户服务对象
-	hdl := web.NewUserHandler(us)
+	hdl := web.NewUserHandler(us, as, fs, es)
 	// 调用 UserHandler 的 RegisterRoutes 方法，向引擎注册用户相关的路由
 	hdl.RegisterRoutes(server)
 }
 
-func initDB() *gorm.DB {
-	// 使用 gorm 打开 MySQL 数据库连接 (DSN 是数据库连接字符串)
-	db, err := gorm.Open(mysql.Open(config.Config.DB.DSN))
-	// 如果打开数据库连接时发生错误，使用 panic 抛出一个错误，以确保程序能够停止执行，并提醒开发者处理这个错误
+// initPasswordResetHdl 找回密码需要发送邮件，没有配置 SMTP 服务器的时候不开放找回密码的接口
+func initPasswordResetHdl(ur *repository.UserRepository, us *service.UserService, server *gin.Engine) {
+	mc := config.Config.Mail
+	if mc.Addr == "" {
+		return
+	}
+	cfg := config.Config.Password
+	svc := service.NewPasswordResetService(ur, us, mailer.NewSMTPMailer(mc.Addr, mc.From, mc.Username, mc.Password),
+		cfg.ResetURL, cfg.ResetExpiration)
+	web.NewPasswordResetHandler(svc).RegisterRoutes(server)
+}
+
+// initProcessor 注册所有的消费者。重试之后仍然失败的消息发送到死信 topic，
+// 由 DeadLetterConsumer 记在错误日志里面，排查之后可以根据日志重放
+func initProcessor(broker *events.MemoryBroker, intrSvc *service.InteractionService,
+	avatarSvc *service.AvatarService) *events.Processor {
+	p := events.NewProcessor(broker, "webook").DeadLetter(broker)
+	consumer.NewReadConsumer(intrSvc).Register(p)
+	consumer.NewUserEventConsumer().Register(p)
+	consumer.NewAvatarConsumer(avatarSvc).Register(p)
+	consumer.NewDeadLetterConsumer(domain.TopicBizRead, domain.TopicUserPurged).Register(p)
+	return p
+}
+
+// initRankingSvc 热榜在 Redis 中保存三个计算周期，计算连续失败几次之后才会消失
+func initRankingSvc(redisClient redis.Cmdable, ar *repository.ArticleRepository,
+	intrRepo *repository.InteractionRepository, ur *repository.UserRepository) *service.RankingService {
+	cfg := config.Config.Ranking
+	repo := repository.NewRankingRepository(cache.NewRankingCache(redisClient, 3*cfg.Interval),
+		cache.NewRankingLocalCache(cfg.LocalCacheExpiration))
+	return service.NewRankingService(ar, intrRepo, ur, repo, cfg.Window)
+}
+
+func initCommentHdl(cr *repository.CommentRepository, redisClient redis.Cmdable, ur *repository.UserRepository,
+	ar *repository.ArticleRepository, server *gin.Engine) {
+	svc := service.NewCommentService(cr, ur, ar)
+	// 每个用户每分钟最多发表 10 条评论，和全局的 IP 限流共用指标，用 prefix 区分
+	limiter := ratelimit.NewBuilder(redisClient, time.Minute, 10).
+		Prefix("comment-limiter").
+		KeyFunc(func(ctx *gin.Context) string {
+			if uc, ok := ctx.Value("user").(web.UserClaims); ok {
+				return strconv.FormatInt(uc.Uid, 10)
+			}
+			return ""
+		}).
+		Metrics(metricsNamespace, prometheus.DefaultRegisterer).
+		Build()
+	web.NewCommentHandler(svc, limiter).RegisterRoutes(server)
+}
+
+// initOutboxRelaySvc 发件箱中的事件投递到消息队列，需要接入 Kafka 的时候换成实现了
+// events.Publisher 的生产者即可。搜索索引、欢迎邮件之类的消费方在 initProcessor 中订阅对应的 topic
+func initOutboxRelaySvc(db *gorm.DB, publisher events.Publisher) *service.OutboxRelayService {
+	return service.NewOutboxRelayService(repository.NewOutboxRepository(dao.NewOutboxDAO(db)), publisher)
+}
+
+// initRedis 创建一个新的 Redis 客户端
+func initRedis() redis.Cmdable {
+	client := redis.NewClient(&redis.Options{
+		// 设置 Redis 服务器地址
+		Addr: config.Config.Redis.Addr,
+	})
+	// 记录每一个命令的耗时，并且为每一个命令创建 span
+	client.AddHook(redisx.NewMetricsHook(metricsNamespace, prometheus.DefaultRegisterer))
+	client.AddHook(redisx.NewTracingHook())
+	return client
+}
+
+// initHasher 根据配置创建密码哈希器，新密码使用配置的算法，其余算法只用来校验历史密码
+func initHasher() hasher.PasswordHasher {
+	bc := hasher.NewBcryptHasher(config.Config.Password.BcryptCost)
+	if config.Config.Password.Algorithm == "bcrypt" {
+		return hasher.NewMultiHasher(bc, hasher.NewArgon2idHasher(hasher.DefaultArgon2idParams))
+	}
+	return hasher.NewMultiHasher(hasher.NewArgon2idHasher(hasher.DefaultArgon2idParams), bc)
+}
+
+// initPasswordPolicy 创建密码策略，配置了本地泄露库目录的时候才会检查泄露库。
+// 配置了目录但是目录不存在或者为空的时候直接退出，不然泄露库检查会悄悄地全部通过
+func initPasswordPolicy() *pwdpolicy.Policy {
+	cfg := config.Config.Password
+	if cfg.BreachCorpusDir == "" {
+		return pwdpolicy.NewPolicy(nil)
+	}
+	source := pwdpolicy.NewDirRangeSource(cfg.BreachCorpusDir)
+	if err := source.Validate(); err != nil {
+		panic(err)
+	}
+	return pwdpolicy.NewPolicy(pwdpolicy.NewBreachChecker(source, cfg.BreachMinCount))
+}
+
+// dialectorOf 根据配置的驱动选择 gorm 的方言，本地开发和测试可以使用 sqlite，
+// 例如 DSN 为 file::memory:?cache=shared 时使用内存数据库
+func dialectorOf(cfg config.DBConfig) gorm.Dialector {
+	switch cfg.Driver {
+	case "sqlite":
+		return sqlite.Open(cfg.DSN)
+	case "postgres":
+		return postgres.Open(cfg.DSN)
+	case "mysql", "":
+		return mysql.Open(cfg.DSN)
+	default:
+		panic(fmt.Sprintf("unsupported db driver: %s", cfg.Driver))
+	}
+}
+
+// connDialectorOf 使用已经打开的连接池，dbresolver 拿到的从库连接池就是 conn 本身
+func connDialectorOf(driver string, conn gorm.ConnPool) gorm.Dialector {
+	switch driver {
+	case "sqlite":
+		return &sqlite.Dialector{Conn: conn}
+	case "postgres":
+		return postgres.New(postgres.Config{Conn: conn})
+	case "mysql", "":
+		return mysql.New(mysql.Config{Conn: conn})
+	default:
+		panic(fmt.Sprintf("unsupported db driver: %s", driver))
+	}
+}
+
+func initDB(ctx context.Context) *gorm.DB {
+	db := openDB()
+	// 启动的时候执行还没有执行过的迁移，多个副本同时启动的时候只有拿到锁的副本会执行
+	err := migrateDB(db)
+	// 如果初始化表结构时发生错误，使用 panic 抛出错误
+	if err != nil {
+		panic(err)
+	}
+	// 迁移只在主库上执行，执行完之后再注册从库
+	err = useReplicas(ctx, db)
+	if err != nil {
+		panic(err)
+	}
+	// 在 dbresolver 之后注册，主库和从库的 SQL 都会被记录
+	err = db.Use(gormx.NewMetricsPlugin(metricsNamespace, prometheus.DefaultRegisterer))
 	if err != nil {
 		panic(err)
 	}
-	// 初始化数据库表，使用 dao 包中的 InitTables 函数
-	err = dao.InitTables(db)
-	// 如果初始化表结构时发生错误，也使用 panic 抛出错误
+	err = db.Use(gormx.NewTracingPlugin())
 	if err != nil {
 		panic(err)
 	}
+	// 连接池的指标，例如正在使用和空闲的连接数
+	sqlDB, err := db.DB()
+	if err != nil {
+		panic(err)
+	}
+	prometheus.MustRegister(collectors.NewDBStatsCollector(sqlDB, "webook"))
 	// 返回初始化后的数据库连接对象
 	return db
 }
 
+func openDB() *gorm.DB {
+	cfg := config.Config.DB
+	// 使用 gorm 打开数据库连接 (DSN 是数据库连接字符串)，SQL 出错和慢查询会记录到日志
+	db, err := gorm.Open(dialectorOf(cfg), &gorm.Config{
+		Logger: gormx.NewLogger(cfg.SlowThreshold),
+		// 唯一索引冲突统一转换成 gorm.ErrDuplicatedKey，日志里面不把它当成错误
+		TranslateError: true,
+	})
+	// 如果打开数据库连接时发生错误，使用 panic 抛出一个错误，以确保程序能够停止执行，并提醒开发者处理这个错误
+	if err != nil {
+		panic(err)
+	}
+	// 设置主库的连接池
+	sqlDB, err := db.DB()
+	if err != nil {
+		panic(err)
+	}
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	return db
+}
+
+// useReplicas 配置了从库的时候注册 dbresolver：读请求在健康的从库之间轮询，写请求走主库。
+// 从库的健康检查一直运行到 ctx 被取消
+func useReplicas(ctx context.Context, db *gorm.DB) error {
+	cfg := config.Config.DB
+	if len(cfg.Replicas) == 0 {
+		return nil
+	}
+	// 从库的连接池在这里打开，再交给 dbresolver 使用，这样健康检查从启动开始就知道所有的从库
+	pools := make([]gorm.ConnPool, 0, len(cfg.Replicas))
+	for _, dsn := range cfg.Replicas {
+		rdb, err := gorm.Open(dialectorOf(config.DBConfig{Driver: cfg.Driver, DSN: dsn}), &gorm.Config{})
+		if err != nil {
+			return err
+		}
+		pool, err := rdb.DB()
+		if err != nil {
+			return err
+		}
+		pools = append(pools, pool)
+	}
+	// 所有从库都不健康的时候读请求走主库，dbresolver 里面的主库也是这个连接池
+	policy := replica.NewHealthCheckPolicy(db.ConnPool, pools, cfg.ReplicaCheckInterval, cfg.ReplicaCheckTimeout)
+	go policy.Start(ctx)
+	return db.Use(dbresolver.Register(dbresolver.Config{
+		Replicas: replica.Dialectors(pools, func(conn gorm.ConnPool) gorm.Dialector {
+			return connDialectorOf(cfg.Driver, conn)
+		}),
+		Policy: policy,
+	}).
+		SetMaxOpenConns(cfg.MaxOpenConns).
+		SetMaxIdleConns(cfg.MaxIdleConns).
+		SetConnMaxLifetime(cfg.ConnMaxLifetime))
+}
+
+// migrateDB 有迁移文件的方言执行版本化迁移，其余方言（例如本地开发的 sqlite）使用 AutoMigrate
+func migrateDB(db *gorm.DB) error {
+	fsys, ok := migrations.ForDialect(db.Dialector.Name())
+	if !ok {
+		return dao.InitTables(db)
+	}
+	m, err := migrator.New(db, fsys)
+	if err != nil {
+		return err
+	}
+	return m.Up(context.Background())
+}
+
+// runMigrate 处理 migrate 子命令
+func runMigrate(args []string) {
+	if len(args) != 1 {
+		fmt.Println("usage: webook migrate up|down|status")
+		os.Exit(2)
+	}
+	db := openDB()
+	fsys, ok := migrations.ForDialect(db.Dialector.Name())
+	if !ok {
+		fmt.Printf("no migrations for dialect %s\n", db.Dialector.Name())
+		os.Exit(1)
+	}
+	m, err := migrator.New(db, fsys)
+	if err != nil {
+		panic(err)
+	}
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		err = m.Up(ctx)
+	case "down":
+		err = m.Down(ctx)
+	case "status":
+		var statuses []migrator.Status
+		statuses, err = m.Status(ctx)
+		for _, st := range statuses {
+			applied := "pending"
+			if st.AppliedAt != nil {
+				applied = st.AppliedAt.Format(time.DateTime)
+			}
+			fmt.Printf("%04d_%s\t%s\n", st.Version, st.Name, applied)
+		}
+	default:
+		fmt.Println("usage: webook migrate up|down|status")
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
 // initWebServer 函数用于初始化 Web 服务器，设置路由和中间件
-func initWebServer() *gin.Engine {
-	// 创建 gin.Default() 对象，该对象默认包含了 Logger 和 Recovery 中间件
-	server := gin.Default()
+func initWebServer(redisClient redis.Cmdable, us *service.UserService, l logger.Logger) *gin.Engine {
+	// 注册自定义的请求参数校验规则
+	if err := validator.Init(); err != nil {
+		panic(err)
+	}
+	// 不使用 gin.Default() 自带的 Logger，访问日志由 accesslog 中间件记录
+	server := gin.New()
+	// 把 *gin.Context 当作 context.Context 往下传的时候，可以取到 Request.Context() 里面的值，
+	// 例如 requestid 中间件放进去的请求 ID 和 Logger
+	server.ContextWithFallback = true
+	// 放在最前面，后面的中间件打的日志也能带上请求 ID
+	server.Use(requestid.NewBuilder(l).Build())
+	// 紧接着创建 server span，后面的中间件（例如限流、登录校验）和业务逻辑的 span 都是它的子 span
+	server.Use(tracing.NewBuilder().Build())
+	// 访问日志放在 Recovery 之前，panic 的请求也会被记录下来
+	server.Use(initAccessLog(l))
+	// 请求耗时的指标也放在 Recovery 之前，panic 的请求记为 500
+	server.Use(metrics.NewBuilder(metricsNamespace).Build())
+	server.Use(gin.CustomRecovery(func(ctx *gin.Context, err any) {
+		logger.FromContext(ctx).Error("panic recovered", logger.Any("panic", err))
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError,
+			web.Result{Code: errs.CodeSystemError, Msg: "System error"})
+	}))
 	// 使用 CORS 中间件，允许跨域请求，并指定了允许的请求头，以及允许的来源
 	server.Use(cors.New(cors.Config{
 		// 是否允许在跨域请求中携带用户凭证（如 cookies、HTTP 认证）
@@ -78,7 +537,7 @@ func initWebServer() *gin.Engine {
 		// 允许的请求头列表
 		AllowHeaders: []string{"Content-Type", "Authorization"},
 		// 这个是允许前端访问你的后端响应中带的头部
-		ExposeHeaders: []string{"x-jwt-token"},
+		ExposeHeaders: []string{"x-jwt-token", requestid.HeaderName},
 		// 验证来源的函数，根据函数的返回值决定是否允许该来源
 		AllowOriginFunc: func(origin string) bool {
 			// 如果来源是以 http://localhost 开头的，就允许该来源
@@ -93,25 +552,46 @@ func initWebServer() *gin.Engine {
 		MaxAge: 12 * time.Hour,
 	}))
 
-	// 创建一个新的 Redis 客户端
-	redisClient := redis.NewClient(&redis.Options{
-		// 设置 Redis 服务器地址
-		Addr: config.Config.Redis.Addr,
-	})
 	// 使用 NewBuilder 函数构建一个新的速率限制器
 	server.Use(ratelimit.NewBuilder(redisClient,
 		// 设置时间窗口为 1 秒
 		time.Second,
 		// 设置每个时间窗口内允许的最大请求数为 100
-		100).Build())
+		100).Metrics(metricsNamespace, prometheus.DefaultRegisterer).Build())
 
 	// 应用 JWT 身份验证中间件到服务器
-	useJWT(server)
+	useJWT(server, us)
+	// 本地对象存储中的文件（例如头像）
+	server.Static("/static", config.Config.Storage.Dir)
 	return server
 }
 
-// 使用 JWT 中间件配置服务器
-func useJWT(server *gin.Engine) {
-	login := &middleware.LoginJWTMiddlewareBuilder{}
+// initAccessLog 根据配置创建访问日志中间件，密码之类的字段和 token 之类的头部会被脱敏
+func initAccessLog(l logger.Logger) gin.HandlerFunc {
+	cfg := config.Config.AccessLog
+	b := accesslog.NewBuilder(accesslog.NewLoggerSink(l)).
+		MaxBodySize(cfg.MaxBodySize).
+		UidExtractor(func(ctx *gin.Context) int64 {
+			// 登录校验通过之后 JWT 中间件会把 UserClaims 放进上下文
+			if uc, ok := ctx.Value("user").(web.UserClaims); ok {
+				return uc.Uid
+			}
+			return 0
+		})
+	if cfg.Headers {
+		b = b.AllowReqHeaders().AllowRespHeaders()
+	}
+	if cfg.ReqBody {
+		b = b.AllowReqBody()
+	}
+	if cfg.RespBody {
+		b = b.AllowRespBody()
+	}
+	return b.Build()
+}
+
+// 使用 JWT 中间件配置服务器，us 用于检查 token 是否已经因为注销账号而被吊销
+func useJWT(server *gin.Engine, us *service.UserService) {
+	login := middleware.NewLoginJWTMiddlewareBuilder(us)
 	server.Use(login.CheckLogin())
 }
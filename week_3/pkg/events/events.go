@@ -0,0 +1,36 @@
+package events
+
+import "context"
+
+// Message 一条消息，字段和 Kafka 的消息对应，Key 相同的消息会按照发送的顺序投递
+type Message struct {
+	Topic string
+	Key   string
+	Value []byte
+}
+
+// Publisher 发送消息。进程内的 MemoryBroker 和 Kafka 之类的消息队列的生产者都实现这个接口，
+// 返回 nil 表示消息已经被对方接收，返回错误的时候调用方会重新发送整批消息，所以消费方需要保证幂等
+type Publisher interface {
+	Publish(ctx context.Context, msgs ...Message) error
+}
+
+// Consumer 从一个 topic 中拉取消息，对应 Kafka 消费者组中的一个消费者
+type Consumer interface {
+	// Fetch 阻塞到至少有一条消息或者 ctx 结束，最多返回 max 条消息
+	Fetch(ctx context.Context, max int) ([]Message, error)
+	// Commit 提交消费进度，提交之前崩溃的话这些消息会被再次消费
+	Commit(ctx context.Context, msgs ...Message) error
+	Close() error
+}
+
+// Subscriber 创建消费者，同一个 group 中的消费者分摊 topic 中的消息，不同 group 各自消费全部的消息
+type Subscriber interface {
+	Subscribe(ctx context.Context, group, topic string) (Consumer, error)
+}
+
+// Handler 处理一条消息
+type Handler func(ctx context.Context, msg Message) error
+
+// BatchHandler 处理一批消息，例如把一批计数的消息合并之后一次性写入数据库
+type BatchHandler func(ctx context.Context, msgs []Message) error
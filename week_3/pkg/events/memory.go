@@ -0,0 +1,98 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrQueueFull 消费者组的队列满了，消费的速度跟不上发送的速度
+var ErrQueueFull = errors.New("events: queue is full")
+
+// MemoryBroker 进程内的消息队列，同时实现了 Publisher 和 Subscriber，用于本地开发和测试。
+// 每一个 (topic, group) 有一个容量固定的队列，消息只保存在内存里面，进程退出之后没有消费的消息会丢失。
+// 没有消费者组订阅的 topic 中的消息会被直接丢弃
+type MemoryBroker struct {
+	mu       sync.RWMutex
+	capacity int
+	// topic 到 group 到队列的映射
+	queues map[string]map[string]chan Message
+}
+
+// NewMemoryBroker capacity 是每一个消费者组的队列最多缓存的消息数量
+func NewMemoryBroker(capacity int) *MemoryBroker {
+	return &MemoryBroker{capacity: capacity, queues: make(map[string]map[string]chan Message)}
+}
+
+// Publish 把消息放进订阅了这个 topic 的每一个消费者组的队列，不会阻塞。
+// 某个队列满了的时候返回 ErrQueueFull，前面的消息已经放进了队列
+func (b *MemoryBroker) Publish(ctx context.Context, msgs ...Message) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, msg := range msgs {
+		for _, q := range b.queues[msg.Topic] {
+			select {
+			case q <- msg:
+			default:
+				return ErrQueueFull
+			}
+		}
+	}
+	return nil
+}
+
+// Subscribe 同一个 group 的消费者共用一个队列，每条消息只会被其中一个消费者拿到
+func (b *MemoryBroker) Subscribe(ctx context.Context, group, topic string) (Consumer, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	groups, ok := b.queues[topic]
+	if !ok {
+		groups = make(map[string]chan Message)
+		b.queues[topic] = groups
+	}
+	q, ok := groups[group]
+	if !ok {
+		q = make(chan Message, b.capacity)
+		groups[group] = q
+	}
+	return &memoryConsumer{q: q}, nil
+}
+
+type memoryConsumer struct {
+	q chan Message
+}
+
+// Fetch 等到第一条消息之后，把队列中已经有的消息一起返回，不会为了凑满一批而等待
+func (c *memoryConsumer) Fetch(ctx context.Context, max int) ([]Message, error) {
+	var res []Message
+	// 队列中已经有消息的时候直接拿，即使 ctx 已经结束，Shutdown 排空队列依赖这一点
+	select {
+	case msg := <-c.q:
+		res = append(res, msg)
+	default:
+		select {
+		case msg := <-c.q:
+			res = append(res, msg)
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	for len(res) < max {
+		select {
+		case msg := <-c.q:
+			res = append(res, msg)
+		default:
+			return res, nil
+		}
+	}
+	return res, nil
+}
+
+// Commit 消息拿出队列之后就不会再被消费，不需要提交
+func (c *memoryConsumer) Commit(ctx context.Context, msgs ...Message) error {
+	return nil
+}
+
+func (c *memoryConsumer) Close() error {
+	return nil
+}
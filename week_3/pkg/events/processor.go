@@ -0,0 +1,313 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"go_homework/week_3/pkg/logger"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// Shutdown 之后拉取剩余消息时每次等待的时间，这段时间内没有拉到消息就认为队列已经空了
+const drainPollTimeout = 100 * time.Millisecond
+
+// DeadLetterSuffix 死信 topic 的后缀，重试之后仍然处理失败的消息发送到 <topic>.dlq
+const DeadLetterSuffix = ".dlq"
+
+// DeadLetterTopic 返回 topic 对应的死信 topic
+func DeadLetterTopic(topic string) string {
+	return topic + DeadLetterSuffix
+}
+
+// Processor 按照 topic 注册处理函数，每一个 topic 一个拉取消息的循环。
+// 处理失败的消息按照指数退避重试，重试之后仍然失败的发送到死信 topic，然后提交消费进度继续往后消费
+type Processor struct {
+	sub   Subscriber
+	group string
+	// 死信 topic 的生产者，为空的时候只记录日志
+	deadLetter Publisher
+
+	batchSize   int
+	concurrency int
+	maxAttempts int
+	// 第一次重试之前等待的时间，之后每次翻倍，最多等待 maxBackoff
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+
+	handlers      map[string]Handler
+	batchHandlers map[string]BatchHandler
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	// 最近一次 Shutdown 传入的 ctx，决定排空队列的截止时间
+	mu       sync.Mutex
+	drainCtx context.Context
+}
+
+// NewProcessor 创建 group 消费者组的 Processor。默认每批最多 100 条消息，
+// 单条处理的消息最多 4 个并发，一共尝试 3 次，重试间隔从 100ms 开始翻倍，最多 2s
+func NewProcessor(sub Subscriber, group string) *Processor {
+	return &Processor{
+		sub:            sub,
+		group:          group,
+		batchSize:      100,
+		concurrency:    4,
+		maxAttempts:    3,
+		initialBackoff: 100 * time.Millisecond,
+		maxBackoff:     2 * time.Second,
+		handlers:       make(map[string]Handler),
+		batchHandlers:  make(map[string]BatchHandler),
+	}
+}
+
+// DeadLetter 设置死信 topic 的生产者
+func (p *Processor) DeadLetter(pub Publisher) *Processor {
+	p.deadLetter = pub
+	return p
+}
+
+// BatchSize 设置每次最多拉取的消息数量
+func (p *Processor) BatchSize(n int) *Processor {
+	p.batchSize = n
+	return p
+}
+
+// Concurrency 设置同一批消息中最多同时处理的消息数量，只对 Handle 注册的处理函数生效。
+// Key 相同的消息总是交给同一个协程按照顺序处理
+func (p *Processor) Concurrency(n int) *Processor {
+	p.concurrency = n
+	return p
+}
+
+// Retry 设置最多尝试的次数和退避的时间
+func (p *Processor) Retry(maxAttempts int, initialBackoff, maxBackoff time.Duration) *Processor {
+	p.maxAttempts = maxAttempts
+	p.initialBackoff = initialBackoff
+	p.maxBackoff = maxBackoff
+	return p
+}
+
+// Handle 注册逐条处理 topic 中消息的处理函数，失败的时候只重试这一条消息
+func (p *Processor) Handle(topic string, h Handler) *Processor {
+	p.handlers[topic] = h
+	delete(p.batchHandlers, topic)
+	return p
+}
+
+// HandleBatch 注册整批处理 topic 中消息的处理函数，失败的时候重试整批消息
+func (p *Processor) HandleBatch(topic string, h BatchHandler) *Processor {
+	p.batchHandlers[topic] = h
+	delete(p.handlers, topic)
+	return p
+}
+
+// Start 订阅所有注册过的 topic，在后台开始消费，订阅失败的时候返回错误。
+// 处理函数拿到的 ctx 继承了 ctx 中的值，但是不会随着 ctx 取消，停止消费使用 Shutdown
+func (p *Processor) Start(ctx context.Context) error {
+	topics := make([]string, 0, len(p.handlers)+len(p.batchHandlers))
+	for topic := range p.handlers {
+		topics = append(topics, topic)
+	}
+	for topic := range p.batchHandlers {
+		topics = append(topics, topic)
+	}
+	consumers := make(map[string]Consumer, len(topics))
+	for _, topic := range topics {
+		c, err := p.sub.Subscribe(ctx, p.group, topic)
+		if err != nil {
+			for _, c := range consumers {
+				_ = c.Close()
+			}
+			return err
+		}
+		consumers[topic] = c
+	}
+	hctx := context.WithoutCancel(ctx)
+	fetchCtx, cancel := context.WithCancel(hctx)
+	p.cancel = cancel
+	for topic, c := range consumers {
+		p.wg.Add(1)
+		go func(topic string, c Consumer) {
+			defer p.wg.Done()
+			p.consume(fetchCtx, hctx, topic, c)
+		}(topic, c)
+	}
+	return nil
+}
+
+// Shutdown 停止阻塞等待新的消息，把队列中剩下的消息处理完并提交之后退出，
+// 或者等到 ctx 结束。调用之前需要先停止发送消息，否则可能一直排不空
+func (p *Processor) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	p.drainCtx = ctx
+	p.mu.Unlock()
+	if p.cancel != nil {
+		p.cancel()
+	}
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// consume 拉取消息的循环，fetchCtx 取消之后排空队列再退出。处理和提交使用 hctx，不会处理到一半被打断
+func (p *Processor) consume(fetchCtx, hctx context.Context, topic string, c Consumer) {
+	l := logger.FromContext(hctx).With(logger.String("topic", topic), logger.String("group", p.group))
+	ctx := logger.WithContext(hctx, l)
+	defer func() {
+		if err := c.Close(); err != nil {
+			l.Warn("close consumer failed", logger.Error(err))
+		}
+	}()
+	for {
+		msgs, err := c.Fetch(fetchCtx, p.batchSize)
+		// 取消的同时可能已经拉到了消息，先处理完再退出
+		if len(msgs) > 0 {
+			p.handle(ctx, topic, c, msgs)
+		}
+		if fetchCtx.Err() != nil {
+			p.drain(ctx, topic, c)
+			return
+		}
+		if err != nil {
+			l.Error("fetch messages failed", logger.Error(err))
+			// 避免消息队列不可用的时候空转
+			select {
+			case <-fetchCtx.Done():
+				p.drain(ctx, topic, c)
+				return
+			case <-time.After(p.maxBackoff):
+			}
+		}
+	}
+}
+
+// drain 处理队列中剩下的消息，直到 drainPollTimeout 之内拉不到消息，或者 Shutdown 的 ctx 结束
+func (p *Processor) drain(ctx context.Context, topic string, c Consumer) {
+	for {
+		p.mu.Lock()
+		deadline := p.drainCtx
+		p.mu.Unlock()
+		if deadline.Err() != nil {
+			return
+		}
+		fctx, cancel := context.WithTimeout(deadline, drainPollTimeout)
+		msgs, err := c.Fetch(fctx, p.batchSize)
+		cancel()
+		if len(msgs) == 0 {
+			if err != nil && !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, context.Canceled) {
+				logger.FromContext(ctx).Error("fetch messages failed while draining", logger.Error(err))
+			}
+			return
+		}
+		p.handle(ctx, topic, c, msgs)
+	}
+}
+
+// handle 交给注册的处理函数处理一批消息，然后提交消费进度
+func (p *Processor) handle(ctx context.Context, topic string, c Consumer, msgs []Message) {
+	if h, ok := p.batchHandlers[topic]; ok {
+		p.processBatch(ctx, msgs, h)
+	} else {
+		p.process(ctx, msgs, p.handlers[topic])
+	}
+	if err := c.Commit(ctx, msgs...); err != nil {
+		logger.FromContext(ctx).Error("commit messages failed", logger.Error(err))
+	}
+}
+
+func (p *Processor) processBatch(ctx context.Context, msgs []Message, h BatchHandler) {
+	err := p.retry(ctx, func() error {
+		return h(ctx, msgs)
+	})
+	if err != nil {
+		p.sendToDeadLetter(ctx, err, msgs...)
+	}
+}
+
+// process 按照 Key 把消息分给最多 concurrency 个协程处理
+func (p *Processor) process(ctx context.Context, msgs []Message, h Handler) {
+	n := min(p.concurrency, len(msgs))
+	if n <= 1 {
+		p.processSeq(ctx, msgs, h)
+		return
+	}
+	shards := make([][]Message, n)
+	for _, msg := range msgs {
+		hash := fnv.New32a()
+		_, _ = hash.Write([]byte(msg.Key))
+		i := hash.Sum32() % uint32(n)
+		shards[i] = append(shards[i], msg)
+	}
+	var wg sync.WaitGroup
+	for _, shard := range shards {
+		if len(shard) == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(shard []Message) {
+			defer wg.Done()
+			p.processSeq(ctx, shard, h)
+		}(shard)
+	}
+	wg.Wait()
+}
+
+func (p *Processor) processSeq(ctx context.Context, msgs []Message, h Handler) {
+	for _, msg := range msgs {
+		err := p.retry(ctx, func() error {
+			return h(ctx, msg)
+		})
+		if err != nil {
+			p.sendToDeadLetter(ctx, err, msg)
+		}
+	}
+}
+
+// retry 最多执行 fn maxAttempts 次，返回最后一次的错误
+func (p *Processor) retry(ctx context.Context, fn func() error) error {
+	backoff := p.initialBackoff
+	var err error
+	for i := 0; i < p.maxAttempts; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return errors.Join(err, ctx.Err())
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, p.maxBackoff)
+		}
+		if err = fn(); err == nil {
+			return nil
+		}
+		logger.FromContext(ctx).Warn("handle messages failed",
+			logger.Int("attempt", i+1), logger.Error(err))
+	}
+	return err
+}
+
+func (p *Processor) sendToDeadLetter(ctx context.Context, cause error, msgs ...Message) {
+	l := logger.FromContext(ctx)
+	if p.deadLetter == nil {
+		l.Error("drop messages after retries", logger.Int("count", len(msgs)), logger.Error(cause))
+		return
+	}
+	dead := make([]Message, 0, len(msgs))
+	for _, msg := range msgs {
+		dead = append(dead, Message{Topic: DeadLetterTopic(msg.Topic), Key: msg.Key, Value: msg.Value})
+	}
+	if err := p.deadLetter.Publish(ctx, dead...); err != nil {
+		l.Error("send messages to dead letter topic failed",
+			logger.Int("count", len(msgs)), logger.Error(errors.Join(cause, err)))
+		return
+	}
+	l.Warn("messages sent to dead letter topic", logger.Int("count", len(msgs)), logger.Error(cause))
+}
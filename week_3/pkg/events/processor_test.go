@@ -0,0 +1,253 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitFor 等到 cond 成立，超过 1 秒认为失败
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	require.Eventually(t, cond, time.Second, 5*time.Millisecond)
+}
+
+func shutdown(t *testing.T, p *Processor) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, p.Shutdown(ctx))
+}
+
+func TestProcessor_Retry(t *testing.T) {
+	broker := NewMemoryBroker(10)
+	var mu sync.Mutex
+	var attempts []time.Time
+	p := NewProcessor(broker, "test").
+		Retry(4, 20*time.Millisecond, 30*time.Millisecond).
+		Handle("topic", func(ctx context.Context, msg Message) error {
+			mu.Lock()
+			defer mu.Unlock()
+			attempts = append(attempts, time.Now())
+			if len(attempts) < 4 {
+				return errors.New("mock error")
+			}
+			return nil
+		})
+	require.NoError(t, p.Start(context.Background()))
+	require.NoError(t, broker.Publish(context.Background(), Message{Topic: "topic", Key: "k"}))
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(attempts) == 4
+	})
+	shutdown(t, p)
+
+	mu.Lock()
+	defer mu.Unlock()
+	// 第四次成功之后不再重试
+	assert.Len(t, attempts, 4)
+	// 退避时间从 20ms 开始翻倍，最多 30ms
+	for i, want := range []time.Duration{20 * time.Millisecond, 30 * time.Millisecond, 30 * time.Millisecond} {
+		gap := attempts[i+1].Sub(attempts[i])
+		assert.GreaterOrEqual(t, gap, want, "backoff before attempt %d", i+2)
+		assert.Less(t, gap, want+50*time.Millisecond, "backoff before attempt %d", i+2)
+	}
+}
+
+func TestProcessor_DeadLetter(t *testing.T) {
+	testCases := []struct {
+		name     string
+		register func(p *Processor, calls *atomic.Int32)
+		// 每一条消息期望的处理次数
+		wantCalls int32
+	}{
+		{
+			name: "handler",
+			register: func(p *Processor, calls *atomic.Int32) {
+				p.Handle("topic", func(ctx context.Context, msg Message) error {
+					calls.Add(1)
+					return errors.New("always fail")
+				})
+			},
+			wantCalls: 3,
+		},
+		{
+			name: "batch handler",
+			register: func(p *Processor, calls *atomic.Int32) {
+				p.BatchSize(2).HandleBatch("topic", func(ctx context.Context, msgs []Message) error {
+					calls.Add(int32(len(msgs)))
+					return errors.New("always fail")
+				})
+			},
+			wantCalls: 3,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			broker := NewMemoryBroker(10)
+			ctx := context.Background()
+			dlq, err := broker.Subscribe(ctx, "inspect", DeadLetterTopic("topic"))
+			require.NoError(t, err)
+			var calls atomic.Int32
+			p := NewProcessor(broker, "test").DeadLetter(broker).Retry(3, time.Millisecond, time.Millisecond)
+			tc.register(p, &calls)
+			require.NoError(t, p.Start(ctx))
+			sent := []Message{
+				{Topic: "topic", Key: "k1", Value: []byte("v1")},
+				{Topic: "topic", Key: "k2", Value: []byte("v2")},
+			}
+			require.NoError(t, broker.Publish(ctx, sent...))
+
+			var dead []Message
+			for len(dead) < len(sent) {
+				fctx, cancel := context.WithTimeout(ctx, time.Second)
+				msgs, err := dlq.Fetch(fctx, 10)
+				cancel()
+				require.NoError(t, err)
+				dead = append(dead, msgs...)
+			}
+			shutdown(t, p)
+			// 不同 Key 的消息并发处理，进入死信 topic 的顺序不固定
+			assert.ElementsMatch(t, []Message{
+				{Topic: "topic.dlq", Key: "k1", Value: []byte("v1")},
+				{Topic: "topic.dlq", Key: "k2", Value: []byte("v2")},
+			}, dead)
+			assert.Equal(t, tc.wantCalls*int32(len(sent)), calls.Load())
+		})
+	}
+}
+
+func TestProcessor_KeyOrdering(t *testing.T) {
+	const keys, perKey = 8, 50
+	broker := NewMemoryBroker(keys * perKey)
+	var mu sync.Mutex
+	got := make(map[string][]int)
+	var active, maxActive atomic.Int32
+	p := NewProcessor(broker, "test").BatchSize(keys*perKey).Concurrency(4).
+		Handle("topic", func(ctx context.Context, msg Message) error {
+			n := active.Add(1)
+			defer active.Add(-1)
+			for {
+				m := maxActive.Load()
+				if n <= m || maxActive.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			var seq int
+			_, _ = fmt.Sscanf(string(msg.Value), "%d", &seq)
+			mu.Lock()
+			got[msg.Key] = append(got[msg.Key], seq)
+			mu.Unlock()
+			return nil
+		})
+	// 先把消息全部放进队列，让它们在同一批里面被拉取
+	var msgs []Message
+	for i := 0; i < perKey; i++ {
+		for k := 0; k < keys; k++ {
+			msgs = append(msgs, Message{Topic: "topic", Key: fmt.Sprintf("key-%d", k), Value: []byte(fmt.Sprint(i))})
+		}
+	}
+	_, err := broker.Subscribe(context.Background(), "test", "topic")
+	require.NoError(t, err)
+	require.NoError(t, broker.Publish(context.Background(), msgs...))
+	require.NoError(t, p.Start(context.Background()))
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		total := 0
+		for _, seqs := range got {
+			total += len(seqs)
+		}
+		return total == len(msgs)
+	})
+	shutdown(t, p)
+
+	for k := 0; k < keys; k++ {
+		seqs := got[fmt.Sprintf("key-%d", k)]
+		require.Len(t, seqs, perKey)
+		for i, seq := range seqs {
+			assert.Equal(t, i, seq, "key-%d out of order", k)
+		}
+	}
+	assert.LessOrEqual(t, maxActive.Load(), int32(4))
+	assert.Greater(t, maxActive.Load(), int32(1))
+}
+
+func TestProcessor_ShutdownWaitsInFlight(t *testing.T) {
+	broker := NewMemoryBroker(10)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var finished atomic.Bool
+	p := NewProcessor(broker, "test").HandleBatch("topic", func(ctx context.Context, msgs []Message) error {
+		close(started)
+		<-release
+		finished.Store(true)
+		return nil
+	})
+	require.NoError(t, p.Start(context.Background()))
+	require.NoError(t, broker.Publish(context.Background(), Message{Topic: "topic"}))
+	<-started
+
+	// 正在处理的时候 ctx 结束，Shutdown 返回 ctx 的错误
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, p.Shutdown(ctx), context.DeadlineExceeded)
+	assert.False(t, finished.Load())
+
+	close(release)
+	shutdown(t, p)
+	assert.True(t, finished.Load())
+}
+
+func TestProcessor_ShutdownDrains(t *testing.T) {
+	broker := NewMemoryBroker(1000)
+	var handled atomic.Int32
+	p := NewProcessor(broker, "test").BatchSize(10).HandleBatch("topic", func(ctx context.Context, msgs []Message) error {
+		time.Sleep(time.Millisecond)
+		handled.Add(int32(len(msgs)))
+		return nil
+	})
+	require.NoError(t, p.Start(context.Background()))
+	msgs := make([]Message, 500)
+	for i := range msgs {
+		msgs[i] = Message{Topic: "topic"}
+	}
+	require.NoError(t, broker.Publish(context.Background(), msgs...))
+	// 队列中还有消息的时候 Shutdown，剩下的消息处理完才返回
+	shutdown(t, p)
+	assert.Equal(t, int32(len(msgs)), handled.Load())
+}
+
+func TestProcessor_ShutdownDrainDeadline(t *testing.T) {
+	broker := NewMemoryBroker(1000)
+	var handled atomic.Int32
+	p := NewProcessor(broker, "test").BatchSize(1).HandleBatch("topic", func(ctx context.Context, msgs []Message) error {
+		time.Sleep(10 * time.Millisecond)
+		handled.Add(int32(len(msgs)))
+		return nil
+	})
+	require.NoError(t, p.Start(context.Background()))
+	msgs := make([]Message, 100)
+	for i := range msgs {
+		msgs[i] = Message{Topic: "topic"}
+	}
+	require.NoError(t, broker.Publish(context.Background(), msgs...))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	// 排空的时间超过了截止时间，不再继续拉取
+	assert.ErrorIs(t, p.Shutdown(ctx), context.DeadlineExceeded)
+	// 等正在处理的那一条处理完，之后不会再处理新的消息
+	time.Sleep(30 * time.Millisecond)
+	n := handled.Load()
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, n, handled.Load())
+	assert.Less(t, n, int32(len(msgs)))
+}
@@ -0,0 +1,180 @@
+package accesslog
+
+import (
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"go_homework/week_3/pkg/ginx/middleware/requestid"
+	"io"
+	"mime"
+	"strings"
+	"time"
+)
+
+// 默认脱敏的字段和头部
+var (
+	defaultRedactFields  = []string{"password", "confirmPassword", "oldPassword", "newPassword", "token"}
+	defaultRedactHeaders = []string{"Authorization", "x-jwt-token", "Cookie", "Set-Cookie"}
+)
+
+type Builder struct {
+	sink Sink
+	// 是否记录请求和响应的头部以及内容
+	allowReqHeaders  bool
+	allowReqBody     bool
+	allowRespHeaders bool
+	allowRespBody    bool
+	// 请求体和响应体最多记录的字节数，超过的部分会被截断
+	maxBodySize   int
+	redactFields  []string
+	redactHeaders []string
+	// 从请求中取出当前登录的用户，在请求处理完之后调用
+	uidExtractor func(ctx *gin.Context) int64
+}
+
+// NewBuilder 默认只记录请求的基本信息，不记录头部和内容
+func NewBuilder(sink Sink) *Builder {
+	return &Builder{
+		sink:          sink,
+		maxBodySize:   1024,
+		redactFields:  defaultRedactFields,
+		redactHeaders: defaultRedactHeaders,
+		uidExtractor:  func(ctx *gin.Context) int64 { return 0 },
+	}
+}
+
+func (b *Builder) AllowReqHeaders() *Builder {
+	b.allowReqHeaders = true
+	return b
+}
+
+func (b *Builder) AllowReqBody() *Builder {
+	b.allowReqBody = true
+	return b
+}
+
+func (b *Builder) AllowRespHeaders() *Builder {
+	b.allowRespHeaders = true
+	return b
+}
+
+func (b *Builder) AllowRespBody() *Builder {
+	b.allowRespBody = true
+	return b
+}
+
+// MaxBodySize 请求体和响应体最多记录的字节数
+func (b *Builder) MaxBodySize(size int) *Builder {
+	b.maxBodySize = size
+	return b
+}
+
+// RedactFields 追加需要脱敏的 JSON 字段或者表单字段，不区分大小写
+func (b *Builder) RedactFields(fields ...string) *Builder {
+	b.redactFields = append(b.redactFields[:len(b.redactFields):len(b.redactFields)], fields...)
+	return b
+}
+
+// RedactHeaders 追加需要脱敏的头部，不区分大小写
+func (b *Builder) RedactHeaders(headers ...string) *Builder {
+	b.redactHeaders = append(b.redactHeaders[:len(b.redactHeaders):len(b.redactHeaders)], headers...)
+	return b
+}
+
+// UidExtractor 设置从请求中取出当前登录用户的方法，登录校验放在访问日志之后也可以取到
+func (b *Builder) UidExtractor(fn func(ctx *gin.Context) int64) *Builder {
+	b.uidExtractor = fn
+	return b
+}
+
+// Build 需要放在 Recovery 之前，这样 panic 的请求也会以 500 记录下来
+func (b *Builder) Build() gin.HandlerFunc {
+	r := newRedactor(b.redactFields, b.redactHeaders)
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		al := &AccessLog{
+			Method:    ctx.Request.Method,
+			Path:      ctx.Request.URL.Path,
+			ClientIP:  ctx.ClientIP(),
+			RequestID: requestid.FromContext(ctx),
+		}
+		if b.allowReqHeaders {
+			al.ReqHeaders = r.header(ctx.Request.Header)
+		}
+		if b.allowReqBody && ctx.Request.Body != nil && textual(ctx.GetHeader("Content-Type")) {
+			// 只读前 maxBodySize + 1 个字节，多读的一个字节用来判断有没有被截断，
+			// 读出来的部分再拼回去，后面的处理逻辑读到的还是完整的请求体
+			body, _ := io.ReadAll(io.LimitReader(ctx.Request.Body, int64(b.maxBodySize+1)))
+			ctx.Request.Body = readCloser{
+				Reader: io.MultiReader(bytes.NewReader(body), ctx.Request.Body),
+				Closer: ctx.Request.Body,
+			}
+			truncated := len(body) > b.maxBodySize
+			if truncated {
+				body = body[:b.maxBodySize]
+			}
+			al.ReqBody = r.body(body, truncated)
+		}
+		var w *responseWriter
+		if b.allowRespBody {
+			w = &responseWriter{ResponseWriter: ctx.Writer, limit: b.maxBodySize}
+			ctx.Writer = w
+		}
+
+		ctx.Next()
+
+		al.Route = ctx.FullPath()
+		al.Status = ctx.Writer.Status()
+		al.Latency = time.Since(start)
+		al.Uid = b.uidExtractor(ctx)
+		if b.allowRespHeaders {
+			al.RespHeaders = r.header(ctx.Writer.Header())
+		}
+		if w != nil && textual(ctx.Writer.Header().Get("Content-Type")) {
+			al.RespBody = r.body(w.body.Bytes(), w.truncated)
+		}
+		b.sink.Write(ctx, al)
+	}
+}
+
+// textual 只记录文本内容，图片、multipart 之类的内容记下来也没有意义
+func textual(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "text/") ||
+		mediaType == "application/json" ||
+		mediaType == "application/x-www-form-urlencoded"
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// responseWriter 在写响应的同时记录响应体的前 limit 个字节
+type responseWriter struct {
+	gin.ResponseWriter
+	body      bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (w *responseWriter) Write(data []byte) (int, error) {
+	w.capture(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *responseWriter) capture(data []byte) {
+	remain := w.limit - w.body.Len()
+	if len(data) > remain {
+		data = data[:max(remain, 0)]
+		w.truncated = true
+	}
+	w.body.Write(data)
+}
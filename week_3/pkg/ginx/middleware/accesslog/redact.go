@@ -0,0 +1,93 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Redacted 被脱敏的值统一替换成这个字符串
+const Redacted = "***"
+
+// redactor 按照字段名脱敏，字段名不区分大小写
+type redactor struct {
+	fields  map[string]struct{}
+	headers map[string]struct{}
+	// 请求体不是合法 JSON 的时候（例如被截断了）用正则兜底，匹配 "field": "value" 和 field=value
+	jsonPattern *regexp.Regexp
+	formPattern *regexp.Regexp
+}
+
+func newRedactor(fields, headers []string) *redactor {
+	r := &redactor{
+		fields:  make(map[string]struct{}, len(fields)),
+		headers: make(map[string]struct{}, len(headers)),
+	}
+	quoted := make([]string, 0, len(fields))
+	for _, f := range fields {
+		r.fields[strings.ToLower(f)] = struct{}{}
+		quoted = append(quoted, regexp.QuoteMeta(f))
+	}
+	for _, h := range headers {
+		r.headers[strings.ToLower(h)] = struct{}{}
+	}
+	if len(quoted) > 0 {
+		names := strings.Join(quoted, "|")
+		r.jsonPattern = regexp.MustCompile(`(?i)("(?:` + names + `)"\s*:\s*)"(?:[^"\\]|\\.)*"?`)
+		r.formPattern = regexp.MustCompile(`(?i)((?:^|&)(?:` + names + `)=)[^&]*`)
+	}
+	return r
+}
+
+// header 复制并脱敏头部，同名的多个值用逗号拼起来
+func (r *redactor) header(h http.Header) map[string]string {
+	res := make(map[string]string, len(h))
+	for k, vals := range h {
+		if _, ok := r.headers[strings.ToLower(k)]; ok {
+			res[k] = Redacted
+			continue
+		}
+		res[k] = strings.Join(vals, ",")
+	}
+	return res
+}
+
+// body 脱敏请求体或者响应体，truncated 表示 body 只是原始内容的前一部分
+func (r *redactor) body(body []byte, truncated bool) string {
+	if len(r.fields) == 0 {
+		return string(body)
+	}
+	if !truncated {
+		var v any
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		if decoder.Decode(&v) == nil {
+			if data, err := json.Marshal(r.value(v)); err == nil {
+				return string(data)
+			}
+		}
+	}
+	s := r.jsonPattern.ReplaceAllString(string(body), `$1"`+Redacted+`"`)
+	return r.formPattern.ReplaceAllString(s, `${1}`+Redacted)
+}
+
+// value 递归脱敏 JSON 中的对象和数组
+func (r *redactor) value(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		for k, item := range val {
+			if _, ok := r.fields[strings.ToLower(k)]; ok {
+				val[k] = Redacted
+				continue
+			}
+			val[k] = r.value(item)
+		}
+	case []any:
+		for i, item := range val {
+			val[i] = r.value(item)
+		}
+	}
+	return v
+}
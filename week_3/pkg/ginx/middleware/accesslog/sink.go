@@ -0,0 +1,46 @@
+package accesslog
+
+import (
+	"context"
+	"go_homework/week_3/pkg/logger"
+	"net/http"
+)
+
+// LoggerSink 把访问日志写到 logger.Logger，5xx 使用 Error 级别，其余使用 Info 级别
+type LoggerSink struct {
+	l logger.Logger
+}
+
+func NewLoggerSink(l logger.Logger) *LoggerSink {
+	return &LoggerSink{l: l}
+}
+
+func (s *LoggerSink) Write(ctx context.Context, al *AccessLog) {
+	fields := []logger.Field{
+		logger.String("method", al.Method),
+		logger.String("path", al.Path),
+		logger.String("route", al.Route),
+		logger.Int("status", al.Status),
+		logger.Any("latency", al.Latency),
+		logger.String("client_ip", al.ClientIP),
+		logger.String("request_id", al.RequestID),
+		logger.Int64("uid", al.Uid),
+	}
+	if al.ReqHeaders != nil {
+		fields = append(fields, logger.Any("req_headers", al.ReqHeaders))
+	}
+	if al.ReqBody != "" {
+		fields = append(fields, logger.String("req_body", al.ReqBody))
+	}
+	if al.RespHeaders != nil {
+		fields = append(fields, logger.Any("resp_headers", al.RespHeaders))
+	}
+	if al.RespBody != "" {
+		fields = append(fields, logger.String("resp_body", al.RespBody))
+	}
+	if al.Status >= http.StatusInternalServerError {
+		s.l.Error("access", fields...)
+		return
+	}
+	s.l.Info("access", fields...)
+}
@@ -0,0 +1,39 @@
+// Package accesslog 记录 HTTP 访问日志，可以按需记录请求和响应的内容，敏感字段会被脱敏
+package accesslog
+
+import (
+	"context"
+	"time"
+)
+
+// AccessLog 一次请求的访问日志
+type AccessLog struct {
+	Method string
+	// 请求的路径，例如 /users/12
+	Path string
+	// 匹配到的路由，例如 /users/:id，没有匹配到路由的时候为空
+	Route     string
+	Status    int
+	Latency   time.Duration
+	ClientIP  string
+	RequestID string
+	// 当前登录的用户，没有登录的时候为 0
+	Uid int64
+	// 下面的字段只有开启了对应的记录之后才有值，已经脱敏
+	ReqHeaders  map[string]string
+	ReqBody     string
+	RespHeaders map[string]string
+	RespBody    string
+}
+
+// Sink 访问日志的输出目的地，例如日志、消息队列，Build 出来的中间件在每个请求结束之后调用一次
+type Sink interface {
+	Write(ctx context.Context, al *AccessLog)
+}
+
+// SinkFunc 让普通的函数实现 Sink
+type SinkFunc func(ctx context.Context, al *AccessLog)
+
+func (f SinkFunc) Write(ctx context.Context, al *AccessLog) {
+	f(ctx, al)
+}
@@ -0,0 +1,72 @@
+// Package metrics 记录 HTTP 请求的 Prometheus 指标：
+//   - <namespace>_http_request_duration_seconds: 直方图，请求的处理时间，
+//     label 为 method、route（路由模板，例如 /users/:id，没有匹配到路由的时候为 unknown）和 status
+//   - <namespace>_http_active_requests: 仪表盘，正在处理的请求数量
+package metrics
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"strconv"
+	"time"
+)
+
+type Builder struct {
+	namespace  string
+	registerer prometheus.Registerer
+	buckets    []float64
+}
+
+// NewBuilder 指标注册到 prometheus 默认的 Registerer
+func NewBuilder(namespace string) *Builder {
+	return &Builder{
+		namespace:  namespace,
+		registerer: prometheus.DefaultRegisterer,
+		buckets:    prometheus.DefBuckets,
+	}
+}
+
+// Registerer 设置注册指标的 Registerer
+func (b *Builder) Registerer(r prometheus.Registerer) *Builder {
+	b.registerer = r
+	return b
+}
+
+// Buckets 设置直方图的桶，单位是秒
+func (b *Builder) Buckets(buckets []float64) *Builder {
+	b.buckets = buckets
+	return b
+}
+
+// Build 会注册指标，同一个 Registerer 只能调用一次
+func (b *Builder) Build() gin.HandlerFunc {
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: b.namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP 请求的处理时间",
+		Buckets:   b.buckets,
+	}, []string{"method", "route", "status"})
+	active := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: b.namespace,
+		Subsystem: "http",
+		Name:      "active_requests",
+		Help:      "正在处理的 HTTP 请求数量",
+	})
+	b.registerer.MustRegister(duration, active)
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		active.Inc()
+		defer func() {
+			active.Dec()
+			// 使用路由模板而不是实际的路径，避免 /users/1、/users/2 变成不同的时间序列
+			route := ctx.FullPath()
+			if route == "" {
+				route = "unknown"
+			}
+			duration.WithLabelValues(ctx.Request.Method, route, strconv.Itoa(ctx.Writer.Status())).
+				Observe(time.Since(start).Seconds())
+		}()
+		ctx.Next()
+	}
+}
@@ -4,8 +4,9 @@ import (
 	_ "embed"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/redis/go-redis/v9"
-	"log"
+	"go_homework/week_3/pkg/logger"
 	"net/http"
 	"time"
 )
@@ -16,6 +17,10 @@ type Builder struct {
 	interval time.Duration
 	// 阈值
 	rate int
+	// 限流对象，默认按照客户端 IP 限流
+	keyFn func(ctx *gin.Context) string
+	// 为 nil 的时候不记录指标
+	metrics *metrics
 }
 
 //go:embed slide_window.lua
@@ -27,6 +32,9 @@ func NewBuilder(cmd redis.Cmdable, interval time.Duration, rate int) *Builder {
 		prefix:   "ip-limiter",
 		interval: interval,
 		rate:     rate,
+		keyFn: func(ctx *gin.Context) string {
+			return ctx.ClientIP()
+		},
 	}
 }
 
@@ -35,11 +43,31 @@ func (b *Builder) Prefix(prefix string) *Builder {
 	return b
 }
 
+// KeyFunc 设置限流对象，例如按照登录用户的 uid 限流，需要放在登录校验之后。
+// 返回空字符串的时候不限流，例如拿不到 uid 的请求
+func (b *Builder) KeyFunc(fn func(ctx *gin.Context) string) *Builder {
+	b.keyFn = fn
+	return b
+}
+
+// Metrics 记录限流的 Prometheus 指标，多个限流器可以使用同一个 Registerer，指标按照 prefix 区分
+func (b *Builder) Metrics(namespace string, registerer prometheus.Registerer) *Builder {
+	b.metrics = newMetrics(namespace, registerer)
+	return b
+}
+
 func (b *Builder) Build() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		limited, err := b.limit(ctx)
+		key := b.keyFn(ctx)
+		if key == "" {
+			ctx.Next()
+			return
+		}
+		limited, err := b.limit(ctx, key)
+		b.observe(limited, err)
 		if err != nil {
-			log.Println(err)
+			logger.FromContext(ctx).Error("ratelimit: redis failed",
+				logger.String("prefix", b.prefix), logger.String("key", key), logger.Error(err))
 			// 这一步很有意思，就是如果这边出错了
 			// 要怎么办？
 			// 保守做法：因为借助于 Redis 来做限流，那么 Redis 崩溃了，为了防止系统崩溃，直接限流
@@ -49,7 +77,8 @@ func (b *Builder) Build() gin.HandlerFunc {
 			return
 		}
 		if limited {
-			log.Println(err)
+			logger.FromContext(ctx).Warn("ratelimit: request limited",
+				logger.String("prefix", b.prefix), logger.String("key", key))
 			ctx.AbortWithStatus(http.StatusTooManyRequests)
 			return
 		}
@@ -57,8 +86,28 @@ func (b *Builder) Build() gin.HandlerFunc {
 	}
 }
 
-func (b *Builder) limit(ctx *gin.Context) (bool, error) {
-	key := fmt.Sprintf("%s:%s", b.prefix, ctx.ClientIP())
-	return b.cmd.Eval(ctx, luaScript, []string{key},
+func (b *Builder) limit(ctx *gin.Context, key string) (bool, error) {
+	key = fmt.Sprintf("%s:%s", b.prefix, key)
+	start := time.Now()
+	limited, err := b.cmd.Eval(ctx, luaScript, []string{key},
 		b.interval.Milliseconds(), b.rate, time.Now().UnixMilli()).Bool()
+	if b.metrics != nil {
+		b.metrics.script.WithLabelValues(b.prefix).Observe(time.Since(start).Seconds())
+	}
+	return limited, err
+}
+
+// observe 记录限流判断的结果
+func (b *Builder) observe(limited bool, err error) {
+	if b.metrics == nil {
+		return
+	}
+	result := "allowed"
+	switch {
+	case err != nil:
+		result = "error"
+	case limited:
+		result = "limited"
+	}
+	b.metrics.requests.WithLabelValues(b.prefix, result).Inc()
 }
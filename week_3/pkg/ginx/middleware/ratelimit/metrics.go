@@ -0,0 +1,51 @@
+package ratelimit
+
+import (
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metrics 限流器的 Prometheus 指标：
+//   - <namespace>_ratelimit_requests_total: 计数器，限流判断的结果，
+//     label 为 prefix（限流器的前缀）和 result（allowed、limited、error）
+//   - <namespace>_ratelimit_script_duration_seconds: 直方图，执行限流 Lua 脚本的耗时，label 为 prefix
+type metrics struct {
+	requests *prometheus.CounterVec
+	script   *prometheus.HistogramVec
+}
+
+func newMetrics(namespace string, registerer prometheus.Registerer) *metrics {
+	m := &metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "ratelimit",
+			Name:      "requests_total",
+			Help:      "限流判断的结果",
+		}, []string{"prefix", "result"}),
+		script: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "ratelimit",
+			Name:      "script_duration_seconds",
+			Help:      "执行限流 Lua 脚本的耗时",
+			Buckets:   []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25},
+		}, []string{"prefix"}),
+	}
+	m.requests = register(registerer, m.requests)
+	m.script = register(registerer, m.script)
+	return m
+}
+
+// register 注册指标，已经被别的限流器注册过的时候使用已经注册的指标
+func register[T prometheus.Collector](registerer prometheus.Registerer, c T) T {
+	err := registerer.Register(c)
+	if err == nil {
+		return c
+	}
+	var are prometheus.AlreadyRegisteredError
+	if errors.As(err, &are) {
+		if existing, ok := are.ExistingCollector.(T); ok {
+			return existing
+		}
+	}
+	panic(err)
+}
@@ -0,0 +1,78 @@
+// Package requestid 给每一个请求分配一个请求 ID，并通过 context 传递下去，
+// 同一个请求在各层打的日志都会带上这个 ID，方便串起来排查问题
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/gin-gonic/gin"
+	"go_homework/week_3/pkg/logger"
+)
+
+// HeaderName 请求和响应中携带请求 ID 的头部
+const HeaderName = "X-Request-ID"
+
+// 上游传过来的请求 ID 的最大长度，太长或者包含奇怪字符的 ID 会被替换掉，避免污染日志
+const maxLength = 64
+
+type ctxKey struct{}
+
+type Builder struct {
+	l logger.Logger
+	// 是否信任上游（例如网关）传过来的请求 ID
+	trustUpstream bool
+}
+
+// NewBuilder l 是基础的 Logger，每个请求会在它的基础上带上 request_id 字段
+func NewBuilder(l logger.Logger) *Builder {
+	return &Builder{l: l, trustUpstream: true}
+}
+
+// TrustUpstream 设置是否沿用请求头中已有的请求 ID，默认沿用
+func (b *Builder) TrustUpstream(trust bool) *Builder {
+	b.trustUpstream = trust
+	return b
+}
+
+// Build 需要配合 gin.Engine.ContextWithFallback = true 使用，
+// 这样把 *gin.Context 当作 context.Context 往下传的时候也能取到请求 ID 和 Logger
+func (b *Builder) Build() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.GetHeader(HeaderName)
+		if !b.trustUpstream || !valid(id) {
+			id = newID()
+		}
+		ctx.Header(HeaderName, id)
+		reqCtx := context.WithValue(ctx.Request.Context(), ctxKey{}, id)
+		reqCtx = logger.WithContext(reqCtx, b.l.With(logger.String("request_id", id)))
+		ctx.Request = ctx.Request.WithContext(reqCtx)
+		ctx.Next()
+	}
+}
+
+// FromContext 取出请求 ID，没有的时候返回空字符串
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+func valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for _, ch := range id {
+		isAlnum := ch >= '0' && ch <= '9' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z'
+		if !isAlnum && ch != '-' && ch != '_' && ch != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	// crypto/rand 在 Linux 上不会失败，失败的时候用全 0 的 ID 也不影响请求
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,81 @@
+// Package tracing 为每一个 HTTP 请求创建 OpenTelemetry 的 server span，
+// 后续各层通过 context 创建的 span 都是它的子 span
+package tracing
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go_homework/week_3/pkg/logger"
+	"net/http"
+)
+
+const instrumentationName = "go_homework/week_3/pkg/ginx/middleware/tracing"
+
+type Builder struct {
+	provider   trace.TracerProvider
+	propagator propagation.TextMapPropagator
+}
+
+// NewBuilder 默认使用 otel 全局的 TracerProvider 和 TextMapPropagator
+func NewBuilder() *Builder {
+	return &Builder{
+		provider:   otel.GetTracerProvider(),
+		propagator: otel.GetTextMapPropagator(),
+	}
+}
+
+func (b *Builder) TracerProvider(provider trace.TracerProvider) *Builder {
+	b.provider = provider
+	return b
+}
+
+func (b *Builder) Propagator(propagator propagation.TextMapPropagator) *Builder {
+	b.propagator = propagator
+	return b
+}
+
+// Build 需要配合 gin.Engine.ContextWithFallback = true 使用，
+// 放在 requestid 之后，日志里面会同时带上请求 ID 和 trace ID
+func (b *Builder) Build() gin.HandlerFunc {
+	tracer := b.provider.Tracer(instrumentationName)
+	return func(ctx *gin.Context) {
+		// 上游传过来的 traceparent 之类的头部
+		reqCtx := b.propagator.Extract(ctx.Request.Context(), propagation.HeaderCarrier(ctx.Request.Header))
+		// gin 在执行中间件之前已经匹配好了路由，这里可以拿到路由模板
+		route := ctx.FullPath()
+		name := ctx.Request.Method + " " + route
+		if route == "" {
+			name = ctx.Request.Method
+		}
+		reqCtx, span := tracer.Start(reqCtx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", ctx.Request.Method),
+				attribute.String("http.route", route),
+				attribute.String("url.path", ctx.Request.URL.Path),
+				attribute.String("client.address", ctx.ClientIP()),
+			))
+		defer span.End()
+		if sc := span.SpanContext(); sc.IsValid() {
+			l := logger.FromContext(reqCtx).With(logger.String("trace_id", sc.TraceID().String()))
+			reqCtx = logger.WithContext(reqCtx, l)
+		}
+		ctx.Request = ctx.Request.WithContext(reqCtx)
+
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		// 4xx 是客户端的问题，不算 server span 的错误
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		if len(ctx.Errors) > 0 {
+			span.SetAttributes(attribute.String("gin.errors", ctx.Errors.String()))
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package tracing
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBuilder_Build(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const parentTraceId = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testCases := []struct {
+		name   string
+		path   string
+		header http.Header
+		status int
+
+		wantName       string
+		wantRoute      string
+		wantStatusCode codes.Code
+		// 期望的 trace ID，为空表示新开的 trace
+		wantTraceId string
+	}{
+		{
+			name:           "ok",
+			path:           "/users/123",
+			status:         http.StatusOK,
+			wantName:       "GET /users/:id",
+			wantRoute:      "/users/:id",
+			wantStatusCode: codes.Unset,
+		},
+		{
+			name:           "client error",
+			path:           "/users/123",
+			status:         http.StatusBadRequest,
+			wantName:       "GET /users/:id",
+			wantRoute:      "/users/:id",
+			wantStatusCode: codes.Unset,
+		},
+		{
+			name:           "server error",
+			path:           "/users/123",
+			status:         http.StatusInternalServerError,
+			wantName:       "GET /users/:id",
+			wantRoute:      "/users/:id",
+			wantStatusCode: codes.Error,
+		},
+		{
+			name:           "no route",
+			path:           "/missing",
+			status:         http.StatusNotFound,
+			wantName:       "GET",
+			wantStatusCode: codes.Unset,
+		},
+		{
+			name: "upstream trace",
+			path: "/users/123",
+			header: http.Header{
+				"Traceparent": []string{"00-" + parentTraceId + "-00f067aa0ba902b7-01"},
+			},
+			status:         http.StatusOK,
+			wantName:       "GET /users/:id",
+			wantRoute:      "/users/:id",
+			wantStatusCode: codes.Unset,
+			wantTraceId:    parentTraceId,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := tracetest.NewSpanRecorder()
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+			server := gin.New()
+			server.Use(NewBuilder().TracerProvider(tp).Propagator(propagation.TraceContext{}).Build())
+			var handlerSpan trace.SpanContext
+			server.GET("/users/:id", func(ctx *gin.Context) {
+				// 处理函数可以从请求的 context 中拿到 server span
+				handlerSpan = trace.SpanContextFromContext(ctx.Request.Context())
+				ctx.Status(tc.status)
+			})
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			for k, v := range tc.header {
+				req.Header[k] = v
+			}
+			server.ServeHTTP(httptest.NewRecorder(), req)
+
+			spans := recorder.Ended()
+			require.Len(t, spans, 1)
+			span := spans[0]
+			assert.Equal(t, tc.wantName, span.Name())
+			assert.Equal(t, trace.SpanKindServer, span.SpanKind())
+			assert.Equal(t, tc.wantStatusCode, span.Status().Code)
+			attrs := attribute.NewSet(span.Attributes()...)
+			route, _ := attrs.Value("http.route")
+			assert.Equal(t, tc.wantRoute, route.AsString())
+			path, _ := attrs.Value("url.path")
+			assert.Equal(t, tc.path, path.AsString())
+			code, _ := attrs.Value("http.response.status_code")
+			assert.Equal(t, int64(tc.status), code.AsInt64())
+			if tc.wantTraceId != "" {
+				assert.Equal(t, tc.wantTraceId, span.SpanContext().TraceID().String())
+				assert.True(t, span.Parent().IsRemote())
+			} else {
+				assert.False(t, span.Parent().IsValid())
+			}
+			if tc.wantRoute != "" {
+				assert.Equal(t, span.SpanContext().SpanID(), handlerSpan.SpanID())
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package validator
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"go_homework/week_3/pkg/optional"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// 密码允许使用的特殊字符
+const passwordSpecialChars = "!@#$%^&*()-_=+[{]};:'\",<.>/?"
+
+// FieldError 单个字段的校验错误
+type FieldError struct {
+	// 字段名，使用 json tag 里面的名字，方便前端直接定位到表单项
+	Field string `json:"field"`
+	// 没有通过的规则，例如 required、email、max
+	Rule string `json:"rule"`
+	// 给用户看的提示
+	Msg string `json:"msg"`
+}
+
+// Init 向 gin 默认的校验引擎注册自定义规则，需要在注册路由之前调用
+func Init() error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("validator: gin binding engine is not go-playground/validator")
+	}
+	return Register(v)
+}
+
+// Register 注册自定义规则：
+//   - password: 8 到 16 位，必须同时包含大写字母、小写字母、数字和特殊字符
+//   - date: YYYY-MM-DD 格式的日期字符串
+//   - notfuture: 日期不能晚于今天，支持 YYYY-MM-DD 字符串和 time.Time
+func Register(v *validator.Validate) error {
+	// 错误信息里面使用 json 的字段名，而不是 Go 的字段名
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" {
+			// 查询参数和表单只有 form tag
+			name, _, _ = strings.Cut(field.Tag.Get("form"), ",")
+		}
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+	// optional.Field 按照里面的值校验，没有传或者传了 null 的时候当作空值，配合 omitempty 使用
+	v.RegisterCustomTypeFunc(optionalValue, optional.Field[string]{}, optional.Field[int64]{})
+	rules := map[string]validator.Func{
+		"password":  validatePassword,
+		"date":      validateDate,
+		"notfuture": validateNotFuture,
+	}
+	for tag, fn := range rules {
+		if err := v.RegisterValidation(tag, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Translate 把校验错误转换成字段级别的错误列表，err 不是校验错误的时候返回 false
+func Translate(err error) ([]FieldError, bool) {
+	var ves validator.ValidationErrors
+	if !errors.As(err, &ves) {
+		return nil, false
+	}
+	res := make([]FieldError, 0, len(ves))
+	for _, fe := range ves {
+		res = append(res, FieldError{
+			Field: fe.Field(),
+			Rule:  fe.Tag(),
+			Msg:   message(fe),
+		})
+	}
+	return res, true
+}
+
+func message(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return fmt.Sprintf("%s is required", fe.Field())
+	case "email":
+		return "Email is invalid"
+	case "password":
+		return "The password must contain at least one uppercase letter, one lowercase letter, " +
+			"one number, and one special character, and must be between 8 and 16 characters long"
+	case "eqfield":
+		// Param 是 Go 的字段名，不直接展示给用户
+		return fmt.Sprintf("%s does not match", fe.Field())
+	case "max":
+		if fe.Kind() != reflect.String {
+			// 数字之类的字段，max 限制的是值而不是长度
+			return fmt.Sprintf("%s must be at most %s", fe.Field(), fe.Param())
+		}
+		return fmt.Sprintf("%s must be at most %s characters long", fe.Field(), fe.Param())
+	case "oneof":
+		return fmt.Sprintf("%s must be one of: %s", fe.Field(), fe.Param())
+	case "date":
+		return fmt.Sprintf("%s must be a date in YYYY-MM-DD format", fe.Field())
+	case "notfuture":
+		return fmt.Sprintf("%s must not be in the future", fe.Field())
+	default:
+		return fmt.Sprintf("%s is invalid", fe.Field())
+	}
+}
+
+func optionalValue(field reflect.Value) any {
+	switch f := field.Interface().(type) {
+	case optional.Field[string]:
+		if f.HasValue() {
+			return f.Value
+		}
+	case optional.Field[int64]:
+		if f.HasValue() {
+			return f.Value
+		}
+	}
+	return nil
+}
+
+func validatePassword(fl validator.FieldLevel) bool {
+	pwd := fl.Field().String()
+	if len(pwd) < 8 || len(pwd) > 16 {
+		return false
+	}
+	var hasUpper, hasLower, hasDigit, hasSpecial bool
+	for _, ch := range pwd {
+		switch {
+		case ch > unicode.MaxASCII:
+			return false
+		case unicode.IsUpper(ch):
+			hasUpper = true
+		case unicode.IsLower(ch):
+			hasLower = true
+		case unicode.IsDigit(ch):
+			hasDigit = true
+		case strings.ContainsRune(passwordSpecialChars, ch):
+			hasSpecial = true
+		default:
+			// 空格之类不允许使用的字符
+			return false
+		}
+	}
+	return hasUpper && hasLower && hasDigit && hasSpecial
+}
+
+func validateDate(fl validator.FieldLevel) bool {
+	_, err := time.Parse(time.DateOnly, fl.Field().String())
+	return err == nil
+}
+
+func validateNotFuture(fl validator.FieldLevel) bool {
+	var t time.Time
+	switch val := fl.Field().Interface().(type) {
+	case string:
+		parsed, err := time.ParseInLocation(time.DateOnly, val, time.Local)
+		if err != nil {
+			// 格式问题交给 date 规则报告
+			return true
+		}
+		t = parsed
+	case time.Time:
+		t = val
+	default:
+		return false
+	}
+	return !t.After(time.Now())
+}
@@ -0,0 +1,86 @@
+// Package gormx gorm 相关的扩展
+package gormx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go_homework/week_3/pkg/logger"
+	"gorm.io/gorm"
+	glogger "gorm.io/gorm/logger"
+	"time"
+)
+
+// Logger 把 gorm 的日志转发到 logger.Logger。
+// 使用 context 中的 Logger，所以 SQL 的错误和慢查询日志会带上请求 ID。
+// 日志中的 SQL 只保留占位符，不带参数的值，避免把密码哈希、邮箱之类的数据写进日志
+type Logger struct {
+	level glogger.LogLevel
+	// 执行时间超过这个值的 SQL 记为慢查询，0 表示不记录
+	slowThreshold time.Duration
+}
+
+// NewLogger 默认只记录执行出错的 SQL 和慢查询
+func NewLogger(slowThreshold time.Duration) *Logger {
+	return &Logger{level: glogger.Warn, slowThreshold: slowThreshold}
+}
+
+func (l *Logger) LogMode(level glogger.LogLevel) glogger.Interface {
+	cp := *l
+	cp.level = level
+	return &cp
+}
+
+func (l *Logger) Info(ctx context.Context, msg string, args ...any) {
+	if l.level >= glogger.Info {
+		logger.FromContext(ctx).Info(fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *Logger) Warn(ctx context.Context, msg string, args ...any) {
+	if l.level >= glogger.Warn {
+		logger.FromContext(ctx).Warn(fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *Logger) Error(ctx context.Context, msg string, args ...any) {
+	if l.level >= glogger.Error {
+		logger.FromContext(ctx).Error(fmt.Sprintf(msg, args...))
+	}
+}
+
+// ParamsFilter 实现 gorm.ParamsFilter，去掉所有参数，gorm 生成日志中的 SQL 时不会再把值填进占位符
+func (l *Logger) ParamsFilter(ctx context.Context, sql string, params ...any) (string, []any) {
+	return sql, nil
+}
+
+func (l *Logger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if l.level <= glogger.Silent {
+		return
+	}
+	elapsed := time.Since(begin)
+	switch {
+	// 没有查到数据是正常的业务情况，由上层决定怎么处理
+	case errors.Is(err, gorm.ErrRecordNotFound):
+	// 唯一索引冲突由 DAO 转换成业务错误，例如邮箱已经注册过。需要开启 gorm.Config.TranslateError 才能识别，
+	// 驱动原始的错误信息里面带着冲突的值，所以不记录错误信息
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		if l.level >= glogger.Info {
+			sql, rows := fc()
+			logger.FromContext(ctx).Debug("gorm: unique conflict", logger.String("sql", sql),
+				logger.Int64("rows", rows), logger.Any("elapsed", elapsed))
+		}
+	case err != nil && l.level >= glogger.Error:
+		sql, rows := fc()
+		logger.FromContext(ctx).Error("gorm: query failed", logger.Error(err),
+			logger.String("sql", sql), logger.Int64("rows", rows), logger.Any("elapsed", elapsed))
+	case l.slowThreshold > 0 && elapsed > l.slowThreshold && l.level >= glogger.Warn:
+		sql, rows := fc()
+		logger.FromContext(ctx).Warn("gorm: slow query", logger.String("sql", sql),
+			logger.Int64("rows", rows), logger.Any("elapsed", elapsed))
+	case l.level >= glogger.Info:
+		sql, rows := fc()
+		logger.FromContext(ctx).Debug("gorm: query", logger.String("sql", sql),
+			logger.Int64("rows", rows), logger.Any("elapsed", elapsed))
+	}
+}
@@ -0,0 +1,57 @@
+package gormx
+
+import (
+	"bytes"
+	"context"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go_homework/week_3/pkg/logger"
+	"gorm.io/gorm"
+	glogger "gorm.io/gorm/logger"
+	"log/slog"
+	"testing"
+)
+
+type loggerUser struct {
+	Id       int64  `gorm:"primaryKey,autoIncrement"`
+	Email    string `gorm:"unique"`
+	Password string
+}
+
+func TestLogger_NoBoundValues(t *testing.T) {
+	var buf bytes.Buffer
+	l := logger.NewSlogLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	ctx := logger.WithContext(context.Background(), l)
+
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{
+		Logger:         NewLogger(0).LogMode(glogger.Info),
+		TranslateError: true,
+	})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	require.NoError(t, db.AutoMigrate(&loggerUser{}))
+
+	const email, password = "secret@qq.com", "$argon2id$v=19$m=65536,t=3,p=4$hash"
+	require.NoError(t, db.WithContext(ctx).Create(&loggerUser{Email: email, Password: password}).Error)
+	err = db.WithContext(ctx).Create(&loggerUser{Email: email, Password: password}).Error
+	assert.ErrorIs(t, err, gorm.ErrDuplicatedKey)
+	err = db.WithContext(ctx).Exec("SELECT * FROM no_such_table WHERE email = ?", email).Error
+	assert.Error(t, err)
+
+	out := buf.String()
+	assert.Contains(t, out, "gorm: unique conflict")
+	assert.Contains(t, out, "gorm: query failed")
+	assert.Contains(t, out, "?")
+	assert.NotContains(t, out, email)
+	assert.NotContains(t, out, password)
+	// 唯一索引冲突不是错误日志
+	for _, line := range bytes.Split(buf.Bytes(), []byte("\n")) {
+		if bytes.Contains(line, []byte("unique conflict")) {
+			assert.Contains(t, string(line), "level=DEBUG")
+		}
+	}
+}
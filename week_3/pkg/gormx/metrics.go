@@ -0,0 +1,87 @@
+package gormx
+
+import (
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm"
+	"time"
+)
+
+// 记录 SQL 开始时间的 key
+const metricsStartKey = "gormx:metrics:start"
+
+// MetricsPlugin 通过 gorm 的 callback 记录 SQL 的 Prometheus 指标：
+//   - <namespace>_gorm_query_duration_seconds: 直方图，SQL 的执行时间，
+//     label 为 operation（create、query、update、delete、row、raw）、
+//     table（表名，原生 SQL 没有表名的时候为空）和 status（ok、not_found、error）
+type MetricsPlugin struct {
+	duration *prometheus.HistogramVec
+}
+
+// NewMetricsPlugin 创建并注册指标，通过 db.Use 使用
+func NewMetricsPlugin(namespace string, registerer prometheus.Registerer) *MetricsPlugin {
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "gorm",
+		Name:      "query_duration_seconds",
+		Help:      "SQL 的执行时间",
+		Buckets:   []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5},
+	}, []string{"operation", "table", "status"})
+	registerer.MustRegister(duration)
+	return &MetricsPlugin{duration: duration}
+}
+
+func (p *MetricsPlugin) Name() string {
+	return "gormx:metrics"
+}
+
+func (p *MetricsPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	// 每一种操作都在 gorm 自己的处理之前记下开始时间，之后记录耗时
+	registers := []struct {
+		operation string
+		before    func(name string, fn func(*gorm.DB)) error
+		after     func(name string, fn func(*gorm.DB)) error
+	}{
+		{"create", cb.Create().Before("gorm:create").Register, cb.Create().After("gorm:create").Register},
+		{"query", cb.Query().Before("gorm:query").Register, cb.Query().After("gorm:query").Register},
+		{"update", cb.Update().Before("gorm:update").Register, cb.Update().After("gorm:update").Register},
+		{"delete", cb.Delete().Before("gorm:delete").Register, cb.Delete().After("gorm:delete").Register},
+		{"row", cb.Row().Before("gorm:row").Register, cb.Row().After("gorm:row").Register},
+		{"raw", cb.Raw().Before("gorm:raw").Register, cb.Raw().After("gorm:raw").Register},
+	}
+	for _, r := range registers {
+		if err := r.before("gormx:metrics_before_"+r.operation, p.before); err != nil {
+			return err
+		}
+		if err := r.after("gormx:metrics_after_"+r.operation, p.after(r.operation)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *MetricsPlugin) before(db *gorm.DB) {
+	db.InstanceSet(metricsStartKey, time.Now())
+}
+
+func (p *MetricsPlugin) after(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		val, ok := db.InstanceGet(metricsStartKey)
+		if !ok {
+			return
+		}
+		start, ok := val.(time.Time)
+		if !ok {
+			return
+		}
+		status := "ok"
+		switch {
+		case errors.Is(db.Error, gorm.ErrRecordNotFound):
+			status = "not_found"
+		case db.Error != nil:
+			status = "error"
+		}
+		p.duration.WithLabelValues(operation, db.Statement.Table, status).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,125 @@
+package replica
+
+import (
+	"context"
+	"go_homework/week_3/pkg/logger"
+	"gorm.io/gorm"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// pinger 能够探活的连接池，*sql.DB 实现了这个接口
+type pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// HealthCheckPolicy 是 dbresolver.Policy 的实现，在健康的从库之间轮询。
+// 后台定期 ping 每一个从库，失败的从库会被移出轮询，恢复之后再加回来。
+// 所有从库都不健康的时候读请求改走主库
+type HealthCheckPolicy struct {
+	interval time.Duration
+	timeout  time.Duration
+	primary  gorm.ConnPool
+	// 所有从库的连接池，在创建的时候登记，探活不依赖 Resolve 有没有被调用过
+	replicas []gorm.ConnPool
+
+	mu        sync.RWMutex
+	unhealthy map[gorm.ConnPool]struct{}
+	next      atomic.Uint64
+}
+
+// NewHealthCheckPolicy primary 是主库的连接池，replicas 是交给 dbresolver 的从库连接池，
+// interval 是探活的间隔，timeout 是单次 ping 的超时时间
+func NewHealthCheckPolicy(primary gorm.ConnPool, replicas []gorm.ConnPool,
+	interval, timeout time.Duration) *HealthCheckPolicy {
+	return &HealthCheckPolicy{
+		interval:  interval,
+		timeout:   timeout,
+		primary:   primary,
+		replicas:  replicas,
+		unhealthy: make(map[gorm.ConnPool]struct{}),
+	}
+}
+
+// Dialectors 把已经打开的从库连接池转换成交给 dbresolver 的 Dialector，dialectorOf 使用 conn 创建 Dialector。
+// dbresolver 只有一个从库的时候不调用 Policy，直接使用这个从库，
+// 所以只有一个从库的时候登记两次，让读请求仍然经过健康检查，从库不健康的时候可以回退到主库
+func Dialectors(pools []gorm.ConnPool, dialectorOf func(conn gorm.ConnPool) gorm.Dialector) []gorm.Dialector {
+	res := make([]gorm.Dialector, 0, max(len(pools), 2))
+	for _, pool := range pools {
+		res = append(res, dialectorOf(pool))
+	}
+	if len(pools) == 1 {
+		res = append(res, dialectorOf(pools[0]))
+	}
+	return res
+}
+
+// Resolve 从健康的从库中选一个，所有从库都不健康的时候返回主库
+func (p *HealthCheckPolicy) Resolve(connPools []gorm.ConnPool) gorm.ConnPool {
+	p.mu.RLock()
+	healthy := make([]gorm.ConnPool, 0, len(connPools))
+	for _, pool := range connPools {
+		if _, ok := p.unhealthy[pool]; !ok {
+			healthy = append(healthy, pool)
+		}
+	}
+	p.mu.RUnlock()
+	if len(healthy) == 0 {
+		return p.primary
+	}
+	return healthy[int(p.next.Add(1)%uint64(len(healthy)))]
+}
+
+// Start 立即探活一次，之后按照 interval 定期探活，阻塞到 ctx 被取消
+func (p *HealthCheckPolicy) Start(ctx context.Context) {
+	p.check(ctx)
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.check(ctx)
+		}
+	}
+}
+
+func (p *HealthCheckPolicy) check(ctx context.Context) {
+	wasAllDown := p.allDown()
+	for i, pool := range p.replicas {
+		pg, ok := pool.(pinger)
+		if !ok {
+			continue
+		}
+		pctx, cancel := context.WithTimeout(ctx, p.timeout)
+		err := pg.PingContext(pctx)
+		cancel()
+		p.mu.Lock()
+		_, wasUnhealthy := p.unhealthy[pool]
+		if err != nil {
+			p.unhealthy[pool] = struct{}{}
+		} else {
+			delete(p.unhealthy, pool)
+		}
+		p.mu.Unlock()
+		switch {
+		case err != nil && !wasUnhealthy:
+			logger.FromContext(ctx).Warn("replica is unhealthy, removed from rotation",
+				logger.Int("replica", i), logger.Error(err))
+		case err == nil && wasUnhealthy:
+			logger.FromContext(ctx).Info("replica recovered, added back to rotation", logger.Int("replica", i))
+		}
+	}
+	if !wasAllDown && p.allDown() {
+		logger.FromContext(ctx).Warn("all replicas are unhealthy, reads fall back to the primary")
+	}
+}
+
+func (p *HealthCheckPolicy) allDown() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return len(p.replicas) > 0 && len(p.unhealthy) == len(p.replicas)
+}
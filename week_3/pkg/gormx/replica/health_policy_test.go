@@ -0,0 +1,146 @@
+package replica
+
+import (
+	"context"
+	"errors"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakePool 可以控制 ping 结果的连接池，只用来比较身份和探活
+type fakePool struct {
+	gorm.ConnPool
+	down atomic.Bool
+}
+
+func (f *fakePool) PingContext(ctx context.Context) error {
+	if f.down.Load() {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func TestHealthCheckPolicy_Resolve(t *testing.T) {
+	primary := &fakePool{}
+	r1, r2 := &fakePool{}, &fakePool{}
+	replicas := []gorm.ConnPool{r1, r2}
+	testCases := []struct {
+		name string
+		down []*fakePool
+		// 多次 Resolve 期望选到的连接池
+		want []gorm.ConnPool
+	}{
+		{
+			name: "all healthy",
+			want: []gorm.ConnPool{r1, r2},
+		},
+		{
+			name: "one replica down",
+			down: []*fakePool{r1},
+			want: []gorm.ConnPool{r2},
+		},
+		{
+			name: "all replicas down",
+			down: []*fakePool{r1, r2},
+			want: []gorm.ConnPool{primary},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r1.down.Store(false)
+			r2.down.Store(false)
+			for _, pool := range tc.down {
+				pool.down.Store(true)
+			}
+			p := NewHealthCheckPolicy(primary, replicas, time.Minute, time.Second)
+			// 没有调用过 Resolve 也会探活所有的从库
+			p.check(context.Background())
+			got := make(map[gorm.ConnPool]struct{})
+			for i := 0; i < 10; i++ {
+				got[p.Resolve(replicas)] = struct{}{}
+			}
+			want := make(map[gorm.ConnPool]struct{})
+			for _, pool := range tc.want {
+				want[pool] = struct{}{}
+			}
+			assert.Equal(t, want, got)
+		})
+	}
+}
+
+func TestHealthCheckPolicy_Recover(t *testing.T) {
+	primary, r1 := &fakePool{}, &fakePool{}
+	replicas := []gorm.ConnPool{r1}
+	p := NewHealthCheckPolicy(primary, replicas, time.Minute, time.Second)
+
+	r1.down.Store(true)
+	p.check(context.Background())
+	assert.Same(t, primary, p.Resolve(replicas))
+
+	r1.down.Store(false)
+	p.check(context.Background())
+	assert.Same(t, r1, p.Resolve(replicas))
+}
+
+func TestHealthCheckPolicy_StartChecksImmediately(t *testing.T) {
+	primary, r1 := &fakePool{}, &fakePool{}
+	r1.down.Store(true)
+	replicas := []gorm.ConnPool{r1}
+	// 间隔足够长，测试期间只会有启动时的那一次探活
+	p := NewHealthCheckPolicy(primary, replicas, time.Hour, time.Second)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.Start(ctx)
+	assert.Eventually(t, func() bool {
+		return p.Resolve(replicas) == gorm.ConnPool(primary)
+	}, time.Second, 10*time.Millisecond)
+}
+
+// TestHealthCheckPolicy_WithDBResolver 从库用已经打开的连接池注册到 dbresolver，
+// dbresolver 交给 Resolve 的就是登记的连接池。只有一个从库，不可用的时候查询也会落到主库
+func TestHealthCheckPolicy_WithDBResolver(t *testing.T) {
+	type item struct {
+		Id   int64
+		Name string
+	}
+	open := func(name string) *gorm.DB {
+		db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), name)), &gorm.Config{})
+		require.NoError(t, err)
+		sqlDB, err := db.DB()
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = sqlDB.Close() })
+		require.NoError(t, db.AutoMigrate(&item{}))
+		return db
+	}
+	db := open("primary.db")
+	require.NoError(t, db.Create(&item{Id: 1, Name: "primary"}).Error)
+	rdb := open("replica.db")
+	require.NoError(t, rdb.Create(&item{Id: 1, Name: "replica"}).Error)
+	pool, err := rdb.DB()
+	require.NoError(t, err)
+
+	p := NewHealthCheckPolicy(db.ConnPool, []gorm.ConnPool{pool}, time.Hour, time.Second)
+	require.NoError(t, db.Use(dbresolver.Register(dbresolver.Config{
+		Replicas: Dialectors([]gorm.ConnPool{pool}, func(conn gorm.ConnPool) gorm.Dialector {
+			return &sqlite.Dialector{Conn: conn}
+		}),
+		Policy: p,
+	})))
+	var res item
+	require.NoError(t, db.First(&res, 1).Error)
+	assert.Equal(t, "replica", res.Name)
+
+	// 关掉从库之后探活失败，读请求改走主库
+	require.NoError(t, pool.Close())
+	p.check(context.Background())
+	res = item{}
+	require.NoError(t, db.First(&res, 1).Error)
+	assert.Equal(t, "primary", res.Name)
+}
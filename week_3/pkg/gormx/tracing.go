@@ -0,0 +1,95 @@
+package gormx
+
+import (
+	"errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const (
+	tracingInstrumentationName = "go_homework/week_3/pkg/gormx"
+	// 保存 span 的 key
+	tracingSpanKey = "gormx:tracing:span"
+)
+
+// TracingPlugin 通过 gorm 的 callback 为每一条 SQL 创建一个 client span，
+// span 的名字为 gorm.<操作>，例如 gorm.query，记录表名、SQL（参数使用占位符）和影响的行数。
+// 没有查到数据不算错误
+type TracingPlugin struct {
+	tracer trace.Tracer
+}
+
+// NewTracingPlugin 使用 otel 全局的 TracerProvider，通过 db.Use 使用
+func NewTracingPlugin() *TracingPlugin {
+	return &TracingPlugin{tracer: otel.Tracer(tracingInstrumentationName)}
+}
+
+func (p *TracingPlugin) Name() string {
+	return "gormx:tracing"
+}
+
+func (p *TracingPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	registers := []struct {
+		operation string
+		before    func(name string, fn func(*gorm.DB)) error
+		after     func(name string, fn func(*gorm.DB)) error
+	}{
+		{"create", cb.Create().Before("gorm:create").Register, cb.Create().After("gorm:create").Register},
+		{"query", cb.Query().Before("gorm:query").Register, cb.Query().After("gorm:query").Register},
+		{"update", cb.Update().Before("gorm:update").Register, cb.Update().After("gorm:update").Register},
+		{"delete", cb.Delete().Before("gorm:delete").Register, cb.Delete().After("gorm:delete").Register},
+		{"row", cb.Row().Before("gorm:row").Register, cb.Row().After("gorm:row").Register},
+		{"raw", cb.Raw().Before("gorm:raw").Register, cb.Raw().After("gorm:raw").Register},
+	}
+	for _, r := range registers {
+		if err := r.before("gormx:tracing_before_"+r.operation, p.before(r.operation)); err != nil {
+			return err
+		}
+		if err := r.after("gormx:tracing_after_"+r.operation, p.after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *TracingPlugin) before(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		ctx := db.Statement.Context
+		if ctx == nil {
+			return
+		}
+		_, span := p.tracer.Start(ctx, "gorm."+operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String("db.system", db.Dialector.Name()),
+				attribute.String("db.operation.name", operation),
+			))
+		db.InstanceSet(tracingSpanKey, span)
+	}
+}
+
+func (p *TracingPlugin) after(db *gorm.DB) {
+	val, ok := db.InstanceGet(tracingSpanKey)
+	if !ok {
+		return
+	}
+	span, ok := val.(trace.Span)
+	if !ok {
+		return
+	}
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.collection.name", db.Statement.Table),
+		// SQL 中的参数使用占位符，不会把用户数据记录到 span 里面
+		attribute.String("db.query.text", db.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", db.Statement.RowsAffected),
+	)
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		span.RecordError(db.Error)
+		span.SetStatus(codes.Error, db.Error.Error())
+	}
+}
@@ -0,0 +1,104 @@
+package gormx
+
+import (
+	"context"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"testing"
+)
+
+type tracingUser struct {
+	Id   int64 `gorm:"primaryKey,autoIncrement"`
+	Name string
+}
+
+// useSpanRecorder 把 otel 全局的 TracerProvider 换成记录 span 的实现，测试结束之后恢复
+func useSpanRecorder(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	old := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(old) })
+	return recorder
+}
+
+func TestTracingPlugin(t *testing.T) {
+	recorder := useSpanRecorder(t)
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	require.NoError(t, db.AutoMigrate(&tracingUser{}))
+	require.NoError(t, db.Use(NewTracingPlugin()))
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "parent")
+	require.NoError(t, db.WithContext(ctx).Create(&tracingUser{Name: "Tom"}).Error)
+	var u tracingUser
+	err = db.WithContext(ctx).Where("name = ?", "missing").First(&u).Error
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	err = db.WithContext(ctx).Exec("SELECT * FROM no_such_table").Error
+	assert.Error(t, err)
+	parent.End()
+
+	testCases := []struct {
+		name         string
+		wantTable    string
+		wantRows     int64
+		wantSQL      string
+		wantStatus   codes.Code
+		wantHasError bool
+	}{
+		{
+			name:      "gorm.create",
+			wantTable: "tracing_users",
+			wantRows:  1,
+			wantSQL:   "INSERT INTO `tracing_users` (`name`) VALUES (?) RETURNING `id`",
+		},
+		{
+			// 没有查到数据不算错误
+			name:      "gorm.query",
+			wantTable: "tracing_users",
+			wantSQL:   "SELECT * FROM `tracing_users` WHERE name = ? ORDER BY `tracing_users`.`id` LIMIT 1",
+		},
+		{
+			name:         "gorm.raw",
+			wantSQL:      "SELECT * FROM no_such_table",
+			wantStatus:   codes.Error,
+			wantHasError: true,
+		},
+	}
+	// 最后一个是 parent
+	spans := recorder.Ended()
+	require.Len(t, spans, len(testCases)+1)
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			span := spans[i]
+			assert.Equal(t, tc.name, span.Name())
+			assert.Equal(t, trace.SpanKindClient, span.SpanKind())
+			assert.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID())
+			attrs := attribute.NewSet(span.Attributes()...)
+			system, _ := attrs.Value("db.system")
+			assert.Equal(t, "sqlite", system.AsString())
+			table, _ := attrs.Value("db.collection.name")
+			assert.Equal(t, tc.wantTable, table.AsString())
+			sql, _ := attrs.Value("db.query.text")
+			assert.Equal(t, tc.wantSQL, sql.AsString())
+			rows, _ := attrs.Value("db.rows_affected")
+			assert.Equal(t, tc.wantRows, rows.AsInt64())
+			assert.Equal(t, tc.wantStatus, span.Status().Code)
+			assert.Equal(t, tc.wantHasError, len(span.Events()) > 0)
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package hasher
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"strings"
+)
+
+const argon2idPrefix = "$argon2id$"
+
+// Argon2idParams argon2id 的参数
+type Argon2idParams struct {
+	// 内存开销，单位是 KiB
+	Memory uint32
+	// 迭代次数
+	Iterations uint32
+	// 并行度
+	Parallelism uint8
+	// 盐的长度，单位是字节
+	SaltLength uint32
+	// 生成的密钥长度，单位是字节
+	KeyLength uint32
+}
+
+// DefaultArgon2idParams 参考 RFC 9106 推荐的第二套参数（64 MiB 内存）
+var DefaultArgon2idParams = Argon2idParams{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// Argon2idHasher 基于 argon2id 的实现，哈希串使用标准的 PHC 格式：
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>，salt 和 hash 都是不带填充的 base64
+type Argon2idHasher struct {
+	params Argon2idParams
+}
+
+func NewArgon2idHasher(params Argon2idParams) *Argon2idHasher {
+	return &Argon2idHasher{params: params}
+}
+
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	p := h.params
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version,
+		p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h *Argon2idHasher) Verify(encoded string, password string) (bool, error) {
+	p, salt, key, err := h.decode(encoded)
+	if err != nil {
+		return false, err
+	}
+	// 使用哈希串里面记录的参数重新计算，而不是当前配置的参数
+	other := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+func (h *Argon2idHasher) Supports(encoded string) bool {
+	return strings.HasPrefix(encoded, argon2idPrefix)
+}
+
+func (h *Argon2idHasher) NeedsRehash(encoded string) bool {
+	p, salt, _, err := h.decode(encoded)
+	if err != nil {
+		return true
+	}
+	cur := h.params
+	return p.Memory < cur.Memory ||
+		p.Iterations < cur.Iterations ||
+		p.Parallelism != cur.Parallelism ||
+		p.KeyLength < cur.KeyLength ||
+		uint32(len(salt)) < cur.SaltLength
+}
+
+// decode 解析 PHC 格式的哈希串，返回其中记录的参数、盐和密钥
+func (h *Argon2idHasher) decode(encoded string) (Argon2idParams, []byte, []byte, error) {
+	if !h.Supports(encoded) {
+		return Argon2idParams{}, nil, nil, ErrUnsupportedHash
+	}
+	// 切分之后是 ["", "argon2id", "v=19", "m=..,t=..,p=..", salt, hash]
+	segs := strings.Split(encoded, "$")
+	if len(segs) != 6 {
+		return Argon2idParams{}, nil, nil, ErrMalformedHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(segs[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return Argon2idParams{}, nil, nil, ErrMalformedHash
+	}
+	var p Argon2idParams
+	if _, err := fmt.Sscanf(segs[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return Argon2idParams{}, nil, nil, ErrMalformedHash
+	}
+	// 迭代次数和并行度为 0 的时候 argon2.IDKey 会 panic
+	if p.Iterations == 0 || p.Parallelism == 0 {
+		return Argon2idParams{}, nil, nil, ErrMalformedHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(segs[4])
+	if err != nil {
+		return Argon2idParams{}, nil, nil, ErrMalformedHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(segs[5])
+	if err != nil || len(key) == 0 {
+		return Argon2idParams{}, nil, nil, ErrMalformedHash
+	}
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(key))
+	return p, salt, key, nil
+}
@@ -0,0 +1,163 @@
+package hasher
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+// testArgon2idParams 测试使用很小的内存开销，避免拖慢测试
+var testArgon2idParams = Argon2idParams{
+	Memory:      1024,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+func TestArgon2idHasher_RoundTrip(t *testing.T) {
+	h := NewArgon2idHasher(testArgon2idParams)
+	encoded, err := h.Hash("hello#world123")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encoded, "$argon2id$v=19$m=1024,t=1,p=1$"))
+	assert.True(t, h.Supports(encoded))
+	assert.False(t, h.NeedsRehash(encoded))
+
+	ok, err := h.Verify(encoded, "hello#world123")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = h.Verify(encoded, "hello#world124")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// 同一个密码每次使用不同的盐
+	other, err := h.Hash("hello#world123")
+	require.NoError(t, err)
+	assert.NotEqual(t, encoded, other)
+}
+
+func TestArgon2idHasher_VerifyMalformed(t *testing.T) {
+	h := NewArgon2idHasher(testArgon2idParams)
+	encoded, err := h.Hash("hello#world123")
+	require.NoError(t, err)
+	segs := strings.Split(encoded, "$")
+	join := func(replace map[int]string) string {
+		res := append([]string(nil), segs...)
+		for i, s := range replace {
+			res[i] = s
+		}
+		return strings.Join(res, "$")
+	}
+	testCases := []struct {
+		name    string
+		encoded string
+		wantErr error
+	}{
+		{
+			name:    "bcrypt hash",
+			encoded: "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy",
+			wantErr: ErrUnsupportedHash,
+		},
+		{
+			name:    "missing segment",
+			encoded: strings.Join(segs[:5], "$"),
+			wantErr: ErrMalformedHash,
+		},
+		{
+			name:    "unknown version",
+			encoded: join(map[int]string{2: "v=16"}),
+			wantErr: ErrMalformedHash,
+		},
+		{
+			name:    "bad params",
+			encoded: join(map[int]string{3: "m=abc,t=1,p=1"}),
+			wantErr: ErrMalformedHash,
+		},
+		{
+			name:    "zero iterations",
+			encoded: join(map[int]string{3: "m=1024,t=0,p=1"}),
+			wantErr: ErrMalformedHash,
+		},
+		{
+			name:    "zero parallelism",
+			encoded: join(map[int]string{3: "m=1024,t=1,p=0"}),
+			wantErr: ErrMalformedHash,
+		},
+		{
+			name:    "bad salt",
+			encoded: join(map[int]string{4: "!!!"}),
+			wantErr: ErrMalformedHash,
+		},
+		{
+			name:    "empty key",
+			encoded: join(map[int]string{5: ""}),
+			wantErr: ErrMalformedHash,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ok, err := h.Verify(tc.encoded, "hello#world123")
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.False(t, ok)
+			// 解析不了的哈希串总是需要重新哈希
+			assert.True(t, h.NeedsRehash(tc.encoded))
+		})
+	}
+}
+
+func TestArgon2idHasher_NeedsRehash(t *testing.T) {
+	encoded, err := NewArgon2idHasher(testArgon2idParams).Hash("hello#world123")
+	require.NoError(t, err)
+	testCases := []struct {
+		name   string
+		params func(p *Argon2idParams)
+		want   bool
+	}{
+		{
+			name:   "same params",
+			params: func(p *Argon2idParams) {},
+		},
+		{
+			name:   "more memory",
+			params: func(p *Argon2idParams) { p.Memory *= 2 },
+			want:   true,
+		},
+		{
+			name:   "less memory",
+			params: func(p *Argon2idParams) { p.Memory /= 2 },
+		},
+		{
+			name:   "more iterations",
+			params: func(p *Argon2idParams) { p.Iterations++ },
+			want:   true,
+		},
+		{
+			name:   "different parallelism",
+			params: func(p *Argon2idParams) { p.Parallelism++ },
+			want:   true,
+		},
+		{
+			name:   "longer salt",
+			params: func(p *Argon2idParams) { p.SaltLength = 32 },
+			want:   true,
+		},
+		{
+			name:   "longer key",
+			params: func(p *Argon2idParams) { p.KeyLength = 64 },
+			want:   true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := testArgon2idParams
+			tc.params(&p)
+			h := NewArgon2idHasher(p)
+			assert.Equal(t, tc.want, h.NeedsRehash(encoded))
+			// 参数调整之后，旧参数生成的哈希依旧可以校验
+			ok, err := h.Verify(encoded, "hello#world123")
+			require.NoError(t, err)
+			assert.True(t, ok)
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package hasher
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"testing"
+)
+
+func TestBcryptHasher_RoundTrip(t *testing.T) {
+	h := NewBcryptHasher(bcrypt.MinCost)
+	encoded, err := h.Hash("hello#world123")
+	require.NoError(t, err)
+	assert.True(t, h.Supports(encoded))
+	assert.False(t, h.NeedsRehash(encoded))
+
+	ok, err := h.Verify(encoded, "hello#world123")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = h.Verify(encoded, "hello#world124")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestBcryptHasher_VerifyMalformed(t *testing.T) {
+	h := NewBcryptHasher(bcrypt.MinCost)
+	testCases := []struct {
+		name    string
+		encoded string
+	}{
+		{
+			name:    "too short",
+			encoded: "$2a$04$short",
+		},
+		{
+			name:    "bad cost",
+			encoded: "$2a$xx$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ok, err := h.Verify(tc.encoded, "hello#world123")
+			assert.ErrorIs(t, err, ErrMalformedHash)
+			assert.False(t, ok)
+			assert.True(t, h.NeedsRehash(tc.encoded))
+		})
+	}
+}
+
+func TestBcryptHasher_NeedsRehash(t *testing.T) {
+	encoded, err := NewBcryptHasher(bcrypt.MinCost + 1).Hash("hello#world123")
+	require.NoError(t, err)
+	testCases := []struct {
+		name string
+		cost int
+		want bool
+	}{
+		{
+			name: "same cost",
+			cost: bcrypt.MinCost + 1,
+		},
+		{
+			name: "higher cost",
+			cost: bcrypt.MinCost + 2,
+			want: true,
+		},
+		{
+			// 调低 cost 不会让已有的密码降级
+			name: "lower cost",
+			cost: bcrypt.MinCost,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, NewBcryptHasher(tc.cost).NeedsRehash(encoded))
+		})
+	}
+}
+
+func TestNewBcryptHasher_InvalidCost(t *testing.T) {
+	assert.Equal(t, bcrypt.DefaultCost, NewBcryptHasher(bcrypt.MaxCost+1).cost)
+	assert.Equal(t, bcrypt.DefaultCost, NewBcryptHasher(0).cost)
+}
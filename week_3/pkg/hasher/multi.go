@@ -0,0 +1,46 @@
+package hasher
+
+// MultiHasher 组合多个算法：新密码总是使用 preferred 哈希，
+// 校验的时候根据哈希串的前缀找到对应的算法，这样老算法生成的哈希依旧可以登录。
+type MultiHasher struct {
+	preferred PasswordHasher
+	all       []PasswordHasher
+}
+
+// NewMultiHasher 创建一个 MultiHasher，legacy 是仍然需要支持校验的旧算法
+func NewMultiHasher(preferred PasswordHasher, legacy ...PasswordHasher) *MultiHasher {
+	return &MultiHasher{
+		preferred: preferred,
+		all:       append([]PasswordHasher{preferred}, legacy...),
+	}
+}
+
+func (h *MultiHasher) Hash(password string) (string, error) {
+	return h.preferred.Hash(password)
+}
+
+func (h *MultiHasher) Verify(encoded string, password string) (bool, error) {
+	for _, ph := range h.all {
+		if ph.Supports(encoded) {
+			return ph.Verify(encoded, password)
+		}
+	}
+	return false, ErrUnsupportedHash
+}
+
+func (h *MultiHasher) Supports(encoded string) bool {
+	for _, ph := range h.all {
+		if ph.Supports(encoded) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *MultiHasher) NeedsRehash(encoded string) bool {
+	// 不是首选算法生成的，一律需要重新哈希
+	if !h.preferred.Supports(encoded) {
+		return true
+	}
+	return h.preferred.NeedsRehash(encoded)
+}
@@ -0,0 +1,108 @@
+package hasher
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+	"testing"
+)
+
+func TestMultiHasher(t *testing.T) {
+	argon := NewArgon2idHasher(testArgon2idParams)
+	bc := NewBcryptHasher(bcrypt.MinCost)
+	argonHash, err := argon.Hash("hello#world123")
+	require.NoError(t, err)
+	bcryptHash, err := bc.Hash("hello#world123")
+	require.NoError(t, err)
+	// 当前参数比 argonHash 生成时的更高
+	stronger := testArgon2idParams
+	stronger.Iterations++
+
+	testCases := []struct {
+		name       string
+		h          *MultiHasher
+		encoded    string
+		password   string
+		wantOk     bool
+		wantErr    error
+		wantRehash bool
+	}{
+		{
+			name:     "preferred algorithm",
+			h:        NewMultiHasher(argon, bc),
+			encoded:  argonHash,
+			password: "hello#world123",
+			wantOk:   true,
+		},
+		{
+			name:       "legacy algorithm",
+			h:          NewMultiHasher(argon, bc),
+			encoded:    bcryptHash,
+			password:   "hello#world123",
+			wantOk:     true,
+			wantRehash: true,
+		},
+		{
+			name:       "legacy algorithm wrong password",
+			h:          NewMultiHasher(argon, bc),
+			encoded:    bcryptHash,
+			password:   "hello#world124",
+			wantRehash: true,
+		},
+		{
+			name:       "preferred algorithm with old params",
+			h:          NewMultiHasher(NewArgon2idHasher(stronger), bc),
+			encoded:    argonHash,
+			password:   "hello#world123",
+			wantOk:     true,
+			wantRehash: true,
+		},
+		{
+			name:       "algorithm not configured",
+			h:          NewMultiHasher(argon),
+			encoded:    bcryptHash,
+			password:   "hello#world123",
+			wantErr:    ErrUnsupportedHash,
+			wantRehash: true,
+		},
+		{
+			name:       "unknown format",
+			h:          NewMultiHasher(argon, bc),
+			encoded:    "5f4dcc3b5aa765d61d8327deb882cf99",
+			password:   "hello#world123",
+			wantErr:    ErrUnsupportedHash,
+			wantRehash: true,
+		},
+		{
+			name:       "malformed preferred hash",
+			h:          NewMultiHasher(argon, bc),
+			encoded:    strings.Replace(argonHash, "p=1", "p=0", 1),
+			password:   "hello#world123",
+			wantErr:    ErrMalformedHash,
+			wantRehash: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ok, err := tc.h.Verify(tc.encoded, tc.password)
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.Equal(t, tc.wantOk, ok)
+			assert.Equal(t, tc.wantErr != ErrUnsupportedHash, tc.h.Supports(tc.encoded))
+			assert.Equal(t, tc.wantRehash, tc.h.NeedsRehash(tc.encoded))
+		})
+	}
+}
+
+func TestMultiHasher_HashUsesPreferred(t *testing.T) {
+	argon := NewArgon2idHasher(testArgon2idParams)
+	bc := NewBcryptHasher(bcrypt.MinCost)
+	h := NewMultiHasher(argon, bc)
+	encoded, err := h.Hash("hello#world123")
+	require.NoError(t, err)
+	assert.True(t, argon.Supports(encoded))
+	assert.False(t, h.NeedsRehash(encoded))
+	ok, err := h.Verify(encoded, "hello#world123")
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
@@ -0,0 +1,24 @@
+package hasher
+
+import "errors"
+
+var (
+	// ErrUnsupportedHash 表示哈希串的格式无法被任何已知算法识别
+	ErrUnsupportedHash = errors.New("hasher: unsupported hash format")
+	// ErrMalformedHash 表示哈希串能识别出算法，但是参数或者内容被破坏了
+	ErrMalformedHash = errors.New("hasher: malformed hash")
+)
+
+// PasswordHasher 密码哈希算法的抽象。
+// 所有实现产生的哈希串都是自描述的（PHC 字符串格式，形如 $id$params$salt$hash），
+// 算法和参数都保存在哈希串里面，所以后续可以随时调整算法或者参数，而不需要强制用户重置密码。
+type PasswordHasher interface {
+	// Hash 使用当前的算法和参数对明文密码进行哈希
+	Hash(password string) (string, error)
+	// Verify 校验明文密码和哈希串是否匹配，不匹配的时候返回 false, nil
+	Verify(encoded string, password string) (bool, error)
+	// Supports 判断哈希串是否是该算法生成的
+	Supports(encoded string) bool
+	// NeedsRehash 判断哈希串的算法或者参数是否已经落后于当前配置
+	NeedsRehash(encoded string) bool
+}
@@ -0,0 +1,38 @@
+package logger
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+type ctxKey struct{}
+
+// 没有从 context 中拿到 Logger 的时候使用的默认 Logger
+var defaultLogger atomic.Pointer[Logger]
+
+func init() {
+	SetDefault(NewNopLogger())
+}
+
+// SetDefault 设置默认的 Logger，一般在 main 里面初始化日志之后调用
+func SetDefault(l Logger) {
+	defaultLogger.Store(&l)
+}
+
+// Default 返回默认的 Logger
+func Default() Logger {
+	return *defaultLogger.Load()
+}
+
+// WithContext 把 l 放进 context，后续通过 FromContext 取出来的 Logger 会带上 l 上面的字段（例如请求 ID）
+func WithContext(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// FromContext 取出 WithContext 放进去的 Logger，没有的时候返回默认的 Logger
+func FromContext(ctx context.Context) Logger {
+	if l, ok := ctx.Value(ctxKey{}).(Logger); ok {
+		return l
+	}
+	return Default()
+}
@@ -0,0 +1,20 @@
+package logger
+
+// NopLogger 丢弃所有日志
+type NopLogger struct{}
+
+func NewNopLogger() *NopLogger {
+	return &NopLogger{}
+}
+
+func (n *NopLogger) Debug(msg string, args ...Field) {}
+
+func (n *NopLogger) Info(msg string, args ...Field) {}
+
+func (n *NopLogger) Warn(msg string, args ...Field) {}
+
+func (n *NopLogger) Error(msg string, args ...Field) {}
+
+func (n *NopLogger) With(args ...Field) Logger {
+	return n
+}
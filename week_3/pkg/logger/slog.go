@@ -0,0 +1,52 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SlogLogger 基于标准库 log/slog 的实现，不想引入 zap 的时候使用
+type SlogLogger struct {
+	l *slog.Logger
+}
+
+func NewSlogLogger(l *slog.Logger) *SlogLogger {
+	return &SlogLogger{l: l}
+}
+
+func (s *SlogLogger) Debug(msg string, args ...Field) {
+	s.l.LogAttrs(context.Background(), slog.LevelDebug, msg, s.toAttrs(args)...)
+}
+
+func (s *SlogLogger) Info(msg string, args ...Field) {
+	s.l.LogAttrs(context.Background(), slog.LevelInfo, msg, s.toAttrs(args)...)
+}
+
+func (s *SlogLogger) Warn(msg string, args ...Field) {
+	s.l.LogAttrs(context.Background(), slog.LevelWarn, msg, s.toAttrs(args)...)
+}
+
+func (s *SlogLogger) Error(msg string, args ...Field) {
+	s.l.LogAttrs(context.Background(), slog.LevelError, msg, s.toAttrs(args)...)
+}
+
+func (s *SlogLogger) With(args ...Field) Logger {
+	attrs := make([]any, 0, len(args))
+	for _, attr := range s.toAttrs(args) {
+		attrs = append(attrs, attr)
+	}
+	return &SlogLogger{l: s.l.With(attrs...)}
+}
+
+func (s *SlogLogger) toAttrs(args []Field) []slog.Attr {
+	res := make([]slog.Attr, 0, len(args))
+	for _, arg := range args {
+		// slog 对 error 没有特殊处理，JSON 格式下会输出成 {}，这里转换成字符串
+		if err, ok := arg.Value.(error); ok && err != nil {
+			res = append(res, slog.String(arg.Key, err.Error()))
+			continue
+		}
+		res = append(res, slog.Any(arg.Key, arg.Value))
+	}
+	return res
+}
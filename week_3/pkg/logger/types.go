@@ -0,0 +1,44 @@
+// Package logger 结构化日志的抽象，业务代码只依赖 Logger 接口，
+// 具体使用 zap 还是标准库的 slog 在 main 里面决定
+package logger
+
+// Logger 结构化、分级别的日志。args 是附加在这一条日志上的字段
+type Logger interface {
+	Debug(msg string, args ...Field)
+	Info(msg string, args ...Field)
+	Warn(msg string, args ...Field)
+	Error(msg string, args ...Field)
+	// With 返回一个新的 Logger，之后的每一条日志都会带上 args
+	With(args ...Field) Logger
+}
+
+// Field 日志中的一个字段
+type Field struct {
+	Key   string
+	Value any
+}
+
+func String(key, val string) Field {
+	return Field{Key: key, Value: val}
+}
+
+func Int64(key string, val int64) Field {
+	return Field{Key: key, Value: val}
+}
+
+func Int(key string, val int) Field {
+	return Field{Key: key, Value: val}
+}
+
+func Bool(key string, val bool) Field {
+	return Field{Key: key, Value: val}
+}
+
+func Any(key string, val any) Field {
+	return Field{Key: key, Value: val}
+}
+
+// Error 错误统一使用 error 作为字段名
+func Error(err error) Field {
+	return Field{Key: "error", Value: err}
+}
@@ -0,0 +1,42 @@
+package logger
+
+import "go.uber.org/zap"
+
+// ZapLogger 基于 zap 的实现
+type ZapLogger struct {
+	l *zap.Logger
+}
+
+func NewZapLogger(l *zap.Logger) *ZapLogger {
+	// 跳过 ZapLogger 自己这一层，日志里面的 caller 才是真正打日志的地方
+	return &ZapLogger{l: l.WithOptions(zap.AddCallerSkip(1))}
+}
+
+func (z *ZapLogger) Debug(msg string, args ...Field) {
+	z.l.Debug(msg, z.toZapFields(args)...)
+}
+
+func (z *ZapLogger) Info(msg string, args ...Field) {
+	z.l.Info(msg, z.toZapFields(args)...)
+}
+
+func (z *ZapLogger) Warn(msg string, args ...Field) {
+	z.l.Warn(msg, z.toZapFields(args)...)
+}
+
+func (z *ZapLogger) Error(msg string, args ...Field) {
+	z.l.Error(msg, z.toZapFields(args)...)
+}
+
+func (z *ZapLogger) With(args ...Field) Logger {
+	return &ZapLogger{l: z.l.With(z.toZapFields(args)...)}
+}
+
+func (z *ZapLogger) toZapFields(args []Field) []zap.Field {
+	res := make([]zap.Field, 0, len(args))
+	for _, arg := range args {
+		// zap.Any 会根据值的类型选择合适的编码方式，error 会输出 Error() 的内容
+		res = append(res, zap.Any(arg.Key, arg.Value))
+	}
+	return res
+}
@@ -0,0 +1,55 @@
+package migrator
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"hash/fnv"
+	"time"
+)
+
+// ErrLockTimeout 等待迁移锁超时，说明其它副本正在执行迁移并且耗时过长
+var ErrLockTimeout = errors.New("migrator: timeout waiting for migration lock")
+
+// lock 在 conn 上获取一个数据库级别的锁，返回释放锁的函数。
+// conn 必须是固定的一个连接（gorm.DB.Connection），MySQL 和 Postgres 的锁都是会话级别的
+func lock(conn *gorm.DB, name string, timeout time.Duration) (func() error, error) {
+	switch conn.Dialector.Name() {
+	case "mysql":
+		var got int
+		err := conn.Raw("SELECT GET_LOCK(?, ?)", name, int(timeout.Seconds())).Scan(&got).Error
+		if err != nil {
+			return nil, err
+		}
+		if got != 1 {
+			return nil, ErrLockTimeout
+		}
+		return func() error {
+			return conn.Exec("SELECT RELEASE_LOCK(?)", name).Error
+		}, nil
+	case "postgres":
+		key := advisoryKey(name)
+		err := conn.Exec(fmt.Sprintf("SET lock_timeout = '%dms'", timeout.Milliseconds())).Error
+		if err != nil {
+			return nil, err
+		}
+		if err = conn.Exec("SELECT pg_advisory_lock(?)", key).Error; err != nil {
+			return nil, err
+		}
+		return func() error {
+			// lock_timeout 是会话级别的设置，连接会被放回连接池，需要恢复默认值
+			return errors.Join(conn.Exec("SELECT pg_advisory_unlock(?)", key).Error,
+				conn.Exec("RESET lock_timeout").Error)
+		}, nil
+	default:
+		// sqlite 之类的嵌入式数据库只会有一个进程访问，不需要加锁
+		return func() error { return nil }, nil
+	}
+}
+
+// advisoryKey Postgres 的 advisory lock 只接受整数，把锁的名字哈希成整数
+func advisoryKey(name string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(name))
+	return int64(h.Sum64())
+}
@@ -0,0 +1,98 @@
+package migrator
+
+import (
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 迁移文件的命名规则：<版本号>_<名字>.up.sql 和 <版本号>_<名字>.down.sql，例如 0001_init.up.sql
+var fileNamePattern = regexp.MustCompile(`^(\d+)_([a-zA-Z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration 一个版本的迁移
+type Migration struct {
+	Version int64
+	Name    string
+	// 升级使用的 SQL
+	Up string
+	// 回滚使用的 SQL，为空表示这个版本不支持回滚
+	Down string
+}
+
+// Load 从 fsys 的根目录加载所有的迁移文件，按照版本号升序返回
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		segs := fileNamePattern.FindStringSubmatch(entry.Name())
+		if segs == nil {
+			return nil, fmt.Errorf("migrator: invalid migration file name %q", entry.Name())
+		}
+		version, err := strconv.ParseInt(segs[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: segs[2]}
+			byVersion[version] = m
+		}
+		if m.Name != segs[2] {
+			return nil, fmt.Errorf("migrator: version %d has two names %q and %q", version, m.Name, segs[2])
+		}
+		if segs[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+	res := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if strings.TrimSpace(m.Up) == "" {
+			return nil, fmt.Errorf("migrator: version %d has no up migration", m.Version)
+		}
+		res = append(res, *m)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Version < res[j].Version
+	})
+	return res, nil
+}
+
+// splitStatements 把一个文件拆分成多条语句，MySQL 驱动默认不允许一次执行多条语句。
+// 语句以行尾的分号结束，-- 开头的注释行会被忽略
+func splitStatements(sql string) []string {
+	var (
+		res []string
+		cur strings.Builder
+	)
+	for _, line := range strings.Split(sql, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		cur.WriteString(line)
+		cur.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			res = append(res, strings.TrimSpace(cur.String()))
+			cur.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(cur.String()); rest != "" {
+		res = append(res, rest)
+	}
+	return res
+}
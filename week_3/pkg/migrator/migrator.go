@@ -0,0 +1,191 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"io/fs"
+	"time"
+)
+
+// ErrNothingToRollback 没有任何已经执行过的迁移
+var ErrNothingToRollback = errors.New("migrator: nothing to rollback")
+
+// Status 一个迁移的执行状态
+type Status struct {
+	Version int64
+	Name    string
+	// 没有执行过的迁移为 nil
+	AppliedAt *time.Time
+}
+
+// schemaVersion 记录已经执行过的迁移
+type schemaVersion struct {
+	Version   int64 `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt int64
+}
+
+// Migrator 按照版本号顺序执行迁移，已经执行的版本记录在 schema_migrations 表中。
+// 执行迁移之前会先获取数据库级别的锁，多个副本同时启动的时候只有一个副本会真正执行迁移，
+// 其余副本等锁释放之后发现已经是最新版本，直接返回
+type Migrator struct {
+	db          *gorm.DB
+	migrations  []Migration
+	table       string
+	lockName    string
+	lockTimeout time.Duration
+}
+
+// New 从 fsys 加载迁移文件并创建 Migrator
+func New(db *gorm.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		db:          db,
+		migrations:  migrations,
+		table:       "schema_migrations",
+		lockName:    "webook:schema_migrations",
+		lockTimeout: time.Minute,
+	}, nil
+}
+
+// LockTimeout 设置等待迁移锁的最长时间
+func (m *Migrator) LockTimeout(timeout time.Duration) *Migrator {
+	m.lockTimeout = timeout
+	return m
+}
+
+// Up 执行所有还没有执行的迁移
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *gorm.DB) error {
+		applied, err := m.applied(conn)
+		if err != nil {
+			return err
+		}
+		for _, mg := range m.migrations {
+			if _, ok := applied[mg.Version]; ok {
+				continue
+			}
+			if err = m.apply(conn, mg); err != nil {
+				return fmt.Errorf("migrator: apply %04d_%s: %w", mg.Version, mg.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Down 回滚最近执行的一个迁移
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *gorm.DB) error {
+		var last schemaVersion
+		err := conn.Table(m.table).Order("version DESC").First(&last).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNothingToRollback
+		}
+		if err != nil {
+			return err
+		}
+		for _, mg := range m.migrations {
+			if mg.Version == last.Version {
+				if err = m.rollback(conn, mg); err != nil {
+					return fmt.Errorf("migrator: rollback %04d_%s: %w", mg.Version, mg.Name, err)
+				}
+				return nil
+			}
+		}
+		return fmt.Errorf("migrator: version %d is applied but its migration file is missing", last.Version)
+	})
+}
+
+// Status 返回所有迁移的执行状态
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	conn := m.db.WithContext(ctx)
+	if err := m.ensureTable(conn); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(conn)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]Status, 0, len(m.migrations))
+	for _, mg := range m.migrations {
+		st := Status{Version: mg.Version, Name: mg.Name}
+		if sv, ok := applied[mg.Version]; ok {
+			t := time.UnixMilli(sv.AppliedAt)
+			st.AppliedAt = &t
+		}
+		res = append(res, st)
+	}
+	return res, nil
+}
+
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *gorm.DB) error) error {
+	return m.db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		unlock, err := lock(conn, m.lockName, m.lockTimeout)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = unlock()
+		}()
+		if err = m.ensureTable(conn); err != nil {
+			return err
+		}
+		return fn(conn)
+	})
+}
+
+func (m *Migrator) ensureTable(conn *gorm.DB) error {
+	// 这条语句在 MySQL、Postgres 和 sqlite 上都可以执行
+	return conn.Exec("CREATE TABLE IF NOT EXISTS " + m.table + " (" +
+		"version BIGINT NOT NULL PRIMARY KEY, " +
+		"name VARCHAR(255) NOT NULL, " +
+		"applied_at BIGINT NOT NULL)").Error
+}
+
+func (m *Migrator) applied(conn *gorm.DB) (map[int64]schemaVersion, error) {
+	var versions []schemaVersion
+	if err := conn.Table(m.table).Find(&versions).Error; err != nil {
+		return nil, err
+	}
+	res := make(map[int64]schemaVersion, len(versions))
+	for _, v := range versions {
+		res[v.Version] = v
+	}
+	return res, nil
+}
+
+// apply 执行一个迁移并记录版本。
+// MySQL 的 DDL 会隐式提交事务，所以这里的事务只在 Postgres 和 sqlite 上能保证原子性
+func (m *Migrator) apply(conn *gorm.DB, mg Migration) error {
+	return conn.Transaction(func(tx *gorm.DB) error {
+		for _, stmt := range splitStatements(mg.Up) {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Table(m.table).Create(&schemaVersion{
+			Version:   mg.Version,
+			Name:      mg.Name,
+			AppliedAt: time.Now().UnixMilli(),
+		}).Error
+	})
+}
+
+func (m *Migrator) rollback(conn *gorm.DB, mg Migration) error {
+	if mg.Down == "" {
+		return fmt.Errorf("version %d has no down migration", mg.Version)
+	}
+	return conn.Transaction(func(tx *gorm.DB) error {
+		for _, stmt := range splitStatements(mg.Down) {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Table(m.table).Where("version = ?", mg.Version).Delete(&schemaVersion{}).Error
+	})
+}
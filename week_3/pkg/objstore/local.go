@@ -0,0 +1,75 @@
+package objstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStorage 把对象保存在本地目录，通过 baseURL 对外提供访问，
+// 需要配合 gin 的 Static 之类的静态文件服务使用
+type LocalStorage struct {
+	dir     string
+	baseURL string
+}
+
+func NewLocalStorage(dir string, baseURL string) *LocalStorage {
+	return &LocalStorage{dir: dir, baseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+func (s *LocalStorage) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) (string, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return "", err
+	}
+	if err = os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return "", err
+	}
+	// 先写临时文件再重命名，避免读到写了一半的文件
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, io.LimitReader(r, size))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+	if err = os.Rename(tmp.Name(), p); err != nil {
+		return "", err
+	}
+	return s.baseURL + "/" + path.Clean(key), nil
+}
+
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *LocalStorage) Key(url string) (string, bool) {
+	key, ok := strings.CutPrefix(url, s.baseURL+"/")
+	return key, ok && key != ""
+}
+
+// path 把 key 转换成本地路径，拒绝 ../ 之类跳出根目录的 key
+func (s *LocalStorage) path(key string) (string, error) {
+	if !fs.ValidPath(key) {
+		return "", errors.New("objstore: invalid key " + key)
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
@@ -0,0 +1,18 @@
+// Package objstore 对象存储的抽象，本地开发使用 LocalStorage，
+// 线上可以换成 OSS、S3 之类的实现
+package objstore
+
+import (
+	"context"
+	"io"
+)
+
+// Storage 对象存储
+type Storage interface {
+	// Put 保存对象并返回可以公开访问的 URL
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) (string, error)
+	// Delete 删除对象，对象不存在的时候不返回错误
+	Delete(ctx context.Context, key string) error
+	// Key 根据 Put 返回的 URL 得到对象的 key，不是这个存储保存的对象时返回 false
+	Key(url string) (string, bool)
+}
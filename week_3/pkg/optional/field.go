@@ -0,0 +1,48 @@
+// Package optional 用于区分 JSON 请求里面“没有传”和“传了 null”两种情况，
+// 实现 PATCH 语义：没有传表示不修改，传 null 表示清空，传值表示修改成这个值
+package optional
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Field 一个可选的 JSON 字段
+type Field[T any] struct {
+	// 请求里面出现了这个字段，包括值为 null 的情况
+	Set bool
+	// 请求里面这个字段的值是 null
+	Null bool
+	// 字段的值，Null 为 true 的时候是零值
+	Value T
+}
+
+// Of 创建一个有值的 Field
+func Of[T any](val T) Field[T] {
+	return Field[T]{Set: true, Value: val}
+}
+
+// UnmarshalJSON 只有字段出现在 JSON 里面的时候才会被调用，所以能区分没有传和传了 null
+func (f *Field[T]) UnmarshalJSON(data []byte) error {
+	f.Set = true
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		f.Null = true
+		var zero T
+		f.Value = zero
+		return nil
+	}
+	f.Null = false
+	return json.Unmarshal(data, &f.Value)
+}
+
+func (f Field[T]) MarshalJSON() ([]byte, error) {
+	if !f.Set || f.Null {
+		return []byte("null"), nil
+	}
+	return json.Marshal(f.Value)
+}
+
+// HasValue 字段传了非 null 的值
+func (f Field[T]) HasValue() bool {
+	return f.Set && !f.Null
+}
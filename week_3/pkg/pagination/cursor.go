@@ -0,0 +1,74 @@
+// Package pagination 基于游标的分页。游标是上一页最后一条记录的排序键，
+// 编码成不透明的字符串交给前端，前端原样带回来就能取下一页。
+// 和 offset 分页相比，翻页的时候有新的数据插入也不会重复或者遗漏，而且深度翻页不会变慢
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// ErrInvalidCursor 游标不是 EncodeCursor 生成的，或者已经被篡改
+var ErrInvalidCursor = errors.New("pagination: invalid cursor")
+
+// Page 一页数据，NextCursor 为空表示没有下一页了
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"nextCursor"`
+}
+
+// EncodeCursor 把排序键编码成游标，v 需要可以被 JSON 序列化
+func EncodeCursor(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecodeCursor 把游标解码到 v 中，cursor 为空的时候表示第一页，v 保持不变
+func DecodeCursor(cursor string, v any) error {
+	if cursor == "" {
+		return nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return ErrInvalidCursor
+	}
+	if err = json.Unmarshal(data, v); err != nil {
+		return ErrInvalidCursor
+	}
+	return nil
+}
+
+// Limit 规范化每页的条数，没有传的时候使用 def，超过 max 的时候使用 max
+func Limit(limit, def, max int) int {
+	if limit <= 0 {
+		return def
+	}
+	return min(limit, max)
+}
+
+// NewPage 根据查询结果构造一页数据。查询的时候需要多取一条（limit + 1），
+// 多出来的那一条说明还有下一页，用这一页最后一条记录的 cursorOf 生成 NextCursor
+func NewPage[T any](items []T, limit int, cursorOf func(last T) any) (Page[T], error) {
+	if len(items) <= limit {
+		return Page[T]{Items: items}, nil
+	}
+	items = items[:limit]
+	next, err := EncodeCursor(cursorOf(items[limit-1]))
+	if err != nil {
+		return Page[T]{}, err
+	}
+	return Page[T]{Items: items, NextCursor: next}, nil
+}
+
+// Map 转换一页数据里面的元素，游标保持不变，一般用于把领域对象转换成响应结构
+func Map[T, R any](p Page[T], fn func(T) R) Page[R] {
+	items := make([]R, 0, len(p.Items))
+	for _, item := range p.Items {
+		items = append(items, fn(item))
+	}
+	return Page[R]{Items: items, NextCursor: p.NextCursor}
+}
@@ -0,0 +1,101 @@
+package pwdpolicy
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// 和 Have I Been Pwned 的 range 接口一致，使用 SHA-1 的前 5 个十六进制字符作为查询前缀
+const prefixLen = 5
+
+// RangeSource 按照哈希前缀返回泄露库中的一段数据。
+// 每一行的格式是 SUFFIX:COUNT，SUFFIX 是 SHA-1 去掉前缀之后的 35 个十六进制字符。
+// 调用方只会把前缀交给数据源，完整的哈希不会离开本进程，这就是 k-anonymity 的查询方式。
+type RangeSource interface {
+	Range(ctx context.Context, prefix string) (io.ReadCloser, error)
+}
+
+// DirRangeSource 从本地目录读取泄露库，目录下每个前缀一个文件，文件名就是大写的前缀，
+// 例如 5BAA6，和 HIBP 官方下载工具导出的结构一致
+type DirRangeSource struct {
+	dir string
+}
+
+func NewDirRangeSource(dir string) *DirRangeSource {
+	return &DirRangeSource{dir: dir}
+}
+
+// Validate 检查目录存在并且不为空。Range 把不存在的前缀文件当作没有泄露，
+// 目录没有挂载的时候所有密码都会被当成没有泄露，所以启动的时候需要先确认一次
+func (s *DirRangeSource) Validate() error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return fmt.Errorf("pwdpolicy: read breach corpus dir: %w", err)
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("pwdpolicy: breach corpus dir %s is empty", s.dir)
+	}
+	return nil
+}
+
+func (s *DirRangeSource) Range(ctx context.Context, prefix string) (io.ReadCloser, error) {
+	f, err := os.Open(filepath.Join(s.dir, prefix))
+	if errors.Is(err, fs.ErrNotExist) {
+		// 没有这个前缀的文件，说明没有任何泄露的密码落在这个区间
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+	return f, err
+}
+
+// BreachChecker 检查密码是否出现在泄露库中
+type BreachChecker struct {
+	source RangeSource
+	// 出现次数达到这个值才认为是泄露的密码
+	minCount int
+}
+
+func NewBreachChecker(source RangeSource, minCount int) *BreachChecker {
+	if minCount < 1 {
+		minCount = 1
+	}
+	return &BreachChecker{source: source, minCount: minCount}
+}
+
+func (c *BreachChecker) IsBreached(ctx context.Context, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:prefixLen], hash[prefixLen:]
+	rc, err := c.source.Range(ctx, prefix)
+	if err != nil {
+		return false, err
+	}
+	defer rc.Close()
+	scanner := bufio.NewScanner(rc)
+	for scanner.Scan() {
+		s, cnt, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !strings.EqualFold(s, suffix) {
+			continue
+		}
+		return parseCount(cnt) >= c.minCount, nil
+	}
+	return false, scanner.Err()
+}
+
+// parseCount 解析出现次数，没有次数或者格式不对的行当作出现过一次
+func parseCount(s string) int {
+	cnt, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return 1
+	}
+	return cnt
+}
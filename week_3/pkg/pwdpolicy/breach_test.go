@@ -0,0 +1,126 @@
+package pwdpolicy
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeRange 把 password 以 HIBP 的格式写进 dir 下前缀对应的文件，出现次数是 count
+func writeRange(t *testing.T, dir, password string, count int) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	f, err := os.OpenFile(filepath.Join(dir, hash[:prefixLen]), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = fmt.Fprintf(f, "%s:%d\r\n", hash[prefixLen:], count)
+	require.NoError(t, err)
+}
+
+// stubSource 只返回固定的内容，同时记录查询的前缀
+type stubSource struct {
+	data   string
+	err    error
+	prefix string
+}
+
+func (s *stubSource) Range(ctx context.Context, prefix string) (io.ReadCloser, error) {
+	s.prefix = prefix
+	if s.err != nil {
+		return nil, s.err
+	}
+	return io.NopCloser(strings.NewReader(s.data)), nil
+}
+
+func TestBreachChecker_IsBreached(t *testing.T) {
+	// SHA-1("password") = 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8
+	const suffix = "1E4C9B93F3F0682250B6CF8331B7EE68FD8"
+	testCases := []struct {
+		name     string
+		data     string
+		minCount int
+		want     bool
+	}{
+		{
+			name:     "命中",
+			data:     "0018A45C4D1DEF81644B54AB7F969B88D65:1\r\n" + suffix + ":3861493\r\n",
+			minCount: 1,
+			want:     true,
+		},
+		{
+			name:     "后缀小写",
+			data:     strings.ToLower(suffix) + ":2",
+			minCount: 1,
+			want:     true,
+		},
+		{
+			name:     "次数不够",
+			data:     suffix + ":2",
+			minCount: 3,
+		},
+		{
+			name:     "没有次数当作出现过一次",
+			data:     suffix,
+			minCount: 1,
+			want:     true,
+		},
+		{
+			name:     "次数格式不对当作出现过一次",
+			data:     suffix + ":abc",
+			minCount: 2,
+		},
+		{
+			name:     "没有命中",
+			data:     "0018A45C4D1DEF81644B54AB7F969B88D65:1\n",
+			minCount: 1,
+		},
+		{
+			name:     "空数据",
+			minCount: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			source := &stubSource{data: tc.data}
+			got, err := NewBreachChecker(source, tc.minCount).IsBreached(context.Background(), "password")
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+			// 只有前缀交给数据源
+			assert.Equal(t, "5BAA6", source.prefix)
+		})
+	}
+}
+
+func TestBreachChecker_SourceError(t *testing.T) {
+	wantErr := errors.New("io error")
+	_, err := NewBreachChecker(&stubSource{err: wantErr}, 1).IsBreached(context.Background(), "password")
+	assert.ErrorIs(t, err, wantErr)
+}
+
+func TestDirRangeSource(t *testing.T) {
+	dir := t.TempDir()
+	// 空目录说明泄露库没有准备好
+	assert.Error(t, NewDirRangeSource(dir).Validate())
+	assert.Error(t, NewDirRangeSource(filepath.Join(dir, "missing")).Validate())
+
+	writeRange(t, dir, "password", 5)
+	source := NewDirRangeSource(dir)
+	require.NoError(t, source.Validate())
+	checker := NewBreachChecker(source, 1)
+	breached, err := checker.IsBreached(context.Background(), "password")
+	require.NoError(t, err)
+	assert.True(t, breached)
+	// 没有对应前缀的文件，说明这个区间没有泄露的密码
+	breached, err = checker.IsBreached(context.Background(), "Kq7#vLp2!xZr")
+	require.NoError(t, err)
+	assert.False(t, breached)
+}
@@ -0,0 +1,112 @@
+package pwdpolicy
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrCommonPassword 密码出现在常见密码列表中
+	ErrCommonPassword = errors.New("pwdpolicy: password is too common")
+	// ErrPersonalInfo 密码中包含了用户自己的邮箱或者昵称
+	ErrPersonalInfo = errors.New("pwdpolicy: password contains personal information")
+	// ErrBreachedPassword 密码出现在已泄露的密码库中
+	ErrBreachedPassword = errors.New("pwdpolicy: password has appeared in a data breach")
+)
+
+//go:embed common_passwords.txt
+var commonPasswords string
+
+// 邮箱前缀和昵称太短的时候不做包含检测，不然误伤太多
+const minPersonalInfoLen = 3
+
+// UserInfo 校验密码时需要参考的用户信息
+type UserInfo struct {
+	Email    string
+	Nickname string
+}
+
+// Policy 密码策略，只关心密码是否容易被猜到。
+// 字符种类、长度这一类的格式要求由请求校验负责。
+type Policy struct {
+	common map[string]struct{}
+	// 为 nil 的时候不检查泄露库
+	breach *BreachChecker
+}
+
+// NewPolicy 创建一个 Policy，breach 可以为 nil
+func NewPolicy(breach *BreachChecker) *Policy {
+	common := make(map[string]struct{})
+	scanner := bufio.NewScanner(strings.NewReader(commonPasswords))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		common[strings.ToLower(line)] = struct{}{}
+	}
+	return &Policy{common: common, breach: breach}
+}
+
+// Check 按照 常见密码 -> 个人信息 -> 泄露库 的顺序检查密码，返回第一个不满足的错误
+func (p *Policy) Check(ctx context.Context, password string, info UserInfo) error {
+	if p.isCommon(password) {
+		return ErrCommonPassword
+	}
+	if p.containsPersonalInfo(password, info) {
+		return ErrPersonalInfo
+	}
+	if p.breach == nil {
+		return nil
+	}
+	breached, err := p.breach.IsBreached(ctx, password)
+	if err != nil {
+		return err
+	}
+	if breached {
+		return ErrBreachedPassword
+	}
+	return nil
+}
+
+// isCommon 除了原样比较，还会去掉首尾的数字、符号并且还原常见的字符替换之后再比较，
+// 这样 Password1!、P@ssw0rd123 之类的变体也能识别出来
+func (p *Policy) isCommon(password string) bool {
+	lower := strings.ToLower(password)
+	if _, ok := p.common[lower]; ok {
+		return true
+	}
+	core := strings.TrimFunc(lower, func(r rune) bool {
+		return r < 'a' || r > 'z'
+	})
+	for _, candidate := range []string{core, unleet(core)} {
+		if len(candidate) < minPersonalInfoLen {
+			continue
+		}
+		if _, ok := p.common[candidate]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Policy) containsPersonalInfo(password string, info UserInfo) bool {
+	lower := strings.ToLower(password)
+	local, _, _ := strings.Cut(strings.ToLower(info.Email), "@")
+	for _, s := range []string{local, strings.ToLower(info.Nickname)} {
+		s = strings.TrimSpace(s)
+		if len(s) >= minPersonalInfoLen && strings.Contains(lower, s) {
+			return true
+		}
+	}
+	return false
+}
+
+var leetReplacer = strings.NewReplacer("@", "a", "4", "a", "0", "o", "1", "i", "3", "e", "$", "s", "5", "s", "7", "t")
+
+func unleet(s string) string {
+	return leetReplacer.Replace(s)
+}
@@ -0,0 +1,81 @@
+package pwdpolicy
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPolicy_Check(t *testing.T) {
+	testCases := []struct {
+		name     string
+		password string
+		info     UserInfo
+		wantErr  error
+	}{
+		{
+			name:     "列表中的原样密码",
+			password: "qwerty",
+			wantErr:  ErrCommonPassword,
+		},
+		{
+			name:     "忽略大小写",
+			password: "DRAGON",
+			wantErr:  ErrCommonPassword,
+		},
+		{
+			name:     "首尾加了数字和符号",
+			password: "Password1!",
+			wantErr:  ErrCommonPassword,
+		},
+		{
+			name:     "常见的字符替换",
+			password: "P@ssw0rd123",
+			wantErr:  ErrCommonPassword,
+		},
+		{
+			name:     "包含邮箱前缀",
+			password: "Xx#tomcat2024",
+			info:     UserInfo{Email: "TomCat@qq.com"},
+			wantErr:  ErrPersonalInfo,
+		},
+		{
+			name:     "包含昵称",
+			password: "k9!SnowFox#2",
+			info:     UserInfo{Email: "a@qq.com", Nickname: "snowfox"},
+			wantErr:  ErrPersonalInfo,
+		},
+		{
+			name:     "邮箱前缀太短不检查",
+			password: "Kq7#vLp2!xZr",
+			info:     UserInfo{Email: "kq@qq.com", Nickname: "vL"},
+		},
+		{
+			name:     "通过",
+			password: "Kq7#vLp2!xZr",
+			info:     UserInfo{Email: "tom@qq.com", Nickname: "Tom"},
+		},
+	}
+	p := NewPolicy(nil)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := p.Check(context.Background(), tc.password, tc.info)
+			assert.ErrorIs(t, err, tc.wantErr)
+			if tc.wantErr == nil {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestPolicy_CheckBreach(t *testing.T) {
+	dir := t.TempDir()
+	writeRange(t, dir, "Kq7#vLp2!xZr", 3)
+	p := NewPolicy(NewBreachChecker(NewDirRangeSource(dir), 1))
+	ctx := context.Background()
+
+	assert.ErrorIs(t, p.Check(ctx, "Kq7#vLp2!xZr", UserInfo{}), ErrBreachedPassword)
+	assert.NoError(t, p.Check(ctx, "Zr8$wMq3@yTn", UserInfo{}))
+	// 常见密码在查询泄露库之前就被拒绝
+	assert.ErrorIs(t, p.Check(ctx, "password", UserInfo{}), ErrCommonPassword)
+}
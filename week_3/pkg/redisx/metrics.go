@@ -0,0 +1,68 @@
+// Package redisx go-redis 相关的扩展
+package redisx
+
+import (
+	"context"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+	"net"
+	"time"
+)
+
+// MetricsHook 记录 Redis 命令的 Prometheus 指标：
+//   - <namespace>_redis_command_duration_seconds: 直方图，命令的执行时间，
+//     label 为 command（命令名，例如 get、evalsha，pipeline 记为 pipeline）和
+//     status（ok、nil 表示 key 不存在、error）
+type MetricsHook struct {
+	duration *prometheus.HistogramVec
+}
+
+// NewMetricsHook 创建并注册指标，通过 redis.Client.AddHook 使用
+func NewMetricsHook(namespace string, registerer prometheus.Registerer) *MetricsHook {
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "redis",
+		Name:      "command_duration_seconds",
+		Help:      "Redis 命令的执行时间",
+		// Redis 命令一般在毫秒级别
+		Buckets: []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1},
+	}, []string{"command", "status"})
+	registerer.MustRegister(duration)
+	return &MetricsHook{duration: duration}
+}
+
+func (h *MetricsHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (h *MetricsHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		h.duration.WithLabelValues(cmd.Name(), status(err)).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+func (h *MetricsHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		h.duration.WithLabelValues("pipeline", status(err)).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+func status(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, redis.Nil):
+		return "nil"
+	default:
+		return "error"
+	}
+}
@@ -0,0 +1,69 @@
+package redisx
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"net"
+)
+
+const instrumentationName = "go_homework/week_3/pkg/redisx"
+
+// TracingHook 为每一个 Redis 命令创建一个 client span，span 的名字为 redis.<命令名>，
+// 例如限流脚本是 redis.evalsha 或者 redis.eval。key 不存在（redis.Nil）不算错误
+type TracingHook struct {
+	tracer trace.Tracer
+}
+
+// NewTracingHook 使用 otel 全局的 TracerProvider，通过 redis.Client.AddHook 使用
+func NewTracingHook() *TracingHook {
+	return &TracingHook{tracer: otel.Tracer(instrumentationName)}
+}
+
+func (h *TracingHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (h *TracingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		ctx, span := h.tracer.Start(ctx, "redis."+cmd.Name(),
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String("db.system", "redis"),
+				attribute.String("db.operation.name", cmd.Name()),
+			))
+		defer span.End()
+		err := next(ctx, cmd)
+		record(span, err)
+		return err
+	}
+}
+
+func (h *TracingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		ctx, span := h.tracer.Start(ctx, "redis.pipeline",
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String("db.system", "redis"),
+				attribute.Int("db.operation.batch.size", len(cmds)),
+			))
+		defer span.End()
+		err := next(ctx, cmds)
+		record(span, err)
+		return err
+	}
+}
+
+func record(span trace.Span, err error) {
+	if err == nil || errors.Is(err, redis.Nil) {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,77 @@
+package redisx
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"testing"
+)
+
+func TestTracingHook(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	old := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(old) })
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	client.AddHook(NewTracingHook())
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "parent")
+	require.NoError(t, client.Set(ctx, "key", "val", 0).Err())
+	// key 不存在不算错误
+	assert.ErrorIs(t, client.Get(ctx, "missing").Err(), redis.Nil)
+	// 对字符串执行 HGET 出错
+	assert.Error(t, client.HGet(ctx, "key", "field").Err())
+	_, err := client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		p.Incr(ctx, "cnt")
+		p.Incr(ctx, "cnt")
+		return nil
+	})
+	require.NoError(t, err)
+	parent.End()
+
+	testCases := []struct {
+		name       string
+		wantOp     string
+		wantStatus codes.Code
+		wantBatch  int64
+	}{
+		{name: "redis.set", wantOp: "set"},
+		{name: "redis.get", wantOp: "get"},
+		{name: "redis.hget", wantOp: "hget", wantStatus: codes.Error},
+		{name: "redis.pipeline", wantBatch: 2},
+	}
+	// 跳过建立连接时的 hello 之类的命令，只看测试发出的命令
+	var spans []sdktrace.ReadOnlySpan
+	for _, s := range recorder.Ended() {
+		if s.Parent().SpanID() == parent.SpanContext().SpanID() {
+			spans = append(spans, s)
+		}
+	}
+	require.Len(t, spans, len(testCases))
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			span := spans[i]
+			assert.Equal(t, tc.name, span.Name())
+			assert.Equal(t, trace.SpanKindClient, span.SpanKind())
+			assert.Equal(t, tc.wantStatus, span.Status().Code)
+			attrs := attribute.NewSet(span.Attributes()...)
+			system, _ := attrs.Value("db.system")
+			assert.Equal(t, "redis", system.AsString())
+			op, _ := attrs.Value("db.operation.name")
+			assert.Equal(t, tc.wantOp, op.AsString())
+			batch, _ := attrs.Value("db.operation.batch.size")
+			assert.Equal(t, tc.wantBatch, batch.AsInt64())
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package rlock
+
+import (
+	"context"
+	"crypto/rand"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+var (
+	// ErrFailedToPreemptLock 锁被别人持有
+	ErrFailedToPreemptLock = errors.New("rlock: failed to preempt lock")
+	// ErrLockNotHold 锁已经过期，或者已经被别人持有
+	ErrLockNotHold = errors.New("rlock: lock not hold")
+)
+
+// 续约和释放锁的超时时间，Do 里面 fn 的 ctx 可能已经超时或者被取消，需要单独的 ctx
+const lockOpTimeout = time.Second
+
+var (
+	//go:embed lua/lock.lua
+	luaLock string
+	//go:embed lua/unlock.lua
+	luaUnlock string
+	//go:embed lua/refresh.lua
+	luaRefresh string
+)
+
+// Client 基于 Redis 的分布式锁。加锁的时候写入一个随机值，
+// 续约和释放的时候用 Lua 脚本检查这个值，保证只会操作自己加的锁
+type Client struct {
+	cmd redis.Cmdable
+}
+
+func NewClient(cmd redis.Cmdable) *Client {
+	return &Client{cmd: cmd}
+}
+
+// TryLock 尝试加锁一次，锁被别人持有的时候返回 ErrFailedToPreemptLock。
+// expiration 是锁的过期时间，持有锁的时间可能超过 expiration 的时候需要调用 Lock.Refresh 续约
+func (c *Client) TryLock(ctx context.Context, key string, expiration time.Duration) (*Lock, error) {
+	return c.Lock(ctx, key, expiration, nil)
+}
+
+// Lock 加锁，失败的时候按照 retry 重试，retry 为 nil 的时候不重试。
+// 锁被别人持有和网络错误都会重试，重试使用同一个值，超时但是其实加锁成功的情况下重试也能拿到锁。
+// 重试次数用完之后返回最后一次的错误，锁被别人持有的时候是 ErrFailedToPreemptLock，ctx 结束的时候返回 ctx 的错误
+func (c *Client) Lock(ctx context.Context, key string, expiration time.Duration,
+	retry RetryStrategy) (*Lock, error) {
+	val := newValue()
+	for retries := 0; ; retries++ {
+		ok, err := c.cmd.Eval(ctx, luaLock, []string{key}, val, expiration.Milliseconds()).Bool()
+		if err == nil && ok {
+			return &Lock{cmd: c.cmd, key: key, value: val, expiration: expiration}, nil
+		}
+		if err == nil {
+			err = ErrFailedToPreemptLock
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if retry == nil {
+			return nil, err
+		}
+		interval, ok := retry.Next(retries)
+		if !ok {
+			return nil, err
+		}
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Do 在锁的保护下执行 fn，多个实例中同一时间只有一个实例在执行。加锁的方式和 Lock 一样，加锁失败的时候不执行 fn。
+// fn 执行期间每隔 expiration/3 在后台续约一次，续约失败的时候取消 fn 的 ctx，避免和拿到锁的实例同时执行，
+// 这时返回的错误中包含续约的错误。fn 返回之后释放锁，释放失败的时候返回的错误中包含释放的错误，
+// 锁会在过期之后自动释放
+func (c *Client) Do(ctx context.Context, key string, expiration time.Duration, retry RetryStrategy,
+	fn func(ctx context.Context) error) error {
+	lock, err := c.Lock(ctx, key, expiration, retry)
+	if err != nil {
+		return err
+	}
+	fnCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// 续约不跟着 ctx 取消，fn 返回之前锁一直是自己的
+	refreshCtx, stopRefresh := context.WithCancel(context.WithoutCancel(ctx))
+	refreshed := make(chan error, 1)
+	go func() {
+		err := lock.AutoRefresh(refreshCtx, expiration/3, lockOpTimeout)
+		if err != nil {
+			cancel()
+		}
+		refreshed <- err
+	}()
+	err = fn(fnCtx)
+	// 先停止续约再释放锁，避免释放之后又续约
+	stopRefresh()
+	if rerr := <-refreshed; rerr != nil {
+		err = errors.Join(err, rerr)
+	}
+	uctx, ucancel := context.WithTimeout(context.WithoutCancel(ctx), lockOpTimeout)
+	defer ucancel()
+	if uerr := lock.Unlock(uctx); uerr != nil {
+		err = errors.Join(err, uerr)
+	}
+	return err
+}
+
+// Lock 已经加上的锁
+type Lock struct {
+	cmd        redis.Cmdable
+	key        string
+	value      string
+	expiration time.Duration
+}
+
+// Key 锁的 key
+func (l *Lock) Key() string {
+	return l.key
+}
+
+// Refresh 续约，把过期时间重新设置为加锁时的 expiration。锁已经不是自己的时候返回 ErrLockNotHold
+func (l *Lock) Refresh(ctx context.Context) error {
+	res, err := l.cmd.Eval(ctx, luaRefresh, []string{l.key}, l.value, l.expiration.Milliseconds()).Int64()
+	if err != nil {
+		return err
+	}
+	if res != 1 {
+		return ErrLockNotHold
+	}
+	return nil
+}
+
+// AutoRefresh 每隔 interval 续约一次，每次续约的超时时间是 timeout。
+// 阻塞到 ctx 结束的时候返回 nil，续约失败的时候返回续约的错误，这时锁可能已经被别人拿到了
+func (l *Lock) AutoRefresh(ctx context.Context, interval, timeout time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			rctx, cancel := context.WithTimeout(ctx, timeout)
+			err := l.Refresh(rctx)
+			cancel()
+			if err != nil && ctx.Err() == nil {
+				return err
+			}
+		}
+	}
+}
+
+// Unlock 释放锁。锁已经过期或者不是自己的时候返回 ErrLockNotHold
+func (l *Lock) Unlock(ctx context.Context) error {
+	res, err := l.cmd.Eval(ctx, luaUnlock, []string{l.key}, l.value).Int64()
+	if err != nil {
+		return err
+	}
+	if res != 1 {
+		return ErrLockNotHold
+	}
+	return nil
+}
+
+// newValue 生成锁的值，用来区分不同实例、不同次加的锁
+func newValue() string {
+	b := make([]byte, 16)
+	// crypto/rand 在 Linux 上不会失败
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,330 @@
+package rlock
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestClient(t *testing.T) (*Client, *miniredis.Miniredis, *redis.Client) {
+	mr := miniredis.RunT(t)
+	rc := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = rc.Close() })
+	return NewClient(rc), mr, rc
+}
+
+func TestClient_TryLock(t *testing.T) {
+	testCases := []struct {
+		name    string
+		before  func(mr *miniredis.Miniredis)
+		wantErr error
+	}{
+		{
+			name:   "free lock",
+			before: func(mr *miniredis.Miniredis) {},
+		},
+		{
+			name: "held by another value",
+			before: func(mr *miniredis.Miniredis) {
+				require.NoError(t, mr.Set("lock", "other"))
+			},
+			wantErr: ErrFailedToPreemptLock,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, mr, _ := newTestClient(t)
+			tc.before(mr)
+			l, err := c.TryLock(context.Background(), "lock", time.Minute)
+			assert.ErrorIs(t, err, tc.wantErr)
+			if err != nil {
+				val, _ := mr.Get("lock")
+				assert.Equal(t, "other", val)
+				return
+			}
+			assert.Equal(t, "lock", l.Key())
+			val, _ := mr.Get("lock")
+			assert.Equal(t, l.value, val)
+			assert.Equal(t, time.Minute, mr.TTL("lock"))
+		})
+	}
+}
+
+// countingRetry 记录重试策略被询问的次数
+type countingRetry struct {
+	RetryStrategy
+	calls int
+}
+
+func (r *countingRetry) Next(retries int) (time.Duration, bool) {
+	r.calls++
+	return r.RetryStrategy.Next(retries)
+}
+
+func TestClient_Lock_GiveUp(t *testing.T) {
+	testCases := []struct {
+		name  string
+		retry RetryStrategy
+		// 加锁一共尝试的次数
+		wantAttempts int
+	}{
+		{
+			name:         "no retry",
+			wantAttempts: 1,
+		},
+		{
+			name:         "fixed interval",
+			retry:        NewFixedIntervalRetry(time.Millisecond, 3),
+			wantAttempts: 4,
+		},
+		{
+			name:         "exponential backoff",
+			retry:        NewExponentialBackoffRetry(time.Millisecond, 4*time.Millisecond, 5),
+			wantAttempts: 6,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, mr, rc := newTestClient(t)
+			require.NoError(t, mr.Set("lock", "other"))
+			hook := &evalHook{}
+			rc.AddHook(hook)
+			var retry RetryStrategy
+			counting := &countingRetry{RetryStrategy: tc.retry}
+			if tc.retry != nil {
+				retry = counting
+			}
+			_, err := c.Lock(context.Background(), "lock", time.Minute, retry)
+			assert.ErrorIs(t, err, ErrFailedToPreemptLock)
+			assert.Equal(t, int32(tc.wantAttempts), hook.calls.Load())
+			if tc.retry != nil {
+				// 最后一次询问返回 false
+				assert.Equal(t, tc.wantAttempts, counting.calls)
+			}
+		})
+	}
+}
+
+func TestClient_Lock_RetryUntilReleased(t *testing.T) {
+	c, mr, _ := newTestClient(t)
+	require.NoError(t, mr.Set("lock", "other"))
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		mr.Del("lock")
+	}()
+	l, err := c.Lock(context.Background(), "lock", time.Minute, NewFixedIntervalRetry(10*time.Millisecond, 100))
+	require.NoError(t, err)
+	val, _ := mr.Get("lock")
+	assert.Equal(t, l.value, val)
+}
+
+func TestClient_Lock_ContextDone(t *testing.T) {
+	c, mr, _ := newTestClient(t)
+	require.NoError(t, mr.Set("lock", "other"))
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	_, err := c.Lock(ctx, "lock", time.Minute, NewFixedIntervalRetry(10*time.Millisecond, 1000))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+// TestClient_Lock_LostResponse 第一次加锁其实成功了，但是客户端没有收到响应。
+// 重试使用同一个值，lock.lua 发现值是自己的，加锁成功并重新设置过期时间
+func TestClient_Lock_LostResponse(t *testing.T) {
+	c, mr, rc := newTestClient(t)
+	hook := &evalHook{failFirst: true}
+	rc.AddHook(hook)
+	l, err := c.Lock(context.Background(), "lock", time.Minute, NewFixedIntervalRetry(time.Millisecond, 3))
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), hook.calls.Load())
+	val, _ := mr.Get("lock")
+	assert.Equal(t, l.value, val)
+	assert.Equal(t, time.Minute, mr.TTL("lock"))
+}
+
+func TestLock_RefreshAndUnlock(t *testing.T) {
+	testCases := []struct {
+		name string
+		// 加锁之后对 Redis 的修改
+		after   func(mr *miniredis.Miniredis)
+		wantErr error
+		// 操作之后 key 的值，空字符串表示 key 不存在
+		wantVal string
+	}{
+		{
+			name:  "hold",
+			after: func(mr *miniredis.Miniredis) {},
+		},
+		{
+			name: "expired",
+			after: func(mr *miniredis.Miniredis) {
+				mr.FastForward(2 * time.Second)
+			},
+			wantErr: ErrLockNotHold,
+		},
+		{
+			name: "taken over",
+			after: func(mr *miniredis.Miniredis) {
+				require.NoError(t, mr.Set("lock", "other"))
+			},
+			wantErr: ErrLockNotHold,
+			wantVal: "other",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, mr, _ := newTestClient(t)
+			ctx := context.Background()
+			l, err := c.TryLock(ctx, "lock", time.Second)
+			require.NoError(t, err)
+			tc.after(mr)
+			err = l.Refresh(ctx)
+			assert.ErrorIs(t, err, tc.wantErr)
+			if err == nil {
+				assert.Equal(t, time.Second, mr.TTL("lock"))
+			}
+			assert.ErrorIs(t, l.Unlock(ctx), tc.wantErr)
+			val, _ := mr.Get("lock")
+			assert.Equal(t, tc.wantVal, val)
+		})
+	}
+}
+
+func TestLock_AutoRefresh(t *testing.T) {
+	c, mr, _ := newTestClient(t)
+	l, err := c.TryLock(context.Background(), "lock", time.Second)
+	require.NoError(t, err)
+	// 模拟时间流逝，锁快要过期了
+	mr.SetTTL("lock", 10*time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = l.AutoRefresh(ctx, 20*time.Millisecond, time.Second)
+	// ctx 结束的时候正常返回
+	assert.NoError(t, err)
+	assert.Equal(t, time.Second, mr.TTL("lock"))
+}
+
+func TestLock_AutoRefresh_Lost(t *testing.T) {
+	c, mr, _ := newTestClient(t)
+	l, err := c.TryLock(context.Background(), "lock", time.Second)
+	require.NoError(t, err)
+	require.NoError(t, mr.Set("lock", "other"))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err = l.AutoRefresh(ctx, 10*time.Millisecond, time.Second)
+	assert.ErrorIs(t, err, ErrLockNotHold)
+	assert.NoError(t, ctx.Err())
+}
+
+func TestClient_Do(t *testing.T) {
+	c, mr, _ := newTestClient(t)
+	var called bool
+	err := c.Do(context.Background(), "lock", 60*time.Millisecond, nil, func(ctx context.Context) error {
+		called = true
+		// 执行时间超过了过期时间，期间一直在续约
+		time.Sleep(100 * time.Millisecond)
+		assert.True(t, mr.Exists("lock"))
+		return ctx.Err()
+	})
+	assert.NoError(t, err)
+	assert.True(t, called)
+	// 执行完之后释放锁
+	assert.False(t, mr.Exists("lock"))
+}
+
+func TestClient_Do_Held(t *testing.T) {
+	c, mr, _ := newTestClient(t)
+	require.NoError(t, mr.Set("lock", "other"))
+	err := c.Do(context.Background(), "lock", time.Second, nil, func(ctx context.Context) error {
+		t.Fatal("fn should not be called")
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrFailedToPreemptLock)
+}
+
+func TestClient_Do_FnError(t *testing.T) {
+	c, mr, _ := newTestClient(t)
+	wantErr := errors.New("fn failed")
+	err := c.Do(context.Background(), "lock", time.Second, nil, func(ctx context.Context) error {
+		return wantErr
+	})
+	assert.ErrorIs(t, err, wantErr)
+	assert.False(t, mr.Exists("lock"))
+}
+
+// TestClient_Do_RenewalFailed 锁被别人拿走之后续约失败，fn 的 ctx 被取消，返回的错误中包含续约和释放的错误
+func TestClient_Do_RenewalFailed(t *testing.T) {
+	c, mr, _ := newTestClient(t)
+	err := c.Do(context.Background(), "lock", 30*time.Millisecond, nil, func(ctx context.Context) error {
+		require.NoError(t, mr.Set("lock", "other"))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+			t.Error("ctx should be cancelled after renewal failed")
+			return nil
+		}
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, err, ErrLockNotHold)
+	// 不会删掉别人的锁
+	val, _ := mr.Get("lock")
+	assert.Equal(t, "other", val)
+}
+
+func TestFixedIntervalRetry_Next(t *testing.T) {
+	r := NewFixedIntervalRetry(10*time.Millisecond, 2)
+	for retries, wantOk := range []bool{true, true, false} {
+		d, ok := r.Next(retries)
+		assert.Equal(t, wantOk, ok)
+		if ok {
+			assert.Equal(t, 10*time.Millisecond, d)
+		}
+	}
+}
+
+func TestExponentialBackoffRetry_Next(t *testing.T) {
+	r := NewExponentialBackoffRetry(10*time.Millisecond, 50*time.Millisecond, 5)
+	want := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond,
+		50 * time.Millisecond, 50 * time.Millisecond}
+	for retries, wantD := range want {
+		d, ok := r.Next(retries)
+		assert.True(t, ok)
+		assert.Equal(t, wantD, d)
+	}
+	_, ok := r.Next(len(want))
+	assert.False(t, ok)
+}
+
+// evalHook 记录 EVAL 的次数，failFirst 为 true 的时候第一次 EVAL 执行成功但是返回错误，模拟响应丢失
+type evalHook struct {
+	calls     atomic.Int32
+	failFirst bool
+}
+
+func (h *evalHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h *evalHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if cmd.Name() != "eval" {
+			return next(ctx, cmd)
+		}
+		n := h.calls.Add(1)
+		err := next(ctx, cmd)
+		if h.failFirst && n == 1 {
+			return errors.New("i/o timeout")
+		}
+		return err
+	}
+}
+
+func (h *evalHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
@@ -0,0 +1,13 @@
+-- 加锁，key 不存在的时候写入加锁的值
+-- ARGV[1] 是加锁的值，ARGV[2] 是过期时间，单位毫秒
+-- 重试的时候使用同一个值，值已经是自己的说明上一次其实加锁成功了，只是没有收到响应，重新设置过期时间即可
+local val = redis.call('GET', KEYS[1])
+if val == false then
+    redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+    return 1
+elseif val == ARGV[1] then
+    redis.call('PEXPIRE', KEYS[1], ARGV[2])
+    return 1
+else
+    return 0
+end
@@ -0,0 +1,7 @@
+-- 续约，只有锁还是自己的时候才重新设置过期时间
+-- ARGV[1] 是加锁时写入的值，ARGV[2] 是新的过期时间，单位毫秒
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+    return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+else
+    return 0
+end
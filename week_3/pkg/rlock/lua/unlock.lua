@@ -0,0 +1,6 @@
+-- 释放锁，只有锁还是自己的时候才删除，避免锁过期之后删掉别人加的锁
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+    return redis.call('DEL', KEYS[1])
+else
+    return 0
+end
@@ -0,0 +1,45 @@
+package rlock
+
+import "time"
+
+// RetryStrategy 加锁失败之后的重试策略
+type RetryStrategy interface {
+	// Next 返回下一次重试之前需要等待的时间，retries 是已经重试过的次数。不再重试的时候返回 false
+	Next(retries int) (time.Duration, bool)
+}
+
+// FixedIntervalRetry 每次重试之前等待固定的时间，最多重试 maxRetries 次
+type FixedIntervalRetry struct {
+	interval   time.Duration
+	maxRetries int
+}
+
+func NewFixedIntervalRetry(interval time.Duration, maxRetries int) FixedIntervalRetry {
+	return FixedIntervalRetry{interval: interval, maxRetries: maxRetries}
+}
+
+func (r FixedIntervalRetry) Next(retries int) (time.Duration, bool) {
+	return r.interval, retries < r.maxRetries
+}
+
+// ExponentialBackoffRetry 第一次重试之前等待 initial，之后每次翻倍，最多等待 max，最多重试 maxRetries 次
+type ExponentialBackoffRetry struct {
+	initial    time.Duration
+	max        time.Duration
+	maxRetries int
+}
+
+func NewExponentialBackoffRetry(initial, max time.Duration, maxRetries int) ExponentialBackoffRetry {
+	return ExponentialBackoffRetry{initial: initial, max: max, maxRetries: maxRetries}
+}
+
+func (r ExponentialBackoffRetry) Next(retries int) (time.Duration, bool) {
+	if retries >= r.maxRetries {
+		return 0, false
+	}
+	d := r.initial
+	for i := 0; i < retries && d < r.max; i++ {
+		d *= 2
+	}
+	return min(d, r.max), true
+}
@@ -1 +1,2 @@
-create database webook;
\ No newline at end of file
+-- 只负责建库，表结构由 internal/repository/dao/migrations 下的迁移文件管理
+create database webook;